@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"medods-tz/pkg/passwordpolicy"
+	"os"
+	"strings"
+)
+
+// buildBreachedFilter reads either SHA-1 lines as distributed by Pwned Passwords ("HASH:count")
+// or, with -plain, one password per line, and writes a bloom filter the service can load.
+func buildBreachedFilter(args []string) error {
+	flags := flag.NewFlagSet("build-breached-filter", flag.ExitOnError)
+	in := flags.String("in", "", "input file with one SHA-1 hash (optionally followed by :count) or password per line")
+	out := flags.String("out", "breached.bloom", "output bloom filter file")
+	falsePositiveRate := flags.Float64("fp", 0.001, "false positive rate")
+	plain := flags.Bool("plain", false, "input lines are plaintext passwords instead of SHA-1 hashes")
+	_ = flags.Parse(args)
+
+	if *in == "" {
+		return errors.New("-in is required")
+	}
+
+	entries, err := countLines(*in)
+	if err != nil {
+		return err
+	}
+
+	filter := passwordpolicy.NewBloomFilter(entries, *falsePositiveRate)
+
+	file, err := os.Open(*in)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var added, lineNumber uint64
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if *plain {
+			filter.Add(line)
+			added++
+			continue
+		}
+
+		hash, _, _ := strings.Cut(line, ":")
+		decoded, err := hex.DecodeString(hash)
+		if err != nil || len(decoded) != sha1.Size {
+			return fmt.Errorf("line %d: not a SHA-1 hash", lineNumber)
+		}
+		filter.AddSHA1([sha1.Size]byte(decoded))
+		added++
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	output, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+
+	writer := bufio.NewWriter(output)
+	if _, err := filter.WriteTo(writer); err != nil {
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("added %d entries to %s\n", added, *out)
+	return nil
+}
+
+func countLines(path string) (uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var lines uint64
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines++
+	}
+
+	return lines, scanner.Err()
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"medods-tz/internal/sender"
+	"strings"
+)
+
+// previewEmail renders an email template with sample data, so template changes can be checked
+// without sending anything. Without -name it lists the templates and locales.
+func previewEmail(args []string) error {
+	flags := flag.NewFlagSet("preview-email", flag.ExitOnError)
+	name := flags.String("name", "", "template name, e.g. "+sender.TemplateSuspiciousLogin)
+	locale := flags.String("locale", "", "locale to render, falls back like for users")
+	format := flags.String("format", "text", "part to print: text, html or all")
+	dir := flags.String("dir", "", "template directory overriding the built-in templates, as email_templates.dir")
+	defaultLocale := flags.String("default-locale", sender.FallbackLocale, "as email_templates.default_locale")
+	_ = flags.Parse(args)
+
+	templates, err := sender.NewTemplates(*dir, *defaultLocale)
+	if err != nil {
+		return err
+	}
+
+	if *name == "" {
+		fmt.Printf("templates: %s\n", strings.Join(sender.TemplateNames, ", "))
+		fmt.Printf("locales:   %s\n", strings.Join(templates.Locales(), ", "))
+		return nil
+	}
+
+	data, err := sender.SampleData(*name)
+	if err != nil {
+		return err
+	}
+
+	message, err := templates.Render(*name, *locale, data)
+	if err != nil {
+		return err
+	}
+
+	switch *format {
+	case "text":
+		fmt.Printf("Subject: %s\n\n%s", message.Subject, message.TextBody)
+	case "html":
+		fmt.Print(message.HTMLBody)
+	case "all":
+		fmt.Printf("Subject: %s\n\n%s\n%s", message.Subject, message.TextBody, message.HTMLBody)
+	default:
+		return errors.New("-format must be text, html or all")
+	}
+
+	return nil
+}
@@ -16,6 +16,25 @@ type (
 		Database Database `yaml:"database"`
 		JWT      JWT      `yaml:"jwt"`
 		SMTP     SMTP     `yaml:"smtp"`
+
+		EmailOutbox       EmailOutbox       `yaml:"email_outbox"`
+		EmailTemplates    EmailTemplates    `yaml:"email_templates"`
+		Notifications     Notifications     `yaml:"notifications"`
+		EmailVerification EmailVerification `yaml:"email_verification"`
+		PasswordReset     PasswordReset     `yaml:"password_reset"`
+		LoginReport       LoginReport       `yaml:"login_report"`
+		GeoIP             GeoIP             `yaml:"geoip"`
+		Risk              Risk              `yaml:"risk"`
+		Devices           Devices           `yaml:"devices"`
+		PasswordHashing   PasswordHashing   `yaml:"password_hashing"`
+		PasswordPolicy    PasswordPolicy    `yaml:"password_policy"`
+		BruteForce        BruteForce        `yaml:"brute_force"`
+		RateLimit         RateLimit         `yaml:"rate_limit"`
+		Audit             Audit             `yaml:"audit"`
+		SecurityEvents    SecurityEvents    `yaml:"security_events"`
+		Webhooks          Webhooks          `yaml:"webhooks"`
+		Relations         Relations         `yaml:"relations"`
+		Admin             Admin             `yaml:"admin"`
 	}
 
 	HTTP struct {
@@ -45,6 +64,12 @@ type (
 		Password      string `yaml:"password" env-required:"true"`
 		Name          string `yaml:"name" env-required:"true"`
 		MigrationPath string `yaml:"migration_path" env-default:"./migrations"`
+		// MigrationUser and MigrationPassword are the owner of the tables that runs the migrations,
+		// User and Password by default.
+		MigrationUser     string `yaml:"migration_user"`
+		MigrationPassword string `yaml:"migration_password"`
+		// AllowRLSBypass lets the service start as a role that row-level security does not apply to.
+		AllowRLSBypass bool `yaml:"allow_rls_bypass" env-default:"false"`
 	}
 
 	SMTP struct {
@@ -52,9 +77,301 @@ type (
 		Port     int    `yaml:"port" env-default:"587"`
 		User     string `yaml:"user" env-default:"sender@example.com"`
 		Password string `yaml:"password" env-default:"password"`
+		// From is the sender address, the user is used when it is empty.
+		From string `yaml:"from"`
+		// TLSMode is starttls, tls (implicit TLS, usually port 465) or none.
+		TLSMode     string        `yaml:"tls_mode" env-default:"starttls"`
+		Timeout     time.Duration `yaml:"timeout" env-default:"10s"`
+		PoolSize    int           `yaml:"pool_size" env-default:"2"`
+		IdleTimeout time.Duration `yaml:"idle_timeout" env-default:"1m"`
+		// RequireConnection stops the startup when the server cannot be reached.
+		RequireConnection bool `yaml:"require_connection" env-default:"false"`
+
+		CircuitBreaker struct {
+			// FailureThreshold consecutive connection failures make sends fail fast for Cooldown.
+			FailureThreshold int           `yaml:"failure_threshold" env-default:"5"`
+			Cooldown         time.Duration `yaml:"cooldown" env-default:"30s"`
+		} `yaml:"circuit_breaker"`
+
+		// DKIM signs outgoing mail when PrivateKeyPath is set.
+		DKIM struct {
+			Domain         string   `yaml:"domain"`
+			Selector       string   `yaml:"selector"`
+			PrivateKeyPath string   `yaml:"private_key_path"`
+			Headers        []string `yaml:"headers"`
+		} `yaml:"dkim"`
+	}
+
+	EmailOutbox struct {
+		// PollInterval is how often the outbox is checked for due emails.
+		PollInterval time.Duration `yaml:"poll_interval" env-default:"5s"`
+		BatchSize    int           `yaml:"batch_size" env-default:"20"`
+		// Lease is how long a claimed batch is reserved for one worker, it must cover sending the whole batch.
+		Lease time.Duration `yaml:"lease" env-default:"5m"`
+		// MaxAttempts failed sends mark an email as failed.
+		MaxAttempts int           `yaml:"max_attempts" env-default:"8"`
+		BaseBackoff time.Duration `yaml:"base_backoff" env-default:"30s"`
+		MaxBackoff  time.Duration `yaml:"max_backoff" env-default:"1h"`
+	}
+
+	EmailTemplates struct {
+		// Dir overrides the built-in templates file by file and may add locales, empty uses only the built-in ones.
+		Dir           string `yaml:"dir" env-default:""`
+		DefaultLocale string `yaml:"default_locale" env-default:"en"`
+	}
+
+	// Notifications configures the channels of security notifications. A channel other than email is
+	// enabled when its endpoint or token is set. MinSeverity is the lowest severity a channel carries:
+	// info, warning or critical.
+	Notifications struct {
+		// EmailTransport is smtp, or mailbox to write emails to the mailbox directory in development.
+		EmailTransport string        `yaml:"email_transport" env-default:"smtp"`
+		Timeout        time.Duration `yaml:"timeout" env-default:"10s"`
+
+		Email struct {
+			MinSeverity string `yaml:"min_severity" env-default:"info"`
+		} `yaml:"email"`
+		Webhook struct {
+			URL         string `yaml:"url"`
+			BearerToken string `yaml:"bearer_token"`
+			MinSeverity string `yaml:"min_severity" env-default:"info"`
+		} `yaml:"webhook"`
+		Telegram struct {
+			APIURL      string `yaml:"api_url" env-default:"https://api.telegram.org"`
+			BotToken    string `yaml:"bot_token"`
+			MinSeverity string `yaml:"min_severity" env-default:"info"`
+		} `yaml:"telegram"`
+		Slack struct {
+			APIURL      string `yaml:"api_url" env-default:"https://slack.com/api"`
+			BotToken    string `yaml:"bot_token"`
+			MinSeverity string `yaml:"min_severity" env-default:"info"`
+		} `yaml:"slack"`
+		SMS struct {
+			URL         string `yaml:"url"`
+			APIKey      string `yaml:"api_key"`
+			From        string `yaml:"from"`
+			MinSeverity string `yaml:"min_severity" env-default:"critical"`
+		} `yaml:"sms"`
+		// Mailbox writes messages as .eml files to Dir, or to stdout when Dir is "-".
+		Mailbox struct {
+			Dir         string `yaml:"dir"`
+			MinSeverity string `yaml:"min_severity" env-default:"info"`
+		} `yaml:"mailbox"`
+
+		Throttle struct {
+			// Window is how long a notification suppresses the same one, e.g. a suspicious login from the same IP.
+			Window time.Duration `yaml:"window" env-default:"1h"`
+			// BurstLimit notifications of one kind per window are sent right away, later ones are
+			// summarized in a digest sent DigestDelay after the first of them. 0 disables digests.
+			BurstLimit   int           `yaml:"burst_limit" env-default:"3"`
+			DigestDelay  time.Duration `yaml:"digest_delay" env-default:"15m"`
+			PollInterval time.Duration `yaml:"poll_interval" env-default:"1m"`
+		} `yaml:"throttle"`
+	}
+
+	EmailVerification struct {
+		Required bool          `yaml:"required" env-default:"false"`
+		TokenTTL time.Duration `yaml:"token_ttl" env-default:"24h"`
+		LinkURL  string        `yaml:"link_url" env-default:"http://localhost:8080/api/v1/auth/verify-email"`
+	}
+
+	PasswordReset struct {
+		TokenTTL time.Duration `yaml:"token_ttl" env-default:"30m"`
+		LinkURL  string        `yaml:"link_url" env-default:"http://localhost:8080/reset-password"`
+	}
+
+	// LoginReport is the "this wasn't me" link of suspicious login emails.
+	LoginReport struct {
+		TokenTTL time.Duration `yaml:"token_ttl" env-default:"168h"`
+		LinkURL  string        `yaml:"link_url" env-default:"http://localhost:8080/api/v1/auth/report-login"`
+	}
+
+	// GeoIP databases are MaxMind-format .mmdb files, such as GeoLite2-City and GeoLite2-ASN.
+	// Without them every change of network counts as a new location.
+	GeoIP struct {
+		CityDB         string  `yaml:"city_db"`
+		ASNDB          string  `yaml:"asn_db"`
+		MaxTravelSpeed float64 `yaml:"max_travel_speed" env-default:"1000"`
+	}
+
+	// Risk scores every token issuance and refresh. Unset values take the default,
+	// so rules and bands are disabled with -1.
+	Risk struct {
+		Rules struct {
+			IPChanged         int           `yaml:"ip_changed" env-default:"10"`
+			NewLocation       int           `yaml:"new_location" env-default:"30"`
+			ImpossibleTravel  int           `yaml:"impossible_travel" env-default:"100"`
+			DistancePer1000Km int           `yaml:"distance_per_1000km" env-default:"5"`
+			FailedAttempt     int           `yaml:"failed_attempt" env-default:"10"`
+			Idle              int           `yaml:"idle" env-default:"10"`
+			IdleAfter         time.Duration `yaml:"idle_after" env-default:"720h"`
+			BadIP             int           `yaml:"bad_ip" env-default:"60"`
+			NewDevice         int           `yaml:"new_device" env-default:"20"`
+		} `yaml:"rules"`
+		BadIPList string `yaml:"bad_ip_list"`
+		Bands     struct {
+			Notify int `yaml:"notify" env-default:"30"`
+			StepUp int `yaml:"step_up" env-default:"60"`
+			Deny   int `yaml:"deny" env-default:"100"`
+		} `yaml:"bands"`
+		StepUp struct {
+			CodeTTL     time.Duration `yaml:"code_ttl" env-default:"10m"`
+			MaxAttempts int           `yaml:"max_attempts" env-default:"5"`
+		} `yaml:"step_up"`
+	}
+
+	// Devices are recognized by a key that browsers keep in a signed cookie and apps send in the
+	// X-Device-ID header.
+	Devices struct {
+		CookieName   string        `yaml:"cookie_name" env-default:"device_id"`
+		CookieTTL    time.Duration `yaml:"cookie_ttl" env-default:"8760h"`
+		CookieSecure bool          `yaml:"cookie_secure" env-default:"true"`
+	}
+
+	PasswordPolicy struct {
+		MinLength           int      `yaml:"min_length" env-default:"10"`
+		MaxLength           int      `yaml:"max_length" env-default:"72"`
+		MinCharacterClasses int      `yaml:"min_character_classes" env-default:"3"`
+		MaxRepeatedChars    int      `yaml:"max_repeated_chars" env-default:"3"`
+		ServiceName         string   `yaml:"service_name" env-default:"medods"`
+		BannedWords         []string `yaml:"banned_words"`
+		BreachedFilterPath  string   `yaml:"breached_filter_path"`
+	}
+
+	BruteForce struct {
+		Store            string        `yaml:"store" env-default:"postgres"`
+		Window           time.Duration `yaml:"window" env-default:"15m"`
+		FreeAttempts     int           `yaml:"free_attempts" env-default:"3"`
+		BaseDelay        time.Duration `yaml:"base_delay" env-default:"1s"`
+		MaxDelay         time.Duration `yaml:"max_delay" env-default:"15m"`
+		LockoutThreshold int           `yaml:"lockout_threshold" env-default:"10"`
+		LockoutDuration  time.Duration `yaml:"lockout_duration" env-default:"30m"`
+		UnlockLinkURL    string        `yaml:"unlock_link_url" env-default:"http://localhost:8080/api/v1/auth/unlock"`
+	}
+
+	RateLimit struct {
+		Enabled        bool              `yaml:"enabled" env-default:"true"`
+		Store          string            `yaml:"store" env-default:"memory"`
+		ClientIDHeader string            `yaml:"client_id_header" env-default:"X-Client-ID"`
+		Default        RateLimitPolicy   `yaml:"default"`
+		Routes         []RateLimitPolicy `yaml:"routes"`
+		PruneInterval  time.Duration     `yaml:"prune_interval" env-default:"10m"`
+	}
+
+	RateLimitPolicy struct {
+		Method string        `yaml:"method"`
+		Path   string        `yaml:"path"`
+		Key    string        `yaml:"key"`
+		Limit  int           `yaml:"limit"`
+		Period time.Duration `yaml:"period"`
+		Burst  int           `yaml:"burst"`
+	}
+
+	Audit struct {
+		// CheckpointInterval is how often finished days of the audit chain are sealed with a signed checkpoint.
+		CheckpointInterval time.Duration `yaml:"checkpoint_interval" env-default:"1h"`
+	}
+
+	SecurityEvents struct {
+		// QueueSize is the number of events buffered per sink before new ones are dropped.
+		QueueSize int `yaml:"queue_size" env-default:"1024"`
+		// ForwardLogLevel also sends security log entries of this level and above to the sinks, empty disables it.
+		ForwardLogLevel string              `yaml:"forward_log_level"`
+		CEF             CEF                 `yaml:"cef"`
+		Syslog          []SyslogSink        `yaml:"syslog"`
+		Files           []SecurityEventFile `yaml:"files"`
+	}
+
+	CEF struct {
+		Vendor  string `yaml:"vendor" env-default:"Medods"`
+		Product string `yaml:"product" env-default:"auth-service"`
+		Version string `yaml:"version" env-default:"1.0"`
+	}
+
+	SyslogSink struct {
+		Network            string `yaml:"network"`
+		Address            string `yaml:"address"`
+		Format             string `yaml:"format"`
+		AppName            string `yaml:"app_name"`
+		CAFile             string `yaml:"ca_file"`
+		InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+	}
+
+	SecurityEventFile struct {
+		Path       string `yaml:"path"`
+		Format     string `yaml:"format"`
+		MaxSizeMB  int    `yaml:"max_size_mb"`
+		MaxBackups int    `yaml:"max_backups"`
+	}
+
+	Webhooks struct {
+		// PollInterval is how often the outbox is checked for due deliveries.
+		PollInterval time.Duration `yaml:"poll_interval" env-default:"5s"`
+		BatchSize    int           `yaml:"batch_size" env-default:"50"`
+		Timeout      time.Duration `yaml:"timeout" env-default:"10s"`
+		// MaxAttempts failed attempts move a delivery to the dead letters.
+		MaxAttempts int           `yaml:"max_attempts" env-default:"10"`
+		BaseBackoff time.Duration `yaml:"base_backoff" env-default:"10s"`
+		MaxBackoff  time.Duration `yaml:"max_backoff" env-default:"6h"`
+	}
+
+	Relations struct {
+		// SchemaPath is the namespace configuration, the relation API is disabled while it is empty.
+		SchemaPath string `yaml:"schema_path"`
+		MaxDepth   int    `yaml:"max_depth" env-default:"25"`
+		// SnapshotRetention is how long reads at the exact snapshot of a consistency token are possible.
+		SnapshotRetention time.Duration `yaml:"snapshot_retention" env-default:"1h"`
+		PruneInterval     time.Duration `yaml:"prune_interval" env-default:"10m"`
+	}
+
+	Admin struct {
+		// APIKey guards the admin API, which is disabled while it is empty.
+		APIKey string `yaml:"api_key"`
+		// CheckAPIKey lets downstream services ask for permission and relation checks without the admin key.
+		CheckAPIKey string `yaml:"check_api_key"`
+	}
+
+	PasswordHashing struct {
+		Algorithm string   `yaml:"algorithm" env-default:"argon2id"`
+		Argon2id  Argon2id `yaml:"argon2id"`
+		Bcrypt    Bcrypt   `yaml:"bcrypt"`
+		Scrypt    Scrypt   `yaml:"scrypt"`
+	}
+
+	Argon2id struct {
+		Memory      uint32 `yaml:"memory" env-default:"65536"`
+		Iterations  uint32 `yaml:"iterations" env-default:"3"`
+		Parallelism uint8  `yaml:"parallelism" env-default:"2"`
+		SaltLength  uint32 `yaml:"salt_length" env-default:"16"`
+		KeyLength   uint32 `yaml:"key_length" env-default:"32"`
+	}
+
+	Bcrypt struct {
+		Cost int `yaml:"cost" env-default:"12"`
+	}
+
+	Scrypt struct {
+		N          int `yaml:"n" env-default:"32768"`
+		R          int `yaml:"r" env-default:"8"`
+		P          int `yaml:"p" env-default:"1"`
+		SaltLength int `yaml:"salt_length" env-default:"16"`
+		KeyLength  int `yaml:"key_length" env-default:"32"`
 	}
 )
 
+// URL is the connection string for pgx.
+func (p Postgres) URL() string {
+	return fmt.Sprintf("postgres://%s:%s@%s:%v/%s?sslmode=disable", p.User, p.Password, p.Host, p.Port, p.Name)
+}
+
+// MigrationURL is the connection string for migrations.
+func (p Postgres) MigrationURL() string {
+	if p.MigrationUser == "" {
+		return p.URL()
+	}
+	return fmt.Sprintf("postgres://%s:%s@%s:%v/%s?sslmode=disable", p.MigrationUser, p.MigrationPassword, p.Host, p.Port, p.Name)
+}
+
 func NewConfig(configPath string) (*Config, error) {
 	cfg := &Config{}
 
@@ -1,18 +1,27 @@
 package app
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
-	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/labstack/echo/v4"
 	log "github.com/sirupsen/logrus"
 	"medods-tz/config"
 	v1 "medods-tz/internal/controller/http/v1"
 	"medods-tz/internal/repository"
+	"medods-tz/internal/repository/memory"
+	"medods-tz/internal/repository/postgres"
 	"medods-tz/internal/sender"
 	"medods-tz/internal/service"
+	"medods-tz/pkg/geoip"
+	"medods-tz/pkg/hasher"
+	"medods-tz/pkg/iplist"
 	"medods-tz/pkg/logger"
+	"medods-tz/pkg/passwordpolicy"
+	"medods-tz/pkg/relation"
+	"medods-tz/pkg/secevent"
 	"medods-tz/pkg/validator"
 	"net/http"
 	"os"
@@ -36,39 +45,151 @@ func Run(configPath string) {
 		log.Fatal(fmt.Errorf("error while initializing logs for security: %w", err))
 	}
 
+	log.Debug("Initializing security event sinks")
+	securityEvents, err := newSecurityEventDispatcher(cfg.SecurityEvents)
+	if err != nil {
+		log.Fatal(fmt.Errorf("error in security event sinks config: %w", err))
+	}
+	if securityEvents != nil && cfg.SecurityEvents.ForwardLogLevel != "" {
+		level, err := log.ParseLevel(cfg.SecurityEvents.ForwardLogLevel)
+		if err != nil {
+			log.Fatal(fmt.Errorf("error in security_events.forward_log_level: %w", err))
+		}
+		scrLogs.AddHook(secevent.NewHook(securityEvents, level))
+	}
+
 	log.Debug("Initializing smtp-client")
-	sender := sender.NewSender(sender.NewEmailSender(
-		cfg.SMTP.Host,
-		cfg.SMTP.Port,
-		cfg.SMTP.User,
-		cfg.SMTP.Password))
+	emailSender, err := newEmailSender(cfg.SMTP)
+	if err != nil {
+		log.Fatal(fmt.Errorf("error in smtp config: %w", err))
+	}
+	defer emailSender.Close()
+
+	if cfg.Notifications.EmailTransport != "mailbox" {
+		err = emailSender.EnsureSMTPConnection(ctx)
+		if err != nil && cfg.SMTP.RequireConnection {
+			log.Fatal(fmt.Errorf("error while connecting to smtp-client: %w", err))
+		}
+		if err != nil {
+			log.Errorf("error while connecting to smtp-client: %v", err)
+		}
+	}
+
+	log.Debug("Initializing notification channels")
+	transports, notificationConfig, err := newNotificationChannels(cfg.Notifications, emailSender, cmp.Or(cfg.SMTP.From, cfg.SMTP.User))
+	if err != nil {
+		log.Fatal(fmt.Errorf("error in notifications config: %w", err))
+	}
 
-	err = sender.EnsureSMTPConnection()
+	log.Debug("Loading email templates")
+	emailTemplates, err := sender.NewTemplates(cfg.EmailTemplates.Dir, cfg.EmailTemplates.DefaultLocale)
 	if err != nil {
-		log.Errorf("error while connecting to smtp-client: %w", err)
+		log.Fatal(fmt.Errorf("error loading email templates: %w", err))
+	}
+
+	log.Debug("Initializing password hasher")
+	passwordHasher, err := hasher.New(cfg.PasswordHashing.Algorithm,
+		hasher.NewArgon2id(hasher.Argon2idParams{
+			Memory:      cfg.PasswordHashing.Argon2id.Memory,
+			Iterations:  cfg.PasswordHashing.Argon2id.Iterations,
+			Parallelism: cfg.PasswordHashing.Argon2id.Parallelism,
+			SaltLength:  cfg.PasswordHashing.Argon2id.SaltLength,
+			KeyLength:   cfg.PasswordHashing.Argon2id.KeyLength,
+		}),
+		hasher.NewBcrypt(cfg.PasswordHashing.Bcrypt.Cost),
+		hasher.NewScrypt(hasher.ScryptParams{
+			N:          cfg.PasswordHashing.Scrypt.N,
+			R:          cfg.PasswordHashing.Scrypt.R,
+			P:          cfg.PasswordHashing.Scrypt.P,
+			SaltLength: cfg.PasswordHashing.Scrypt.SaltLength,
+			KeyLength:  cfg.PasswordHashing.Scrypt.KeyLength,
+		}))
+	if err != nil {
+		log.Fatal(fmt.Errorf("error initializing password hasher: %w", err))
+	}
+
+	log.Debug("Initializing password policy")
+	var breachedList passwordpolicy.BreachedList
+	if cfg.PasswordPolicy.BreachedFilterPath != "" {
+		breachedList, err = passwordpolicy.LoadBloomFilter(cfg.PasswordPolicy.BreachedFilterPath)
+		if err != nil {
+			log.Fatal(fmt.Errorf("error loading breached password filter: %w", err))
+		}
+	}
+	passwordPolicy := passwordpolicy.New(passwordpolicy.Config{
+		MinLength:           cfg.PasswordPolicy.MinLength,
+		MaxLength:           cfg.PasswordPolicy.MaxLength,
+		MaxBytes:            passwordHasher.MaxPasswordBytes(),
+		MinCharacterClasses: cfg.PasswordPolicy.MinCharacterClasses,
+		MaxRepeatedChars:    cfg.PasswordPolicy.MaxRepeatedChars,
+		BannedWords:         append(cfg.PasswordPolicy.BannedWords, cfg.PasswordPolicy.ServiceName),
+	}, breachedList)
+
+	log.Debug("Opening GeoIP databases")
+	var geoReader *geoip.Reader
+	if cfg.GeoIP.CityDB != "" || cfg.GeoIP.ASNDB != "" {
+		geoReader, err = geoip.Open(cfg.GeoIP.CityDB, cfg.GeoIP.ASNDB)
+		if err != nil {
+			log.Fatal(fmt.Errorf("error opening geoip databases: %w", err))
+		}
+		defer geoReader.Close()
+	}
+
+	var badIPs *iplist.List
+	if cfg.Risk.BadIPList != "" {
+		badIPs, err = iplist.Load(cfg.Risk.BadIPList)
+		if err != nil {
+			log.Fatal(fmt.Errorf("error loading bad ip list: %w", err))
+		}
+		log.Infof("Loaded %d bad ip entries", badIPs.Len())
+	}
+
+	var relationSchema *relation.Schema
+	if cfg.Relations.SchemaPath != "" {
+		relationSchema, err = relation.Load(cfg.Relations.SchemaPath)
+		if err != nil {
+			log.Fatal(fmt.Errorf("error loading relation namespace configuration: %w", err))
+		}
 	}
 
 	log.Debug("Connecting postgres...")
-	pgURL := fmt.Sprintf("postgres://%s:%s@%s:%v/%s?sslmode=disable",
-		cfg.Database.Postgres.User,
-		cfg.Database.Postgres.Password,
-		cfg.Database.Postgres.Host,
-		cfg.Database.Postgres.Port,
-		cfg.Database.Postgres.Name)
-	pg, err := pgx.Connect(ctx, pgURL)
+	pgURL := cfg.Database.Postgres.URL()
+	pg, err := pgxpool.Connect(ctx, pgURL)
 	if err != nil {
 		log.Fatal(fmt.Errorf("error connecting postgres: %w", err))
 	}
-	defer pg.Close(ctx)
+	defer pg.Close()
+
+	bypassesRLS, err := postgres.BypassesRowLevelSecurity(ctx, pg)
+	if err != nil {
+		log.Fatal(fmt.Errorf("error checking postgres role: %w", err))
+	}
+	if bypassesRLS {
+		if !cfg.Database.Postgres.AllowRLSBypass {
+			log.Fatalf("postgres user %q is a superuser or bypasses row-level security, so organizations are not isolated from each other: connect as a member of medods_app or set database.postgres.allow_rls_bypass", cfg.Database.Postgres.User)
+		}
+		log.Warnf("postgres user %q is a superuser or bypasses row-level security, organizations are NOT isolated from each other", cfg.Database.Postgres.User)
+	}
 
 	log.Debug("Running migrations...")
-	err = RunMigrations(pgURL, cfg.Database.Postgres.MigrationPath)
+	err = RunMigrations(cfg.Database.Postgres.MigrationURL(), cfg.Database.Postgres.MigrationPath)
 	if err != nil {
 		log.Debug(fmt.Errorf("error running migrations: %w", err))
 	}
 
 	log.Debug("Initializing repositories...")
 	repositories := repository.NewRepository(pg)
+	if cfg.BruteForce.Store == "memory" {
+		repositories.LoginAttemptRepository = memory.NewLoginAttemptMemory()
+	}
+	if cfg.RateLimit.Store == "memory" {
+		repositories.RateLimitRepository = memory.NewRateLimitMemory()
+	}
+
+	rateLimits, err := rateLimitConfig(cfg.RateLimit)
+	if err != nil {
+		log.Fatal(fmt.Errorf("error in rate limit config: %w", err))
+	}
 
 	log.Debug("Initializing services")
 	dependencies := service.ServicesDependencies{
@@ -77,14 +198,124 @@ func Run(configPath string) {
 		SignKey:         cfg.JWT.SignKey,
 		RefreshTokenTTL: cfg.JWT.RefreshTokenTTL,
 		SecurityLog:     scrLogs,
-		Sender:          sender,
+		Transports:      transports,
+		Notifications:   notificationConfig,
+		EmailTemplates:  emailTemplates,
+		PasswordHasher:  passwordHasher,
+		PasswordPolicy:  passwordPolicy,
+		BruteForce: service.BruteForceConfig{
+			Window:           cfg.BruteForce.Window,
+			FreeAttempts:     cfg.BruteForce.FreeAttempts,
+			BaseDelay:        cfg.BruteForce.BaseDelay,
+			MaxDelay:         cfg.BruteForce.MaxDelay,
+			LockoutThreshold: cfg.BruteForce.LockoutThreshold,
+			LockoutDuration:  cfg.BruteForce.LockoutDuration,
+			UnlockLinkURL:    cfg.BruteForce.UnlockLinkURL,
+		},
+		EmailOutbox: service.EmailOutboxConfig{
+			MaxAttempts: cfg.EmailOutbox.MaxAttempts,
+			BaseBackoff: cfg.EmailOutbox.BaseBackoff,
+			MaxBackoff:  cfg.EmailOutbox.MaxBackoff,
+			BatchSize:   cfg.EmailOutbox.BatchSize,
+			Lease:       cfg.EmailOutbox.Lease,
+		},
+		Webhooks: service.WebhookConfig{
+			MaxAttempts: cfg.Webhooks.MaxAttempts,
+			BaseBackoff: cfg.Webhooks.BaseBackoff,
+			MaxBackoff:  cfg.Webhooks.MaxBackoff,
+			Timeout:     cfg.Webhooks.Timeout,
+			BatchSize:   cfg.Webhooks.BatchSize,
+		},
+
+		RequireVerifiedEmail: cfg.EmailVerification.Required,
+		VerificationTokenTTL: cfg.EmailVerification.TokenTTL,
+		VerificationLinkURL:  cfg.EmailVerification.LinkURL,
+		PasswordResetTTL:     cfg.PasswordReset.TokenTTL,
+		PasswordResetLinkURL: cfg.PasswordReset.LinkURL,
+		LoginReport: service.LoginReportConfig{
+			TokenTTL: cfg.LoginReport.TokenTTL,
+			LinkURL:  cfg.LoginReport.LinkURL,
+		},
+		Geo: service.GeoPolicyConfig{
+			MaxTravelSpeed: cfg.GeoIP.MaxTravelSpeed,
+		},
+		BadIPs: badIPs,
+		Risk: service.RiskConfig{
+			Rules: service.RiskRules{
+				IPChanged:         cfg.Risk.Rules.IPChanged,
+				NewLocation:       cfg.Risk.Rules.NewLocation,
+				ImpossibleTravel:  cfg.Risk.Rules.ImpossibleTravel,
+				DistancePer1000Km: cfg.Risk.Rules.DistancePer1000Km,
+				FailedAttempt:     cfg.Risk.Rules.FailedAttempt,
+				Idle:              cfg.Risk.Rules.Idle,
+				IdleAfter:         cfg.Risk.Rules.IdleAfter,
+				BadIP:             cfg.Risk.Rules.BadIP,
+				NewDevice:         cfg.Risk.Rules.NewDevice,
+			},
+			NotifyScore: cfg.Risk.Bands.Notify,
+			StepUpScore: cfg.Risk.Bands.StepUp,
+			DenyScore:   cfg.Risk.Bands.Deny,
+		},
+		StepUp: service.StepUpConfig{
+			CodeTTL:     cfg.Risk.StepUp.CodeTTL,
+			MaxAttempts: cfg.Risk.StepUp.MaxAttempts,
+		},
+		Relations: service.RelationConfig{
+			Schema:            relationSchema,
+			MaxDepth:          cfg.Relations.MaxDepth,
+			SnapshotRetention: cfg.Relations.SnapshotRetention,
+		},
+		RateLimit: service.RateLimitConfig{
+			IdleTime: rateLimitIdleTime(rateLimits),
+		},
+	}
+	// a nil *Dispatcher must not end up in the interface
+	if securityEvents != nil {
+		dependencies.SecurityEvents = securityEvents
+	}
+	if geoReader != nil {
+		dependencies.GeoLocator = geoReader
 	}
 	services := service.NewService(dependencies)
 
+	if cfg.Audit.CheckpointInterval <= 0 {
+		log.Fatal("audit.checkpoint_interval must be positive")
+	}
+	if cfg.Webhooks.PollInterval <= 0 || cfg.Webhooks.BatchSize <= 0 || cfg.Webhooks.MaxAttempts <= 0 {
+		log.Fatal("webhooks.poll_interval, batch_size and max_attempts must be positive")
+	}
+	if cfg.EmailOutbox.PollInterval <= 0 || cfg.EmailOutbox.BatchSize <= 0 || cfg.EmailOutbox.MaxAttempts <= 0 {
+		log.Fatal("email_outbox.poll_interval, batch_size and max_attempts must be positive")
+	}
+	workersCtx, stopWorkers := context.WithCancel(ctx)
+	defer stopWorkers()
+	go runPeriodically(workersCtx, "audit checkpoints", cfg.Audit.CheckpointInterval, services.AuditService.CreateCheckpoints)
+	go runPeriodically(workersCtx, "webhook delivery", cfg.Webhooks.PollInterval, services.WebhookService.DeliverPending)
+	go runPeriodically(workersCtx, "email delivery", cfg.EmailOutbox.PollInterval, services.EmailService.DeliverPending)
+	go runPeriodically(workersCtx, "password reset requests", cfg.EmailOutbox.PollInterval, services.AccountService.ProcessPasswordResetRequests)
+	go runPeriodically(workersCtx, "notification digests", cfg.Notifications.Throttle.PollInterval, services.NotificationService.SendDigests)
+	if relationSchema != nil {
+		if cfg.Relations.PruneInterval <= 0 {
+			log.Fatal("relations.prune_interval must be positive")
+		}
+		go runPeriodically(workersCtx, "relation snapshot pruning", cfg.Relations.PruneInterval, services.RelationService.PruneSnapshots)
+	}
+	if cfg.RateLimit.Store == "postgres" {
+		if cfg.RateLimit.PruneInterval <= 0 {
+			log.Fatal("rate_limit.prune_interval must be positive")
+		}
+		go runPeriodically(workersCtx, "rate limit bucket pruning", cfg.RateLimit.PruneInterval, services.RateLimitService.PruneBuckets)
+	}
+
 	log.Debug("Initializing handlers and routes...")
 	handler := echo.New()
 	handler.Validator = validator.NewCustomValidator()
-	v1.NewRouter(handler, services, cfg.Log.LogPath)
+	deviceCookie := v1.DeviceCookieConfig{
+		Name:   cfg.Devices.CookieName,
+		MaxAge: cfg.Devices.CookieTTL,
+		Secure: cfg.Devices.CookieSecure,
+	}
+	v1.NewRouter(handler, services, cfg.Log.LogPath, rateLimits, deviceCookie, cfg.Admin.APIKey, cfg.Admin.CheckAPIKey)
 
 	log.Info("Starting http server...")
 	log.Debugf("Server port: %s", cfg.HTTP.Port)
@@ -108,6 +339,7 @@ func Run(configPath string) {
 	// Graceful Shutdown
 	go func() {
 		<-stop
+		stopWorkers()
 		ctx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
 		defer cancel()
 
@@ -115,6 +347,12 @@ func Run(configPath string) {
 			log.Fatalf("error shutdown: %v", err)
 		}
 
+		if securityEvents != nil {
+			if err := securityEvents.Close(ctx); err != nil {
+				log.Errorf("error while flushing security events: %v", err)
+			}
+		}
+
 		close(done)
 	}()
 
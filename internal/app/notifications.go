@@ -0,0 +1,85 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"medods-tz/config"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/sender"
+	"medods-tz/internal/service"
+	"net/http"
+)
+
+// newNotificationChannels builds the transports of the enabled channels and the lowest severity each carries.
+func newNotificationChannels(cfg config.Notifications, smtp sender.Transport, from string) (map[string]sender.Transport, service.NotificationConfig, error) {
+	client := &http.Client{Timeout: cfg.Timeout}
+	transports := map[string]sender.Transport{}
+	notificationConfig := service.NotificationConfig{
+		Channels:       map[string]string{},
+		ThrottleWindow: cfg.Throttle.Window,
+		BurstLimit:     cfg.Throttle.BurstLimit,
+		DigestDelay:    cfg.Throttle.DigestDelay,
+	}
+
+	enable := func(channel, minSeverity string, transport sender.Transport) error {
+		if entity.SeverityRank(minSeverity) == 0 {
+			return fmt.Errorf("%s.min_severity: unknown severity %q", channel, minSeverity)
+		}
+
+		transports[channel] = transport
+		notificationConfig.Channels[channel] = minSeverity
+		return nil
+	}
+
+	var mailbox *sender.Mailbox
+	if cfg.Mailbox.Dir != "" {
+		mailbox = sender.NewMailbox(cfg.Mailbox.Dir, from)
+		if err := enable(sender.ChannelMailbox, cfg.Mailbox.MinSeverity, mailbox); err != nil {
+			return nil, notificationConfig, err
+		}
+	}
+
+	switch cfg.EmailTransport {
+	case "", "smtp":
+	case "mailbox":
+		if mailbox == nil {
+			return nil, notificationConfig, errors.New("email_transport mailbox requires mailbox.dir")
+		}
+		smtp = mailbox
+	default:
+		return nil, notificationConfig, fmt.Errorf("unknown email_transport %q", cfg.EmailTransport)
+	}
+	if err := enable(sender.ChannelEmail, cfg.Email.MinSeverity, smtp); err != nil {
+		return nil, notificationConfig, err
+	}
+
+	if cfg.Webhook.URL != "" {
+		err := enable(sender.ChannelWebhook, cfg.Webhook.MinSeverity, sender.NewWebhookChannel(cfg.Webhook.URL, cfg.Webhook.BearerToken, client))
+		if err != nil {
+			return nil, notificationConfig, err
+		}
+	}
+
+	if cfg.Telegram.BotToken != "" {
+		err := enable(sender.ChannelTelegram, cfg.Telegram.MinSeverity, sender.NewTelegramChannel(cfg.Telegram.APIURL, cfg.Telegram.BotToken, client))
+		if err != nil {
+			return nil, notificationConfig, err
+		}
+	}
+
+	if cfg.Slack.BotToken != "" {
+		err := enable(sender.ChannelSlack, cfg.Slack.MinSeverity, sender.NewSlackChannel(cfg.Slack.APIURL, cfg.Slack.BotToken, client))
+		if err != nil {
+			return nil, notificationConfig, err
+		}
+	}
+
+	if cfg.SMS.URL != "" {
+		err := enable(sender.ChannelSMS, cfg.SMS.MinSeverity, sender.NewSMSGateway(cfg.SMS.URL, cfg.SMS.APIKey, cfg.SMS.From, client))
+		if err != nil {
+			return nil, notificationConfig, err
+		}
+	}
+
+	return transports, notificationConfig, nil
+}
@@ -0,0 +1,70 @@
+package app
+
+import (
+	"fmt"
+	"medods-tz/config"
+	v1 "medods-tz/internal/controller/http/v1"
+	"medods-tz/internal/service"
+	"time"
+)
+
+func rateLimitConfig(cfg config.RateLimit) (v1.RateLimitConfig, error) {
+	rateLimits := v1.RateLimitConfig{
+		Enabled:        cfg.Enabled,
+		ClientIDHeader: cfg.ClientIDHeader,
+	}
+
+	if cfg.Default.Limit > 0 {
+		rule, err := rateLimitRule(cfg.Default)
+		if err != nil {
+			return v1.RateLimitConfig{}, fmt.Errorf("default rate limit: %w", err)
+		}
+		rateLimits.Default = rule
+	}
+
+	for _, route := range cfg.Routes {
+		rule, err := rateLimitRule(route)
+		if err != nil {
+			return v1.RateLimitConfig{}, fmt.Errorf("rate limit for %s %s: %w", route.Method, route.Path, err)
+		}
+		rateLimits.Routes = append(rateLimits.Routes, rule)
+	}
+
+	return rateLimits, nil
+}
+
+func rateLimitRule(policy config.RateLimitPolicy) (v1.RateLimitRule, error) {
+	if policy.Limit <= 0 || policy.Period <= 0 {
+		return v1.RateLimitRule{}, fmt.Errorf("limit and period must be positive")
+	}
+
+	switch policy.Key {
+	case v1.RateLimitKeyIP, v1.RateLimitKeyClientID, v1.RateLimitKeyUserID:
+	default:
+		return v1.RateLimitRule{}, fmt.Errorf("unknown key %q", policy.Key)
+	}
+
+	return v1.RateLimitRule{
+		Method: policy.Method,
+		Path:   policy.Path,
+		KeyBy:  policy.Key,
+		Limit:  policy.Limit,
+		Period: policy.Period,
+		Burst:  policy.Burst,
+	}, nil
+}
+
+// rateLimitIdleTime is the longest time a rule takes to refill an empty bucket.
+func rateLimitIdleTime(rateLimits v1.RateLimitConfig) time.Duration {
+	var idleTime time.Duration
+	for _, rule := range append([]v1.RateLimitRule{rateLimits.Default}, rateLimits.Routes...) {
+		// a zero default rule leaves routes unlimited
+		if rule.Limit <= 0 {
+			continue
+		}
+		policy := service.RateLimitPolicy{Limit: rule.Limit, Period: rule.Period, Burst: rule.Burst}
+		idleTime = max(idleTime, policy.RefillTime())
+	}
+
+	return idleTime
+}
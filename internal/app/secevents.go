@@ -0,0 +1,114 @@
+package app
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"medods-tz/config"
+	"medods-tz/pkg/secevent"
+	"os"
+)
+
+const defaultSyslogAppName = "auth-service"
+
+// newSecurityEventDispatcher builds the configured sinks, it returns nil when there are none.
+// Sink errors go to the server log: the security log may itself be forwarded to the sinks.
+func newSecurityEventDispatcher(cfg config.SecurityEvents) (*secevent.Dispatcher, error) {
+	if len(cfg.Syslog) == 0 && len(cfg.Files) == 0 {
+		return nil, nil
+	}
+	if cfg.QueueSize <= 0 {
+		return nil, errors.New("queue_size must be positive")
+	}
+
+	cef := secevent.CEFFormatter{Vendor: cfg.CEF.Vendor, Product: cfg.CEF.Product, Version: cfg.CEF.Version}
+	dispatcher := secevent.NewDispatcher(cfg.QueueSize, func(sink string, err error) {
+		log.Errorf("error in security event sink %s: %v", sink, err)
+	})
+
+	for i, sinkCfg := range cfg.Syslog {
+		var body secevent.Formatter
+		switch sinkCfg.Format {
+		case "", "rfc5424":
+		case "cef":
+			body = cef
+		case "json":
+			body = secevent.JSONFormatter{}
+		default:
+			return nil, fmt.Errorf("syslog[%d]: unknown format %q", i, sinkCfg.Format)
+		}
+
+		tlsConfig, err := syslogTLSConfig(sinkCfg)
+		if err != nil {
+			return nil, fmt.Errorf("syslog[%d]: %w", i, err)
+		}
+
+		appName := sinkCfg.AppName
+		if appName == "" {
+			appName = defaultSyslogAppName
+		}
+
+		sink, err := secevent.NewSyslogSink(secevent.SyslogConfig{
+			Network:  sinkCfg.Network,
+			Address:  sinkCfg.Address,
+			Facility: secevent.FacilityAuthPriv,
+			AppName:  appName,
+			TLS:      tlsConfig,
+			Body:     body,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("syslog[%d]: %w", i, err)
+		}
+		dispatcher.AddSink(fmt.Sprintf("syslog %s://%s", sinkCfg.Network, sinkCfg.Address), sink)
+	}
+
+	for i, fileCfg := range cfg.Files {
+		var format secevent.Formatter
+		switch fileCfg.Format {
+		case "", "jsonl":
+			format = secevent.JSONFormatter{}
+		case "cef":
+			format = cef
+		default:
+			return nil, fmt.Errorf("files[%d]: unknown format %q", i, fileCfg.Format)
+		}
+
+		sink, err := secevent.NewFileSink(secevent.FileConfig{
+			Path:       fileCfg.Path,
+			MaxSize:    int64(fileCfg.MaxSizeMB) << 20,
+			MaxBackups: fileCfg.MaxBackups,
+			Format:     format,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("files[%d]: %w", i, err)
+		}
+		dispatcher.AddSink("file "+fileCfg.Path, sink)
+	}
+
+	return dispatcher, nil
+}
+
+func syslogTLSConfig(cfg config.SyslogSink) (*tls.Config, error) {
+	if cfg.Network != "tls" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.CAFile)
+		}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,33 @@
+package app
+
+import (
+	"medods-tz/config"
+	"medods-tz/internal/sender"
+	"medods-tz/pkg/dkim"
+)
+
+func newEmailSender(cfg config.SMTP) (*sender.EmailSender, error) {
+	var signer *dkim.Signer
+	if cfg.DKIM.PrivateKeyPath != "" {
+		var err error
+		signer, err = dkim.LoadSigner(cfg.DKIM.Domain, cfg.DKIM.Selector, cfg.DKIM.PrivateKeyPath, cfg.DKIM.Headers)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return sender.NewEmailSender(sender.SMTPConfig{
+		Host:             cfg.Host,
+		Port:             cfg.Port,
+		User:             cfg.User,
+		Password:         cfg.Password,
+		From:             cfg.From,
+		TLSMode:          sender.TLSMode(cfg.TLSMode),
+		Timeout:          cfg.Timeout,
+		PoolSize:         cfg.PoolSize,
+		IdleTimeout:      cfg.IdleTimeout,
+		DKIM:             signer,
+		BreakerThreshold: cfg.CircuitBreaker.FailureThreshold,
+		BreakerCooldown:  cfg.CircuitBreaker.Cooldown,
+	})
+}
@@ -0,0 +1,26 @@
+package app
+
+import (
+	"context"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// runPeriodically runs job right away and then every interval until ctx is cancelled.
+// Errors are logged, the next run retries.
+func runPeriodically(ctx context.Context, name string, interval time.Duration, job func(ctx context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := job(ctx); err != nil {
+			log.Errorf("error in %s: %v", name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
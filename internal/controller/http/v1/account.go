@@ -0,0 +1,304 @@
+package v1
+
+import (
+	"bytes"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"html/template"
+	"medods-tz/internal/service"
+	"net/http"
+	"strings"
+)
+
+type accountRoutes struct {
+	accountService service.AccountService
+}
+
+func newAccountRoutes(g *echo.Group, accountService service.AccountService, authService service.AuthService) {
+	r := &accountRoutes{
+		accountService: accountService,
+	}
+
+	g.POST("/register", r.register)
+	g.GET("/verify-email", r.verifyEmail)
+	g.POST("/verify-email", r.verifyEmail)
+	g.POST("/password/forgot", r.forgotPassword)
+	g.POST("/password/reset", r.resetPassword)
+	g.GET("/report-login", r.confirmReportLogin)
+	g.POST("/report-login", r.reportLogin)
+
+	identity := newIdentityMiddleware(authService)
+	g.PUT("/email", r.changeEmail, identity)
+	g.PUT("/locale", r.setLocale, identity)
+	g.POST("/verify-email/resend", r.resendVerificationEmail, identity)
+}
+
+type registerInput struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,max=1024"`
+	// Locale of the user's emails, the Accept-Language header is used when it is empty.
+	Locale string `json:"locale" validate:"omitempty,bcp47_language_tag"`
+}
+
+type registerResponse struct {
+	UserID string `json:"user_id"`
+}
+
+func (r *accountRoutes) register(c echo.Context) error {
+	var input registerInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	locale := input.Locale
+	if locale == "" {
+		locale = acceptLanguage(c.Request().Header.Get("Accept-Language"))
+	}
+
+	userID, err := r.accountService.Register(c.Request().Context(), input.Email, input.Password, locale)
+	if err != nil {
+		if errors.Is(err, service.ErrUserAlreadyExists) {
+			return newErrorResponse(c, http.StatusConflict, err)
+		}
+		if errors.Is(err, service.ErrPasswordPolicyViolation) {
+			return newPasswordPolicyErrorResponse(c, "password", err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusCreated, registerResponse{UserID: userID})
+}
+
+type verifyEmailInput struct {
+	Token string `json:"token" query:"token" validate:"required"`
+}
+
+func (r *accountRoutes) verifyEmail(c echo.Context) error {
+	var input verifyEmailInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	err := r.accountService.VerifyEmail(c.Request().Context(), input.Token)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidVerificationToken) || errors.Is(err, service.ErrUserNotFound) {
+			return newErrorResponse(c, http.StatusBadRequest, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, SuccessResponse{Message: "email verified"})
+}
+
+type changeEmailInput struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+func (r *accountRoutes) changeEmail(c echo.Context) error {
+	var input changeEmailInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	err := r.accountService.ChangeEmail(c.Request().Context(), c.Get(userIDCtx).(string), input.Email)
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			return newErrorResponse(c, http.StatusBadRequest, err)
+		}
+		if errors.Is(err, service.ErrUserAlreadyExists) {
+			return newErrorResponse(c, http.StatusConflict, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, SuccessResponse{Message: "verification email sent"})
+}
+
+type setLocaleInput struct {
+	// Locale is empty to return to the default locale.
+	Locale string `json:"locale" validate:"omitempty,bcp47_language_tag"`
+}
+
+func (r *accountRoutes) setLocale(c echo.Context) error {
+	var input setLocaleInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	err := r.accountService.SetLocale(c.Request().Context(), c.Get(userIDCtx).(string), input.Locale)
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			return newErrorResponse(c, http.StatusBadRequest, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, SuccessResponse{Message: "locale updated"})
+}
+
+func (r *accountRoutes) resendVerificationEmail(c echo.Context) error {
+	err := r.accountService.ResendVerificationEmail(c.Request().Context(), c.Get(userIDCtx).(string))
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) || errors.Is(err, service.ErrEmailAlreadyVerified) {
+			return newErrorResponse(c, http.StatusBadRequest, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, SuccessResponse{Message: "verification email sent"})
+}
+
+type forgotPasswordInput struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+func (r *accountRoutes) forgotPassword(c echo.Context) error {
+	var input forgotPasswordInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	err := r.accountService.ForgotPassword(c.Request().Context(), input.Email)
+	if err != nil {
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusAccepted, SuccessResponse{Message: "if the email is registered, a password reset link has been sent"})
+}
+
+type resetPasswordInput struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,max=1024"`
+}
+
+func (r *accountRoutes) resetPassword(c echo.Context) error {
+	var input resetPasswordInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	err := r.accountService.ResetPassword(c.Request().Context(), input.Token, input.Password)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidPasswordResetToken) || errors.Is(err, service.ErrUserNotFound) {
+			return newErrorResponse(c, http.StatusBadRequest, err)
+		}
+		if errors.Is(err, service.ErrPasswordPolicyViolation) {
+			return newPasswordPolicyErrorResponse(c, "password", err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, SuccessResponse{Message: "password has been reset"})
+}
+
+type reportLoginInput struct {
+	Token string `json:"token" query:"token" form:"token" validate:"required"`
+}
+
+// reportLoginPage posts the token of the link back. Mail scanners and prefetchers open links, so
+// opening one must not report the sign-in, only the button of the page does.
+var reportLoginPage = template.Must(template.New("report-login").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Report a sign-in</title></head>
+<body>
+<p>Wasn't this sign-in you? Reporting it signs you out everywhere and asks you to set a new password.</p>
+<form method="post">
+<input type="hidden" name="token" value="{{.}}">
+<button type="submit">This wasn't me</button>
+</form>
+</body>
+</html>
+`))
+
+// confirmReportLogin is where the "this wasn't me" link of suspicious login emails leads.
+func (r *accountRoutes) confirmReportLogin(c echo.Context) error {
+	var input reportLoginInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	var page bytes.Buffer
+	if err := reportLoginPage.Execute(&page, input.Token); err != nil {
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.HTML(http.StatusOK, page.String())
+}
+
+// reportLogin reports the sign-in of a suspicious login email as not the user's.
+func (r *accountRoutes) reportLogin(c echo.Context) error {
+	var input reportLoginInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	err := r.accountService.ReportSuspiciousLogin(c.Request().Context(), input.Token)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidLoginReportToken) || errors.Is(err, service.ErrUserNotFound) {
+			return newErrorResponse(c, http.StatusBadRequest, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, SuccessResponse{Message: "all sessions have been signed out, check your email to reset your password"})
+}
+
+// acceptLanguage returns the first language of an Accept-Language header, "de-CH,de;q=0.9" gives "de-CH".
+// Clients list their preferred language first, so the weights are not parsed.
+func acceptLanguage(header string) string {
+	first, _, _ := strings.Cut(header, ",")
+	tag, _, _ := strings.Cut(first, ";")
+	tag = strings.TrimSpace(tag)
+	if tag == "*" || len(tag) > 16 {
+		return ""
+	}
+
+	return tag
+}
@@ -0,0 +1,110 @@
+package v1
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/service"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type adminRoutes struct {
+	auditService service.AuditService
+}
+
+func newAdminRoutes(g *echo.Group, auditService service.AuditService, adminAPIKey string) {
+	r := &adminRoutes{
+		auditService: auditService,
+	}
+
+	g.Use(newAdminKeyMiddleware(adminAPIKey))
+	g.GET("/audit", r.listAuditEvents)
+}
+
+type listAuditEventsInput struct {
+	UserID string `query:"user_id" validate:"omitempty,uuid"`
+	Type   string `query:"type"`
+	From   string `query:"from" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	To     string `query:"to" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	Cursor string `query:"cursor"`
+	Limit  int    `query:"limit" validate:"omitempty,min=1,max=200"`
+}
+
+type listAuditEventsResponse struct {
+	Events     []entity.AuditEvent `json:"events"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+func (r *adminRoutes) listAuditEvents(c echo.Context) error {
+	var input listAuditEventsInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	filter := entity.AuditFilter{
+		UserID: input.UserID,
+		From:   parseOptionalTime(input.From),
+		To:     parseOptionalTime(input.To),
+		Limit:  input.Limit,
+	}
+	if input.Type != "" {
+		filter.Types = strings.Split(input.Type, ",")
+	}
+
+	ctx := c.Request().Context()
+	events, nextCursor, err := r.auditService.ListEvents(ctx, filter, input.Cursor)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCursor) {
+			return newErrorResponse(c, http.StatusBadRequest, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	recordAdminAction(c, r.auditService, input.UserID, "list_audit_events", map[string]string{"query": c.QueryString()})
+
+	if events == nil {
+		events = []entity.AuditEvent{}
+	}
+
+	return c.JSON(http.StatusOK, listAuditEventsResponse{Events: events, NextCursor: nextCursor})
+}
+
+// recordAdminAction records an admin API call. The action is attributed to the admin signed in with an
+// access token, or to the admin key holder.
+func recordAdminAction(c echo.Context, auditService service.AuditService, userID, action string, metadata map[string]string) {
+	metadata["action"] = action
+
+	actorID := entity.AuditActorAdmin
+	if adminID, ok := c.Get(userIDCtx).(string); ok {
+		actorID = adminID
+	}
+
+	auditService.Record(c.Request().Context(), entity.AuditEvent{
+		Type:      entity.AuditAdminAction,
+		ActorID:   actorID,
+		SubjectID: userID,
+		Metadata:  metadata,
+	})
+}
+
+// parseOptionalTime parses an RFC 3339 time that has already passed validation.
+func parseOptionalTime(value string) *time.Time {
+	if value == "" {
+		return nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil
+	}
+
+	return &parsed
+}
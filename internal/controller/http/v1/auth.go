@@ -9,21 +9,35 @@ import (
 )
 
 type authRoutes struct {
-	authService service.AuthService
+	authService       service.AuthService
+	bruteForceService service.BruteForceService
+	devices           deviceCookies
 }
 
-func newAuthRoutes(g *echo.Group, authService service.AuthService) {
+func newAuthRoutes(g *echo.Group, authService service.AuthService, bruteForceService service.BruteForceService, devices deviceCookies) {
 	r := &authRoutes{
-		authService: authService,
+		authService:       authService,
+		bruteForceService: bruteForceService,
+		devices:           devices,
 	}
 
 	g.POST("/token", r.createTokens)
-	g.POST("/refresh", r.refreshTokens)
+	g.POST("/refresh", r.refreshTokens, newIPThrottleMiddleware(bruteForceService))
+	g.POST("/login", r.login)
+	g.POST("/step-up", r.completeStepUp, newIPThrottleMiddleware(bruteForceService))
+	g.GET("/unlock", r.unlock)
+	g.POST("/unlock", r.unlock)
+	g.POST("/logout", r.logout, newIdentityMiddleware(authService))
 }
 
 type createTokensInput struct {
 	UserId   string `json:"user_id" validate:"required,uuid"`
 	ClientIP string `json:"client_ip" validate:"required,ip"`
+	// DeviceID and UserAgent describe the client's device when a backend asks for its tokens.
+	DeviceID  string `json:"device_id" validate:"max=255"`
+	UserAgent string `json:"user_agent" validate:"max=1024"`
+	// OrgID starts a session of the organization, the user must be one of its members.
+	OrgID string `json:"org_id" validate:"omitempty,uuid"`
 }
 
 func (r *authRoutes) createTokens(c echo.Context) error {
@@ -37,15 +51,45 @@ func (r *authRoutes) createTokens(c echo.Context) error {
 		return newErrorResponse(c, http.StatusBadRequest, err)
 	}
 
-	tokens, err := r.authService.CreateTokens(c.Request().Context(), input.UserId, input.ClientIP)
+	device, ownID, err := r.devices.input(c, input.DeviceID)
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+	if input.UserAgent != "" {
+		device.UserAgent = input.UserAgent
+	}
+
+	tokens, err := r.authService.CreateTokens(c.Request().Context(), input.UserId, input.OrgID, input.ClientIP, device)
 	if err != nil {
 		if errors.Is(err, service.ErrSessionAlreadyExists) || errors.Is(err, service.ErrUserNotFound) {
 			return newErrorResponse(c, http.StatusBadRequest, err)
 		}
+		if errors.Is(err, service.ErrEmailNotVerified) || errors.Is(err, service.ErrPasswordResetRequired) {
+			return newErrorResponse(c, http.StatusForbidden, err)
+		}
+		if errors.Is(err, service.ErrUserDisabled) || errors.Is(err, service.ErrUserLocked) {
+			return newErrorResponse(c, http.StatusForbidden, err)
+		}
+		if errors.Is(err, service.ErrStepUpRequired) {
+			return newStepUpRequiredResponse(c, err)
+		}
+		if errors.Is(err, service.ErrRiskDenied) || errors.Is(err, service.ErrMFAUnavailable) {
+			return newErrorResponse(c, http.StatusForbidden, err)
+		}
+		if errors.Is(err, service.ErrNotOrgMember) || errors.Is(err, service.ErrGrantNotAllowed) {
+			return newErrorResponse(c, http.StatusForbidden, err)
+		}
+		if errors.Is(err, service.ErrIPNotAllowed) {
+			return newErrorCodeResponse(c, http.StatusForbidden, errCodeIPNotAllowed, err)
+		}
 
 		return newErrorResponse(c, http.StatusInternalServerError, err)
 	}
 
+	if !ownID {
+		r.devices.set(c, tokens)
+	}
+
 	return c.JSON(http.StatusOK, tokens)
 }
 
@@ -60,20 +104,215 @@ func (r *authRoutes) refreshTokens(c echo.Context) error {
 		return newErrorResponse(c, http.StatusBadRequest, err)
 	}
 
-	tokens, err := r.authService.RefreshTokens(c.Request().Context(), input.RefreshToken, input.AccessToken)
+	device, ownID, err := r.devices.input(c, "")
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	tokens, err := r.authService.RefreshTokens(c.Request().Context(), input.RefreshToken, input.AccessToken, c.RealIP(), device)
 	if err != nil {
 		if errors.Is(err, service.ErrParsingAccessToken) ||
 			errors.Is(err, service.ErrRefreshTokenNotFound) ||
 			errors.Is(err, service.ErrRefreshTokenAlreadyUsed) ||
 			errors.Is(err, service.ErrRefreshTokenExpired) ||
+			errors.Is(err, service.ErrSessionLifetimeExceeded) ||
+			errors.Is(err, service.ErrRefreshTokenRevoked) ||
 			errors.Is(err, service.ErrUserNotFound) ||
 			errors.Is(err, service.ErrNoSessionsFoundWithThisUserID) {
 
 			return newErrorResponse(c, http.StatusBadRequest, err)
 		}
+		if errors.Is(err, service.ErrEmailNotVerified) || errors.Is(err, service.ErrUserDisabled) {
+			return newErrorResponse(c, http.StatusForbidden, err)
+		}
+		if errors.Is(err, service.ErrStepUpRequired) {
+			return newStepUpRequiredResponse(c, err)
+		}
+		if errors.Is(err, service.ErrRiskDenied) {
+			return newErrorResponse(c, http.StatusForbidden, err)
+		}
+		if errors.Is(err, service.ErrNotOrgMember) || errors.Is(err, service.ErrGrantNotAllowed) {
+			return newErrorResponse(c, http.StatusForbidden, err)
+		}
+		if errors.Is(err, service.ErrIPNotAllowed) {
+			return newErrorCodeResponse(c, http.StatusForbidden, errCodeIPNotAllowed, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	if !ownID {
+		r.devices.set(c, tokens)
+	}
+
+	return c.JSON(http.StatusOK, tokens)
+}
+
+type loginInput struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,max=1024"`
+	OrgID    string `json:"org_id" validate:"omitempty,uuid"`
+}
+
+func (r *authRoutes) login(c echo.Context) error {
+	var input loginInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	device, ownID, err := r.devices.input(c, "")
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	tokens, err := r.authService.Login(c.Request().Context(), input.Email, input.Password, input.OrgID, c.RealIP(), device)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCredentials) {
+			return newErrorResponse(c, http.StatusUnauthorized, err)
+		}
+		if errors.Is(err, service.ErrTooManyAttempts) {
+			return newTooManyRequestsResponse(c, err)
+		}
+		if errors.Is(err, service.ErrEmailNotVerified) || errors.Is(err, service.ErrPasswordResetRequired) {
+			return newErrorResponse(c, http.StatusForbidden, err)
+		}
+		if errors.Is(err, service.ErrUserDisabled) || errors.Is(err, service.ErrUserLocked) {
+			return newErrorResponse(c, http.StatusForbidden, err)
+		}
+		if errors.Is(err, service.ErrStepUpRequired) {
+			return newStepUpRequiredResponse(c, err)
+		}
+		if errors.Is(err, service.ErrRiskDenied) || errors.Is(err, service.ErrMFAUnavailable) {
+			return newErrorResponse(c, http.StatusForbidden, err)
+		}
+		if errors.Is(err, service.ErrNotOrgMember) || errors.Is(err, service.ErrGrantNotAllowed) {
+			return newErrorResponse(c, http.StatusForbidden, err)
+		}
+		if errors.Is(err, service.ErrIPNotAllowed) {
+			return newErrorCodeResponse(c, http.StatusForbidden, errCodeIPNotAllowed, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	if !ownID {
+		r.devices.set(c, tokens)
+	}
+
+	return c.JSON(http.StatusOK, tokens)
+}
+
+type stepUpInput struct {
+	ChallengeID string `json:"challenge_id" validate:"required,uuid"`
+	Code        string `json:"code" validate:"required,numeric"`
+}
+
+func (r *authRoutes) completeStepUp(c echo.Context) error {
+	var input stepUpInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	device, ownID, err := r.devices.input(c, "")
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	tokens, err := r.authService.CompleteStepUp(c.Request().Context(), input.ChallengeID, input.Code, c.RealIP(), device)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidStepUpCode) ||
+			errors.Is(err, service.ErrUserNotFound) ||
+			errors.Is(err, service.ErrRefreshTokenNotFound) ||
+			errors.Is(err, service.ErrRefreshTokenAlreadyUsed) ||
+			errors.Is(err, service.ErrRefreshTokenExpired) ||
+			errors.Is(err, service.ErrSessionLifetimeExceeded) ||
+			errors.Is(err, service.ErrRefreshTokenRevoked) {
+			return newErrorResponse(c, http.StatusBadRequest, err)
+		}
+		if errors.Is(err, service.ErrPasswordResetRequired) {
+			return newErrorResponse(c, http.StatusForbidden, err)
+		}
+		if errors.Is(err, service.ErrUserDisabled) || errors.Is(err, service.ErrUserLocked) {
+			return newErrorResponse(c, http.StatusForbidden, err)
+		}
+		if errors.Is(err, service.ErrNotOrgMember) || errors.Is(err, service.ErrGrantNotAllowed) {
+			return newErrorResponse(c, http.StatusForbidden, err)
+		}
+		if errors.Is(err, service.ErrIPNotAllowed) {
+			return newErrorCodeResponse(c, http.StatusForbidden, errCodeIPNotAllowed, err)
+		}
 
 		return newErrorResponse(c, http.StatusInternalServerError, err)
 	}
 
+	if !ownID {
+		r.devices.set(c, tokens)
+	}
+
 	return c.JSON(http.StatusOK, tokens)
 }
+
+type unlockInput struct {
+	Token string `json:"token" query:"token" validate:"required"`
+}
+
+func (r *authRoutes) unlock(c echo.Context) error {
+	var input unlockInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	err := r.bruteForceService.Unlock(c.Request().Context(), input.Token)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidUnlockToken) {
+			return newErrorResponse(c, http.StatusBadRequest, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, SuccessResponse{Message: "account unlocked"})
+}
+
+type logoutInput struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+func (r *authRoutes) logout(c echo.Context) error {
+	var input logoutInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	claims := c.Get(tokenClaimsCtx).(*service.TokenClaims)
+	err := r.authService.Logout(c.Request().Context(), claims.UserID, claims.OrgID, input.RefreshToken)
+	if err != nil {
+		if errors.Is(err, service.ErrRefreshTokenNotFound) {
+			return newErrorResponse(c, http.StatusBadRequest, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, SuccessResponse{Message: "logged out"})
+}
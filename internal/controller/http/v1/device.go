@@ -0,0 +1,148 @@
+package v1
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/service"
+	"net/http"
+	"time"
+)
+
+// deviceIDHeader carries the device ID of apps that do not keep cookies.
+const deviceIDHeader = "X-Device-ID"
+
+var errInvalidDeviceID = errors.New("device id must be at most 255 characters")
+
+// DeviceCookieConfig is the cookie that keeps the device key in browsers.
+type DeviceCookieConfig struct {
+	Name   string
+	MaxAge time.Duration
+	Secure bool
+}
+
+// deviceCookies reads the device of a request and keeps browsers on the device a session was issued to.
+type deviceCookies struct {
+	deviceService service.DeviceService
+	config        DeviceCookieConfig
+}
+
+// input reads the device of a request. A device ID the client sent wins over the device cookie, and
+// cookies the service did not sign are ignored. ownID tells that the client identifies the device itself.
+func (d deviceCookies) input(c echo.Context, deviceID string) (input service.DeviceInput, ownID bool, err error) {
+	input.UserAgent = c.Request().UserAgent()
+
+	if deviceID == "" {
+		deviceID = c.Request().Header.Get(deviceIDHeader)
+	}
+	if deviceID != "" {
+		if len(deviceID) > 255 {
+			return input, true, errInvalidDeviceID
+		}
+
+		input.Key = deviceID
+		return input, true, nil
+	}
+
+	if cookie, err := c.Cookie(d.config.Name); err == nil {
+		input.Key, _ = d.deviceService.ParseDeviceCookie(cookie.Value)
+	}
+
+	return input, false, nil
+}
+
+// set renews the device cookie with the device key of the issued session.
+func (d deviceCookies) set(c echo.Context, tokens *entity.Tokens) {
+	if tokens.DeviceKey == "" {
+		return
+	}
+
+	c.SetCookie(&http.Cookie{
+		Name:     d.config.Name,
+		Value:    d.deviceService.SignDeviceKey(tokens.DeviceKey),
+		Path:     "/",
+		MaxAge:   int(d.config.MaxAge.Seconds()),
+		Secure:   d.config.Secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+type deviceRoutes struct {
+	deviceService service.DeviceService
+}
+
+func newDeviceRoutes(g *echo.Group, deviceService service.DeviceService, authService service.AuthService) {
+	r := &deviceRoutes{
+		deviceService: deviceService,
+	}
+
+	g.Use(newIdentityMiddleware(authService))
+	g.GET("", r.listDevices)
+	g.PUT("/:id", r.renameDevice)
+	g.DELETE("/:id", r.forgetDevice)
+}
+
+func (r *deviceRoutes) listDevices(c echo.Context) error {
+	devices, err := r.deviceService.ListDevices(c.Request().Context(), c.Get(userIDCtx).(string))
+	if err != nil {
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, devices)
+}
+
+type renameDeviceInput struct {
+	ID   string `param:"id" validate:"required,uuid"`
+	Name string `json:"name" validate:"max=255"`
+}
+
+func (r *deviceRoutes) renameDevice(c echo.Context) error {
+	var input renameDeviceInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	err := r.deviceService.RenameDevice(c.Request().Context(), c.Get(userIDCtx).(string), input.ID, input.Name)
+	if err != nil {
+		if errors.Is(err, service.ErrDeviceNotFound) {
+			return newErrorResponse(c, http.StatusNotFound, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, SuccessResponse{Message: "device renamed"})
+}
+
+type deviceIDInput struct {
+	ID string `param:"id" validate:"required,uuid"`
+}
+
+func (r *deviceRoutes) forgetDevice(c echo.Context) error {
+	var input deviceIDInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	err := r.deviceService.ForgetDevice(c.Request().Context(), c.Get(userIDCtx).(string), input.ID)
+	if err != nil {
+		if errors.Is(err, service.ErrDeviceNotFound) {
+			return newErrorResponse(c, http.StatusNotFound, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, SuccessResponse{Message: "device forgotten"})
+}
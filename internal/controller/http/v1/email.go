@@ -0,0 +1,167 @@
+package v1
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/service"
+	"net/http"
+	"strconv"
+)
+
+type emailRoutes struct {
+	emailService service.EmailService
+	auditService service.AuditService
+}
+
+// newEmailRoutes registers the email outbox admin API, g must already be guarded by the admin key middleware.
+func newEmailRoutes(g *echo.Group, emailService service.EmailService, auditService service.AuditService) {
+	r := &emailRoutes{
+		emailService: emailService,
+		auditService: auditService,
+	}
+
+	g.GET("", r.listMessages)
+	g.POST("/:id/retry", r.retry)
+	g.GET("/templates", r.listTemplates)
+	g.GET("/templates/:name/preview", r.previewTemplate)
+}
+
+type listEmailMessagesInput struct {
+	Channel   string `query:"channel" validate:"omitempty,oneof=email webhook telegram slack sms mailbox"`
+	Status    string `query:"status" validate:"omitempty,oneof=pending sent failed"`
+	Recipient string `query:"recipient" validate:"omitempty,max=255"`
+	Cursor    string `query:"cursor"`
+	Limit     int    `query:"limit" validate:"omitempty,min=1,max=200"`
+}
+
+type listEmailMessagesResponse struct {
+	Emails     []entity.EmailMessage `json:"emails"`
+	NextCursor string                `json:"next_cursor,omitempty"`
+}
+
+func (r *emailRoutes) listMessages(c echo.Context) error {
+	var input listEmailMessagesInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	filter := entity.EmailMessageFilter{
+		Channel:   input.Channel,
+		Status:    input.Status,
+		Recipient: input.Recipient,
+		Limit:     input.Limit,
+	}
+
+	ctx := c.Request().Context()
+	messages, nextCursor, err := r.emailService.ListMessages(ctx, filter, input.Cursor)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCursor) {
+			return newErrorResponse(c, http.StatusBadRequest, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	recordAdminAction(c, r.auditService, "", "list_emails", map[string]string{"query": c.QueryString()})
+
+	if messages == nil {
+		messages = []entity.EmailMessage{}
+	}
+
+	return c.JSON(http.StatusOK, listEmailMessagesResponse{Emails: messages, NextCursor: nextCursor})
+}
+
+type retryEmailInput struct {
+	ID int64 `param:"id" validate:"required,min=1"`
+}
+
+func (r *emailRoutes) retry(c echo.Context) error {
+	var input retryEmailInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	ctx := c.Request().Context()
+	err := r.emailService.Retry(ctx, input.ID)
+	if err != nil {
+		if errors.Is(err, service.ErrEmailNotFound) {
+			return newErrorResponse(c, http.StatusNotFound, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	recordAdminAction(c, r.auditService, "", "retry_email", map[string]string{"email_id": strconv.FormatInt(input.ID, 10)})
+
+	return c.JSON(http.StatusOK, SuccessResponse{Message: "email queued"})
+}
+
+type listEmailTemplatesResponse struct {
+	Templates []string `json:"templates"`
+	Locales   []string `json:"locales"`
+}
+
+func (r *emailRoutes) listTemplates(c echo.Context) error {
+	names, locales := r.emailService.ListTemplates()
+
+	return c.JSON(http.StatusOK, listEmailTemplatesResponse{Templates: names, Locales: locales})
+}
+
+type previewEmailTemplateInput struct {
+	Name   string `param:"name" validate:"required"`
+	Locale string `query:"locale"`
+	Format string `query:"format" validate:"omitempty,oneof=html text json"`
+}
+
+type previewEmailTemplateResponse struct {
+	Subject  string `json:"subject"`
+	TextBody string `json:"text_body"`
+	HTMLBody string `json:"html_body"`
+}
+
+// previewTemplate renders a template with sample data. The html and text formats return the part
+// itself, so it can be opened in a browser.
+func (r *emailRoutes) previewTemplate(c echo.Context) error {
+	var input previewEmailTemplateInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	message, err := r.emailService.PreviewTemplate(input.Name, input.Locale)
+	if err != nil {
+		if errors.Is(err, service.ErrEmailTemplateNotFound) {
+			return newErrorResponse(c, http.StatusNotFound, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	switch input.Format {
+	case "text":
+		return c.String(http.StatusOK, message.TextBody)
+	case "json":
+		return c.JSON(http.StatusOK, previewEmailTemplateResponse{
+			Subject:  message.Subject,
+			TextBody: message.TextBody,
+			HTMLBody: message.HTMLBody,
+		})
+	}
+
+	return c.HTML(http.StatusOK, message.HTMLBody)
+}
@@ -0,0 +1,228 @@
+package v1
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/service"
+	"net/http"
+)
+
+type ipRuleRoutes struct {
+	ipRuleService service.IPRuleService
+	auditService  service.AuditService
+}
+
+// newIPRuleRoutes registers the ip rule admin API under /users/:id/ip-rules and /orgs/:id/ip-rules,
+// g must already be guarded by the admin key middleware.
+func newIPRuleRoutes(g *echo.Group, ipRuleService service.IPRuleService, auditService service.AuditService) {
+	r := &ipRuleRoutes{
+		ipRuleService: ipRuleService,
+		auditService:  auditService,
+	}
+
+	g.GET("/users/:id/ip-rules", r.listIPRules)
+	g.POST("/users/:id/ip-rules", r.createIPRule)
+	g.DELETE("/users/:id/ip-rules/:rule_id", r.deleteIPRule)
+
+	g.GET("/orgs/:id/ip-rules", r.listOrgIPRules)
+	g.POST("/orgs/:id/ip-rules", r.createOrgIPRule)
+	g.DELETE("/orgs/:id/ip-rules/:rule_id", r.deleteOrgIPRule)
+}
+
+type userIDInput struct {
+	UserID string `param:"id" validate:"required,uuid"`
+}
+
+func (r *ipRuleRoutes) listIPRules(c echo.Context) error {
+	var input userIDInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	rules, err := r.ipRuleService.ListIPRules(c.Request().Context(), input.UserID)
+	if err != nil {
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, rules)
+}
+
+type createIPRuleInput struct {
+	UserID      string `param:"id" validate:"required,uuid"`
+	Action      string `json:"action" validate:"required,oneof=allow deny"`
+	CIDR        string `json:"cidr" validate:"required,max=64"`
+	Description string `json:"description" validate:"max=255"`
+}
+
+func (r *ipRuleRoutes) createIPRule(c echo.Context) error {
+	var input createIPRuleInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	rule, err := r.ipRuleService.CreateIPRule(c.Request().Context(), entity.IPRule{
+		UserID:      input.UserID,
+		Action:      input.Action,
+		CIDR:        input.CIDR,
+		Description: input.Description,
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidIPRule) {
+			return newErrorResponse(c, http.StatusBadRequest, err)
+		}
+		if errors.Is(err, service.ErrUserNotFound) {
+			return newErrorResponse(c, http.StatusNotFound, err)
+		}
+		if errors.Is(err, service.ErrIPRuleAlreadyExists) {
+			return newErrorResponse(c, http.StatusConflict, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	recordAdminAction(c, r.auditService, rule.UserID, "create_ip_rule", map[string]string{
+		"ip_rule_id": rule.ID,
+		"ip_action":  rule.Action,
+		"cidr":       rule.CIDR,
+	})
+
+	return c.JSON(http.StatusCreated, rule)
+}
+
+type ipRuleIDInput struct {
+	UserID string `param:"id" validate:"required,uuid"`
+	ID     string `param:"rule_id" validate:"required,uuid"`
+}
+
+func (r *ipRuleRoutes) deleteIPRule(c echo.Context) error {
+	var input ipRuleIDInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	err := r.ipRuleService.DeleteIPRule(c.Request().Context(), input.UserID, input.ID)
+	if err != nil {
+		if errors.Is(err, service.ErrIPRuleNotFound) {
+			return newErrorResponse(c, http.StatusNotFound, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	recordAdminAction(c, r.auditService, input.UserID, "delete_ip_rule", map[string]string{"ip_rule_id": input.ID})
+
+	return c.JSON(http.StatusOK, SuccessResponse{Message: "ip rule deleted"})
+}
+
+func (r *ipRuleRoutes) listOrgIPRules(c echo.Context) error {
+	var input orgIDInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	rules, err := r.ipRuleService.ListOrgIPRules(c.Request().Context(), input.ID)
+	if err != nil {
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, rules)
+}
+
+type createOrgIPRuleInput struct {
+	OrgID       string `param:"id" validate:"required,uuid"`
+	Action      string `json:"action" validate:"required,oneof=allow deny"`
+	CIDR        string `json:"cidr" validate:"required,max=64"`
+	Description string `json:"description" validate:"max=255"`
+}
+
+func (r *ipRuleRoutes) createOrgIPRule(c echo.Context) error {
+	var input createOrgIPRuleInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	rule, err := r.ipRuleService.CreateIPRule(c.Request().Context(), entity.IPRule{
+		OrgID:       input.OrgID,
+		Action:      input.Action,
+		CIDR:        input.CIDR,
+		Description: input.Description,
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidIPRule) {
+			return newErrorResponse(c, http.StatusBadRequest, err)
+		}
+		if errors.Is(err, service.ErrOrganizationNotFound) {
+			return newErrorResponse(c, http.StatusNotFound, err)
+		}
+		if errors.Is(err, service.ErrIPRuleAlreadyExists) {
+			return newErrorResponse(c, http.StatusConflict, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	recordAdminAction(c, r.auditService, "", "create_ip_rule", map[string]string{
+		"org_id":     rule.OrgID,
+		"ip_rule_id": rule.ID,
+		"ip_action":  rule.Action,
+		"cidr":       rule.CIDR,
+	})
+
+	return c.JSON(http.StatusCreated, rule)
+}
+
+type orgIPRuleIDInput struct {
+	OrgID string `param:"id" validate:"required,uuid"`
+	ID    string `param:"rule_id" validate:"required,uuid"`
+}
+
+func (r *ipRuleRoutes) deleteOrgIPRule(c echo.Context) error {
+	var input orgIPRuleIDInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	err := r.ipRuleService.DeleteOrgIPRule(c.Request().Context(), input.OrgID, input.ID)
+	if err != nil {
+		if errors.Is(err, service.ErrIPRuleNotFound) {
+			return newErrorResponse(c, http.StatusNotFound, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	recordAdminAction(c, r.auditService, "", "delete_ip_rule", map[string]string{"org_id": input.OrgID, "ip_rule_id": input.ID})
+
+	return c.JSON(http.StatusOK, SuccessResponse{Message: "ip rule deleted"})
+}
@@ -0,0 +1,192 @@
+package v1
+
+import (
+	"crypto/subtle"
+	"errors"
+	"github.com/labstack/echo/v4"
+	log "github.com/sirupsen/logrus"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/service"
+	"net/http"
+	"strings"
+)
+
+const (
+	userIDCtx      = "userID"
+	tokenClaimsCtx = "tokenClaims"
+
+	adminKeyHeader = "X-Admin-Key"
+	checkKeyHeader = "X-Check-Key"
+)
+
+var (
+	errMissingBearerToken = errors.New("missing bearer token")
+	errAdminAPIDisabled   = errors.New("admin api is disabled")
+	errInvalidAdminKey    = errors.New("invalid admin key")
+	errInvalidCheckKey    = errors.New("invalid check key")
+	errMissingAdminScope  = errors.New("access token lacks the admin scope")
+	errInactiveAdmin      = errors.New("admin account is not active")
+)
+
+// newRequestInfoMiddleware puts the client IP and user agent into the request context for audit events.
+func newRequestInfoMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := service.WithRequestInfo(c.Request().Context(), c.RealIP(), c.Request().UserAgent())
+			c.SetRequest(c.Request().WithContext(ctx))
+
+			return next(c)
+		}
+	}
+}
+
+// newAdminKeyMiddleware guards the admin API with a shared key. An empty key disables the API altogether.
+func newAdminKeyMiddleware(adminAPIKey string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if adminAPIKey == "" {
+				return newErrorResponse(c, http.StatusForbidden, errAdminAPIDisabled)
+			}
+
+			key := c.Request().Header.Get(adminKeyHeader)
+			if subtle.ConstantTimeCompare([]byte(key), []byte(adminAPIKey)) != 1 {
+				return newErrorResponse(c, http.StatusUnauthorized, errInvalidAdminKey)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// newCheckKeyMiddleware guards the permission and relation checks. Downstream services send the check
+// key, which grants nothing else, while admins may keep using the admin key. An empty check key leaves
+// only the admin key.
+func newCheckKeyMiddleware(checkAPIKey, adminAPIKey string) echo.MiddlewareFunc {
+	adminKey := newAdminKeyMiddleware(adminAPIKey)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		withAdminKey := adminKey(next)
+
+		return func(c echo.Context) error {
+			key := c.Request().Header.Get(checkKeyHeader)
+			if key == "" {
+				return withAdminKey(c)
+			}
+
+			if checkAPIKey == "" || subtle.ConstantTimeCompare([]byte(key), []byte(checkAPIKey)) != 1 {
+				return newErrorResponse(c, http.StatusUnauthorized, errInvalidCheckKey)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// newAdminScopeMiddleware lets admins in with an access token that carries scope, as well as with the shared
+// admin key. The scope is checked again against the current roles of the user, and the user has to be
+// active, so revoking the role or locking or disabling the admin takes effect before the token expires.
+func newAdminScopeMiddleware(
+	adminAPIKey, scope string,
+	authService service.AuthService,
+	roleService service.RoleService,
+	userService service.UserService) echo.MiddlewareFunc {
+	adminKey := newAdminKeyMiddleware(adminAPIKey)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		withAdminKey := adminKey(next)
+
+		return func(c echo.Context) error {
+			accessToken, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+			if !ok || accessToken == "" || c.Request().Header.Get(adminKeyHeader) != "" {
+				return withAdminKey(c)
+			}
+
+			ctx := c.Request().Context()
+			claims, err := authService.Authenticate(ctx, accessToken)
+			if err != nil {
+				return newErrorResponse(c, http.StatusUnauthorized, service.ErrInvalidAccessToken)
+			}
+
+			if !claims.HasScope(scope) {
+				return newErrorResponse(c, http.StatusForbidden, errMissingAdminScope)
+			}
+
+			admin, err := userService.GetUser(ctx, claims.UserID)
+			if err != nil {
+				if errors.Is(err, service.ErrUserNotFound) {
+					return newErrorResponse(c, http.StatusUnauthorized, service.ErrInvalidAccessToken)
+				}
+
+				return newErrorResponse(c, http.StatusInternalServerError, err)
+			}
+			if admin.Status != entity.UserStatusActive {
+				return newErrorResponse(c, http.StatusForbidden, errInactiveAdmin)
+			}
+
+			granted, err := roleService.CheckPermission(ctx, claims.UserID, scope)
+			if err != nil {
+				return newErrorResponse(c, http.StatusInternalServerError, err)
+			}
+			if !granted {
+				return newErrorResponse(c, http.StatusForbidden, errMissingAdminScope)
+			}
+
+			c.Set(userIDCtx, claims.UserID)
+			c.Set(tokenClaimsCtx, claims)
+
+			return next(c)
+		}
+	}
+}
+
+func newIdentityMiddleware(authService service.AuthService) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			accessToken, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+			if !ok || accessToken == "" {
+				return newErrorResponse(c, http.StatusUnauthorized, errMissingBearerToken)
+			}
+
+			claims, err := authService.Authenticate(c.Request().Context(), accessToken)
+			if err != nil {
+				return newErrorResponse(c, http.StatusUnauthorized, service.ErrInvalidAccessToken)
+			}
+
+			c.Set(userIDCtx, claims.UserID)
+			c.Set(tokenClaimsCtx, claims)
+
+			return next(c)
+		}
+	}
+}
+
+// newIPThrottleMiddleware blocks client IPs that keep failing on endpoints without an account to attribute
+// failures to, like /refresh. Login counts failures per IP and per account in the service itself.
+func newIPThrottleMiddleware(bruteForceService service.BruteForceService) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := c.Request().Context()
+			attemptKey := service.IPAttemptKey(c.RealIP())
+
+			err := bruteForceService.Check(ctx, attemptKey)
+			if err != nil {
+				if errors.Is(err, service.ErrTooManyAttempts) {
+					return newTooManyRequestsResponse(c, err)
+				}
+
+				return newErrorResponse(c, http.StatusInternalServerError, err)
+			}
+
+			err = next(c)
+
+			status := c.Response().Status
+			if status == http.StatusBadRequest || status == http.StatusUnauthorized {
+				if failureErr := bruteForceService.RegisterFailure(ctx, attemptKey); failureErr != nil {
+					log.Errorf("error while registering failed attempt: %v", failureErr)
+				}
+			}
+
+			return err
+		}
+	}
+}
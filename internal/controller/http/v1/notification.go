@@ -0,0 +1,82 @@
+package v1
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/service"
+	"net/http"
+)
+
+type notificationRoutes struct {
+	notificationService service.NotificationService
+}
+
+func newNotificationRoutes(g *echo.Group, notificationService service.NotificationService, authService service.AuthService) {
+	r := &notificationRoutes{
+		notificationService: notificationService,
+	}
+
+	g.Use(newIdentityMiddleware(authService))
+	g.GET("", r.getPreferences)
+	g.PUT("", r.setPreferences)
+}
+
+type notificationPreferencesResponse struct {
+	Channels map[string]string               `json:"available_channels"`
+	Current  []entity.NotificationPreference `json:"channels"`
+}
+
+func (r *notificationRoutes) getPreferences(c echo.Context) error {
+	preferences, err := r.notificationService.GetPreferences(c.Request().Context(), c.Get(userIDCtx).(string))
+	if err != nil {
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, notificationPreferencesResponse{
+		Channels: r.notificationService.Channels(),
+		Current:  preferences,
+	})
+}
+
+type notificationPreferenceInput struct {
+	Channel     string `json:"channel" validate:"required"`
+	Address     string `json:"address" validate:"max=255"`
+	MinSeverity string `json:"min_severity" validate:"required,oneof=info warning critical"`
+}
+
+type setNotificationPreferencesInput struct {
+	Channels []notificationPreferenceInput `json:"channels" validate:"max=10,dive"`
+}
+
+func (r *notificationRoutes) setPreferences(c echo.Context) error {
+	var input setNotificationPreferencesInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	preferences := make([]entity.NotificationPreference, 0, len(input.Channels))
+	for _, channel := range input.Channels {
+		preferences = append(preferences, entity.NotificationPreference{
+			Channel:     channel.Channel,
+			Address:     channel.Address,
+			MinSeverity: channel.MinSeverity,
+		})
+	}
+
+	err := r.notificationService.SetPreferences(c.Request().Context(), c.Get(userIDCtx).(string), preferences)
+	if err != nil {
+		if errors.Is(err, service.ErrNotificationChannelUnavailable) || errors.Is(err, service.ErrInvalidNotificationPreference) {
+			return newErrorResponse(c, http.StatusBadRequest, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, SuccessResponse{Message: "notification preferences updated"})
+}
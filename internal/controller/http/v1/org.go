@@ -0,0 +1,313 @@
+package v1
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/service"
+	"net/http"
+)
+
+type orgRoutes struct {
+	orgService   service.OrganizationService
+	auditService service.AuditService
+}
+
+// newOrgRoutes registers the organization admin API, g must already be guarded by the admin key middleware.
+func newOrgRoutes(g *echo.Group, orgService service.OrganizationService, auditService service.AuditService) {
+	r := &orgRoutes{
+		orgService:   orgService,
+		auditService: auditService,
+	}
+
+	g.GET("", r.listOrganizations)
+	g.POST("", r.createOrganization)
+	g.GET("/:id", r.getOrganization)
+	g.PUT("/:id", r.updateOrganization)
+	g.DELETE("/:id", r.deleteOrganization)
+
+	g.GET("/:id/members", r.listMembers)
+	g.PUT("/:id/members/:user_id", r.setMember)
+	g.DELETE("/:id/members/:user_id", r.removeMember)
+
+	g.GET("/:id/sessions", r.listSessions)
+}
+
+func (r *orgRoutes) listOrganizations(c echo.Context) error {
+	orgs, err := r.orgService.ListOrganizations(c.Request().Context())
+	if err != nil {
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, orgs)
+}
+
+type organizationInput struct {
+	Name string `json:"name" validate:"required,max=255"`
+}
+
+func (r *orgRoutes) createOrganization(c echo.Context) error {
+	var input organizationInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	org, err := r.orgService.CreateOrganization(c.Request().Context(), entity.Organization{Name: input.Name})
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidOrganization) {
+			return newErrorResponse(c, http.StatusBadRequest, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	recordAdminAction(c, r.auditService, "", "create_organization", map[string]string{"org_id": org.ID})
+
+	return c.JSON(http.StatusCreated, org)
+}
+
+type orgIDInput struct {
+	ID string `param:"id" validate:"required,uuid"`
+}
+
+func (r *orgRoutes) getOrganization(c echo.Context) error {
+	var input orgIDInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	org, err := r.orgService.GetOrganization(c.Request().Context(), input.ID)
+	if err != nil {
+		if errors.Is(err, service.ErrOrganizationNotFound) {
+			return newErrorResponse(c, http.StatusNotFound, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, org)
+}
+
+type updateOrganizationInput struct {
+	ID   string `param:"id" validate:"required,uuid"`
+	Name string `json:"name" validate:"required,max=255"`
+}
+
+func (r *orgRoutes) updateOrganization(c echo.Context) error {
+	var input updateOrganizationInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	org, err := r.orgService.UpdateOrganization(c.Request().Context(), entity.Organization{ID: input.ID, Name: input.Name})
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidOrganization) {
+			return newErrorResponse(c, http.StatusBadRequest, err)
+		}
+		if errors.Is(err, service.ErrOrganizationNotFound) {
+			return newErrorResponse(c, http.StatusNotFound, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	recordAdminAction(c, r.auditService, "", "update_organization", map[string]string{"org_id": org.ID})
+
+	return c.JSON(http.StatusOK, org)
+}
+
+func (r *orgRoutes) deleteOrganization(c echo.Context) error {
+	var input orgIDInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	err := r.orgService.DeleteOrganization(c.Request().Context(), input.ID)
+	if err != nil {
+		if errors.Is(err, service.ErrOrganizationNotFound) {
+			return newErrorResponse(c, http.StatusNotFound, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	recordAdminAction(c, r.auditService, "", "delete_organization", map[string]string{"org_id": input.ID})
+
+	return c.JSON(http.StatusOK, SuccessResponse{Message: "organization deleted"})
+}
+
+func (r *orgRoutes) listMembers(c echo.Context) error {
+	var input orgIDInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	members, err := r.orgService.ListMembers(c.Request().Context(), input.ID)
+	if err != nil {
+		if errors.Is(err, service.ErrOrganizationNotFound) {
+			return newErrorResponse(c, http.StatusNotFound, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, members)
+}
+
+type setMemberInput struct {
+	ID     string `param:"id" validate:"required,uuid"`
+	UserID string `param:"user_id" validate:"required,uuid"`
+	Role   string `json:"role" validate:"required,oneof=owner admin member"`
+}
+
+func (r *orgRoutes) setMember(c echo.Context) error {
+	var input setMemberInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	membership, err := r.orgService.SetMember(c.Request().Context(), input.ID, input.UserID, input.Role)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidOrgRole) {
+			return newErrorResponse(c, http.StatusBadRequest, err)
+		}
+		if errors.Is(err, service.ErrOrganizationNotFound) || errors.Is(err, service.ErrUserNotFound) {
+			return newErrorResponse(c, http.StatusNotFound, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	recordAdminAction(c, r.auditService, input.UserID, "set_org_member", map[string]string{
+		"org_id":   input.ID,
+		"org_role": membership.Role,
+	})
+
+	return c.JSON(http.StatusOK, membership)
+}
+
+type memberIDInput struct {
+	ID     string `param:"id" validate:"required,uuid"`
+	UserID string `param:"user_id" validate:"required,uuid"`
+}
+
+func (r *orgRoutes) removeMember(c echo.Context) error {
+	var input memberIDInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	err := r.orgService.RemoveMember(c.Request().Context(), input.ID, input.UserID)
+	if err != nil {
+		if errors.Is(err, service.ErrMembershipNotFound) {
+			return newErrorResponse(c, http.StatusNotFound, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	recordAdminAction(c, r.auditService, input.UserID, "remove_org_member", map[string]string{"org_id": input.ID})
+
+	return c.JSON(http.StatusOK, SuccessResponse{Message: "member removed, their sessions of the organization have been signed out"})
+}
+
+func (r *orgRoutes) listSessions(c echo.Context) error {
+	var input orgIDInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	sessions, err := r.orgService.ListSessions(c.Request().Context(), input.ID)
+	if err != nil {
+		if errors.Is(err, service.ErrOrganizationNotFound) {
+			return newErrorResponse(c, http.StatusNotFound, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, sessions)
+}
+
+type memberOrgRoutes struct {
+	orgService service.OrganizationService
+}
+
+// newMemberOrgRoutes registers the organizations of the signed in user and the sessions of the
+// organization their access token was issued for.
+func newMemberOrgRoutes(g *echo.Group, orgService service.OrganizationService, authService service.AuthService) {
+	r := &memberOrgRoutes{
+		orgService: orgService,
+	}
+
+	g.Use(newIdentityMiddleware(authService))
+	g.GET("", r.listOrganizations)
+	g.GET("/sessions", r.listSessions)
+}
+
+func (r *memberOrgRoutes) listOrganizations(c echo.Context) error {
+	memberships, err := r.orgService.ListUserOrganizations(c.Request().Context(), c.Get(userIDCtx).(string))
+	if err != nil {
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, memberships)
+}
+
+func (r *memberOrgRoutes) listSessions(c echo.Context) error {
+	claims := c.Get(tokenClaimsCtx).(*service.TokenClaims)
+	if claims.OrgID == "" {
+		return newErrorResponse(c, http.StatusBadRequest, service.ErrNoActiveOrg)
+	}
+
+	sessions, err := r.orgService.ListMemberSessions(c.Request().Context(), claims.OrgID, claims.UserID)
+	if err != nil {
+		if errors.Is(err, service.ErrNotOrgMember) {
+			return newErrorResponse(c, http.StatusForbidden, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, sessions)
+}
@@ -0,0 +1,129 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	log "github.com/sirupsen/logrus"
+	"math"
+	"medods-tz/internal/service"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	RateLimitKeyIP       = "ip"
+	RateLimitKeyClientID = "client_id"
+	RateLimitKeyUserID   = "user_id"
+)
+
+var errRateLimitExceeded = errors.New("rate limit exceeded")
+
+// RateLimitRule limits requests to one route. Method "*" matches every method,
+// Path is the route as registered in the router, e.g. "/api/v1/auth/refresh".
+type RateLimitRule struct {
+	Method string
+	Path   string
+	KeyBy  string
+	Limit  int
+	Period time.Duration
+	Burst  int
+}
+
+type RateLimitConfig struct {
+	Enabled        bool
+	ClientIDHeader string
+	// Default applies to routes without their own rule, a zero Limit leaves them unlimited.
+	Default RateLimitRule
+	Routes  []RateLimitRule
+}
+
+type rateLimiter struct {
+	rateLimitService service.RateLimitService
+	authService      service.AuthService
+	config           RateLimitConfig
+}
+
+func newRateLimitMiddleware(rateLimitService service.RateLimitService, authService service.AuthService, config RateLimitConfig) echo.MiddlewareFunc {
+	l := &rateLimiter{
+		rateLimitService: rateLimitService,
+		authService:      authService,
+		config:           config,
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			rule, name, ok := l.ruleFor(c)
+			if !ok {
+				return next(c)
+			}
+
+			policy := service.RateLimitPolicy{
+				Name:   name,
+				Limit:  rule.Limit,
+				Period: rule.Period,
+				Burst:  rule.Burst,
+			}
+
+			result, err := l.rateLimitService.Take(c.Request().Context(), policy, l.keyFor(c, rule.KeyBy))
+			if err != nil {
+				// an unavailable limiter store should not take the whole service down with it
+				log.Errorf("error while applying rate limit %s: %v", name, err)
+				return next(c)
+			}
+
+			header := c.Response().Header()
+			header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+			header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d;burst=%d", rule.Limit, ceilSeconds(rule.Period), result.Limit))
+
+			if !result.Allowed {
+				header.Set(echo.HeaderRetryAfter, strconv.Itoa(ceilSeconds(result.RetryAfter)))
+				return newErrorResponse(c, http.StatusTooManyRequests, errRateLimitExceeded)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+func (l *rateLimiter) ruleFor(c echo.Context) (RateLimitRule, string, bool) {
+	method := c.Request().Method
+	for _, rule := range l.config.Routes {
+		if rule.Path == c.Path() && (rule.Method == "*" || strings.EqualFold(rule.Method, method)) {
+			return rule, rule.Method + " " + rule.Path, true
+		}
+	}
+
+	if l.config.Default.Limit > 0 {
+		return l.config.Default, "default", true
+	}
+
+	return RateLimitRule{}, "", false
+}
+
+// keyFor falls back to the client IP when the request has no client ID or valid access token.
+func (l *rateLimiter) keyFor(c echo.Context, keyBy string) string {
+	switch keyBy {
+	case RateLimitKeyClientID:
+		if clientID := c.Request().Header.Get(l.config.ClientIDHeader); clientID != "" {
+			return "client:" + clientID
+		}
+	case RateLimitKeyUserID:
+		accessToken, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+		if ok {
+			if claims, err := l.authService.Authenticate(c.Request().Context(), accessToken); err == nil {
+				return "user:" + claims.UserID
+			}
+		}
+	}
+
+	return "ip:" + c.RealIP()
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
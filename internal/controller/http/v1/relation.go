@@ -0,0 +1,236 @@
+package v1
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/service"
+	"net/http"
+	"strconv"
+)
+
+type relationRoutes struct {
+	relationService service.RelationService
+	auditService    service.AuditService
+}
+
+// newRelationRoutes registers the relation tuple API for downstream services. Writes go on g, which must
+// already be guarded by the admin key middleware, reads on checks, guarded by the check key middleware.
+func newRelationRoutes(g, checks *echo.Group, relationService service.RelationService, auditService service.AuditService) {
+	r := &relationRoutes{
+		relationService: relationService,
+		auditService:    auditService,
+	}
+
+	g.POST("/write", r.writeTuples)
+	checks.POST("/check", r.check)
+	checks.POST("/expand", r.expand)
+	checks.POST("/list-objects", r.listObjects)
+}
+
+// consistencyInput chooses the snapshot a read evaluates, both are tokens of earlier writes or reads.
+type consistencyInput struct {
+	ConsistencyToken string `json:"consistency_token"`
+	AtSnapshot       string `json:"at_snapshot"`
+}
+
+func (i consistencyInput) consistency() service.Consistency {
+	return service.Consistency{AtLeastAsFresh: i.ConsistencyToken, AtSnapshot: i.AtSnapshot}
+}
+
+type writeTuplesInput struct {
+	Writes  []string `json:"writes" validate:"max=1000"`
+	Deletes []string `json:"deletes" validate:"max=1000"`
+}
+
+type consistencyTokenResponse struct {
+	ConsistencyToken string `json:"consistency_token"`
+}
+
+func (r *relationRoutes) writeTuples(c echo.Context) error {
+	var input writeTuplesInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	writes, err := parseRelationTuples(input.Writes)
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+	deletes, err := parseRelationTuples(input.Deletes)
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	ctx := c.Request().Context()
+	token, err := r.relationService.WriteTuples(ctx, writes, deletes)
+	if err != nil {
+		return r.relationErrorResponse(c, err)
+	}
+
+	recordAdminAction(c, r.auditService, "", "write_relation_tuples", map[string]string{
+		"writes":  strconv.Itoa(len(writes)),
+		"deletes": strconv.Itoa(len(deletes)),
+	})
+
+	return c.JSON(http.StatusOK, consistencyTokenResponse{ConsistencyToken: token})
+}
+
+func parseRelationTuples(values []string) ([]entity.RelationTuple, error) {
+	tuples := make([]entity.RelationTuple, 0, len(values))
+	for _, value := range values {
+		tuple, err := service.ParseRelationTuple(value)
+		if err != nil {
+			return nil, err
+		}
+
+		tuples = append(tuples, tuple)
+	}
+
+	return tuples, nil
+}
+
+type checkInput struct {
+	consistencyInput
+	Object   string `json:"object" validate:"required"`
+	Relation string `json:"relation" validate:"required"`
+	Subject  string `json:"subject" validate:"required"`
+}
+
+type checkResponse struct {
+	Allowed          bool   `json:"allowed"`
+	ConsistencyToken string `json:"consistency_token"`
+}
+
+func (r *relationRoutes) check(c echo.Context) error {
+	var input checkInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	userset, err := parseUserset(input.Object, input.Relation)
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+	subject, err := service.ParseRelationSubject(input.Subject)
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	allowed, token, err := r.relationService.Check(c.Request().Context(), userset, subject, input.consistency())
+	if err != nil {
+		return r.relationErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, checkResponse{Allowed: allowed, ConsistencyToken: token})
+}
+
+type expandInput struct {
+	consistencyInput
+	Object   string `json:"object" validate:"required"`
+	Relation string `json:"relation" validate:"required"`
+}
+
+type expandResponse struct {
+	Tree             *service.ExpandNode `json:"tree"`
+	ConsistencyToken string              `json:"consistency_token"`
+}
+
+func (r *relationRoutes) expand(c echo.Context) error {
+	var input expandInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	userset, err := parseUserset(input.Object, input.Relation)
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	tree, token, err := r.relationService.Expand(c.Request().Context(), userset, input.consistency())
+	if err != nil {
+		return r.relationErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, expandResponse{Tree: tree, ConsistencyToken: token})
+}
+
+type listObjectsInput struct {
+	consistencyInput
+	Namespace string `json:"namespace" validate:"required"`
+	Relation  string `json:"relation" validate:"required"`
+	Subject   string `json:"subject" validate:"required"`
+}
+
+type listObjectsResponse struct {
+	Objects          []string `json:"objects"`
+	ConsistencyToken string   `json:"consistency_token"`
+}
+
+func (r *relationRoutes) listObjects(c echo.Context) error {
+	var input listObjectsInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	subject, err := service.ParseRelationSubject(input.Subject)
+	if err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	objectIDs, token, err := r.relationService.ListObjects(c.Request().Context(), input.Namespace, input.Relation, subject, input.consistency())
+	if err != nil {
+		return r.relationErrorResponse(c, err)
+	}
+
+	objects := make([]string, 0, len(objectIDs))
+	for _, objectID := range objectIDs {
+		objects = append(objects, input.Namespace+":"+objectID)
+	}
+
+	return c.JSON(http.StatusOK, listObjectsResponse{Objects: objects, ConsistencyToken: token})
+}
+
+// parseUserset parses an object such as document:readme together with one of its relations.
+func parseUserset(object, relation string) (entity.RelationSubject, error) {
+	return service.ParseRelationSubject(object + "#" + relation)
+}
+
+func (r *relationRoutes) relationErrorResponse(c echo.Context, err error) error {
+	if errors.Is(err, service.ErrRelationsDisabled) {
+		return newErrorResponse(c, http.StatusNotFound, err)
+	}
+	if errors.Is(err, service.ErrUnknownRelation) ||
+		errors.Is(err, service.ErrNoRelationTuples) ||
+		errors.Is(err, service.ErrInvalidConsistencyToken) {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+	if errors.Is(err, service.ErrSnapshotExpired) {
+		return newErrorResponse(c, http.StatusGone, err)
+	}
+	if errors.Is(err, service.ErrRelationDepthExceeded) {
+		return newErrorResponse(c, http.StatusUnprocessableEntity, err)
+	}
+
+	return newErrorResponse(c, http.StatusInternalServerError, err)
+}
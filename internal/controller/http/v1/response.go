@@ -1,9 +1,14 @@
 package v1
 
 import (
+	"errors"
 	"fmt"
 	"github.com/labstack/echo/v4"
+	"medods-tz/internal/service"
+	"medods-tz/pkg/passwordpolicy"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 type SuccessResponse struct {
@@ -11,8 +16,32 @@ type SuccessResponse struct {
 	Content interface{} `json:"content,omitempty"`
 }
 
+// errCodeIPNotAllowed tells clients that the ip rules of the user refused the sign-in,
+// so they can ask the user to connect from an allowed network.
+const errCodeIPNotAllowed = "ip_not_allowed"
+
 type ErrorResponse struct {
 	Error string `json:"error"`
+	// Code is set for errors clients are expected to handle in their own way.
+	Code string `json:"code,omitempty"`
+}
+
+// StepUpRequiredResponse tells the client which challenge the emailed code completes.
+type StepUpRequiredResponse struct {
+	Error       string    `json:"error"`
+	ChallengeID string    `json:"challenge_id"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type ValidationErrorResponse struct {
+	Error  string       `json:"error"`
+	Fields []FieldError `json:"fields"`
 }
 
 func newErrorResponse(c echo.Context, statusCode int, err error) error {
@@ -29,3 +58,60 @@ func newErrorResponse(c echo.Context, statusCode int, err error) error {
 	}
 	return err
 }
+
+func newErrorCodeResponse(c echo.Context, statusCode int, code string, err error) error {
+	errJSON := c.JSON(statusCode, ErrorResponse{Error: err.Error(), Code: code})
+	if errJSON != nil {
+		return fmt.Errorf("error while returning json: %w", errJSON)
+	}
+	return err
+}
+
+// newPasswordPolicyErrorResponse reports every violated password rule separately,
+// so clients can show them next to the field instead of one combined message.
+func newPasswordPolicyErrorResponse(c echo.Context, field string, err error) error {
+	response := ValidationErrorResponse{Error: service.ErrPasswordPolicyViolation.Error()}
+
+	var violationErr *passwordpolicy.ViolationError
+	if errors.As(err, &violationErr) {
+		for _, violation := range violationErr.Violations {
+			response.Fields = append(response.Fields, FieldError{
+				Field:   field,
+				Code:    violation.Code,
+				Message: violation.Message,
+			})
+		}
+	}
+
+	errJSON := c.JSON(http.StatusUnprocessableEntity, response)
+	if errJSON != nil {
+		return fmt.Errorf("error while returning json: %w", errJSON)
+	}
+	return err
+}
+
+func newStepUpRequiredResponse(c echo.Context, err error) error {
+	var stepUpErr *service.StepUpRequiredError
+	if !errors.As(err, &stepUpErr) {
+		return newErrorResponse(c, http.StatusForbidden, err)
+	}
+
+	errJSON := c.JSON(http.StatusForbidden, StepUpRequiredResponse{
+		Error:       err.Error(),
+		ChallengeID: stepUpErr.ChallengeID,
+		ExpiresAt:   stepUpErr.ExpiresAt,
+	})
+	if errJSON != nil {
+		return fmt.Errorf("error while returning json: %w", errJSON)
+	}
+	return err
+}
+
+func newTooManyRequestsResponse(c echo.Context, err error) error {
+	var tooManyAttemptsErr *service.TooManyAttemptsError
+	if errors.As(err, &tooManyAttemptsErr) {
+		c.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(ceilSeconds(tooManyAttemptsErr.RetryAfter)))
+	}
+
+	return newErrorResponse(c, http.StatusTooManyRequests, err)
+}
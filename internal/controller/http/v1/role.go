@@ -0,0 +1,350 @@
+package v1
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/service"
+	"net/http"
+)
+
+type roleRoutes struct {
+	roleService  service.RoleService
+	auditService service.AuditService
+}
+
+// newRoleRoutes registers the roles and permissions admin API on g, which must already be guarded by the
+// admin key middleware, and the permission check for downstream services on checks, guarded by the check
+// key middleware.
+func newRoleRoutes(g, checks *echo.Group, roleService service.RoleService, auditService service.AuditService) {
+	r := &roleRoutes{
+		roleService:  roleService,
+		auditService: auditService,
+	}
+
+	g.GET("/permissions", r.listPermissions)
+	g.POST("/permissions", r.createPermission)
+	checks.POST("/permissions/check", r.checkPermission)
+	g.DELETE("/permissions/:name", r.deletePermission)
+
+	g.GET("/roles", r.listRoles)
+	g.POST("/roles", r.createRole)
+	g.GET("/roles/:id", r.getRole)
+	g.PUT("/roles/:id", r.updateRole)
+	g.DELETE("/roles/:id", r.deleteRole)
+
+	g.GET("/users/:id/roles", r.listUserRoles)
+	g.POST("/users/:id/roles", r.assignRole)
+	g.DELETE("/users/:id/roles/:role_id", r.unassignRole)
+}
+
+func (r *roleRoutes) listPermissions(c echo.Context) error {
+	permissions, err := r.roleService.ListPermissions(c.Request().Context())
+	if err != nil {
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, permissions)
+}
+
+type createPermissionInput struct {
+	Name        string `json:"name" validate:"required,max=128"`
+	Description string `json:"description" validate:"max=255"`
+}
+
+func (r *roleRoutes) createPermission(c echo.Context) error {
+	var input createPermissionInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	permission, err := r.roleService.CreatePermission(c.Request().Context(), entity.Permission{
+		Name:        input.Name,
+		Description: input.Description,
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidPermissionName) {
+			return newErrorResponse(c, http.StatusBadRequest, err)
+		}
+		if errors.Is(err, service.ErrPermissionAlreadyExists) {
+			return newErrorResponse(c, http.StatusConflict, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	recordAdminAction(c, r.auditService, "", "create_permission", map[string]string{"permission": permission.Name})
+
+	return c.JSON(http.StatusCreated, permission)
+}
+
+type permissionNameInput struct {
+	Name string `param:"name" validate:"required,max=128"`
+}
+
+func (r *roleRoutes) deletePermission(c echo.Context) error {
+	var input permissionNameInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	err := r.roleService.DeletePermission(c.Request().Context(), input.Name)
+	if err != nil {
+		if errors.Is(err, service.ErrPermissionNotFound) {
+			return newErrorResponse(c, http.StatusNotFound, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	recordAdminAction(c, r.auditService, "", "delete_permission", map[string]string{"permission": input.Name})
+
+	return c.JSON(http.StatusOK, SuccessResponse{Message: "permission deleted"})
+}
+
+type checkPermissionInput struct {
+	UserID     string `json:"user_id" validate:"required,uuid"`
+	Permission string `json:"permission" validate:"required,max=128"`
+}
+
+type checkPermissionResponse struct {
+	Allowed bool `json:"allowed"`
+}
+
+// checkPermission answers from the current role assignments, unlike the scope of access tokens,
+// which is only as fresh as the last refresh.
+func (r *roleRoutes) checkPermission(c echo.Context) error {
+	var input checkPermissionInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	allowed, err := r.roleService.CheckPermission(c.Request().Context(), input.UserID, input.Permission)
+	if err != nil {
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, checkPermissionResponse{Allowed: allowed})
+}
+
+func (r *roleRoutes) listRoles(c echo.Context) error {
+	roles, err := r.roleService.ListRoles(c.Request().Context())
+	if err != nil {
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, roles)
+}
+
+type roleInput struct {
+	ID          string   `param:"id" validate:"omitempty,uuid"`
+	Name        string   `json:"name" validate:"required,max=64"`
+	Description string   `json:"description" validate:"max=255"`
+	Permissions []string `json:"permissions" validate:"dive,required,max=128"`
+}
+
+func (i roleInput) role() entity.Role {
+	return entity.Role{
+		ID:          i.ID,
+		Name:        i.Name,
+		Description: i.Description,
+		Permissions: i.Permissions,
+	}
+}
+
+type roleIDInput struct {
+	ID string `param:"id" validate:"required,uuid"`
+}
+
+func (r *roleRoutes) createRole(c echo.Context) error {
+	var input roleInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	role, err := r.roleService.CreateRole(c.Request().Context(), input.role())
+	if err != nil {
+		return r.roleErrorResponse(c, err)
+	}
+
+	recordAdminAction(c, r.auditService, "", "create_role", map[string]string{"role_id": role.ID, "role": role.Name})
+
+	return c.JSON(http.StatusCreated, role)
+}
+
+func (r *roleRoutes) getRole(c echo.Context) error {
+	var input roleIDInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	role, err := r.roleService.GetRole(c.Request().Context(), input.ID)
+	if err != nil {
+		if errors.Is(err, service.ErrRoleNotFound) {
+			return newErrorResponse(c, http.StatusNotFound, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, role)
+}
+
+func (r *roleRoutes) updateRole(c echo.Context) error {
+	var input roleInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	role, err := r.roleService.UpdateRole(c.Request().Context(), input.role())
+	if err != nil {
+		return r.roleErrorResponse(c, err)
+	}
+
+	recordAdminAction(c, r.auditService, "", "update_role", map[string]string{"role_id": role.ID, "role": role.Name})
+
+	return c.JSON(http.StatusOK, role)
+}
+
+func (r *roleRoutes) roleErrorResponse(c echo.Context, err error) error {
+	if errors.Is(err, service.ErrInvalidRoleName) || errors.Is(err, service.ErrPermissionNotFound) {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+	if errors.Is(err, service.ErrRoleNotFound) {
+		return newErrorResponse(c, http.StatusNotFound, err)
+	}
+	if errors.Is(err, service.ErrRoleAlreadyExists) {
+		return newErrorResponse(c, http.StatusConflict, err)
+	}
+
+	return newErrorResponse(c, http.StatusInternalServerError, err)
+}
+
+func (r *roleRoutes) deleteRole(c echo.Context) error {
+	var input roleIDInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	err := r.roleService.DeleteRole(c.Request().Context(), input.ID)
+	if err != nil {
+		if errors.Is(err, service.ErrRoleNotFound) {
+			return newErrorResponse(c, http.StatusNotFound, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	recordAdminAction(c, r.auditService, "", "delete_role", map[string]string{"role_id": input.ID})
+
+	return c.JSON(http.StatusOK, SuccessResponse{Message: "role deleted"})
+}
+
+func (r *roleRoutes) listUserRoles(c echo.Context) error {
+	var input userIDInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	roles, err := r.roleService.ListUserRoles(c.Request().Context(), input.UserID)
+	if err != nil {
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, roles)
+}
+
+type userRoleInput struct {
+	UserID string `param:"id" validate:"required,uuid"`
+	RoleID string `json:"role_id" param:"role_id" validate:"required,uuid"`
+}
+
+func (r *roleRoutes) assignRole(c echo.Context) error {
+	var input userRoleInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	err := r.roleService.AssignRole(c.Request().Context(), input.UserID, input.RoleID)
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) || errors.Is(err, service.ErrRoleNotFound) {
+			return newErrorResponse(c, http.StatusNotFound, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	recordAdminAction(c, r.auditService, input.UserID, "assign_role", map[string]string{"role_id": input.RoleID})
+
+	return c.JSON(http.StatusOK, SuccessResponse{Message: "role assigned"})
+}
+
+func (r *roleRoutes) unassignRole(c echo.Context) error {
+	var input userRoleInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	err := r.roleService.UnassignRole(c.Request().Context(), input.UserID, input.RoleID)
+	if err != nil {
+		if errors.Is(err, service.ErrRoleNotFound) {
+			return newErrorResponse(c, http.StatusNotFound, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	recordAdminAction(c, r.auditService, input.UserID, "unassign_role", map[string]string{"role_id": input.RoleID})
+
+	return c.JSON(http.StatusOK, SuccessResponse{Message: "role unassigned"})
+}
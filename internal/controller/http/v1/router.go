@@ -9,17 +9,40 @@ import (
 	"os"
 )
 
-func NewRouter(handler *echo.Echo, service *service.Service, logPath string) {
+func NewRouter(handler *echo.Echo, service *service.Service, logPath string, rateLimits RateLimitConfig, deviceCookie DeviceCookieConfig, adminAPIKey, checkAPIKey string) {
 	handler.Use(middleware.LoggerWithConfig(middleware.LoggerConfig{
 		Format: `{"time":"${time_rfc3339_nano}", "method":"${method}","uri":"${uri}", "status":${status},"error":"${error}"}` + "\n",
 		Output: setLogsFile(logPath),
 	}))
 	handler.Use(middleware.Recover())
+	handler.Use(newRequestInfoMiddleware())
+	if rateLimits.Enabled {
+		handler.Use(newRateLimitMiddleware(service.RateLimitService, service.AuthService, rateLimits))
+	}
 	//handler.GET("/swagger/*", echoSwagger.WrapHandler)
 
 	v1 := handler.Group("/api/v1")
 	{
-		newAuthRoutes(v1.Group("/auth"), service.AuthService)
+		auth := v1.Group("/auth")
+		newAuthRoutes(auth, service.AuthService, service.BruteForceService, deviceCookies{deviceService: service.DeviceService, config: deviceCookie})
+		newAccountRoutes(auth, service.AccountService, service.AuthService)
+		newNotificationRoutes(auth.Group("/notifications"), service.NotificationService, service.AuthService)
+		newDeviceRoutes(auth.Group("/devices"), service.DeviceService, service.AuthService)
+		newMemberOrgRoutes(auth.Group("/orgs"), service.OrganizationService, service.AuthService)
+
+		admin := v1.Group("/admin")
+		newAdminRoutes(admin, service.AuditService, adminAPIKey)
+		newWebhookRoutes(admin.Group("/webhooks"), service.WebhookService, service.AuditService)
+		newEmailRoutes(admin.Group("/emails"), service.EmailService, service.AuditService)
+		newIPRuleRoutes(admin, service.IPRuleService, service.AuditService)
+		newOrgRoutes(admin.Group("/orgs"), service.OrganizationService, service.AuditService)
+		newTokenPolicyRoutes(admin, v1, service.TokenPolicyService, service.AuditService)
+		// the permission and relation checks of downstream services, which hold the check key
+		checks := v1.Group("/admin", newCheckKeyMiddleware(checkAPIKey, adminAPIKey))
+		newRoleRoutes(admin, checks, service.RoleService, service.AuditService)
+		newRelationRoutes(admin.Group("/relations"), checks.Group("/relations"), service.RelationService, service.AuditService)
+		newUserRoutes(v1.Group("/admin/users", newAdminScopeMiddleware(adminAPIKey, adminUsersScope, service.AuthService, service.RoleService, service.UserService)),
+			service.UserService, service.AuditService)
 	}
 }
 
@@ -0,0 +1,240 @@
+package v1
+
+import (
+	"encoding/base64"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/service"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type tokenPolicyRoutes struct {
+	policyService service.TokenPolicyService
+	auditService  service.AuditService
+}
+
+// newTokenPolicyRoutes registers the token policy admin API under /orgs/:id/token-policy, g must already
+// be guarded by the admin key middleware. The public keys of organizations are served on public.
+func newTokenPolicyRoutes(g, public *echo.Group, policyService service.TokenPolicyService, auditService service.AuditService) {
+	r := &tokenPolicyRoutes{
+		policyService: policyService,
+		auditService:  auditService,
+	}
+
+	g.GET("/orgs/:id/token-policy", r.getTokenPolicy)
+	g.PUT("/orgs/:id/token-policy", r.setTokenPolicy)
+	g.POST("/orgs/:id/token-policy/signing-key", r.rotateSigningKey)
+	g.DELETE("/orgs/:id/token-policy/signing-key", r.removeSigningKey)
+	public.GET("/orgs/:id/jwks.json", r.listPublicKeys)
+}
+
+// tokenPolicyResponse is a token policy without its signing keys, durations are in seconds and 0
+// stands for the global setting.
+type tokenPolicyResponse struct {
+	OrgID                string     `json:"org_id"`
+	AccessTokenTTL       int64      `json:"access_token_ttl_seconds"`
+	RefreshTokenTTL      int64      `json:"refresh_token_ttl_seconds"`
+	MaxSessionLifetime   int64      `json:"max_session_lifetime_seconds"`
+	AllowedGrants        []string   `json:"allowed_grants"`
+	RequireMFA           bool       `json:"require_mfa"`
+	DedicatedSigningKey  bool       `json:"dedicated_signing_key"`
+	PreviousKeyExpiresAt *time.Time `json:"previous_key_expires_at,omitempty"`
+}
+
+func newTokenPolicyResponse(policy *entity.TokenPolicy) tokenPolicyResponse {
+	response := tokenPolicyResponse{
+		OrgID:               policy.OrgID,
+		AccessTokenTTL:      int64(policy.AccessTokenTTL / time.Second),
+		RefreshTokenTTL:     int64(policy.RefreshTokenTTL / time.Second),
+		MaxSessionLifetime:  int64(policy.MaxSessionLifetime / time.Second),
+		AllowedGrants:       policy.AllowedGrants,
+		RequireMFA:          policy.RequireMFA,
+		DedicatedSigningKey: policy.SigningKey != "",
+	}
+	if response.AllowedGrants == nil {
+		response.AllowedGrants = []string{}
+	}
+	if policy.PreviousKeyExpiresAt != nil && policy.PreviousKeyExpiresAt.After(time.Now()) {
+		response.PreviousKeyExpiresAt = policy.PreviousKeyExpiresAt
+	}
+
+	return response
+}
+
+func (r *tokenPolicyRoutes) getTokenPolicy(c echo.Context) error {
+	var input orgIDInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	policy, err := r.policyService.GetTokenPolicy(c.Request().Context(), input.ID)
+	if err != nil {
+		if errors.Is(err, service.ErrOrganizationNotFound) {
+			return newErrorResponse(c, http.StatusNotFound, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, newTokenPolicyResponse(policy))
+}
+
+type tokenPolicyInput struct {
+	ID                 string   `param:"id" validate:"required,uuid"`
+	AccessTokenTTL     int64    `json:"access_token_ttl_seconds" validate:"min=0"`
+	RefreshTokenTTL    int64    `json:"refresh_token_ttl_seconds" validate:"min=0"`
+	MaxSessionLifetime int64    `json:"max_session_lifetime_seconds" validate:"min=0"`
+	AllowedGrants      []string `json:"allowed_grants" validate:"max=3,dive,oneof=user_id password refresh_token"`
+	RequireMFA         bool     `json:"require_mfa"`
+}
+
+func (r *tokenPolicyRoutes) setTokenPolicy(c echo.Context) error {
+	var input tokenPolicyInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	policy, err := r.policyService.SetTokenPolicy(c.Request().Context(), entity.TokenPolicy{
+		OrgID:              input.ID,
+		AccessTokenTTL:     time.Duration(input.AccessTokenTTL) * time.Second,
+		RefreshTokenTTL:    time.Duration(input.RefreshTokenTTL) * time.Second,
+		MaxSessionLifetime: time.Duration(input.MaxSessionLifetime) * time.Second,
+		AllowedGrants:      input.AllowedGrants,
+		RequireMFA:         input.RequireMFA,
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidTokenPolicy) {
+			return newErrorResponse(c, http.StatusBadRequest, err)
+		}
+		if errors.Is(err, service.ErrOrganizationNotFound) {
+			return newErrorResponse(c, http.StatusNotFound, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	recordAdminAction(c, r.auditService, "", "set_token_policy", map[string]string{
+		"org_id":      input.ID,
+		"require_mfa": strconv.FormatBool(policy.RequireMFA),
+	})
+
+	return c.JSON(http.StatusOK, newTokenPolicyResponse(policy))
+}
+
+// jsonWebKey is a public signing key in the JSON Web Key format of RFC 8037.
+type jsonWebKey struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	KeyID     string `json:"kid"`
+	X         string `json:"x"`
+}
+
+func newJSONWebKey(key entity.PublicSigningKey) jsonWebKey {
+	return jsonWebKey{
+		KeyType:   "OKP",
+		Curve:     "Ed25519",
+		Algorithm: "EdDSA",
+		Use:       "sig",
+		KeyID:     key.ID,
+		X:         base64.RawURLEncoding.EncodeToString(key.Key),
+	}
+}
+
+type jsonWebKeySetResponse struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+func (r *tokenPolicyRoutes) rotateSigningKey(c echo.Context) error {
+	var input orgIDInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	publicKey, err := r.policyService.RotateSigningKey(c.Request().Context(), input.ID)
+	if err != nil {
+		if errors.Is(err, service.ErrOrganizationNotFound) {
+			return newErrorResponse(c, http.StatusNotFound, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	recordAdminAction(c, r.auditService, "", "rotate_signing_key", map[string]string{"org_id": input.ID, "kid": publicKey.ID})
+
+	return c.JSON(http.StatusOK, newJSONWebKey(*publicKey))
+}
+
+func (r *tokenPolicyRoutes) removeSigningKey(c echo.Context) error {
+	var input orgIDInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	err := r.policyService.RemoveSigningKey(c.Request().Context(), input.ID)
+	if err != nil {
+		if errors.Is(err, service.ErrOrganizationNotFound) {
+			return newErrorResponse(c, http.StatusNotFound, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	recordAdminAction(c, r.auditService, "", "remove_signing_key", map[string]string{"org_id": input.ID})
+
+	return c.JSON(http.StatusOK, SuccessResponse{Message: "signing key removed, access tokens are signed with the global key again"})
+}
+
+// listPublicKeys publishes the keys the access tokens of an organization are verified with, so services
+// can verify them without holding a secret.
+func (r *tokenPolicyRoutes) listPublicKeys(c echo.Context) error {
+	var input orgIDInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	keys, err := r.policyService.PublicSigningKeys(c.Request().Context(), input.ID)
+	if err != nil {
+		if errors.Is(err, service.ErrOrganizationNotFound) {
+			return newErrorResponse(c, http.StatusNotFound, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	response := jsonWebKeySetResponse{Keys: []jsonWebKey{}}
+	for _, key := range keys {
+		response.Keys = append(response.Keys, newJSONWebKey(key))
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
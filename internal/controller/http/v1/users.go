@@ -0,0 +1,240 @@
+package v1
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/service"
+	"net/http"
+	"time"
+)
+
+// adminUsersScope is the permission that lets an access token manage users, next to the admin key.
+const adminUsersScope = "admin:users"
+
+type userRoutes struct {
+	userService  service.UserService
+	auditService service.AuditService
+}
+
+// newUserRoutes registers the user admin API, g must already be guarded by the admin scope middleware.
+func newUserRoutes(g *echo.Group, userService service.UserService, auditService service.AuditService) {
+	r := &userRoutes{
+		userService:  userService,
+		auditService: auditService,
+	}
+
+	g.GET("", r.listUsers)
+	g.POST("", r.createUser)
+	g.GET("/:id", r.getUser)
+	g.PUT("/:id", r.updateUser)
+	g.DELETE("/:id", r.deleteUser)
+}
+
+// userResponse is a user as admins see it, without the password hash.
+type userResponse struct {
+	ID                    string     `json:"id"`
+	Email                 string     `json:"email"`
+	EmailVerifiedAt       *time.Time `json:"email_verified_at,omitempty"`
+	Locale                string     `json:"locale,omitempty"`
+	Status                string     `json:"status"`
+	PasswordSet           bool       `json:"password_set"`
+	PasswordResetRequired bool       `json:"password_reset_required"`
+	CreatedAt             time.Time  `json:"created_at"`
+	UpdatedAt             time.Time  `json:"updated_at"`
+}
+
+func newUserResponse(user *entity.User) userResponse {
+	return userResponse{
+		ID:                    user.ID,
+		Email:                 user.Email,
+		EmailVerifiedAt:       user.EmailVerifiedAt,
+		Locale:                user.Locale,
+		Status:                user.Status,
+		PasswordSet:           user.PasswordHash != "",
+		PasswordResetRequired: user.PasswordResetRequired,
+		CreatedAt:             user.CreatedAt,
+		UpdatedAt:             user.UpdatedAt,
+	}
+}
+
+type listUsersInput struct {
+	Email  string `query:"email" validate:"omitempty,max=255"`
+	Status string `query:"status" validate:"omitempty,oneof=active disabled locked"`
+	Cursor string `query:"cursor"`
+	Limit  int    `query:"limit" validate:"omitempty,min=1,max=200"`
+}
+
+type listUsersResponse struct {
+	Users      []userResponse `json:"users"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+func (r *userRoutes) listUsers(c echo.Context) error {
+	var input listUsersInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	users, nextCursor, err := r.userService.ListUsers(c.Request().Context(), entity.UserFilter{
+		EmailPrefix: input.Email,
+		Status:      input.Status,
+		Limit:       input.Limit,
+	}, input.Cursor)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCursor) || errors.Is(err, service.ErrInvalidUserStatus) {
+			return newErrorResponse(c, http.StatusBadRequest, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	response := listUsersResponse{Users: make([]userResponse, 0, len(users)), NextCursor: nextCursor}
+	for i := range users {
+		response.Users = append(response.Users, newUserResponse(&users[i]))
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+type createUserInput struct {
+	Email         string `json:"email" validate:"required,email,max=255"`
+	Password      string `json:"password" validate:"omitempty,max=1024"`
+	Locale        string `json:"locale" validate:"omitempty,bcp47_language_tag"`
+	Status        string `json:"status" validate:"omitempty,oneof=active disabled locked"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+func (r *userRoutes) createUser(c echo.Context) error {
+	var input createUserInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	user := entity.User{
+		Email:  input.Email,
+		Locale: input.Locale,
+		Status: input.Status,
+	}
+	if input.EmailVerified {
+		verifiedAt := time.Now()
+		user.EmailVerifiedAt = &verifiedAt
+	}
+
+	created, err := r.userService.CreateUser(c.Request().Context(), user, input.Password)
+	if err != nil {
+		if errors.Is(err, service.ErrPasswordPolicyViolation) || errors.Is(err, service.ErrInvalidUserStatus) {
+			return newErrorResponse(c, http.StatusBadRequest, err)
+		}
+		if errors.Is(err, service.ErrUserAlreadyExists) {
+			return newErrorResponse(c, http.StatusConflict, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	recordAdminAction(c, r.auditService, created.ID, "create_user", map[string]string{"status": created.Status})
+
+	return c.JSON(http.StatusCreated, newUserResponse(created))
+}
+
+func (r *userRoutes) getUser(c echo.Context) error {
+	var input userIDInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	user, err := r.userService.GetUser(c.Request().Context(), input.UserID)
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			return newErrorResponse(c, http.StatusNotFound, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+type updateUserInput struct {
+	ID     string `param:"id" validate:"required,uuid"`
+	Email  string `json:"email" validate:"required,email,max=255"`
+	Locale string `json:"locale" validate:"omitempty,bcp47_language_tag"`
+	Status string `json:"status" validate:"required,oneof=active disabled locked"`
+}
+
+func (r *userRoutes) updateUser(c echo.Context) error {
+	var input updateUserInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	user, err := r.userService.UpdateUser(c.Request().Context(), entity.User{
+		ID:     input.ID,
+		Email:  input.Email,
+		Locale: input.Locale,
+		Status: input.Status,
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidUserStatus) {
+			return newErrorResponse(c, http.StatusBadRequest, err)
+		}
+		if errors.Is(err, service.ErrUserNotFound) {
+			return newErrorResponse(c, http.StatusNotFound, err)
+		}
+		if errors.Is(err, service.ErrUserAlreadyExists) {
+			return newErrorResponse(c, http.StatusConflict, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	recordAdminAction(c, r.auditService, user.ID, "update_user", map[string]string{"status": user.Status})
+
+	return c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+func (r *userRoutes) deleteUser(c echo.Context) error {
+	var input userIDInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	err := r.userService.DeleteUser(c.Request().Context(), input.UserID)
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			return newErrorResponse(c, http.StatusNotFound, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	recordAdminAction(c, r.auditService, input.UserID, "delete_user", map[string]string{})
+
+	return c.JSON(http.StatusOK, SuccessResponse{Message: "user deleted"})
+}
@@ -0,0 +1,251 @@
+package v1
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/service"
+	"net/http"
+	"strconv"
+)
+
+type webhookRoutes struct {
+	webhookService service.WebhookService
+	auditService   service.AuditService
+}
+
+// newWebhookRoutes registers the webhook admin API, g must already be guarded by the admin key middleware.
+func newWebhookRoutes(g *echo.Group, webhookService service.WebhookService, auditService service.AuditService) {
+	r := &webhookRoutes{
+		webhookService: webhookService,
+		auditService:   auditService,
+	}
+
+	g.POST("", r.createSubscription)
+	g.GET("", r.listSubscriptions)
+	g.GET("/deliveries", r.listDeliveries)
+	g.POST("/deliveries/:id/redeliver", r.redeliver)
+	g.GET("/:id", r.getSubscription)
+	g.PUT("/:id", r.updateSubscription)
+	g.DELETE("/:id", r.deleteSubscription)
+}
+
+type webhookSubscriptionInput struct {
+	ID         string   `param:"id" validate:"omitempty,uuid"`
+	URL        string   `json:"url" validate:"required,url"`
+	EventTypes []string `json:"event_types" validate:"required,min=1"`
+	Secret     string   `json:"secret" validate:"omitempty,min=16,max=255"`
+	Enabled    *bool    `json:"enabled"`
+}
+
+func (i webhookSubscriptionInput) subscription() entity.WebhookSubscription {
+	enabled := true
+	if i.Enabled != nil {
+		enabled = *i.Enabled
+	}
+
+	return entity.WebhookSubscription{
+		ID:         i.ID,
+		URL:        i.URL,
+		EventTypes: i.EventTypes,
+		Secret:     i.Secret,
+		Enabled:    enabled,
+	}
+}
+
+type webhookIDInput struct {
+	ID string `param:"id" validate:"required,uuid"`
+}
+
+func (r *webhookRoutes) createSubscription(c echo.Context) error {
+	var input webhookSubscriptionInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	ctx := c.Request().Context()
+	subscription, err := r.webhookService.CreateSubscription(ctx, input.subscription())
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidWebhookURL) || errors.Is(err, service.ErrUnknownWebhookEventType) {
+			return newErrorResponse(c, http.StatusBadRequest, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	recordAdminAction(c, r.auditService, "", "create_webhook", map[string]string{"webhook_id": subscription.ID, "url": subscription.URL})
+
+	return c.JSON(http.StatusCreated, subscription)
+}
+
+func (r *webhookRoutes) listSubscriptions(c echo.Context) error {
+	subscriptions, err := r.webhookService.ListSubscriptions(c.Request().Context())
+	if err != nil {
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	if subscriptions == nil {
+		subscriptions = []entity.WebhookSubscription{}
+	}
+
+	return c.JSON(http.StatusOK, map[string][]entity.WebhookSubscription{"webhooks": subscriptions})
+}
+
+func (r *webhookRoutes) getSubscription(c echo.Context) error {
+	var input webhookIDInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	subscription, err := r.webhookService.GetSubscription(c.Request().Context(), input.ID)
+	if err != nil {
+		if errors.Is(err, service.ErrWebhookNotFound) {
+			return newErrorResponse(c, http.StatusNotFound, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, subscription)
+}
+
+func (r *webhookRoutes) updateSubscription(c echo.Context) error {
+	var input webhookSubscriptionInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	subscription, err := r.webhookService.UpdateSubscription(c.Request().Context(), input.subscription())
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrWebhookNotFound):
+			return newErrorResponse(c, http.StatusNotFound, err)
+		case errors.Is(err, service.ErrInvalidWebhookURL), errors.Is(err, service.ErrUnknownWebhookEventType):
+			return newErrorResponse(c, http.StatusBadRequest, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	recordAdminAction(c, r.auditService, "", "update_webhook", map[string]string{
+		"webhook_id":     subscription.ID,
+		"url":            subscription.URL,
+		"secret_rotated": strconv.FormatBool(input.Secret != ""),
+	})
+
+	return c.JSON(http.StatusOK, subscription)
+}
+
+func (r *webhookRoutes) deleteSubscription(c echo.Context) error {
+	var input webhookIDInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	err := r.webhookService.DeleteSubscription(c.Request().Context(), input.ID)
+	if err != nil {
+		if errors.Is(err, service.ErrWebhookNotFound) {
+			return newErrorResponse(c, http.StatusNotFound, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	recordAdminAction(c, r.auditService, "", "delete_webhook", map[string]string{"webhook_id": input.ID})
+
+	return c.JSON(http.StatusOK, SuccessResponse{Message: "webhook deleted"})
+}
+
+type listWebhookDeliveriesInput struct {
+	SubscriptionID string `query:"subscription_id" validate:"omitempty,uuid"`
+	Status         string `query:"status" validate:"omitempty,oneof=pending delivered dead"`
+	Cursor         string `query:"cursor"`
+	Limit          int    `query:"limit" validate:"omitempty,min=1,max=200"`
+}
+
+type listWebhookDeliveriesResponse struct {
+	Deliveries []entity.WebhookDelivery `json:"deliveries"`
+	NextCursor string                   `json:"next_cursor,omitempty"`
+}
+
+func (r *webhookRoutes) listDeliveries(c echo.Context) error {
+	var input listWebhookDeliveriesInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	filter := entity.WebhookDeliveryFilter{
+		SubscriptionID: input.SubscriptionID,
+		Status:         input.Status,
+		Limit:          input.Limit,
+	}
+
+	deliveries, nextCursor, err := r.webhookService.ListDeliveries(c.Request().Context(), filter, input.Cursor)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCursor) {
+			return newErrorResponse(c, http.StatusBadRequest, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	if deliveries == nil {
+		deliveries = []entity.WebhookDelivery{}
+	}
+
+	return c.JSON(http.StatusOK, listWebhookDeliveriesResponse{Deliveries: deliveries, NextCursor: nextCursor})
+}
+
+type redeliverWebhookInput struct {
+	ID int64 `param:"id" validate:"required,min=1"`
+}
+
+func (r *webhookRoutes) redeliver(c echo.Context) error {
+	var input redeliverWebhookInput
+
+	if err := c.Bind(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return newErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	err := r.webhookService.Redeliver(c.Request().Context(), input.ID)
+	if err != nil {
+		if errors.Is(err, service.ErrWebhookDeliveryNotFound) {
+			return newErrorResponse(c, http.StatusNotFound, err)
+		}
+
+		return newErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	recordAdminAction(c, r.auditService, "", "redeliver_webhook", map[string]string{"delivery_id": strconv.FormatInt(input.ID, 10)})
+
+	return c.JSON(http.StatusOK, SuccessResponse{Message: "webhook delivery queued"})
+}
@@ -0,0 +1,10 @@
+package entity
+
+import "time"
+
+// AccountUnlock is a locked account the user unlocked with the link of an unlock email.
+type AccountUnlock struct {
+	TokenID    string
+	UserID     string
+	UnlockedAt time.Time
+}
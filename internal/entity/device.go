@@ -0,0 +1,38 @@
+package entity
+
+import "time"
+
+// Device is a browser or app that signed in to the account, recognized by the device cookie or the
+// device ID the client sends.
+type Device struct {
+	ID     string `json:"id"`
+	UserID string `json:"-"`
+	// KeyHash is the SHA-256 of the device key, the key itself is only known to the client.
+	KeyHash string `json:"-"`
+	// Name is given by the user, it is empty until then.
+	Name       string    `json:"name"`
+	Browser    string    `json:"browser"`
+	OS         string    `json:"os"`
+	UserAgent  string    `json:"user_agent"`
+	LastIP     string    `json:"last_ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+// Description is the name of the device, or its browser and OS for unnamed devices.
+func (d Device) Description() string {
+	if d.Name != "" {
+		return d.Name
+	}
+
+	switch {
+	case d.Browser != "" && d.OS != "":
+		return d.Browser + " on " + d.OS
+	case d.Browser != "":
+		return d.Browser
+	case d.OS != "":
+		return d.OS
+	}
+
+	return d.UserAgent
+}
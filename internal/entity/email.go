@@ -0,0 +1,38 @@
+package entity
+
+import "time"
+
+const (
+	EmailPending = "pending"
+	EmailSent    = "sent"
+	// EmailFailed is a message that ran out of attempts, it stays until it is retried.
+	EmailFailed = "failed"
+)
+
+// EmailMessage is a row of the email outbox. Besides emails it holds notifications for the other
+// channels, their Recipient is the address in the channel.
+type EmailMessage struct {
+	ID            int64      `json:"id"`
+	Kind          string     `json:"kind"`
+	Channel       string     `json:"channel"`
+	Recipient     string     `json:"recipient"`
+	Subject       string     `json:"subject"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	LastError     string     `json:"last_error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+
+	// The bodies may contain single-use links, so they are never exposed and are cleared once the message is sent.
+	TextBody string `json:"-"`
+	HTMLBody string `json:"-"`
+}
+
+type EmailMessageFilter struct {
+	Channel   string
+	Status    string
+	Recipient string
+	BeforeID  int64
+	Limit     int
+}
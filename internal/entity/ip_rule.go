@@ -0,0 +1,23 @@
+package entity
+
+import "time"
+
+// IP rule actions.
+const (
+	IPRuleAllow = "allow"
+	IPRuleDeny  = "deny"
+)
+
+// IPRule limits the addresses a user, or the members of an organization, may sign in from. Deny rules
+// win over allow rules, and once there is an allow rule, addresses outside all of them are refused.
+// A rule has either UserID or OrgID set.
+type IPRule struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id,omitempty"`
+	OrgID  string `json:"org_id,omitempty"`
+	Action string `json:"action"`
+	// CIDR is an IPv4 or IPv6 range, single addresses are stored as /32 or /128.
+	CIDR        string    `json:"cidr"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,10 @@
+package entity
+
+import "time"
+
+type LoginAttempts struct {
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+}
@@ -0,0 +1,11 @@
+package entity
+
+import "time"
+
+// LoginReport is a sign-in the user reported as not theirs with the link of a suspicious login email.
+type LoginReport struct {
+	TokenID    string
+	UserID     string
+	IP         string
+	ReportedAt time.Time
+}
@@ -0,0 +1,44 @@
+package entity
+
+import "time"
+
+// Notification severities, from the lowest.
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// SeverityRank orders severities, unknown ones rank below info.
+func SeverityRank(severity string) int {
+	switch severity {
+	case SeverityInfo:
+		return 1
+	case SeverityWarning:
+		return 2
+	case SeverityCritical:
+		return 3
+	}
+
+	return 0
+}
+
+// NotificationPreference routes the user's security notifications of at least MinSeverity to a channel.
+type NotificationPreference struct {
+	Channel string `json:"channel"`
+	// Address in the channel, empty for email, which always goes to the user's address.
+	Address     string    `json:"address,omitempty"`
+	MinSeverity string    `json:"min_severity"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// NotificationDigestItem is a notification held back during a burst, to be sent in the next digest.
+type NotificationDigestItem struct {
+	ID       int64
+	UserID   string
+	Template string
+	Severity string
+	// Subject is rendered in the user's locale when the notification is held back.
+	Subject   string
+	CreatedAt time.Time
+}
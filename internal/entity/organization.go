@@ -0,0 +1,32 @@
+package entity
+
+import "time"
+
+// Membership roles. Owners and admins manage the organization and see the sessions of all members.
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+)
+
+// Organization is a tenant. Sessions issued for it carry its ID, and row-level security keeps the
+// rows of one organization away from queries made for another.
+type Organization struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Membership struct {
+	OrgID string `json:"org_id"`
+	// OrgName is only filled when the memberships of a user are listed.
+	OrgName   string    `json:"org_name,omitempty"`
+	UserID    string    `json:"user_id"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ManagesOrg tells whether the member may manage the organization.
+func (m Membership) ManagesOrg() bool {
+	return m.Role == OrgRoleOwner || m.Role == OrgRoleAdmin
+}
@@ -0,0 +1,19 @@
+package entity
+
+import "time"
+
+type PasswordResetToken struct {
+	ID        string
+	UserID    string
+	TokenHash string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+// PasswordResetRequest is a forgotten password request waiting to be looked up and answered with a reset link.
+type PasswordResetRequest struct {
+	ID        int64
+	Email     string
+	CreatedAt time.Time
+}
@@ -0,0 +1,11 @@
+package entity
+
+import "time"
+
+// RateLimitBucket is the state of a token bucket after a request took (or failed to take) a token.
+type RateLimitBucket struct {
+	Key       string
+	Tokens    float64
+	Allowed   bool
+	UpdatedAt time.Time
+}
@@ -0,0 +1,34 @@
+package entity
+
+// RelationSubject is an object, such as user:7c452d37-..., or with Relation set the userset of a
+// relation of an object, such as group:eng#member.
+type RelationSubject struct {
+	Namespace string
+	ObjectID  string
+	Relation  string
+}
+
+func (s RelationSubject) String() string {
+	if s.Relation == "" {
+		return s.Namespace + ":" + s.ObjectID
+	}
+
+	return s.Namespace + ":" + s.ObjectID + "#" + s.Relation
+}
+
+// RelationTuple grants the relation of an object to a subject, written as document:readme#viewer@user:alice.
+type RelationTuple struct {
+	Namespace string
+	ObjectID  string
+	Relation  string
+	Subject   RelationSubject
+}
+
+// Userset is the object and relation of the tuple, document:readme#viewer.
+func (t RelationTuple) Userset() RelationSubject {
+	return RelationSubject{Namespace: t.Namespace, ObjectID: t.ObjectID, Relation: t.Relation}
+}
+
+func (t RelationTuple) String() string {
+	return t.Userset().String() + "@" + t.Subject.String()
+}
@@ -0,0 +1,19 @@
+package entity
+
+import "time"
+
+// Permission is an action downstream services guard, named like "documents:write".
+type Permission struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Role is a named set of permissions assigned to users.
+type Role struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Permissions []string  `json:"permissions"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,21 @@
+package entity
+
+import "time"
+
+// StepUpChallenge holds back the tokens of a risky sign-in until the user enters the code emailed to them.
+type StepUpChallenge struct {
+	ID       string
+	UserID   string
+	CodeHash string
+	ClientIP string
+	// Operation is the risk operation that was challenged.
+	Operation string
+	// RefreshTokenID is the session being refreshed, it is spent when the challenge is passed.
+	RefreshTokenID *string
+	// OrgID is the organization a new session is started for.
+	OrgID     *string
+	Attempts  int
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
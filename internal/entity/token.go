@@ -1,6 +1,9 @@
 package entity
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 type RefreshToken struct {
 	ID           string
@@ -8,12 +11,56 @@ type RefreshToken struct {
 	RefreshHash  string
 	AccessExpiry time.Time
 	IssuedAt     time.Time
-	ExpiresAt    time.Time
-	ClientIP     string
-	Used         bool
+	// SessionStartedAt is when the session signed in, refreshes keep it.
+	SessionStartedAt time.Time
+	ExpiresAt        time.Time
+	ClientIP         string
+	Location         GeoLocation
+	// DeviceID is the device the session was issued to, nil for sessions older than device recognition.
+	DeviceID *string
+	// OrgID is the organization the session was issued for, nil for personal sessions.
+	OrgID     *string
+	Used      bool
+	RevokedAt *time.Time
+}
+
+// Session is a refresh token as it is shown in session listings.
+type Session struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	OrgID     string    `json:"org_id,omitempty"`
+	DeviceID  *string   `json:"device_id,omitempty"`
+	ClientIP  string    `json:"client_ip"`
+	Location  string    `json:"location,omitempty"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// GeoLocation is where a session was created from, resolved offline from its IP address.
+// Fields are empty when the address is unknown to the GeoIP databases.
+type GeoLocation struct {
+	Country        string
+	City           string
+	ASN            uint
+	Latitude       *float64
+	Longitude      *float64
+	AccuracyRadius int
+}
+
+func (l GeoLocation) String() string {
+	var parts []string
+	for _, part := range []string{l.City, l.Country} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	return strings.Join(parts, ", ")
 }
 
 type Tokens struct {
 	AccessToken  string `json:"access_token" validate:"required,jwt"`
 	RefreshToken string `json:"refresh_token" validate:"required"`
+	// DeviceKey identifies the device of the session, it goes to the device cookie and never into the body.
+	DeviceKey string `json:"-"`
 }
@@ -0,0 +1,59 @@
+package entity
+
+import (
+	"crypto/ed25519"
+	"time"
+)
+
+// Grant types, the ways a session can be issued or extended.
+const (
+	GrantUserID       = "user_id"
+	GrantPassword     = "password"
+	GrantRefreshToken = "refresh_token"
+)
+
+// TokenPolicy is how the sessions of an organization are issued. Zero durations fall back to the
+// global settings, and an empty list of grants allows all of them.
+type TokenPolicy struct {
+	OrgID           string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// MaxSessionLifetime ends a session this long after its sign-in however often it is refreshed,
+	// zero for no limit.
+	MaxSessionLifetime time.Duration
+	AllowedGrants      []string
+	// RequireMFA holds back every new session until the user enters a code emailed to them.
+	RequireMFA bool
+	// SigningKey is the Ed25519 private key the access tokens of the organization are signed with instead
+	// of the global key, empty for none. Only its public key ever leaves the service.
+	SigningKey string
+	// PreviousSigningKey still verifies access tokens until PreviousKeyExpiresAt, so sessions can be
+	// refreshed after a rotation. An empty previous key with an expiry stands for the global key.
+	PreviousSigningKey   string
+	PreviousKeyExpiresAt *time.Time
+	UpdatedAt            time.Time
+}
+
+// PublicSigningKey is a public key the access tokens of an organization are verified with, ID is the kid
+// header of the tokens it verifies.
+type PublicSigningKey struct {
+	ID  string
+	Key ed25519.PublicKey
+	// ExpiresAt is when a replaced key stops verifying access tokens, nil for the current key.
+	ExpiresAt *time.Time
+}
+
+// AllowsGrant tells whether sessions may be issued or extended with grant.
+func (p TokenPolicy) AllowsGrant(grant string) bool {
+	if len(p.AllowedGrants) == 0 {
+		return true
+	}
+
+	for _, allowed := range p.AllowedGrants {
+		if allowed == grant {
+			return true
+		}
+	}
+
+	return false
+}
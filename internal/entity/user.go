@@ -2,9 +2,32 @@ package entity
 
 import "time"
 
+// User statuses. Locked users keep their sessions but cannot start new ones, disabled users lose
+// their sessions too.
+const (
+	UserStatusActive   = "active"
+	UserStatusDisabled = "disabled"
+	UserStatusLocked   = "locked"
+)
+
 type User struct {
-	ID        string
-	Email     string
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID              string
+	Email           string
+	PasswordHash    string
+	EmailVerifiedAt *time.Time
+	Locale          string // of the user's emails, empty for the default one
+	// PasswordResetRequired refuses password logins until the password is reset.
+	PasswordResetRequired bool
+	Status                string
+	CreatedAt             time.Time
+	UpdatedAt             time.Time
+}
+
+type UserFilter struct {
+	// EmailPrefix matches emails starting with it, ignoring case.
+	EmailPrefix string
+	Status      string
+	// AfterEmail returns users whose email sorts after the given one, it is how cursors are resolved.
+	AfterEmail string
+	Limit      int
 }
@@ -0,0 +1,64 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook event types are part of the public contract with subscribers, unlike audit event types.
+const (
+	WebhookSessionRevoked   = "session.revoked"
+	WebhookSessionIPChanged = "session.ip_changed"
+
+	// WebhookAllEvents subscribes to every event type, including ones added later.
+	WebhookAllEvents = "*"
+)
+
+var WebhookEventTypes = []string{
+	WebhookSessionRevoked,
+	WebhookSessionIPChanged,
+}
+
+type WebhookSubscription struct {
+	ID         string    `json:"id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	Secret     string    `json:"secret,omitempty"`
+	Enabled    bool      `json:"enabled"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	// WebhookDeliveryDead is a delivery that ran out of attempts, it stays until it is redelivered.
+	WebhookDeliveryDead = "dead"
+)
+
+// WebhookDelivery is a row of the webhook outbox: one event for one subscription.
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	SubscriptionID string          `json:"subscription_id"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastError      string          `json:"last_error,omitempty"`
+	LastStatusCode int             `json:"last_status_code,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+
+	// URL and Secret of the subscription, filled in when the delivery is claimed by the worker.
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+type WebhookDeliveryFilter struct {
+	SubscriptionID string
+	Status         string
+	BeforeID       int64
+	Limit          int
+}
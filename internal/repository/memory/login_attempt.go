@@ -0,0 +1,92 @@
+package memory
+
+import (
+	"context"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository/repoerrors"
+	"sync"
+	"time"
+)
+
+// sweepEvery is how many increments pass between removals of stale keys.
+const sweepEvery = 1000
+
+// LoginAttemptMemory keeps counters in process memory. It is meant for a single replica,
+// counters are not shared and do not survive restarts.
+type LoginAttemptMemory struct {
+	mu         sync.Mutex
+	attempts   map[string]entity.LoginAttempts
+	increments int
+}
+
+func NewLoginAttemptMemory() *LoginAttemptMemory {
+	return &LoginAttemptMemory{attempts: map[string]entity.LoginAttempts{}}
+}
+
+func (m *LoginAttemptMemory) GetLoginAttempts(_ context.Context, key string) (*entity.LoginAttempts, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	attempts, ok := m.attempts[key]
+	if !ok {
+		return nil, repoerrors.ErrNotFound
+	}
+
+	return &attempts, nil
+}
+
+func (m *LoginAttemptMemory) IncrementLoginFailures(_ context.Context, key string, at time.Time, window time.Duration) (*entity.LoginAttempts, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.increments++
+	if m.increments%sweepEvery == 0 {
+		m.sweep(at, window)
+	}
+
+	attempts, ok := m.attempts[key]
+	if !ok || isStale(attempts, at, window) {
+		attempts = entity.LoginAttempts{Key: key}
+	}
+	attempts.Failures++
+	attempts.LastFailureAt = at
+	m.attempts[key] = attempts
+
+	return &attempts, nil
+}
+
+func (m *LoginAttemptMemory) LockLoginAttempts(_ context.Context, key string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	attempts, ok := m.attempts[key]
+	if !ok {
+		return repoerrors.ErrNotFound
+	}
+	attempts.LockedUntil = &until
+	m.attempts[key] = attempts
+
+	return nil
+}
+
+func (m *LoginAttemptMemory) DeleteLoginAttempts(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.attempts, key)
+
+	return nil
+}
+
+func (m *LoginAttemptMemory) sweep(at time.Time, window time.Duration) {
+	for key, attempts := range m.attempts {
+		if isStale(attempts, at, window) {
+			delete(m.attempts, key)
+		}
+	}
+}
+
+func isStale(attempts entity.LoginAttempts, at time.Time, window time.Duration) bool {
+	return attempts.LastFailureAt.Before(at.Add(-window)) &&
+		(attempts.LockedUntil == nil || attempts.LockedUntil.Before(at))
+}
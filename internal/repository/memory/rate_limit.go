@@ -0,0 +1,81 @@
+package memory
+
+import (
+	"context"
+	"medods-tz/internal/entity"
+	"sync"
+	"time"
+)
+
+type rateLimitBucket struct {
+	entity.RateLimitBucket
+	capacity        float64
+	refillPerSecond float64
+}
+
+// full reports whether the bucket has refilled completely by at, so dropping it changes nothing.
+func (b rateLimitBucket) full(at time.Time) bool {
+	return b.Tokens+at.Sub(b.UpdatedAt).Seconds()*b.refillPerSecond >= b.capacity
+}
+
+type RateLimitMemory struct {
+	mu      sync.Mutex
+	buckets map[string]rateLimitBucket
+	takes   int
+}
+
+func NewRateLimitMemory() *RateLimitMemory {
+	return &RateLimitMemory{buckets: map[string]rateLimitBucket{}}
+}
+
+func (m *RateLimitMemory) TakeRateLimitToken(_ context.Context, key string, capacity, refillPerSecond float64, at time.Time) (*entity.RateLimitBucket, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.takes++
+	if m.takes%sweepEvery == 0 {
+		m.sweep(at)
+	}
+
+	bucket, ok := m.buckets[key]
+	if !ok {
+		bucket = rateLimitBucket{RateLimitBucket: entity.RateLimitBucket{Key: key, Tokens: capacity, UpdatedAt: at}}
+	}
+	bucket.capacity = capacity
+	bucket.refillPerSecond = refillPerSecond
+
+	elapsed := max(at.Sub(bucket.UpdatedAt).Seconds(), 0)
+	bucket.Tokens = min(capacity, bucket.Tokens+elapsed*refillPerSecond)
+	bucket.Allowed = bucket.Tokens >= 1
+	if bucket.Allowed {
+		bucket.Tokens--
+	}
+	if at.After(bucket.UpdatedAt) {
+		bucket.UpdatedAt = at
+	}
+	m.buckets[key] = bucket
+
+	result := bucket.RateLimitBucket
+	return &result, nil
+}
+
+func (m *RateLimitMemory) sweep(at time.Time) {
+	for key, bucket := range m.buckets {
+		if bucket.full(at) {
+			delete(m.buckets, key)
+		}
+	}
+}
+
+func (m *RateLimitMemory) PruneRateLimitBuckets(_ context.Context, before time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, bucket := range m.buckets {
+		if bucket.UpdatedAt.Before(before) {
+			delete(m.buckets, key)
+		}
+	}
+
+	return nil
+}
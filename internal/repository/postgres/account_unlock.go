@@ -0,0 +1,34 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgconn"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository/repoerrors"
+)
+
+type AccountUnlockPostgres struct {
+	*DB
+}
+
+func NewAccountUnlockPostgres(db *DB) *AccountUnlockPostgres {
+	return &AccountUnlockPostgres{DB: db}
+}
+
+// CreateAccountUnlock returns repoerrors.ErrAlreadyExists when the link was used before.
+func (p *AccountUnlockPostgres) CreateAccountUnlock(ctx context.Context, unlock entity.AccountUnlock) error {
+	query := `INSERT INTO account_unlocks (token_id, user_id, unlocked_at) VALUES ($1, $2, $3)`
+
+	_, err := p.Exec(ctx, query, unlock.TokenID, unlock.UserID, unlock.UnlockedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return repoerrors.ErrAlreadyExists
+		}
+
+		return err
+	}
+
+	return nil
+}
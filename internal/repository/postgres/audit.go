@@ -0,0 +1,287 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository/repoerrors"
+	"strings"
+	"time"
+)
+
+type AuditPostgres struct {
+	*DB
+}
+
+func NewAuditPostgres(db *DB) *AuditPostgres {
+	return &AuditPostgres{DB: db}
+}
+
+// auditChainLockID serializes appends to the audit chain across all replicas.
+const auditChainLockID = 7242001
+
+// CreateAuditEvent appends the event to the hash chain of its day. PrevHash and Hash are set here,
+// under an advisory lock, so concurrent writers cannot fork the chain.
+func (p *AuditPostgres) CreateAuditEvent(ctx context.Context, event entity.AuditEvent) (int64, error) {
+	// stored with microsecond precision and without a time zone, hashed exactly as stored
+	event.CreatedAt = event.CreatedAt.UTC().Truncate(time.Microsecond)
+
+	metadata, err := json.Marshal(event.Metadata)
+	if err != nil {
+		return 0, err
+	}
+	if event.Metadata == nil {
+		metadata = []byte("{}")
+	}
+
+	tx, err := p.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, auditChainLockID)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.QueryRow(ctx, `SELECT hash FROM audit_events WHERE chain_day = $1 ORDER BY id DESC LIMIT 1`,
+		event.ChainDay()).Scan(&event.PrevHash)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return 0, err
+	}
+	event.Hash = event.ComputeHash()
+
+	query := `INSERT INTO audit_events (type, actor_id, subject_id, ip, user_agent, metadata, created_at, chain_day, prev_hash, hash)
+				VALUES($1, $2, $3, $4, $5, $6::jsonb, $7, $8, $9, $10) RETURNING id`
+
+	var id int64
+	err = tx.QueryRow(ctx, query,
+		event.Type,
+		event.ActorID,
+		event.SubjectID,
+		event.IP,
+		event.UserAgent,
+		string(metadata),
+		event.CreatedAt,
+		event.ChainDay(),
+		event.PrevHash,
+		event.Hash,
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, tx.Commit(ctx)
+}
+
+func (p *AuditPostgres) ListAuditEvents(ctx context.Context, filter entity.AuditFilter) ([]entity.AuditEvent, error) {
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.UserID != "" {
+		args = append(args, filter.UserID)
+		conditions = append(conditions, fmt.Sprintf("(subject_id = $%d OR actor_id = $%d)", len(args), len(args)))
+	}
+	if len(filter.Types) > 0 {
+		addCondition("type = ANY($%d)", filter.Types)
+	}
+	if filter.From != nil {
+		addCondition("created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		addCondition("created_at < $%d", *filter.To)
+	}
+	if filter.BeforeID > 0 {
+		addCondition("id < $%d", filter.BeforeID)
+	}
+
+	query := `SELECT ` + auditEventColumns + ` FROM audit_events`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	return p.queryAuditEvents(ctx, query, args...)
+}
+
+// ListAuditChain returns events in chain order, starting after the given id.
+func (p *AuditPostgres) ListAuditChain(ctx context.Context, afterID int64, limit int) ([]entity.AuditEvent, error) {
+	query := `SELECT ` + auditEventColumns + ` FROM audit_events WHERE id > $1 ORDER BY id LIMIT $2`
+
+	return p.queryAuditEvents(ctx, query, afterID, limit)
+}
+
+const auditEventColumns = `id, type, actor_id, subject_id, ip, user_agent, metadata::text, created_at, prev_hash, hash`
+
+func (p *AuditPostgres) queryAuditEvents(ctx context.Context, query string, args ...interface{}) ([]entity.AuditEvent, error) {
+	rows, err := p.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []entity.AuditEvent
+	for rows.Next() {
+		var event entity.AuditEvent
+		var metadata string
+		err := rows.Scan(
+			&event.ID,
+			&event.Type,
+			&event.ActorID,
+			&event.SubjectID,
+			&event.IP,
+			&event.UserAgent,
+			&metadata,
+			&event.CreatedAt,
+			&event.PrevHash,
+			&event.Hash)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal([]byte(metadata), &event.Metadata)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// ListUncheckpointedAuditDays returns days before the given one that have events but no checkpoint yet.
+func (p *AuditPostgres) ListUncheckpointedAuditDays(ctx context.Context, before time.Time) ([]time.Time, error) {
+	query := `
+		SELECT DISTINCT e.chain_day
+		FROM audit_events e
+		LEFT JOIN audit_checkpoints c ON c.day = e.chain_day
+		WHERE e.chain_day < $1 AND e.hash <> '' AND c.day IS NULL
+		ORDER BY e.chain_day
+	`
+
+	rows, err := p.Query(ctx, query, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var days []time.Time
+	for rows.Next() {
+		var day time.Time
+		if err := rows.Scan(&day); err != nil {
+			return nil, err
+		}
+		days = append(days, day)
+	}
+
+	return days, rows.Err()
+}
+
+// GetAuditDaySummary returns the chain state of a day as an unsigned checkpoint.
+func (p *AuditPostgres) GetAuditDaySummary(ctx context.Context, day time.Time) (*entity.AuditCheckpoint, error) {
+	query := `
+		SELECT MIN(id), MAX(id), COUNT(*),
+			(SELECT hash FROM audit_events WHERE chain_day = $1 AND hash <> '' ORDER BY id DESC LIMIT 1)
+		FROM audit_events
+		WHERE chain_day = $1 AND hash <> ''
+		HAVING COUNT(*) > 0
+	`
+
+	checkpoint := entity.AuditCheckpoint{Day: day}
+	err := p.QueryRow(ctx, query, day).Scan(
+		&checkpoint.FirstEventID,
+		&checkpoint.LastEventID,
+		&checkpoint.EventCount,
+		&checkpoint.LastHash)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, repoerrors.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &checkpoint, nil
+}
+
+func (p *AuditPostgres) CreateAuditCheckpoint(ctx context.Context, checkpoint entity.AuditCheckpoint) error {
+	query := `INSERT INTO audit_checkpoints (day, first_event_id, last_event_id, event_count, last_hash, prev_signature, signature, created_at)
+				VALUES($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	_, err := p.Exec(ctx, query,
+		checkpoint.Day,
+		checkpoint.FirstEventID,
+		checkpoint.LastEventID,
+		checkpoint.EventCount,
+		checkpoint.LastHash,
+		checkpoint.PrevSignature,
+		checkpoint.Signature,
+		checkpoint.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return repoerrors.ErrAlreadyExists
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (p *AuditPostgres) GetLastAuditCheckpoint(ctx context.Context) (*entity.AuditCheckpoint, error) {
+	checkpoints, err := p.queryAuditCheckpoints(ctx, `SELECT `+auditCheckpointColumns+` FROM audit_checkpoints ORDER BY day DESC LIMIT 1`)
+	if err != nil {
+		return nil, err
+	}
+	if len(checkpoints) == 0 {
+		return nil, repoerrors.ErrNotFound
+	}
+
+	return &checkpoints[0], nil
+}
+
+func (p *AuditPostgres) ListAuditCheckpoints(ctx context.Context) ([]entity.AuditCheckpoint, error) {
+	return p.queryAuditCheckpoints(ctx, `SELECT `+auditCheckpointColumns+` FROM audit_checkpoints ORDER BY day`)
+}
+
+const auditCheckpointColumns = `day, first_event_id, last_event_id, event_count, last_hash, prev_signature, signature, created_at`
+
+func (p *AuditPostgres) queryAuditCheckpoints(ctx context.Context, query string, args ...interface{}) ([]entity.AuditCheckpoint, error) {
+	rows, err := p.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checkpoints []entity.AuditCheckpoint
+	for rows.Next() {
+		var checkpoint entity.AuditCheckpoint
+		err := rows.Scan(
+			&checkpoint.Day,
+			&checkpoint.FirstEventID,
+			&checkpoint.LastEventID,
+			&checkpoint.EventCount,
+			&checkpoint.LastHash,
+			&checkpoint.PrevSignature,
+			&checkpoint.Signature,
+			&checkpoint.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		checkpoints = append(checkpoints, checkpoint)
+	}
+
+	return checkpoints, rows.Err()
+}
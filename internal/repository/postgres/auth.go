@@ -7,26 +7,37 @@ import (
 	"github.com/jackc/pgx/v4"
 	"medods-tz/internal/entity"
 	"medods-tz/internal/repository/repoerrors"
+	"time"
 )
 
 type TokenPostgres struct {
-	*pgx.Conn
+	*DB
 }
 
-func NewTokenPostgres(conn *pgx.Conn) *TokenPostgres {
-	return &TokenPostgres{Conn: conn}
+func NewTokenPostgres(db *DB) *TokenPostgres {
+	return &TokenPostgres{DB: db}
 }
 
 func (p *TokenPostgres) CreateRefreshToken(ctx context.Context, token entity.RefreshToken) error {
-	query := `INSERT INTO refresh_tokens (user_id, refresh_hash, issued_at, expires_at, client_ip)
-				VALUES($1, $2, $3, $4, $5)`
+	query := `INSERT INTO refresh_tokens (user_id, refresh_hash, issued_at, session_started_at, expires_at, client_ip,
+				country, city, asn, latitude, longitude, accuracy_radius, device_id, org_id)
+				VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`
 
 	_, err := p.Exec(ctx, query,
 		token.UserID,
 		token.RefreshHash,
 		token.IssuedAt,
+		token.SessionStartedAt,
 		token.ExpiresAt,
 		token.ClientIP,
+		token.Location.Country,
+		token.Location.City,
+		int64(token.Location.ASN),
+		token.Location.Latitude,
+		token.Location.Longitude,
+		token.Location.AccuracyRadius,
+		token.DeviceID,
+		token.OrgID,
 	)
 
 	if err != nil {
@@ -42,31 +53,66 @@ func (p *TokenPostgres) CreateRefreshToken(ctx context.Context, token entity.Ref
 }
 
 func (p *TokenPostgres) GetRefreshTokenEntitiesByUserID(ctx context.Context, userID string) ([]entity.RefreshToken, error) {
-	query := `SELECT * FROM refresh_tokens WHERE user_id = $1`
+	query := `SELECT id, user_id, refresh_hash, issued_at, session_started_at, expires_at, client_ip,
+				country, city, asn, latitude, longitude, accuracy_radius, device_id, org_id, used, revoked_at
+				FROM refresh_tokens WHERE user_id = $1`
 	rows, err := p.Query(ctx, query, userID)
 	if err != nil {
 		return nil, err
 	}
 
+	return scanRefreshTokens(rows)
+}
+
+// ListOrgRefreshTokens returns the sessions of an organization that can still be refreshed at the given time.
+func (p *TokenPostgres) ListOrgRefreshTokens(ctx context.Context, orgID string, at time.Time) ([]entity.RefreshToken, error) {
+	query := `SELECT id, user_id, refresh_hash, issued_at, session_started_at, expires_at, client_ip,
+				country, city, asn, latitude, longitude, accuracy_radius, device_id, org_id, used, revoked_at
+				FROM refresh_tokens
+				WHERE org_id = $1 AND used = false AND revoked_at IS NULL AND expires_at > $2
+				ORDER BY issued_at DESC, id`
+	rows, err := p.Query(ctx, query, orgID, at)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanRefreshTokens(rows)
+}
+
+func scanRefreshTokens(rows pgx.Rows) ([]entity.RefreshToken, error) {
+	defer rows.Close()
+
 	var refreshTokens []entity.RefreshToken
 	for rows.Next() {
 		var token entity.RefreshToken
+		var asn int64
 		err := rows.Scan(
 			&token.ID,
 			&token.UserID,
 			&token.RefreshHash,
 			&token.IssuedAt,
+			&token.SessionStartedAt,
 			&token.ExpiresAt,
 			&token.ClientIP,
-			&token.Used)
+			&token.Location.Country,
+			&token.Location.City,
+			&asn,
+			&token.Location.Latitude,
+			&token.Location.Longitude,
+			&token.Location.AccuracyRadius,
+			&token.DeviceID,
+			&token.OrgID,
+			&token.Used,
+			&token.RevokedAt)
 		if err != nil {
 			return nil, err
 		}
+		token.Location.ASN = uint(asn)
 
 		refreshTokens = append(refreshTokens, token)
 	}
 
-	return refreshTokens, nil
+	return refreshTokens, rows.Err()
 }
 
 func (p *TokenPostgres) MarkRefreshTokenUsed(ctx context.Context, refreshID string) error {
@@ -83,3 +129,40 @@ func (p *TokenPostgres) MarkRefreshTokenUsed(ctx context.Context, refreshID stri
 
 	return nil
 }
+
+func (p *TokenPostgres) RevokeRefreshToken(ctx context.Context, tokenID string) error {
+	query := `UPDATE refresh_tokens SET revoked_at = COALESCE(revoked_at, NOW()) WHERE id = $1`
+	res, err := p.Exec(ctx, query, tokenID)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() < 1 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+func (p *TokenPostgres) RevokeRefreshTokensByUserID(ctx context.Context, userID string) error {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL AND used = false`
+	_, err := p.Exec(ctx, query, userID)
+
+	return err
+}
+
+// RevokeRefreshTokensByDeviceID signs out every session of a device.
+func (p *TokenPostgres) RevokeRefreshTokensByDeviceID(ctx context.Context, deviceID string) error {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE device_id = $1 AND revoked_at IS NULL AND used = false`
+	_, err := p.Exec(ctx, query, deviceID)
+
+	return err
+}
+
+// RevokeOrgRefreshTokensByUserID signs a user out of every session of an organization.
+func (p *TokenPostgres) RevokeOrgRefreshTokensByUserID(ctx context.Context, orgID, userID string) error {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE org_id = $1 AND user_id = $2 AND revoked_at IS NULL AND used = false`
+	_, err := p.Exec(ctx, query, orgID, userID)
+
+	return err
+}
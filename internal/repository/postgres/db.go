@@ -0,0 +1,150 @@
+package postgres
+
+import (
+	"context"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type txKey struct{}
+
+const (
+	// tenantSetting is the session variable the row-level security policies read the current organization from.
+	tenantSetting = "app.org_id"
+	// bypassSetting lets queries past the row-level security policies while it is "on".
+	bypassSetting = "app.bypass_rls"
+)
+
+type querier interface {
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+}
+
+// DB runs queries on the transaction carried by the context, or on the pool when there is none.
+// Repositories embed it, so every repository method takes part in WithinTransaction.
+type DB struct {
+	pool *pgxpool.Pool
+}
+
+func NewDB(pool *pgxpool.Pool) *DB {
+	return &DB{pool: pool}
+}
+
+// BypassesRowLevelSecurity tells whether the role the pool connects as is a superuser or exempt from
+// row-level security, in which case the tenant isolation policies do not apply to it.
+func BypassesRowLevelSecurity(ctx context.Context, pool *pgxpool.Pool) (bool, error) {
+	var bypasses bool
+	err := pool.QueryRow(ctx, `SELECT rolsuper OR rolbypassrls FROM pg_roles WHERE rolname = current_user`).Scan(&bypasses)
+	return bypasses, err
+}
+
+// WithinTransaction runs fn in a transaction that repositories pick up from the context.
+// Nested calls join the outer transaction. The transaction is committed when fn returns nil.
+func (db *DB) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = fn(context.WithValue(ctx, txKey{}, tx))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// WithinTenant runs fn in a transaction in which row-level security only lets queries see and write the
+// rows of organization orgID. A nested call switches the outer transaction to orgID until fn returns.
+func (db *DB) WithinTenant(ctx context.Context, orgID string, fn func(ctx context.Context) error) error {
+	return db.withinSettings(ctx, map[string]string{tenantSetting: orgID, bypassSetting: ""}, fn)
+}
+
+// WithinAllTenants runs fn in a transaction in which row-level security lets queries see and write the
+// rows of every organization, for admin and system paths that work across organizations. Outside of it
+// and WithinTenant queries only see the rows that belong to no organization.
+func (db *DB) WithinAllTenants(ctx context.Context, fn func(ctx context.Context) error) error {
+	return db.withinSettings(ctx, map[string]string{tenantSetting: "", bypassSetting: "on"}, fn)
+}
+
+// withinSettings runs fn in a transaction with the session variables the row-level security policies read
+// set to values, and restores their previous values when fn returns.
+func (db *DB) withinSettings(ctx context.Context, values map[string]string, fn func(ctx context.Context) error) error {
+	return db.WithinTransaction(ctx, func(ctx context.Context) error {
+		previous := make(map[string]string, len(values))
+		for name, value := range values {
+			var current string
+			err := db.QueryRow(ctx, `SELECT COALESCE(current_setting($1, true), '')`, name).Scan(&current)
+			if err != nil {
+				return err
+			}
+			previous[name] = current
+
+			_, err = db.Exec(ctx, `SELECT set_config($1, $2, true)`, name, value)
+			if err != nil {
+				return err
+			}
+		}
+
+		err := fn(ctx)
+		if err != nil {
+			return err
+		}
+
+		for name, value := range previous {
+			_, err = db.Exec(ctx, `SELECT set_config($1, $2, true)`, name, value)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (db *DB) Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error) {
+	return db.conn(ctx).Exec(ctx, sql, arguments...)
+}
+
+func (db *DB) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return db.conn(ctx).Query(ctx, sql, args...)
+}
+
+func (db *DB) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return db.conn(ctx).QueryRow(ctx, sql, args...)
+}
+
+// Begin starts a transaction, or a savepoint inside the transaction of the context.
+func (db *DB) Begin(ctx context.Context) (pgx.Tx, error) {
+	return db.conn(ctx).Begin(ctx)
+}
+
+// SendBatch runs all queued statements in one round trip and returns the first error.
+func (db *DB) SendBatch(ctx context.Context, batch *pgx.Batch) error {
+	results := db.conn(ctx).SendBatch(ctx, batch)
+	for i := 0; i < batch.Len(); i++ {
+		if _, err := results.Exec(); err != nil {
+			results.Close()
+			return err
+		}
+	}
+
+	return results.Close()
+}
+
+func (db *DB) conn(ctx context.Context) querier {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return tx
+	}
+
+	return db.pool
+}
@@ -0,0 +1,156 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v4"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository/repoerrors"
+	"time"
+)
+
+type DevicePostgres struct {
+	*DB
+}
+
+func NewDevicePostgres(db *DB) *DevicePostgres {
+	return &DevicePostgres{DB: db}
+}
+
+const deviceColumns = `id, user_id, key_hash, name, browser, os, user_agent, last_ip, created_at, last_seen_at`
+
+// UpsertDevice stores a device seen for the first time, or updates what was last seen of it when two
+// sign-ins with the same new key race.
+func (p *DevicePostgres) UpsertDevice(ctx context.Context, device entity.Device) (string, error) {
+	query := `
+		INSERT INTO devices (user_id, key_hash, browser, os, user_agent, last_ip, created_at, last_seen_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		ON CONFLICT (user_id, key_hash) DO UPDATE SET
+			browser = EXCLUDED.browser,
+			os = EXCLUDED.os,
+			user_agent = EXCLUDED.user_agent,
+			last_ip = EXCLUDED.last_ip,
+			last_seen_at = EXCLUDED.last_seen_at
+		RETURNING id
+	`
+	var id string
+	err := p.QueryRow(ctx, query,
+		device.UserID,
+		device.KeyHash,
+		device.Browser,
+		device.OS,
+		device.UserAgent,
+		device.LastIP,
+		device.LastSeenAt,
+	).Scan(&id)
+
+	return id, err
+}
+
+func (p *DevicePostgres) GetDevice(ctx context.Context, userID, id string) (*entity.Device, error) {
+	query := `SELECT ` + deviceColumns + ` FROM devices WHERE user_id = $1 AND id = $2`
+
+	return scanDevice(p.QueryRow(ctx, query, userID, id))
+}
+
+func (p *DevicePostgres) GetDeviceByKeyHash(ctx context.Context, userID, keyHash string) (*entity.Device, error) {
+	query := `SELECT ` + deviceColumns + ` FROM devices WHERE user_id = $1 AND key_hash = $2`
+
+	return scanDevice(p.QueryRow(ctx, query, userID, keyHash))
+}
+
+// ListDevices returns the devices of the user, the most recently used first.
+func (p *DevicePostgres) ListDevices(ctx context.Context, userID string) ([]entity.Device, error) {
+	query := `SELECT ` + deviceColumns + ` FROM devices WHERE user_id = $1 ORDER BY last_seen_at DESC`
+	rows, err := p.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var devices []entity.Device
+	for rows.Next() {
+		device, err := scanDevice(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		devices = append(devices, *device)
+	}
+
+	return devices, rows.Err()
+}
+
+func (p *DevicePostgres) CountDevices(ctx context.Context, userID string) (int, error) {
+	query := `SELECT COUNT(*) FROM devices WHERE user_id = $1`
+
+	var count int
+	err := p.QueryRow(ctx, query, userID).Scan(&count)
+
+	return count, err
+}
+
+func (p *DevicePostgres) TouchDevice(ctx context.Context, id, ip string, at time.Time) error {
+	query := `UPDATE devices SET last_ip = $2, last_seen_at = $3 WHERE id = $1`
+	res, err := p.Exec(ctx, query, id, ip, at)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() < 1 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+func (p *DevicePostgres) RenameDevice(ctx context.Context, userID, id, name string) error {
+	query := `UPDATE devices SET name = $3 WHERE user_id = $1 AND id = $2`
+	res, err := p.Exec(ctx, query, userID, id, name)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() < 1 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+func (p *DevicePostgres) DeleteDevice(ctx context.Context, userID, id string) error {
+	query := `DELETE FROM devices WHERE user_id = $1 AND id = $2`
+	res, err := p.Exec(ctx, query, userID, id)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() < 1 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+func scanDevice(row pgx.Row) (*entity.Device, error) {
+	var device entity.Device
+	err := row.Scan(
+		&device.ID,
+		&device.UserID,
+		&device.KeyHash,
+		&device.Name,
+		&device.Browser,
+		&device.OS,
+		&device.UserAgent,
+		&device.LastIP,
+		&device.CreatedAt,
+		&device.LastSeenAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, repoerrors.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &device, nil
+}
@@ -0,0 +1,176 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository/repoerrors"
+	"strings"
+	"time"
+)
+
+type EmailPostgres struct {
+	*DB
+}
+
+func NewEmailPostgres(db *DB) *EmailPostgres {
+	return &EmailPostgres{DB: db}
+}
+
+func (p *EmailPostgres) CreateEmailMessage(ctx context.Context, message entity.EmailMessage) (int64, error) {
+	query := `INSERT INTO email_outbox (kind, channel, recipient, subject, text_body, html_body, next_attempt_at, created_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
+
+	var id int64
+	err := p.QueryRow(ctx, query,
+		message.Kind,
+		message.Channel,
+		message.Recipient,
+		message.Subject,
+		message.TextBody,
+		message.HTMLBody,
+		message.NextAttemptAt,
+		message.CreatedAt,
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// ClaimEmailMessages leases due messages until lockedUntil, so concurrent workers
+// and replicas skip them. A worker that dies mid-send releases them when the lease expires.
+func (p *EmailPostgres) ClaimEmailMessages(ctx context.Context, now, lockedUntil time.Time, limit int) ([]entity.EmailMessage, error) {
+	query := `
+		UPDATE email_outbox SET locked_until = $2
+		WHERE id IN (
+			SELECT id FROM email_outbox
+			WHERE status = 'pending' AND next_attempt_at <= $1 AND (locked_until IS NULL OR locked_until <= $1)
+			ORDER BY next_attempt_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + emailMessageColumns
+
+	messages, err := p.queryEmailMessages(ctx, query, now, lockedUntil, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// UpdateEmailMessage stores the outcome of an attempt and releases the lease.
+func (p *EmailPostgres) UpdateEmailMessage(ctx context.Context, message entity.EmailMessage) error {
+	query := `
+		UPDATE email_outbox
+		SET status = $1, attempts = $2, next_attempt_at = $3, last_error = $4, sent_at = $5, text_body = $6,
+			html_body = $7, locked_until = NULL
+		WHERE id = $8
+	`
+	res, err := p.Exec(ctx, query,
+		message.Status,
+		message.Attempts,
+		message.NextAttemptAt,
+		message.LastError,
+		message.SentAt,
+		message.TextBody,
+		message.HTMLBody,
+		message.ID)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() < 1 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+// RequeueEmailMessage gives an unsent message a fresh set of attempts starting at the given time.
+func (p *EmailPostgres) RequeueEmailMessage(ctx context.Context, id int64, at time.Time) error {
+	query := `
+		UPDATE email_outbox
+		SET status = 'pending', attempts = 0, next_attempt_at = $1, locked_until = NULL
+		WHERE id = $2 AND status <> 'sent'
+	`
+	res, err := p.Exec(ctx, query, at, id)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() < 1 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+func (p *EmailPostgres) ListEmailMessages(ctx context.Context, filter entity.EmailMessageFilter) ([]entity.EmailMessage, error) {
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Channel != "" {
+		addCondition("channel = $%d", filter.Channel)
+	}
+	if filter.Status != "" {
+		addCondition("status = $%d", filter.Status)
+	}
+	if filter.Recipient != "" {
+		addCondition("recipient = $%d", filter.Recipient)
+	}
+	if filter.BeforeID > 0 {
+		addCondition("id < $%d", filter.BeforeID)
+	}
+
+	query := `SELECT ` + emailMessageColumns + ` FROM email_outbox`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	return p.queryEmailMessages(ctx, query, args...)
+}
+
+const emailMessageColumns = `id, kind, channel, recipient, subject, text_body, html_body, status, attempts, next_attempt_at, last_error, created_at, sent_at`
+
+func (p *EmailPostgres) queryEmailMessages(ctx context.Context, query string, args ...interface{}) ([]entity.EmailMessage, error) {
+	rows, err := p.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []entity.EmailMessage
+	for rows.Next() {
+		var message entity.EmailMessage
+		err := rows.Scan(
+			&message.ID,
+			&message.Kind,
+			&message.Channel,
+			&message.Recipient,
+			&message.Subject,
+			&message.TextBody,
+			&message.HTMLBody,
+			&message.Status,
+			&message.Attempts,
+			&message.NextAttemptAt,
+			&message.LastError,
+			&message.CreatedAt,
+			&message.SentAt)
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, message)
+	}
+
+	return messages, rows.Err()
+}
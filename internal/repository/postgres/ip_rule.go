@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgconn"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository/repoerrors"
+)
+
+type IPRulePostgres struct {
+	*DB
+}
+
+func NewIPRulePostgres(db *DB) *IPRulePostgres {
+	return &IPRulePostgres{DB: db}
+}
+
+func (p *IPRulePostgres) CreateIPRule(ctx context.Context, rule entity.IPRule) (string, error) {
+	query := `INSERT INTO ip_rules (user_id, org_id, action, cidr, description, created_at)
+				VALUES(NULLIF($1, '')::UUID, NULLIF($2, '')::UUID, $3, $4, $5, $6)
+				RETURNING id`
+
+	var id string
+	err := p.QueryRow(ctx, query, rule.UserID, rule.OrgID, rule.Action, rule.CIDR, rule.Description, rule.CreatedAt).Scan(&id)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return "", repoerrors.ErrAlreadyExists
+		}
+
+		return "", err
+	}
+
+	return id, nil
+}
+
+func (p *IPRulePostgres) ListIPRules(ctx context.Context, userID string) ([]entity.IPRule, error) {
+	query := `
+		SELECT id, user_id::TEXT, '', action, cidr, description, created_at
+		FROM ip_rules
+		WHERE user_id = $1
+		ORDER BY created_at, id
+	`
+
+	return p.listIPRules(ctx, query, userID)
+}
+
+func (p *IPRulePostgres) ListOrgIPRules(ctx context.Context, orgID string) ([]entity.IPRule, error) {
+	query := `
+		SELECT id, '', org_id::TEXT, action, cidr, description, created_at
+		FROM ip_rules
+		WHERE org_id = $1
+		ORDER BY created_at, id
+	`
+
+	return p.listIPRules(ctx, query, orgID)
+}
+
+func (p *IPRulePostgres) listIPRules(ctx context.Context, query string, args ...interface{}) ([]entity.IPRule, error) {
+	rows, err := p.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []entity.IPRule
+	for rows.Next() {
+		var rule entity.IPRule
+		err := rows.Scan(&rule.ID, &rule.UserID, &rule.OrgID, &rule.Action, &rule.CIDR, &rule.Description, &rule.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
+
+func (p *IPRulePostgres) DeleteIPRule(ctx context.Context, userID, id string) error {
+	query := `DELETE FROM ip_rules WHERE user_id = $1 AND id = $2`
+	res, err := p.Exec(ctx, query, userID, id)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() < 1 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+func (p *IPRulePostgres) DeleteOrgIPRule(ctx context.Context, orgID, id string) error {
+	query := `DELETE FROM ip_rules WHERE org_id = $1 AND id = $2`
+	res, err := p.Exec(ctx, query, orgID, id)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() < 1 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
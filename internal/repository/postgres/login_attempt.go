@@ -0,0 +1,88 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v4"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository/repoerrors"
+	"time"
+)
+
+type LoginAttemptPostgres struct {
+	*DB
+}
+
+func NewLoginAttemptPostgres(db *DB) *LoginAttemptPostgres {
+	return &LoginAttemptPostgres{DB: db}
+}
+
+func (p *LoginAttemptPostgres) GetLoginAttempts(ctx context.Context, key string) (*entity.LoginAttempts, error) {
+	query := `SELECT key, failures, last_failure_at, locked_until FROM login_attempts WHERE key = $1`
+
+	var attempts entity.LoginAttempts
+	err := p.QueryRow(ctx, query, key).Scan(
+		&attempts.Key,
+		&attempts.Failures,
+		&attempts.LastFailureAt,
+		&attempts.LockedUntil)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, repoerrors.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &attempts, nil
+}
+
+// IncrementLoginFailures counts a failure in one statement, so concurrent requests cannot lose updates.
+// The counter starts over when the previous failure is older than window and the key is not locked.
+func (p *LoginAttemptPostgres) IncrementLoginFailures(ctx context.Context, key string, at time.Time, window time.Duration) (*entity.LoginAttempts, error) {
+	query := `
+		INSERT INTO login_attempts (key, failures, last_failure_at)
+		VALUES ($1, 1, $2)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE
+				WHEN login_attempts.last_failure_at < $3
+					AND (login_attempts.locked_until IS NULL OR login_attempts.locked_until < $2)
+				THEN 1
+				ELSE login_attempts.failures + 1
+			END,
+			last_failure_at = $2
+		RETURNING key, failures, last_failure_at, locked_until
+	`
+
+	var attempts entity.LoginAttempts
+	err := p.QueryRow(ctx, query, key, at, at.Add(-window)).Scan(
+		&attempts.Key,
+		&attempts.Failures,
+		&attempts.LastFailureAt,
+		&attempts.LockedUntil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &attempts, nil
+}
+
+func (p *LoginAttemptPostgres) LockLoginAttempts(ctx context.Context, key string, until time.Time) error {
+	query := `UPDATE login_attempts SET locked_until = $1 WHERE key = $2`
+	res, err := p.Exec(ctx, query, until, key)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() < 1 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+func (p *LoginAttemptPostgres) DeleteLoginAttempts(ctx context.Context, key string) error {
+	query := `DELETE FROM login_attempts WHERE key = $1`
+	_, err := p.Exec(ctx, query, key)
+
+	return err
+}
@@ -0,0 +1,34 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgconn"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository/repoerrors"
+)
+
+type LoginReportPostgres struct {
+	*DB
+}
+
+func NewLoginReportPostgres(db *DB) *LoginReportPostgres {
+	return &LoginReportPostgres{DB: db}
+}
+
+// CreateLoginReport returns repoerrors.ErrAlreadyExists when the link was used before.
+func (p *LoginReportPostgres) CreateLoginReport(ctx context.Context, report entity.LoginReport) error {
+	query := `INSERT INTO login_reports (token_id, user_id, ip, reported_at) VALUES ($1, $2, $3, $4)`
+
+	_, err := p.Exec(ctx, query, report.TokenID, report.UserID, report.IP, report.ReportedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return repoerrors.ErrAlreadyExists
+		}
+
+		return err
+	}
+
+	return nil
+}
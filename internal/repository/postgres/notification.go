@@ -0,0 +1,164 @@
+package postgres
+
+import (
+	"cmp"
+	"context"
+	"github.com/jackc/pgx/v4"
+	"medods-tz/internal/entity"
+	"slices"
+	"time"
+)
+
+type NotificationPostgres struct {
+	*DB
+}
+
+func NewNotificationPostgres(db *DB) *NotificationPostgres {
+	return &NotificationPostgres{DB: db}
+}
+
+func (p *NotificationPostgres) ListNotificationPreferences(ctx context.Context, userID string) ([]entity.NotificationPreference, error) {
+	query := `
+		SELECT channel, address, min_severity, updated_at
+		FROM notification_preferences
+		WHERE user_id = $1
+		ORDER BY channel
+	`
+	rows, err := p.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var preferences []entity.NotificationPreference
+	for rows.Next() {
+		var preference entity.NotificationPreference
+		err := rows.Scan(&preference.Channel, &preference.Address, &preference.MinSeverity, &preference.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		preferences = append(preferences, preference)
+	}
+
+	return preferences, rows.Err()
+}
+
+// ReplaceNotificationPreferences replaces all preferences of the user. The statements of a batch
+// run in one implicit transaction, so readers never see the user without preferences.
+func (p *NotificationPostgres) ReplaceNotificationPreferences(ctx context.Context, userID string, preferences []entity.NotificationPreference) error {
+	batch := &pgx.Batch{}
+	batch.Queue(`DELETE FROM notification_preferences WHERE user_id = $1`, userID)
+	for _, preference := range preferences {
+		batch.Queue(`INSERT INTO notification_preferences (user_id, channel, address, min_severity, updated_at)
+				VALUES ($1, $2, $3, $4, $5)`,
+			userID, preference.Channel, preference.Address, preference.MinSeverity, preference.UpdatedAt)
+	}
+
+	return p.SendBatch(ctx, batch)
+}
+
+// ThrottleNotification records a notification with the key and tells whether it is the first one
+// since windowStart. Later ones only increment the suppressed counter. It runs as one statement,
+// so concurrent refreshes of the same user do not both notify.
+func (p *NotificationPostgres) ThrottleNotification(ctx context.Context, userID, template, key string, now, windowStart time.Time) (bool, error) {
+	query := `
+		INSERT INTO notification_throttle AS t (user_id, template, dedup_key, sent_at, suppressed)
+		VALUES ($1, $2, $3, $4, 0)
+		ON CONFLICT (user_id, template, dedup_key) DO UPDATE SET
+			sent_at = CASE WHEN t.sent_at < $5 THEN $4 ELSE t.sent_at END,
+			suppressed = CASE WHEN t.sent_at < $5 THEN 0 ELSE t.suppressed + 1 END
+		RETURNING suppressed = 0
+	`
+	var first bool
+	err := p.QueryRow(ctx, query, userID, template, key, now, windowStart).Scan(&first)
+
+	return first, err
+}
+
+func (p *NotificationPostgres) CountNotificationsSince(ctx context.Context, userID, template string, since time.Time) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM notification_throttle
+		WHERE user_id = $1 AND template = $2 AND sent_at >= $3
+	`
+	var count int
+	err := p.QueryRow(ctx, query, userID, template, since).Scan(&count)
+
+	return count, err
+}
+
+func (p *NotificationPostgres) DeleteNotificationThrottles(ctx context.Context, sentBefore time.Time) error {
+	_, err := p.Exec(ctx, `DELETE FROM notification_throttle WHERE sent_at < $1`, sentBefore)
+	return err
+}
+
+func (p *NotificationPostgres) CreateNotificationDigestItem(ctx context.Context, item entity.NotificationDigestItem) error {
+	query := `
+		INSERT INTO notification_digest_items (user_id, template, severity, subject, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	_, err := p.Exec(ctx, query, item.UserID, item.Template, item.Severity, item.Subject, item.CreatedAt)
+
+	return err
+}
+
+// ListDueNotificationDigests returns the users whose oldest held back notification was created before createdBefore.
+func (p *NotificationPostgres) ListDueNotificationDigests(ctx context.Context, createdBefore time.Time) ([]string, error) {
+	query := `
+		SELECT user_id
+		FROM notification_digest_items
+		GROUP BY user_id
+		HAVING MIN(created_at) < $1
+	`
+	rows, err := p.Query(ctx, query, createdBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, rows.Err()
+}
+
+// TakeNotificationDigestItems deletes and returns the held back notifications of the user, oldest
+// first. Within a transaction a concurrent worker waits for it and then finds nothing to take.
+func (p *NotificationPostgres) TakeNotificationDigestItems(ctx context.Context, userID string) ([]entity.NotificationDigestItem, error) {
+	query := `
+		DELETE FROM notification_digest_items
+		WHERE user_id = $1
+		RETURNING id, user_id, template, severity, subject, created_at
+	`
+	rows, err := p.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []entity.NotificationDigestItem
+	for rows.Next() {
+		var item entity.NotificationDigestItem
+		err := rows.Scan(&item.ID, &item.UserID, &item.Template, &item.Severity, &item.Subject, &item.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(items, func(a, b entity.NotificationDigestItem) int { return cmp.Compare(a.ID, b.ID) })
+
+	return items, nil
+}
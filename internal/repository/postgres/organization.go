@@ -0,0 +1,194 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository/repoerrors"
+)
+
+type OrganizationPostgres struct {
+	*DB
+}
+
+func NewOrganizationPostgres(db *DB) *OrganizationPostgres {
+	return &OrganizationPostgres{DB: db}
+}
+
+func (p *OrganizationPostgres) CreateOrganization(ctx context.Context, org entity.Organization) (string, error) {
+	query := `INSERT INTO organizations (name, created_at) VALUES($1, $2) RETURNING id`
+
+	var id string
+	err := p.QueryRow(ctx, query, org.Name, org.CreatedAt).Scan(&id)
+
+	return id, err
+}
+
+func (p *OrganizationPostgres) GetOrganization(ctx context.Context, id string) (*entity.Organization, error) {
+	query := `SELECT id, name, created_at FROM organizations WHERE id = $1`
+
+	var org entity.Organization
+	err := p.QueryRow(ctx, query, id).Scan(&org.ID, &org.Name, &org.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, repoerrors.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &org, nil
+}
+
+func (p *OrganizationPostgres) ListOrganizations(ctx context.Context) ([]entity.Organization, error) {
+	query := `SELECT id, name, created_at FROM organizations ORDER BY created_at, id`
+	rows, err := p.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orgs []entity.Organization
+	for rows.Next() {
+		var org entity.Organization
+		err := rows.Scan(&org.ID, &org.Name, &org.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		orgs = append(orgs, org)
+	}
+
+	return orgs, rows.Err()
+}
+
+func (p *OrganizationPostgres) UpdateOrganization(ctx context.Context, org entity.Organization) error {
+	query := `UPDATE organizations SET name = $2 WHERE id = $1`
+	res, err := p.Exec(ctx, query, org.ID, org.Name)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() < 1 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+// DeleteOrganization removes the organization with its memberships, sessions and ip rules.
+func (p *OrganizationPostgres) DeleteOrganization(ctx context.Context, id string) error {
+	query := `DELETE FROM organizations WHERE id = $1`
+	res, err := p.Exec(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() < 1 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+// UpsertMembership adds a user to an organization or changes their role. It returns ErrNotFound when
+// the organization or the user does not exist.
+func (p *OrganizationPostgres) UpsertMembership(ctx context.Context, membership entity.Membership) error {
+	query := `INSERT INTO memberships (org_id, user_id, role, created_at) VALUES($1, $2, $3, $4)
+				ON CONFLICT (org_id, user_id) DO UPDATE SET role = EXCLUDED.role`
+	_, err := p.Exec(ctx, query, membership.OrgID, membership.UserID, membership.Role, membership.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return repoerrors.ErrNotFound
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (p *OrganizationPostgres) GetMembership(ctx context.Context, orgID, userID string) (*entity.Membership, error) {
+	query := `SELECT org_id, user_id, role, created_at FROM memberships WHERE org_id = $1 AND user_id = $2`
+
+	var membership entity.Membership
+	err := p.QueryRow(ctx, query, orgID, userID).Scan(
+		&membership.OrgID,
+		&membership.UserID,
+		&membership.Role,
+		&membership.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, repoerrors.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &membership, nil
+}
+
+func (p *OrganizationPostgres) ListMemberships(ctx context.Context, orgID string) ([]entity.Membership, error) {
+	query := `
+		SELECT org_id, '', user_id, role, created_at
+		FROM memberships
+		WHERE org_id = $1
+		ORDER BY created_at, user_id
+	`
+
+	return p.listMemberships(ctx, query, orgID)
+}
+
+// ListUserMemberships returns the memberships of a user together with the names of their organizations.
+func (p *OrganizationPostgres) ListUserMemberships(ctx context.Context, userID string) ([]entity.Membership, error) {
+	query := `
+		SELECT m.org_id, o.name, m.user_id, m.role, m.created_at
+		FROM memberships m
+		JOIN organizations o ON o.id = m.org_id
+		WHERE m.user_id = $1
+		ORDER BY o.name, m.org_id
+	`
+
+	return p.listMemberships(ctx, query, userID)
+}
+
+func (p *OrganizationPostgres) listMemberships(ctx context.Context, query string, args ...interface{}) ([]entity.Membership, error) {
+	rows, err := p.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var memberships []entity.Membership
+	for rows.Next() {
+		var membership entity.Membership
+		err := rows.Scan(
+			&membership.OrgID,
+			&membership.OrgName,
+			&membership.UserID,
+			&membership.Role,
+			&membership.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		memberships = append(memberships, membership)
+	}
+
+	return memberships, rows.Err()
+}
+
+func (p *OrganizationPostgres) DeleteMembership(ctx context.Context, orgID, userID string) error {
+	query := `DELETE FROM memberships WHERE org_id = $1 AND user_id = $2`
+	res, err := p.Exec(ctx, query, orgID, userID)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() < 1 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
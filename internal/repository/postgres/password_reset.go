@@ -0,0 +1,119 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository/repoerrors"
+)
+
+type PasswordResetPostgres struct {
+	*DB
+}
+
+func NewPasswordResetPostgres(db *DB) *PasswordResetPostgres {
+	return &PasswordResetPostgres{DB: db}
+}
+
+func (p *PasswordResetPostgres) CreatePasswordResetToken(ctx context.Context, token entity.PasswordResetToken) error {
+	query := `INSERT INTO password_reset_tokens (user_id, token_hash, created_at, expires_at)
+				VALUES($1, $2, $3, $4)`
+
+	_, err := p.Exec(ctx, query,
+		token.UserID,
+		token.TokenHash,
+		token.CreatedAt,
+		token.ExpiresAt,
+	)
+
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return repoerrors.ErrAlreadyExists
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (p *PasswordResetPostgres) GetPasswordResetTokenByHash(ctx context.Context, tokenHash string) (*entity.PasswordResetToken, error) {
+	query := `
+		SELECT id, user_id, token_hash, created_at, expires_at, used_at
+		FROM password_reset_tokens
+		WHERE token_hash = $1
+	`
+	var token entity.PasswordResetToken
+	err := p.QueryRow(ctx, query, tokenHash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.TokenHash,
+		&token.CreatedAt,
+		&token.ExpiresAt,
+		&token.UsedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, repoerrors.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// MarkPasswordResetTokenUsed only succeeds once per token, so concurrent resets with the same link cannot both pass.
+func (p *PasswordResetPostgres) MarkPasswordResetTokenUsed(ctx context.Context, id string) error {
+	query := `UPDATE password_reset_tokens SET used_at = NOW() WHERE id = $1 AND used_at IS NULL`
+	res, err := p.Exec(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() < 1 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+func (p *PasswordResetPostgres) CreatePasswordResetRequest(ctx context.Context, request entity.PasswordResetRequest) error {
+	query := `INSERT INTO password_reset_requests (email, created_at) VALUES ($1, $2)`
+
+	_, err := p.Exec(ctx, query, request.Email, request.CreatedAt)
+	return err
+}
+
+// ClaimPasswordResetRequests removes and returns the oldest queued requests. Run in a transaction, they stay
+// locked against other workers until it ends and are queued again if it rolls back.
+func (p *PasswordResetPostgres) ClaimPasswordResetRequests(ctx context.Context, limit int) ([]entity.PasswordResetRequest, error) {
+	query := `
+		DELETE FROM password_reset_requests
+		WHERE id IN (
+			SELECT id FROM password_reset_requests
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, email, created_at
+	`
+	rows, err := p.Query(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []entity.PasswordResetRequest
+	for rows.Next() {
+		var request entity.PasswordResetRequest
+		err = rows.Scan(&request.ID, &request.Email, &request.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+
+	return requests, rows.Err()
+}
@@ -0,0 +1,51 @@
+package postgres
+
+import (
+	"context"
+	"medods-tz/internal/entity"
+	"time"
+)
+
+type RateLimitPostgres struct {
+	*DB
+}
+
+func NewRateLimitPostgres(db *DB) *RateLimitPostgres {
+	return &RateLimitPostgres{DB: db}
+}
+
+// TakeRateLimitToken refills the bucket for the time passed since its last update and takes one token
+// if available. It runs as one statement, so replicas sharing the table never hand out the same token twice.
+func (p *RateLimitPostgres) TakeRateLimitToken(ctx context.Context, key string, capacity, refillPerSecond float64, at time.Time) (*entity.RateLimitBucket, error) {
+	query := `
+		INSERT INTO rate_limit_buckets AS b (key, tokens, allowed, updated_at)
+		VALUES ($1, $2::float8 - 1, true, $3)
+		ON CONFLICT (key) DO UPDATE SET
+			tokens = CASE
+				WHEN LEAST($2::float8, b.tokens + GREATEST(EXTRACT(EPOCH FROM ($3 - b.updated_at)), 0) * $4::float8) >= 1
+				THEN LEAST($2::float8, b.tokens + GREATEST(EXTRACT(EPOCH FROM ($3 - b.updated_at)), 0) * $4::float8) - 1
+				ELSE LEAST($2::float8, b.tokens + GREATEST(EXTRACT(EPOCH FROM ($3 - b.updated_at)), 0) * $4::float8)
+			END,
+			allowed = LEAST($2::float8, b.tokens + GREATEST(EXTRACT(EPOCH FROM ($3 - b.updated_at)), 0) * $4::float8) >= 1,
+			updated_at = GREATEST($3, b.updated_at)
+		RETURNING key, tokens, allowed, updated_at
+	`
+
+	var bucket entity.RateLimitBucket
+	err := p.QueryRow(ctx, query, key, capacity, at, refillPerSecond).Scan(
+		&bucket.Key,
+		&bucket.Tokens,
+		&bucket.Allowed,
+		&bucket.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &bucket, nil
+}
+
+// PruneRateLimitBuckets deletes the buckets last taken from before the given time.
+func (p *RateLimitPostgres) PruneRateLimitBuckets(ctx context.Context, before time.Time) error {
+	_, err := p.Exec(ctx, `DELETE FROM rate_limit_buckets WHERE updated_at < $1`, before)
+	return err
+}
@@ -0,0 +1,137 @@
+package postgres
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4"
+	"medods-tz/internal/entity"
+	"time"
+)
+
+type RelationPostgres struct {
+	*DB
+}
+
+func NewRelationPostgres(db *DB) *RelationPostgres {
+	return &RelationPostgres{DB: db}
+}
+
+// visibleAt selects the tuples of revision $1.
+const visibleAt = `created_revision <= $1 AND (deleted_revision IS NULL OR deleted_revision > $1)`
+
+// CreateRelationRevision starts the next revision, it must run in the transaction that writes its tuples.
+// Writers are serialized, so a revision is only visible once every revision before it is committed.
+func (p *RelationPostgres) CreateRelationRevision(ctx context.Context) (int64, error) {
+	_, err := p.Exec(ctx, `LOCK TABLE relation_revisions IN EXCLUSIVE MODE`)
+	if err != nil {
+		return 0, err
+	}
+
+	var revision int64
+	err = p.QueryRow(ctx, `INSERT INTO relation_revisions DEFAULT VALUES RETURNING revision`).Scan(&revision)
+
+	return revision, err
+}
+
+// GetRelationRevisions returns the oldest retained and the latest revision, both are 0 before the first write.
+func (p *RelationPostgres) GetRelationRevisions(ctx context.Context) (oldest, latest int64, err error) {
+	query := `SELECT COALESCE(MIN(revision), 0), COALESCE(MAX(revision), 0) FROM relation_revisions`
+	err = p.QueryRow(ctx, query).Scan(&oldest, &latest)
+
+	return oldest, latest, err
+}
+
+// WriteRelationTuples deletes and then writes tuples at revision. Writing an existing tuple or
+// deleting a missing one changes nothing.
+func (p *RelationPostgres) WriteRelationTuples(ctx context.Context, writes, deletes []entity.RelationTuple, revision int64) error {
+	batch := &pgx.Batch{}
+	for _, tuple := range deletes {
+		batch.Queue(`
+			UPDATE relation_tuples SET deleted_revision = $7
+			WHERE namespace = $1 AND object_id = $2 AND relation = $3
+				AND subject_namespace = $4 AND subject_id = $5 AND subject_relation = $6
+				AND deleted_revision IS NULL`,
+			tuple.Namespace, tuple.ObjectID, tuple.Relation,
+			tuple.Subject.Namespace, tuple.Subject.ObjectID, tuple.Subject.Relation, revision)
+	}
+	for _, tuple := range writes {
+		batch.Queue(`
+			INSERT INTO relation_tuples (namespace, object_id, relation, subject_namespace, subject_id, subject_relation, created_revision)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (namespace, object_id, relation, subject_namespace, subject_id, subject_relation)
+				WHERE deleted_revision IS NULL DO NOTHING`,
+			tuple.Namespace, tuple.ObjectID, tuple.Relation,
+			tuple.Subject.Namespace, tuple.Subject.ObjectID, tuple.Subject.Relation, revision)
+	}
+
+	return p.SendBatch(ctx, batch)
+}
+
+// ListRelationSubjects returns the subjects of the tuples of namespace:objectID#relation at revision.
+func (p *RelationPostgres) ListRelationSubjects(ctx context.Context, namespace, objectID, relation string, revision int64) ([]entity.RelationSubject, error) {
+	query := `
+		SELECT subject_namespace, subject_id, subject_relation
+		FROM relation_tuples
+		WHERE namespace = $2 AND object_id = $3 AND relation = $4 AND ` + visibleAt + `
+		ORDER BY id
+	`
+	rows, err := p.Query(ctx, query, revision, namespace, objectID, relation)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subjects []entity.RelationSubject
+	for rows.Next() {
+		var subject entity.RelationSubject
+		err := rows.Scan(&subject.Namespace, &subject.ObjectID, &subject.Relation)
+		if err != nil {
+			return nil, err
+		}
+
+		subjects = append(subjects, subject)
+	}
+
+	return subjects, rows.Err()
+}
+
+// ListRelationTuplesBySubject returns the tuples granting a relation to exactly subject at revision.
+func (p *RelationPostgres) ListRelationTuplesBySubject(ctx context.Context, subject entity.RelationSubject, revision int64) ([]entity.RelationTuple, error) {
+	query := `
+		SELECT namespace, object_id, relation
+		FROM relation_tuples
+		WHERE subject_namespace = $2 AND subject_id = $3 AND subject_relation = $4 AND ` + visibleAt + `
+		ORDER BY id
+	`
+	rows, err := p.Query(ctx, query, revision, subject.Namespace, subject.ObjectID, subject.Relation)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tuples []entity.RelationTuple
+	for rows.Next() {
+		tuple := entity.RelationTuple{Subject: subject}
+		err := rows.Scan(&tuple.Namespace, &tuple.ObjectID, &tuple.Relation)
+		if err != nil {
+			return nil, err
+		}
+
+		tuples = append(tuples, tuple)
+	}
+
+	return tuples, rows.Err()
+}
+
+// PruneRelationRevisions forgets the revisions created before the given time, except the latest one,
+// together with the tuples deleted before the oldest revision that is left.
+func (p *RelationPostgres) PruneRelationRevisions(ctx context.Context, before time.Time) error {
+	batch := &pgx.Batch{}
+	batch.Queue(`
+		DELETE FROM relation_revisions
+		WHERE created_at < $1 AND revision < (SELECT MAX(revision) FROM relation_revisions)`, before)
+	batch.Queue(`
+		DELETE FROM relation_tuples
+		WHERE deleted_revision <= (SELECT MIN(revision) FROM relation_revisions)`)
+
+	return p.SendBatch(ctx, batch)
+}
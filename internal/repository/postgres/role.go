@@ -0,0 +1,233 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository/repoerrors"
+)
+
+type RolePostgres struct {
+	*DB
+}
+
+func NewRolePostgres(db *DB) *RolePostgres {
+	return &RolePostgres{DB: db}
+}
+
+// roleQuery selects roles with their permissions, callers add the conditions.
+const roleQuery = `
+	SELECT r.id, r.name, r.description, r.created_at,
+		COALESCE(array_agg(rp.permission ORDER BY rp.permission) FILTER (WHERE rp.permission IS NOT NULL), '{}')
+	FROM roles r
+	LEFT JOIN role_permissions rp ON rp.role_id = r.id
+`
+
+func (p *RolePostgres) CreatePermission(ctx context.Context, permission entity.Permission) error {
+	query := `INSERT INTO permissions (name, description, created_at) VALUES($1, $2, $3)`
+	_, err := p.Exec(ctx, query, permission.Name, permission.Description, permission.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return repoerrors.ErrAlreadyExists
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (p *RolePostgres) ListPermissions(ctx context.Context) ([]entity.Permission, error) {
+	query := `SELECT name, description, created_at FROM permissions ORDER BY name`
+	rows, err := p.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var permissions []entity.Permission
+	for rows.Next() {
+		var permission entity.Permission
+		err := rows.Scan(&permission.Name, &permission.Description, &permission.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		permissions = append(permissions, permission)
+	}
+
+	return permissions, rows.Err()
+}
+
+func (p *RolePostgres) DeletePermission(ctx context.Context, name string) error {
+	query := `DELETE FROM permissions WHERE name = $1`
+	res, err := p.Exec(ctx, query, name)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() < 1 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+func (p *RolePostgres) CreateRole(ctx context.Context, role entity.Role) (string, error) {
+	query := `INSERT INTO roles (name, description, created_at) VALUES($1, $2, $3) RETURNING id`
+
+	var id string
+	err := p.QueryRow(ctx, query, role.Name, role.Description, role.CreatedAt).Scan(&id)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return "", repoerrors.ErrAlreadyExists
+		}
+
+		return "", err
+	}
+
+	return id, nil
+}
+
+func (p *RolePostgres) GetRole(ctx context.Context, id string) (*entity.Role, error) {
+	query := roleQuery + ` WHERE r.id = $1 GROUP BY r.id`
+
+	var role entity.Role
+	err := p.QueryRow(ctx, query, id).Scan(&role.ID, &role.Name, &role.Description, &role.CreatedAt, &role.Permissions)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, repoerrors.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &role, nil
+}
+
+func (p *RolePostgres) ListRoles(ctx context.Context) ([]entity.Role, error) {
+	return p.queryRoles(ctx, roleQuery+` GROUP BY r.id ORDER BY r.name`)
+}
+
+// UpdateRole replaces the name and description of a role.
+func (p *RolePostgres) UpdateRole(ctx context.Context, role entity.Role) error {
+	query := `UPDATE roles SET name = $2, description = $3 WHERE id = $1`
+	res, err := p.Exec(ctx, query, role.ID, role.Name, role.Description)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return repoerrors.ErrAlreadyExists
+		}
+
+		return err
+	}
+
+	if res.RowsAffected() < 1 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+func (p *RolePostgres) DeleteRole(ctx context.Context, id string) error {
+	query := `DELETE FROM roles WHERE id = $1`
+	res, err := p.Exec(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() < 1 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+// SetRolePermissions replaces the permissions of a role. It returns ErrNotFound when one of them does not exist.
+func (p *RolePostgres) SetRolePermissions(ctx context.Context, roleID string, permissions []string) error {
+	batch := &pgx.Batch{}
+	batch.Queue(`DELETE FROM role_permissions WHERE role_id = $1`, roleID)
+	batch.Queue(`INSERT INTO role_permissions (role_id, permission) SELECT $1, unnest($2::varchar[])`, roleID, permissions)
+
+	err := p.SendBatch(ctx, batch)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return repoerrors.ErrNotFound
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// AssignRole gives a role to a user. It returns ErrNotFound when the user or the role does not exist.
+func (p *RolePostgres) AssignRole(ctx context.Context, userID, roleID string) error {
+	query := `INSERT INTO user_roles (user_id, role_id) VALUES($1, $2)`
+	_, err := p.Exec(ctx, query, userID, roleID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			switch pgErr.Code {
+			case "23505":
+				return repoerrors.ErrAlreadyExists
+			case "23503":
+				return repoerrors.ErrNotFound
+			}
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (p *RolePostgres) UnassignRole(ctx context.Context, userID, roleID string) error {
+	query := `DELETE FROM user_roles WHERE user_id = $1 AND role_id = $2`
+	res, err := p.Exec(ctx, query, userID, roleID)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() < 1 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+func (p *RolePostgres) ListUserRoles(ctx context.Context, userID string) ([]entity.Role, error) {
+	query := roleQuery + `
+		JOIN user_roles ur ON ur.role_id = r.id
+		WHERE ur.user_id = $1
+		GROUP BY r.id
+		ORDER BY r.name
+	`
+
+	return p.queryRoles(ctx, query, userID)
+}
+
+func (p *RolePostgres) queryRoles(ctx context.Context, query string, args ...interface{}) ([]entity.Role, error) {
+	rows, err := p.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []entity.Role
+	for rows.Next() {
+		var role entity.Role
+		err := rows.Scan(&role.ID, &role.Name, &role.Description, &role.CreatedAt, &role.Permissions)
+		if err != nil {
+			return nil, err
+		}
+
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
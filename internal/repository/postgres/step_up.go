@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v4"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository/repoerrors"
+)
+
+type StepUpPostgres struct {
+	*DB
+}
+
+func NewStepUpPostgres(db *DB) *StepUpPostgres {
+	return &StepUpPostgres{DB: db}
+}
+
+func (p *StepUpPostgres) CreateStepUpChallenge(ctx context.Context, challenge entity.StepUpChallenge) (string, error) {
+	query := `INSERT INTO step_up_challenges (user_id, code_hash, client_ip, operation, refresh_token_id, org_id, created_at, expires_at)
+				VALUES($1, $2, $3, $4, $5, $6, $7, $8)
+				RETURNING id`
+
+	var id string
+	err := p.QueryRow(ctx, query,
+		challenge.UserID,
+		challenge.CodeHash,
+		challenge.ClientIP,
+		challenge.Operation,
+		challenge.RefreshTokenID,
+		challenge.OrgID,
+		challenge.CreatedAt,
+		challenge.ExpiresAt,
+	).Scan(&id)
+
+	return id, err
+}
+
+// ReserveStepUpAttempt counts an attempt at the code of an unused, unexpired challenge that has had fewer
+// than maxAttempts and returns the challenge. Counting comes before the code is compared, so parallel
+// guesses cannot get past maxAttempts.
+func (p *StepUpPostgres) ReserveStepUpAttempt(ctx context.Context, id string, maxAttempts int) (*entity.StepUpChallenge, error) {
+	query := `
+		UPDATE step_up_challenges SET attempts = attempts + 1
+		WHERE id = $1 AND used_at IS NULL AND expires_at > NOW() AND attempts < $2
+		RETURNING id, user_id, code_hash, client_ip, operation, refresh_token_id, org_id, attempts, created_at, expires_at, used_at
+	`
+	var challenge entity.StepUpChallenge
+	err := p.QueryRow(ctx, query, id, maxAttempts).Scan(
+		&challenge.ID,
+		&challenge.UserID,
+		&challenge.CodeHash,
+		&challenge.ClientIP,
+		&challenge.Operation,
+		&challenge.RefreshTokenID,
+		&challenge.OrgID,
+		&challenge.Attempts,
+		&challenge.CreatedAt,
+		&challenge.ExpiresAt,
+		&challenge.UsedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, repoerrors.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &challenge, nil
+}
+
+// MarkStepUpChallengeUsed only succeeds once per challenge, so a code cannot issue tokens twice.
+func (p *StepUpPostgres) MarkStepUpChallengeUsed(ctx context.Context, id string) error {
+	query := `UPDATE step_up_challenges SET used_at = NOW() WHERE id = $1 AND used_at IS NULL`
+	res, err := p.Exec(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() < 1 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository/repoerrors"
+	"time"
+)
+
+type TokenPolicyPostgres struct {
+	*DB
+}
+
+func NewTokenPolicyPostgres(db *DB) *TokenPolicyPostgres {
+	return &TokenPolicyPostgres{DB: db}
+}
+
+func (p *TokenPolicyPostgres) GetTokenPolicy(ctx context.Context, orgID string) (*entity.TokenPolicy, error) {
+	query := `SELECT org_id, access_token_ttl_seconds, refresh_token_ttl_seconds, max_session_lifetime_seconds,
+				allowed_grants, require_mfa, signing_key, previous_signing_key, previous_key_expires_at, updated_at
+				FROM token_policies WHERE org_id = $1`
+
+	var policy entity.TokenPolicy
+	var accessTokenTTL, refreshTokenTTL, maxSessionLifetime int64
+	err := p.QueryRow(ctx, query, orgID).Scan(
+		&policy.OrgID,
+		&accessTokenTTL,
+		&refreshTokenTTL,
+		&maxSessionLifetime,
+		&policy.AllowedGrants,
+		&policy.RequireMFA,
+		&policy.SigningKey,
+		&policy.PreviousSigningKey,
+		&policy.PreviousKeyExpiresAt,
+		&policy.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, repoerrors.ErrNotFound
+		}
+		return nil, err
+	}
+	policy.AccessTokenTTL = time.Duration(accessTokenTTL) * time.Second
+	policy.RefreshTokenTTL = time.Duration(refreshTokenTTL) * time.Second
+	policy.MaxSessionLifetime = time.Duration(maxSessionLifetime) * time.Second
+
+	return &policy, nil
+}
+
+// UpsertTokenPolicy stores the settings of a policy and keeps its signing keys. It returns ErrNotFound
+// when the organization does not exist.
+func (p *TokenPolicyPostgres) UpsertTokenPolicy(ctx context.Context, policy entity.TokenPolicy) error {
+	query := `INSERT INTO token_policies (org_id, access_token_ttl_seconds, refresh_token_ttl_seconds,
+				max_session_lifetime_seconds, allowed_grants, require_mfa, updated_at)
+				VALUES($1, $2, $3, $4, $5, $6, $7)
+				ON CONFLICT (org_id) DO UPDATE SET
+					access_token_ttl_seconds = EXCLUDED.access_token_ttl_seconds,
+					refresh_token_ttl_seconds = EXCLUDED.refresh_token_ttl_seconds,
+					max_session_lifetime_seconds = EXCLUDED.max_session_lifetime_seconds,
+					allowed_grants = EXCLUDED.allowed_grants,
+					require_mfa = EXCLUDED.require_mfa,
+					updated_at = EXCLUDED.updated_at`
+
+	allowedGrants := policy.AllowedGrants
+	if allowedGrants == nil {
+		allowedGrants = []string{}
+	}
+
+	_, err := p.Exec(ctx, query,
+		policy.OrgID,
+		int64(policy.AccessTokenTTL/time.Second),
+		int64(policy.RefreshTokenTTL/time.Second),
+		int64(policy.MaxSessionLifetime/time.Second),
+		allowedGrants,
+		policy.RequireMFA,
+		policy.UpdatedAt)
+
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return repoerrors.ErrNotFound
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// UpdateTokenPolicyKeys stores the signing keys of a policy and keeps its settings, creating the policy
+// with the global settings when the organization has none yet.
+func (p *TokenPolicyPostgres) UpdateTokenPolicyKeys(ctx context.Context, policy entity.TokenPolicy) error {
+	query := `INSERT INTO token_policies (org_id, signing_key, previous_signing_key, previous_key_expires_at, updated_at)
+				VALUES($1, $2, $3, $4, $5)
+				ON CONFLICT (org_id) DO UPDATE SET
+					signing_key = EXCLUDED.signing_key,
+					previous_signing_key = EXCLUDED.previous_signing_key,
+					previous_key_expires_at = EXCLUDED.previous_key_expires_at,
+					updated_at = EXCLUDED.updated_at`
+
+	_, err := p.Exec(ctx, query,
+		policy.OrgID,
+		policy.SigningKey,
+		policy.PreviousSigningKey,
+		policy.PreviousKeyExpiresAt,
+		policy.UpdatedAt)
+
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return repoerrors.ErrNotFound
+		}
+
+		return err
+	}
+
+	return nil
+}
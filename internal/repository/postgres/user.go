@@ -3,27 +3,112 @@ package postgres
 import (
 	"context"
 	"errors"
+	"fmt"
+	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
 	"medods-tz/internal/entity"
 	"medods-tz/internal/repository/repoerrors"
+	"strings"
+	"time"
 )
 
 type UserPostgres struct {
-	*pgx.Conn
+	*DB
 }
 
-func NewUserPostgres(conn *pgx.Conn) *UserPostgres {
-	return &UserPostgres{Conn: conn}
+func NewUserPostgres(db *DB) *UserPostgres {
+	return &UserPostgres{DB: db}
+}
+
+// CreateUser stores a new user, active unless user.Status says otherwise.
+func (p *UserPostgres) CreateUser(ctx context.Context, user entity.User) (string, error) {
+	query := `INSERT INTO users (email, password_hash, locale, status, email_verified_at)
+				VALUES ($1, NULLIF($2, ''), $3, COALESCE(NULLIF($4, ''), 'active'), $5)
+				RETURNING id`
+
+	var id string
+	err := p.QueryRow(ctx, query, user.Email, user.PasswordHash, user.Locale, user.Status, user.EmailVerifiedAt).Scan(&id)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return "", repoerrors.ErrAlreadyExists
+		}
+
+		return "", err
+	}
+
+	return id, nil
 }
 
 func (p *UserPostgres) GetUserByID(ctx context.Context, id string) (*entity.User, error) {
 	query := `
-		SELECT *
+		SELECT ` + userColumns + `
 		FROM users
 		WHERE id = $1
 	`
-	var user entity.User
-	err := p.QueryRow(ctx, query, id).Scan(&user.ID, &user.Email, &user.CreatedAt, &user.UpdatedAt)
+
+	return p.getUser(ctx, query, id)
+}
+
+func (p *UserPostgres) GetUserByEmail(ctx context.Context, email string) (*entity.User, error) {
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		WHERE email = $1
+	`
+
+	return p.getUser(ctx, query, email)
+}
+
+// ListUsers returns users ordered by email.
+func (p *UserPostgres) ListUsers(ctx context.Context, filter entity.UserFilter) ([]entity.User, error) {
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.EmailPrefix != "" {
+		addCondition("starts_with(lower(email), lower($%d))", filter.EmailPrefix)
+	}
+	if filter.Status != "" {
+		addCondition("status = $%d", filter.Status)
+	}
+	if filter.AfterEmail != "" {
+		addCondition("email > $%d", filter.AfterEmail)
+	}
+
+	query := `SELECT ` + userColumns + ` FROM users`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY email LIMIT $%d", len(args))
+
+	rows, err := p.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []entity.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, *user)
+	}
+
+	return users, rows.Err()
+}
+
+const userColumns = `id, email, COALESCE(password_hash, ''), email_verified_at, locale, password_reset_required, status, created_at, updated_at`
+
+func (p *UserPostgres) getUser(ctx context.Context, query string, args ...interface{}) (*entity.User, error) {
+	user, err := scanUser(p.QueryRow(ctx, query, args...))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, repoerrors.ErrNotFound
@@ -31,5 +116,140 @@ func (p *UserPostgres) GetUserByID(ctx context.Context, id string) (*entity.User
 		return nil, err
 	}
 
+	return user, nil
+}
+
+func scanUser(row pgx.Row) (*entity.User, error) {
+	var user entity.User
+	err := row.Scan(
+		&user.ID,
+		&user.Email,
+		&user.PasswordHash,
+		&user.EmailVerifiedAt,
+		&user.Locale,
+		&user.PasswordResetRequired,
+		&user.Status,
+		&user.CreatedAt,
+		&user.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
 	return &user, nil
 }
+
+func (p *UserPostgres) UpdateUserEmail(ctx context.Context, id, email string) error {
+	query := `UPDATE users SET email = $1, email_verified_at = NULL, updated_at = NOW() WHERE id = $2`
+	res, err := p.Exec(ctx, query, email, id)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return repoerrors.ErrAlreadyExists
+		}
+
+		return err
+	}
+
+	if res.RowsAffected() < 1 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+func (p *UserPostgres) MarkEmailVerified(ctx context.Context, id string, verifiedAt time.Time) error {
+	query := `UPDATE users SET email_verified_at = $1, updated_at = NOW() WHERE id = $2`
+	res, err := p.Exec(ctx, query, verifiedAt, id)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() < 1 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+func (p *UserPostgres) UpdateUserPassword(ctx context.Context, id, passwordHash string) error {
+	query := `UPDATE users SET password_hash = $1, updated_at = NOW() WHERE id = $2`
+	res, err := p.Exec(ctx, query, passwordHash, id)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() < 1 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+func (p *UserPostgres) SetPasswordResetRequired(ctx context.Context, id string, required bool) error {
+	query := `UPDATE users SET password_reset_required = $1, updated_at = NOW() WHERE id = $2`
+	res, err := p.Exec(ctx, query, required, id)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() < 1 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+func (p *UserPostgres) UpdateUserLocale(ctx context.Context, id, locale string) error {
+	query := `UPDATE users SET locale = $1, updated_at = NOW() WHERE id = $2`
+	res, err := p.Exec(ctx, query, locale, id)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() < 1 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+// UpdateUser replaces the email, locale and status of a user. A new email is no longer verified.
+func (p *UserPostgres) UpdateUser(ctx context.Context, user entity.User) error {
+	query := `UPDATE users SET
+				email_verified_at = CASE WHEN email = $2 THEN email_verified_at END,
+				email = $2,
+				locale = $3,
+				status = $4,
+				updated_at = NOW()
+				WHERE id = $1`
+	res, err := p.Exec(ctx, query, user.ID, user.Email, user.Locale, user.Status)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return repoerrors.ErrAlreadyExists
+		}
+
+		return err
+	}
+
+	if res.RowsAffected() < 1 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+// DeleteUser removes a user, everything that belongs to them goes with it.
+func (p *UserPostgres) DeleteUser(ctx context.Context, id string) error {
+	query := `DELETE FROM users WHERE id = $1`
+	res, err := p.Exec(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() < 1 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
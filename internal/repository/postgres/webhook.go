@@ -0,0 +1,330 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v4"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository/repoerrors"
+	"strings"
+	"time"
+)
+
+type WebhookPostgres struct {
+	*DB
+}
+
+func NewWebhookPostgres(db *DB) *WebhookPostgres {
+	return &WebhookPostgres{DB: db}
+}
+
+func (p *WebhookPostgres) CreateWebhookSubscription(ctx context.Context, subscription entity.WebhookSubscription) (string, error) {
+	query := `INSERT INTO webhook_subscriptions (url, event_types, secret, enabled) VALUES ($1, $2, $3, $4) RETURNING id`
+
+	var id string
+	err := p.QueryRow(ctx, query,
+		subscription.URL,
+		subscription.EventTypes,
+		subscription.Secret,
+		subscription.Enabled,
+	).Scan(&id)
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+const webhookSubscriptionColumns = `id, url, event_types, secret, enabled, created_at, updated_at`
+
+func (p *WebhookPostgres) GetWebhookSubscription(ctx context.Context, id string) (*entity.WebhookSubscription, error) {
+	query := `SELECT ` + webhookSubscriptionColumns + ` FROM webhook_subscriptions WHERE id = $1`
+
+	subscriptions, err := p.queryWebhookSubscriptions(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(subscriptions) == 0 {
+		return nil, repoerrors.ErrNotFound
+	}
+
+	return &subscriptions[0], nil
+}
+
+func (p *WebhookPostgres) ListWebhookSubscriptions(ctx context.Context) ([]entity.WebhookSubscription, error) {
+	query := `SELECT ` + webhookSubscriptionColumns + ` FROM webhook_subscriptions ORDER BY created_at`
+
+	return p.queryWebhookSubscriptions(ctx, query)
+}
+
+// ListWebhookSubscriptionsForEvent returns enabled subscriptions to the event type or to all events.
+func (p *WebhookPostgres) ListWebhookSubscriptionsForEvent(ctx context.Context, eventType string) ([]entity.WebhookSubscription, error) {
+	query := `
+		SELECT ` + webhookSubscriptionColumns + `
+		FROM webhook_subscriptions
+		WHERE enabled AND ($1 = ANY(event_types) OR $2 = ANY(event_types))
+	`
+
+	return p.queryWebhookSubscriptions(ctx, query, eventType, entity.WebhookAllEvents)
+}
+
+func (p *WebhookPostgres) queryWebhookSubscriptions(ctx context.Context, query string, args ...interface{}) ([]entity.WebhookSubscription, error) {
+	rows, err := p.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subscriptions []entity.WebhookSubscription
+	for rows.Next() {
+		var subscription entity.WebhookSubscription
+		err := rows.Scan(
+			&subscription.ID,
+			&subscription.URL,
+			&subscription.EventTypes,
+			&subscription.Secret,
+			&subscription.Enabled,
+			&subscription.CreatedAt,
+			&subscription.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	return subscriptions, rows.Err()
+}
+
+func (p *WebhookPostgres) UpdateWebhookSubscription(ctx context.Context, subscription entity.WebhookSubscription) error {
+	query := `
+		UPDATE webhook_subscriptions
+		SET url = $1, event_types = $2, secret = $3, enabled = $4, updated_at = NOW()
+		WHERE id = $5
+	`
+	res, err := p.Exec(ctx, query,
+		subscription.URL,
+		subscription.EventTypes,
+		subscription.Secret,
+		subscription.Enabled,
+		subscription.ID)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() < 1 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+func (p *WebhookPostgres) DeleteWebhookSubscription(ctx context.Context, id string) error {
+	res, err := p.Exec(ctx, `DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() < 1 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+func (p *WebhookPostgres) CreateWebhookDeliveries(ctx context.Context, deliveries []entity.WebhookDelivery) error {
+	query := `INSERT INTO webhook_outbox (subscription_id, event_id, event_type, payload, next_attempt_at, created_at)
+				VALUES ($1, $2, $3, $4::jsonb, $5, $6)`
+
+	batch := &pgx.Batch{}
+	for _, delivery := range deliveries {
+		batch.Queue(query,
+			delivery.SubscriptionID,
+			delivery.EventID,
+			delivery.EventType,
+			string(delivery.Payload),
+			delivery.NextAttemptAt,
+			delivery.CreatedAt)
+	}
+
+	return p.SendBatch(ctx, batch)
+}
+
+// ClaimWebhookDeliveries leases due deliveries until lockedUntil, so concurrent workers
+// and replicas skip them. A worker that dies mid-delivery releases them when the lease expires.
+func (p *WebhookPostgres) ClaimWebhookDeliveries(ctx context.Context, now, lockedUntil time.Time, limit int) ([]entity.WebhookDelivery, error) {
+	query := `
+		WITH claimed AS (
+			UPDATE webhook_outbox SET locked_until = $2
+			WHERE id IN (
+				SELECT id FROM webhook_outbox
+				WHERE status = 'pending' AND next_attempt_at <= $1 AND (locked_until IS NULL OR locked_until <= $1)
+				ORDER BY next_attempt_at
+				LIMIT $3
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING *
+		)
+		SELECT ` + prefixColumns("c", webhookDeliveryColumns) + `, s.url, s.secret
+		FROM claimed c
+		JOIN webhook_subscriptions s ON s.id = c.subscription_id
+		ORDER BY c.id
+	`
+
+	rows, err := p.Query(ctx, query, now, lockedUntil, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []entity.WebhookDelivery
+	for rows.Next() {
+		var delivery entity.WebhookDelivery
+		err := rows.Scan(append(webhookDeliveryFields(&delivery), &delivery.URL, &delivery.Secret)...)
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// UpdateWebhookDelivery stores the outcome of an attempt and releases the lease.
+func (p *WebhookPostgres) UpdateWebhookDelivery(ctx context.Context, delivery entity.WebhookDelivery) error {
+	query := `
+		UPDATE webhook_outbox
+		SET status = $1, attempts = $2, next_attempt_at = $3, last_error = $4, last_status_code = $5,
+			delivered_at = $6, locked_until = NULL
+		WHERE id = $7
+	`
+	res, err := p.Exec(ctx, query,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.NextAttemptAt,
+		delivery.LastError,
+		delivery.LastStatusCode,
+		delivery.DeliveredAt,
+		delivery.ID)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() < 1 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+// RequeueWebhookDelivery gives a delivery a fresh set of attempts starting at the given time.
+func (p *WebhookPostgres) RequeueWebhookDelivery(ctx context.Context, id int64, at time.Time) error {
+	query := `
+		UPDATE webhook_outbox
+		SET status = 'pending', attempts = 0, next_attempt_at = $1, locked_until = NULL
+		WHERE id = $2 AND status <> 'delivered'
+	`
+	res, err := p.Exec(ctx, query, at, id)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() < 1 {
+		return repoerrors.ErrNotFound
+	}
+
+	return nil
+}
+
+func (p *WebhookPostgres) ListWebhookDeliveries(ctx context.Context, filter entity.WebhookDeliveryFilter) ([]entity.WebhookDelivery, error) {
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.SubscriptionID != "" {
+		addCondition("subscription_id = $%d", filter.SubscriptionID)
+	}
+	if filter.Status != "" {
+		addCondition("status = $%d", filter.Status)
+	}
+	if filter.BeforeID > 0 {
+		addCondition("id < $%d", filter.BeforeID)
+	}
+
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_outbox`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	rows, err := p.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []entity.WebhookDelivery
+	for rows.Next() {
+		var delivery entity.WebhookDelivery
+		if err := rows.Scan(webhookDeliveryFields(&delivery)...); err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+const webhookDeliveryColumns = `id, subscription_id, event_id, event_type, payload::text, status, attempts, next_attempt_at, ` +
+	`last_error, last_status_code, created_at, delivered_at`
+
+func webhookDeliveryFields(delivery *entity.WebhookDelivery) []interface{} {
+	return []interface{}{
+		&delivery.ID,
+		&delivery.SubscriptionID,
+		&delivery.EventID,
+		&delivery.EventType,
+		(*rawJSON)(&delivery.Payload),
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&delivery.LastError,
+		&delivery.LastStatusCode,
+		&delivery.CreatedAt,
+		&delivery.DeliveredAt,
+	}
+}
+
+var errUnexpectedJSONType = errors.New("unexpected type for json column")
+
+// rawJSON scans the text form of a json column without decoding it.
+type rawJSON []byte
+
+func (r *rawJSON) Scan(src interface{}) error {
+	switch value := src.(type) {
+	case string:
+		*r = rawJSON(value)
+	case []byte:
+		*r = append(rawJSON(nil), value...)
+	default:
+		return errUnexpectedJSONType
+	}
+
+	return nil
+}
+
+func prefixColumns(prefix, columns string) string {
+	parts := strings.Split(columns, ", ")
+	for i, column := range parts {
+		parts[i] = prefix + "." + column
+	}
+
+	return strings.Join(parts, ", ")
+}
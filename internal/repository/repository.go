@@ -2,29 +2,234 @@ package repository
 
 import (
 	"context"
-	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
 	"medods-tz/internal/entity"
 	"medods-tz/internal/repository/postgres"
+	"time"
 )
 
+// Transactor runs fn in a database transaction. Repository calls made with the ctx passed to fn
+// take part in it, nested calls join the outer transaction. WithinTenant also scopes the transaction
+// to an organization: row-level security hides the rows of all other organizations from it. Outside of
+// a tenant scope only rows that belong to no organization are visible, WithinAllTenants lifts that for
+// admin and system paths that work across organizations.
+type Transactor interface {
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+	WithinTenant(ctx context.Context, orgID string, fn func(ctx context.Context) error) error
+	WithinAllTenants(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
 type TokenRepository interface {
 	CreateRefreshToken(ctx context.Context, token entity.RefreshToken) error
 	GetRefreshTokenEntitiesByUserID(ctx context.Context, userID string) ([]entity.RefreshToken, error)
 	MarkRefreshTokenUsed(ctx context.Context, refreshID string) error
+	RevokeRefreshToken(ctx context.Context, tokenID string) error
+	RevokeRefreshTokensByUserID(ctx context.Context, userID string) error
+	RevokeRefreshTokensByDeviceID(ctx context.Context, deviceID string) error
+	ListOrgRefreshTokens(ctx context.Context, orgID string, at time.Time) ([]entity.RefreshToken, error)
+	RevokeOrgRefreshTokensByUserID(ctx context.Context, orgID, userID string) error
 }
 
 type UserRepository interface {
+	CreateUser(ctx context.Context, user entity.User) (string, error)
 	GetUserByID(ctx context.Context, id string) (*entity.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*entity.User, error)
+	UpdateUserEmail(ctx context.Context, id, email string) error
+	UpdateUserPassword(ctx context.Context, id, passwordHash string) error
+	MarkEmailVerified(ctx context.Context, id string, verifiedAt time.Time) error
+	UpdateUserLocale(ctx context.Context, id, locale string) error
+	SetPasswordResetRequired(ctx context.Context, id string, required bool) error
+	ListUsers(ctx context.Context, filter entity.UserFilter) ([]entity.User, error)
+	UpdateUser(ctx context.Context, user entity.User) error
+	DeleteUser(ctx context.Context, id string) error
+}
+
+type LoginReportRepository interface {
+	CreateLoginReport(ctx context.Context, report entity.LoginReport) error
+}
+
+type StepUpRepository interface {
+	CreateStepUpChallenge(ctx context.Context, challenge entity.StepUpChallenge) (string, error)
+	ReserveStepUpAttempt(ctx context.Context, id string, maxAttempts int) (*entity.StepUpChallenge, error)
+	MarkStepUpChallengeUsed(ctx context.Context, id string) error
+}
+
+type DeviceRepository interface {
+	UpsertDevice(ctx context.Context, device entity.Device) (string, error)
+	GetDevice(ctx context.Context, userID, id string) (*entity.Device, error)
+	GetDeviceByKeyHash(ctx context.Context, userID, keyHash string) (*entity.Device, error)
+	ListDevices(ctx context.Context, userID string) ([]entity.Device, error)
+	CountDevices(ctx context.Context, userID string) (int, error)
+	TouchDevice(ctx context.Context, id, ip string, at time.Time) error
+	RenameDevice(ctx context.Context, userID, id, name string) error
+	DeleteDevice(ctx context.Context, userID, id string) error
+}
+
+type IPRuleRepository interface {
+	CreateIPRule(ctx context.Context, rule entity.IPRule) (string, error)
+	ListIPRules(ctx context.Context, userID string) ([]entity.IPRule, error)
+	DeleteIPRule(ctx context.Context, userID, id string) error
+	ListOrgIPRules(ctx context.Context, orgID string) ([]entity.IPRule, error)
+	DeleteOrgIPRule(ctx context.Context, orgID, id string) error
+}
+
+type OrganizationRepository interface {
+	CreateOrganization(ctx context.Context, org entity.Organization) (string, error)
+	GetOrganization(ctx context.Context, id string) (*entity.Organization, error)
+	ListOrganizations(ctx context.Context) ([]entity.Organization, error)
+	UpdateOrganization(ctx context.Context, org entity.Organization) error
+	DeleteOrganization(ctx context.Context, id string) error
+	UpsertMembership(ctx context.Context, membership entity.Membership) error
+	GetMembership(ctx context.Context, orgID, userID string) (*entity.Membership, error)
+	ListMemberships(ctx context.Context, orgID string) ([]entity.Membership, error)
+	ListUserMemberships(ctx context.Context, userID string) ([]entity.Membership, error)
+	DeleteMembership(ctx context.Context, orgID, userID string) error
+}
+
+type TokenPolicyRepository interface {
+	GetTokenPolicy(ctx context.Context, orgID string) (*entity.TokenPolicy, error)
+	UpsertTokenPolicy(ctx context.Context, policy entity.TokenPolicy) error
+	UpdateTokenPolicyKeys(ctx context.Context, policy entity.TokenPolicy) error
+}
+
+type RoleRepository interface {
+	CreatePermission(ctx context.Context, permission entity.Permission) error
+	ListPermissions(ctx context.Context) ([]entity.Permission, error)
+	DeletePermission(ctx context.Context, name string) error
+	CreateRole(ctx context.Context, role entity.Role) (string, error)
+	GetRole(ctx context.Context, id string) (*entity.Role, error)
+	ListRoles(ctx context.Context) ([]entity.Role, error)
+	UpdateRole(ctx context.Context, role entity.Role) error
+	DeleteRole(ctx context.Context, id string) error
+	SetRolePermissions(ctx context.Context, roleID string, permissions []string) error
+	AssignRole(ctx context.Context, userID, roleID string) error
+	UnassignRole(ctx context.Context, userID, roleID string) error
+	ListUserRoles(ctx context.Context, userID string) ([]entity.Role, error)
+}
+
+type RelationRepository interface {
+	CreateRelationRevision(ctx context.Context) (int64, error)
+	GetRelationRevisions(ctx context.Context) (oldest, latest int64, err error)
+	WriteRelationTuples(ctx context.Context, writes, deletes []entity.RelationTuple, revision int64) error
+	ListRelationSubjects(ctx context.Context, namespace, objectID, relation string, revision int64) ([]entity.RelationSubject, error)
+	ListRelationTuplesBySubject(ctx context.Context, subject entity.RelationSubject, revision int64) ([]entity.RelationTuple, error)
+	PruneRelationRevisions(ctx context.Context, before time.Time) error
+}
+
+type PasswordResetRepository interface {
+	CreatePasswordResetToken(ctx context.Context, token entity.PasswordResetToken) error
+	GetPasswordResetTokenByHash(ctx context.Context, tokenHash string) (*entity.PasswordResetToken, error)
+	MarkPasswordResetTokenUsed(ctx context.Context, id string) error
+	CreatePasswordResetRequest(ctx context.Context, request entity.PasswordResetRequest) error
+	ClaimPasswordResetRequests(ctx context.Context, limit int) ([]entity.PasswordResetRequest, error)
+}
+
+type LoginAttemptRepository interface {
+	GetLoginAttempts(ctx context.Context, key string) (*entity.LoginAttempts, error)
+	IncrementLoginFailures(ctx context.Context, key string, at time.Time, window time.Duration) (*entity.LoginAttempts, error)
+	LockLoginAttempts(ctx context.Context, key string, until time.Time) error
+	DeleteLoginAttempts(ctx context.Context, key string) error
+}
+
+type AccountUnlockRepository interface {
+	CreateAccountUnlock(ctx context.Context, unlock entity.AccountUnlock) error
+}
+
+type RateLimitRepository interface {
+	TakeRateLimitToken(ctx context.Context, key string, capacity, refillPerSecond float64, at time.Time) (*entity.RateLimitBucket, error)
+	PruneRateLimitBuckets(ctx context.Context, before time.Time) error
+}
+
+type AuditRepository interface {
+	CreateAuditEvent(ctx context.Context, event entity.AuditEvent) (int64, error)
+	ListAuditEvents(ctx context.Context, filter entity.AuditFilter) ([]entity.AuditEvent, error)
+	ListAuditChain(ctx context.Context, afterID int64, limit int) ([]entity.AuditEvent, error)
+	ListUncheckpointedAuditDays(ctx context.Context, before time.Time) ([]time.Time, error)
+	GetAuditDaySummary(ctx context.Context, day time.Time) (*entity.AuditCheckpoint, error)
+	CreateAuditCheckpoint(ctx context.Context, checkpoint entity.AuditCheckpoint) error
+	GetLastAuditCheckpoint(ctx context.Context) (*entity.AuditCheckpoint, error)
+	ListAuditCheckpoints(ctx context.Context) ([]entity.AuditCheckpoint, error)
+}
+
+type WebhookRepository interface {
+	CreateWebhookSubscription(ctx context.Context, subscription entity.WebhookSubscription) (string, error)
+	GetWebhookSubscription(ctx context.Context, id string) (*entity.WebhookSubscription, error)
+	ListWebhookSubscriptions(ctx context.Context) ([]entity.WebhookSubscription, error)
+	ListWebhookSubscriptionsForEvent(ctx context.Context, eventType string) ([]entity.WebhookSubscription, error)
+	UpdateWebhookSubscription(ctx context.Context, subscription entity.WebhookSubscription) error
+	DeleteWebhookSubscription(ctx context.Context, id string) error
+	CreateWebhookDeliveries(ctx context.Context, deliveries []entity.WebhookDelivery) error
+	ClaimWebhookDeliveries(ctx context.Context, now, lockedUntil time.Time, limit int) ([]entity.WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, delivery entity.WebhookDelivery) error
+	RequeueWebhookDelivery(ctx context.Context, id int64, at time.Time) error
+	ListWebhookDeliveries(ctx context.Context, filter entity.WebhookDeliveryFilter) ([]entity.WebhookDelivery, error)
+}
+
+type EmailRepository interface {
+	CreateEmailMessage(ctx context.Context, message entity.EmailMessage) (int64, error)
+	ClaimEmailMessages(ctx context.Context, now, lockedUntil time.Time, limit int) ([]entity.EmailMessage, error)
+	UpdateEmailMessage(ctx context.Context, message entity.EmailMessage) error
+	RequeueEmailMessage(ctx context.Context, id int64, at time.Time) error
+	ListEmailMessages(ctx context.Context, filter entity.EmailMessageFilter) ([]entity.EmailMessage, error)
+}
+
+type NotificationRepository interface {
+	ListNotificationPreferences(ctx context.Context, userID string) ([]entity.NotificationPreference, error)
+	ReplaceNotificationPreferences(ctx context.Context, userID string, preferences []entity.NotificationPreference) error
+	ThrottleNotification(ctx context.Context, userID, template, key string, now, windowStart time.Time) (bool, error)
+	CountNotificationsSince(ctx context.Context, userID, template string, since time.Time) (int, error)
+	DeleteNotificationThrottles(ctx context.Context, sentBefore time.Time) error
+	CreateNotificationDigestItem(ctx context.Context, item entity.NotificationDigestItem) error
+	ListDueNotificationDigests(ctx context.Context, createdBefore time.Time) ([]string, error)
+	TakeNotificationDigestItems(ctx context.Context, userID string) ([]entity.NotificationDigestItem, error)
 }
 
 type Repository struct {
+	Transactor
 	TokenRepository
 	UserRepository
+	PasswordResetRepository
+	LoginAttemptRepository
+	AccountUnlockRepository
+	RateLimitRepository
+	AuditRepository
+	WebhookRepository
+	EmailRepository
+	NotificationRepository
+	LoginReportRepository
+	StepUpRepository
+	DeviceRepository
+	IPRuleRepository
+	OrganizationRepository
+	TokenPolicyRepository
+	RoleRepository
+	RelationRepository
 }
 
-func NewRepository(pgConn *pgx.Conn) *Repository {
+func NewRepository(pool *pgxpool.Pool) *Repository {
+	db := postgres.NewDB(pool)
+
 	return &Repository{
-		TokenRepository: postgres.NewTokenPostgres(pgConn),
-		UserRepository:  postgres.NewUserPostgres(pgConn),
+		Transactor: db,
+
+		TokenRepository: postgres.NewTokenPostgres(db),
+		UserRepository:  postgres.NewUserPostgres(db),
+
+		PasswordResetRepository: postgres.NewPasswordResetPostgres(db),
+		LoginAttemptRepository:  postgres.NewLoginAttemptPostgres(db),
+		AccountUnlockRepository: postgres.NewAccountUnlockPostgres(db),
+		RateLimitRepository:     postgres.NewRateLimitPostgres(db),
+		AuditRepository:         postgres.NewAuditPostgres(db),
+		WebhookRepository:       postgres.NewWebhookPostgres(db),
+		EmailRepository:         postgres.NewEmailPostgres(db),
+		NotificationRepository:  postgres.NewNotificationPostgres(db),
+		LoginReportRepository:   postgres.NewLoginReportPostgres(db),
+		StepUpRepository:        postgres.NewStepUpPostgres(db),
+		DeviceRepository:        postgres.NewDevicePostgres(db),
+		IPRuleRepository:        postgres.NewIPRulePostgres(db),
+		OrganizationRepository:  postgres.NewOrganizationPostgres(db),
+		TokenPolicyRepository:   postgres.NewTokenPolicyPostgres(db),
+		RoleRepository:          postgres.NewRolePostgres(db),
+		RelationRepository:      postgres.NewRelationPostgres(db),
 	}
 }
@@ -0,0 +1,88 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Channels a notification can be delivered through. Each has its own Transport, Message.To is
+// the address in that channel: an email address, a chat id, a phone number and so on.
+const (
+	ChannelEmail    = "email"
+	ChannelWebhook  = "webhook"
+	ChannelTelegram = "telegram"
+	ChannelSlack    = "slack"
+	ChannelSMS      = "sms"
+	// ChannelMailbox writes messages to files for local development.
+	ChannelMailbox = "mailbox"
+)
+
+var ChannelNames = []string{ChannelEmail, ChannelWebhook, ChannelTelegram, ChannelSlack, ChannelSMS, ChannelMailbox}
+
+// maxErrorBody limits how much of an error response ends up in the outbox.
+const maxErrorBody = 512
+
+// PlainText is the message as a single text, for channels without subjects.
+func (m Message) PlainText() string {
+	if m.TextBody == "" {
+		return m.Subject
+	}
+
+	return m.Subject + "\n\n" + m.TextBody
+}
+
+// postJSON posts body as JSON and returns the response body. Responses other than 2xx are errors.
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, body any) ([]byte, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, truncate(strings.TrimSpace(string(respBody)), maxErrorBody))
+	}
+
+	return respBody, nil
+}
+
+// redactURL hides path secrets such as bot tokens from errors that end up in the outbox.
+func redactURL(err error, secret string) error {
+	if err == nil || secret == "" {
+		return err
+	}
+
+	return fmt.Errorf("%s", strings.ReplaceAll(err.Error(), secret, "<redacted>"))
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	return s[:n]
+}
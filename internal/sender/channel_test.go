@@ -0,0 +1,69 @@
+package sender
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTelegramChannel_Send(t *testing.T) {
+	var request map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/botsecret-token/sendMessage", r.URL.Path)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+
+		if request["chat_id"] == "blocked" {
+			http.Error(w, `{"ok":false,"description":"Forbidden: bot was blocked by the user"}`, http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	channel := NewTelegramChannel(server.URL+"/", "secret-token", server.Client())
+	message := Message{To: "42", Subject: "New sign-in", TextBody: "details"}
+
+	assert.NoError(t, channel.Send(context.Background(), message))
+	assert.Equal(t, "New sign-in\n\ndetails", request["text"])
+
+	message.To = "blocked"
+	err := channel.Send(context.Background(), message)
+	assert.ErrorContains(t, err, "bot was blocked")
+	assert.NotContains(t, err.Error(), "secret-token")
+}
+
+func TestSlackChannel_Send_NotOK(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer xoxb-token", r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(`{"ok":false,"error":"channel_not_found"}`))
+	}))
+	defer server.Close()
+
+	err := NewSlackChannel(server.URL, "xoxb-token", server.Client()).Send(context.Background(), Message{To: "C123", Subject: "s"})
+
+	assert.EqualError(t, err, "channel_not_found")
+}
+
+func TestMailbox_Send(t *testing.T) {
+	dir := t.TempDir()
+	mailbox := NewMailbox(dir, "auth@example.com")
+
+	err := mailbox.Send(context.Background(), Message{Kind: TemplateVerifyEmail, To: "user@example.com", Subject: "Verify", TextBody: "text part", HTMLBody: "<p>html part</p>"})
+	assert.NoError(t, err)
+
+	files, err := filepath.Glob(filepath.Join(dir, "user@example.com", "*-verify_email.eml"))
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	content, err := os.ReadFile(files[0])
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(string(content), "Subject: Verify"))
+	assert.Contains(t, string(content), "text part")
+	assert.Contains(t, string(content), "multipart/alternative")
+}
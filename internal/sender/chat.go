@@ -0,0 +1,96 @@
+package sender
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// TelegramChannel sends notifications through the Telegram Bot API, Message.To is the chat id.
+type TelegramChannel struct {
+	APIURL   string
+	BotToken string
+	client   *http.Client
+}
+
+func NewTelegramChannel(apiURL, botToken string, client *http.Client) *TelegramChannel {
+	return &TelegramChannel{
+		APIURL:   strings.TrimSuffix(apiURL, "/"),
+		BotToken: botToken,
+		client:   client,
+	}
+}
+
+func (t *TelegramChannel) Send(ctx context.Context, message Message) error {
+	// the token is part of the URL, it must not end up in the outbox with an error
+	url := fmt.Sprintf("%s/bot%s/sendMessage", t.APIURL, t.BotToken)
+	body, err := postJSON(ctx, t.client, url, nil, map[string]any{
+		"chat_id":                  message.To,
+		"text":                     message.PlainText(),
+		"disable_web_page_preview": true,
+	})
+	if err != nil {
+		return redactURL(err, t.BotToken)
+	}
+
+	return checkChatResponse(body)
+}
+
+// SlackChannel sends notifications through chat.postMessage of a Slack-compatible bot API,
+// Message.To is the channel or user id.
+type SlackChannel struct {
+	APIURL   string
+	BotToken string
+	client   *http.Client
+}
+
+func NewSlackChannel(apiURL, botToken string, client *http.Client) *SlackChannel {
+	return &SlackChannel{
+		APIURL:   strings.TrimSuffix(apiURL, "/"),
+		BotToken: botToken,
+		client:   client,
+	}
+}
+
+func (s *SlackChannel) Send(ctx context.Context, message Message) error {
+	body, err := postJSON(ctx, s.client, s.APIURL+"/chat.postMessage", map[string]string{"Authorization": "Bearer " + s.BotToken}, map[string]any{
+		"channel": message.To,
+		"text":    message.PlainText(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return checkChatResponse(body)
+}
+
+// chatResponse is the envelope of both bot APIs. They answer some errors with status 200 and "ok": false.
+type chatResponse struct {
+	OK          bool   `json:"ok"`
+	Error       string `json:"error"`
+	Description string `json:"description"`
+}
+
+func checkChatResponse(body []byte) error {
+	var resp chatResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+
+	if !resp.OK {
+		reason := resp.Description
+		if reason == "" {
+			reason = resp.Error
+		}
+		if reason == "" {
+			reason = "request was not accepted"
+		}
+
+		return errors.New(reason)
+	}
+
+	return nil
+}
@@ -1,46 +1,234 @@
 package sender
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"gopkg.in/gomail.v2"
+	"medods-tz/pkg/breaker"
+	"medods-tz/pkg/dkim"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
 )
 
+// ErrTransportUnavailable is returned without an attempt while the server is known to be down.
+var ErrTransportUnavailable = errors.New("transport is temporarily unavailable")
+
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+	// From defaults to User.
+	From    string
+	TLSMode TLSMode
+	// Timeout bounds dialing and every send.
+	Timeout time.Duration
+	// PoolSize connections are kept open between sends, and at most as many are open at once.
+	PoolSize int
+	// IdleTimeout closes pooled connections unused for longer, servers drop them after a while anyway.
+	IdleTimeout time.Duration
+	// DKIM signs outgoing mail when it is set.
+	DKIM *dkim.Signer
+	// BreakerThreshold consecutive connection failures fail further sends for BreakerCooldown.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// EmailSender is the SMTP Transport. It keeps a small pool of authenticated connections and
+// reconnects when the server has closed one.
 type EmailSender struct {
-	SMTPHost     string
-	SMTPPort     int
-	SMTPUser     string
-	SMTPPassword string
+	config  SMTPConfig
+	breaker *breaker.Breaker
+	// idle holds the pooled connections, slots limits the open ones
+	idle  chan *smtpConn
+	slots chan struct{}
 }
 
-func NewEmailSender(host string, port int, user, password string) *EmailSender {
+func NewEmailSender(config SMTPConfig) (*EmailSender, error) {
+	switch config.TLSMode {
+	case TLSModeStartTLS, TLSModeImplicit, TLSModeNone:
+	default:
+		return nil, fmt.Errorf("unknown smtp tls mode %q", config.TLSMode)
+	}
+	if config.From == "" {
+		config.From = config.User
+	}
+	if _, err := mail.ParseAddress(config.From); err != nil {
+		return nil, fmt.Errorf("invalid smtp from address: %w", err)
+	}
+	config.PoolSize = max(config.PoolSize, 1)
+
 	return &EmailSender{
-		SMTPHost:     host,
-		SMTPPort:     port,
-		SMTPUser:     user,
-		SMTPPassword: password,
+		config:  config,
+		breaker: breaker.New(config.BreakerThreshold, config.BreakerCooldown),
+		idle:    make(chan *smtpConn, config.PoolSize),
+		slots:   make(chan struct{}, config.PoolSize),
+	}, nil
+}
+
+func (e *EmailSender) Send(ctx context.Context, message Message) error {
+	data, err := e.compose(message)
+	if err != nil {
+		return err
+	}
+
+	if err := e.breaker.Allow(); err != nil {
+		return fmt.Errorf("%w: smtp server: %w", ErrTransportUnavailable, err)
+	}
+
+	err = e.send(ctx, message.To, data)
+
+	// errors of the server about the message or its recipient do not mean the server is down
+	var protocolErr *textproto.Error
+	if err == nil || errors.As(err, &protocolErr) {
+		e.breaker.Success()
+	} else {
+		e.breaker.Failure()
+	}
+
+	return err
+}
+
+func (e *EmailSender) send(ctx context.Context, to string, data []byte) error {
+	select {
+	case e.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-e.slots }()
+
+	conn, reused, err := e.acquire(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = conn.send(ctx, e.config.Timeout, e.config.From, to, data)
+	if err != nil && reused && !isProtocolError(err) {
+		// the server may have closed the pooled connection in the meantime
+		conn.close()
+		conn, err = dialSMTP(ctx, e.config)
+		if err != nil {
+			return err
+		}
+		err = conn.send(ctx, e.config.Timeout, e.config.From, to, data)
+	}
+
+	e.release(conn, err)
+
+	return err
+}
+
+// acquire returns a pooled connection that is still usable or dials a new one.
+func (e *EmailSender) acquire(ctx context.Context) (*smtpConn, bool, error) {
+	for {
+		select {
+		case conn := <-e.idle:
+			if time.Since(conn.lastUsed) > e.config.IdleTimeout {
+				conn.close()
+				continue
+			}
+
+			return conn, true, nil
+		default:
+			conn, err := dialSMTP(ctx, e.config)
+			return conn, false, err
+		}
 	}
 }
 
-func (e *EmailSender) SendWarningEmail(toEmail, subject, body string) error {
-	message := gomail.NewMessage()
-	message.SetHeader("From", e.SMTPUser)
-	message.SetHeader("To", toEmail)
-	message.SetHeader("Subject", subject)
-	message.SetBody("text/html", body)
+// release pools the connection unless it failed, a rejected message leaves it usable after a reset.
+func (e *EmailSender) release(conn *smtpConn, err error) {
+	if err != nil && (!isProtocolError(err) || conn.client.Reset() != nil) {
+		conn.close()
+		return
+	}
+
+	conn.lastUsed = time.Now()
+	select {
+	case e.idle <- conn:
+	default:
+		conn.close()
+	}
+}
+
+// compose renders the MIME message and signs it.
+func (e *EmailSender) compose(message Message) ([]byte, error) {
+	m := gomail.NewMessage()
+	m.SetHeader("From", e.config.From)
+	m.SetHeader("To", message.To)
+	m.SetHeader("Subject", message.Subject)
+	m.SetHeader("Message-ID", e.messageID())
+	m.SetDateHeader("Date", time.Now())
+	if message.TextBody != "" {
+		// the plain-text part comes first, clients show the last alternative they support
+		m.SetBody("text/plain", message.TextBody)
+		m.AddAlternative("text/html", message.HTMLBody)
+	} else {
+		m.SetBody("text/html", message.HTMLBody)
+	}
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		return nil, fmt.Errorf("error while composing email: %w", err)
+	}
+
+	if e.config.DKIM == nil {
+		return buf.Bytes(), nil
+	}
 
-	dialer := gomail.NewDialer(e.SMTPHost, e.SMTPPort, e.SMTPUser, e.SMTPPassword)
+	signed, err := e.config.DKIM.Sign(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("error while signing email: %w", err)
+	}
 
-	return dialer.DialAndSend(message)
+	return signed, nil
 }
 
-func (e *EmailSender) EnsureSMTPConnection() error {
-	dialer := gomail.NewDialer(e.SMTPHost, e.SMTPPort, e.SMTPUser, e.SMTPPassword)
+func (e *EmailSender) messageID() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+
+	domain := "localhost"
+	if address, err := mail.ParseAddress(e.config.From); err == nil {
+		if _, host, ok := strings.Cut(address.Address, "@"); ok {
+			domain = host
+		}
+	}
+
+	return "<" + hex.EncodeToString(id) + "@" + domain + ">"
+}
 
-	conn, err := dialer.Dial()
+// EnsureSMTPConnection dials and authenticates once, to report a misconfigured server at startup.
+func (e *EmailSender) EnsureSMTPConnection(ctx context.Context) error {
+	conn, err := dialSMTP(ctx, e.config)
 	if err != nil {
 		return fmt.Errorf("failed to connect to SMTP server: %w", err)
 	}
-	defer conn.Close()
+
+	e.release(conn, nil)
 
 	return nil
 }
+
+// Close closes the pooled connections.
+func (e *EmailSender) Close() {
+	for {
+		select {
+		case conn := <-e.idle:
+			conn.quit()
+		default:
+			return
+		}
+	}
+}
+
+func isProtocolError(err error) bool {
+	var protocolErr *textproto.Error
+	return errors.As(err, &protocolErr)
+}
@@ -0,0 +1,218 @@
+package sender
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"medods-tz/pkg/breaker"
+	"medods-tz/pkg/dkim"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSMTPServer speaks just enough SMTP for EmailSender, recipients containing "rejected" are refused.
+type fakeSMTPServer struct {
+	listener net.Listener
+
+	mu          sync.Mutex
+	connections int
+	auth        []string
+	messages    []string
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &fakeSMTPServer{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.connections++
+			s.mu.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { _ = listener.Close() })
+
+	return s
+}
+
+func (s *fakeSMTPServer) config() SMTPConfig {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+
+	return SMTPConfig{
+		Host:             host,
+		Port:             portNumber,
+		User:             "auth@example.com",
+		Password:         "password",
+		TLSMode:          TLSModeNone,
+		Timeout:          time.Second,
+		PoolSize:         1,
+		IdleTimeout:      time.Minute,
+		BreakerThreshold: 2,
+		BreakerCooldown:  time.Minute,
+	}
+}
+
+func (s *fakeSMTPServer) serve(conn net.Conn) {
+	text := textproto.NewConn(conn)
+	defer text.Close()
+
+	_ = text.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+
+		command, argument, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(command) {
+		case "EHLO":
+			_ = text.PrintfLine("250-localhost\r\n250 AUTH PLAIN LOGIN")
+		case "AUTH":
+			s.mu.Lock()
+			s.auth = append(s.auth, argument)
+			s.mu.Unlock()
+			_ = text.PrintfLine("235 2.7.0 Authentication successful")
+		case "RCPT":
+			if strings.Contains(argument, "rejected") {
+				_ = text.PrintfLine("550 5.1.1 No such user")
+				continue
+			}
+			_ = text.PrintfLine("250 OK")
+		case "DATA":
+			_ = text.PrintfLine("354 Go ahead")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, string(data))
+			s.mu.Unlock()
+			_ = text.PrintfLine("250 OK")
+		case "QUIT":
+			_ = text.PrintfLine("221 Bye")
+			return
+		default:
+			_ = text.PrintfLine("250 OK")
+		}
+	}
+}
+
+func TestEmailSender_Send_ReusesConnection(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	emailSender, err := NewEmailSender(server.config())
+	require.NoError(t, err)
+	defer emailSender.Close()
+
+	ctx := context.Background()
+	message := Message{To: "user@example.com", Subject: "New sign-in", TextBody: "text part", HTMLBody: "<p>html part</p>"}
+	assert.NoError(t, emailSender.Send(ctx, message))
+	assert.NoError(t, emailSender.Send(ctx, message))
+
+	rejected := message
+	rejected.To = "rejected@example.com"
+	assert.Error(t, emailSender.Send(ctx, rejected))
+	assert.NoError(t, emailSender.Send(ctx, message))
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	assert.Equal(t, 1, server.connections)
+	assert.Len(t, server.auth, 1)
+	assert.True(t, strings.HasPrefix(server.auth[0], "PLAIN "))
+	assert.Len(t, server.messages, 3)
+	assert.Contains(t, server.messages[0], "Subject: New sign-in")
+	assert.Contains(t, server.messages[0], "Message-ID: <")
+	assert.Contains(t, server.messages[0], "text part")
+	assert.Contains(t, server.messages[0], "html part")
+}
+
+func TestEmailSender_Send_ReconnectsClosedConnection(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	emailSender, err := NewEmailSender(server.config())
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	message := Message{To: "user@example.com", Subject: "s", HTMLBody: "<p>html part</p>"}
+	assert.NoError(t, emailSender.Send(ctx, message))
+
+	// the pooled connection is dropped while idle
+	pooled := <-emailSender.idle
+	_ = pooled.conn.Close()
+	emailSender.idle <- pooled
+
+	assert.NoError(t, emailSender.Send(ctx, message))
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	assert.Equal(t, 2, server.connections)
+	assert.Len(t, server.messages, 2)
+}
+
+func TestEmailSender_Send_CircuitBreaker(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	config := server.config()
+	_ = server.listener.Close()
+
+	emailSender, err := NewEmailSender(config)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	message := Message{To: "user@example.com", Subject: "s", HTMLBody: "<p>html part</p>"}
+	for range config.BreakerThreshold {
+		err = emailSender.Send(ctx, message)
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, ErrTransportUnavailable)
+	}
+
+	err = emailSender.Send(ctx, message)
+	assert.ErrorIs(t, err, ErrTransportUnavailable)
+	assert.ErrorIs(t, err, breaker.ErrOpen)
+}
+
+func TestEmailSender_Send_DKIM(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	signer, err := dkim.NewSigner("example.com", "mail", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil)
+	require.NoError(t, err)
+
+	server := newFakeSMTPServer(t)
+	config := server.config()
+	config.DKIM = signer
+	emailSender, err := NewEmailSender(config)
+	require.NoError(t, err)
+	defer emailSender.Close()
+
+	assert.NoError(t, emailSender.Send(context.Background(), Message{To: "user@example.com", Subject: "s", HTMLBody: "<p>html part</p>"}))
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	require.Len(t, server.messages, 1)
+	assert.True(t, strings.HasPrefix(server.messages[0], "DKIM-Signature: v=1; a=ed25519-sha256;"))
+	assert.Contains(t, server.messages[0], "d=example.com;")
+}
+
+func TestNewEmailSender_InvalidConfig(t *testing.T) {
+	_, err := NewEmailSender(SMTPConfig{User: "auth@example.com", TLSMode: "ssl"})
+	assert.ErrorContains(t, err, "unknown smtp tls mode")
+
+	_, err = NewEmailSender(SMTPConfig{User: "not an address", TLSMode: TLSModeStartTLS})
+	assert.ErrorContains(t, err, "invalid smtp from address")
+}
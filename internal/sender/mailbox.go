@@ -0,0 +1,83 @@
+package sender
+
+import (
+	"context"
+	"fmt"
+	"gopkg.in/gomail.v2"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// ConsoleMailbox is the Mailbox directory that writes to stdout instead.
+const ConsoleMailbox = "-"
+
+var unsafePathChars = regexp.MustCompile(`[^a-zA-Z0-9@._+-]`)
+
+// Mailbox is a Transport for local development. It writes every message as an .eml file to
+// <dir>/<recipient>/, where any mail client can open it, or prints it when dir is ConsoleMailbox.
+type Mailbox struct {
+	Dir  string
+	From string
+	// serializes console output
+	mu sync.Mutex
+}
+
+func NewMailbox(dir, from string) *Mailbox {
+	return &Mailbox{Dir: dir, From: from}
+}
+
+func (b *Mailbox) Send(ctx context.Context, message Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m := gomail.NewMessage()
+	m.SetHeader("From", b.From)
+	m.SetHeader("To", message.To)
+	m.SetHeader("Subject", message.Subject)
+	m.SetDateHeader("Date", time.Now())
+	m.SetHeader("X-Notification-Kind", message.Kind)
+	m.SetBody("text/plain", message.TextBody)
+	if message.HTMLBody != "" {
+		m.AddAlternative("text/html", message.HTMLBody)
+	}
+
+	if b.Dir == ConsoleMailbox {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		return writeMessage(os.Stdout, m)
+	}
+
+	dir := filepath.Join(b.Dir, unsafePathChars.ReplaceAllString(message.To, "_"))
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), unsafePathChars.ReplaceAllString(message.Kind, "_"))
+	file, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o640)
+	if err != nil {
+		return err
+	}
+
+	err = writeMessage(file, m)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+func writeMessage(w io.Writer, m *gomail.Message) error {
+	_, err := m.WriteTo(w)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "\n")
+	return err
+}
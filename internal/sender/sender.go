@@ -1,16 +1,32 @@
 package sender
 
+import "context"
+
+// Recipient is the address of an email and the locale its template is rendered in.
+type Recipient struct {
+	Email  string
+	Locale string
+}
+
+// Email composes the emails of the service and hands them over for delivery.
+// Implementations may deliver later, so a nil error does not mean the email was sent.
 type Email interface {
-	SendWarningEmail(toEmail, subject, body string) error
-	EnsureSMTPConnection() error
+	SendVerificationEmail(ctx context.Context, to Recipient, link string) error
+	SendPasswordResetEmail(ctx context.Context, to Recipient, link string) error
+	SendAccountUnlockEmail(ctx context.Context, to Recipient, link string) error
+	SendStepUpCodeEmail(ctx context.Context, to Recipient, data StepUpCodeData) error
 }
 
-type Sender struct {
-	Email
+// Transport delivers a composed message through one channel.
+type Transport interface {
+	Send(ctx context.Context, message Message) error
 }
 
-func NewSender(email Email) *Sender {
-	return &Sender{
-		Email: email,
-	}
+type Message struct {
+	// Kind is the name of the template the message was rendered from, it is not sent.
+	Kind     string
+	To       string
+	Subject  string
+	TextBody string
+	HTMLBody string
 }
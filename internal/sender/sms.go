@@ -0,0 +1,41 @@
+package sender
+
+import (
+	"context"
+	"net/http"
+)
+
+// SMSGateway is an adapter for HTTP SMS gateways. It posts {"from", "to", "text"} as JSON with the API
+// key as bearer token, a gateway with another API sits behind a small proxy. Message.To is the phone
+// number in E.164 format.
+type SMSGateway struct {
+	URL    string
+	APIKey string
+	From   string
+	client *http.Client
+}
+
+func NewSMSGateway(url, apiKey, from string, client *http.Client) *SMSGateway {
+	return &SMSGateway{
+		URL:    url,
+		APIKey: apiKey,
+		From:   from,
+		client: client,
+	}
+}
+
+func (g *SMSGateway) Send(ctx context.Context, message Message) error {
+	headers := map[string]string{}
+	if g.APIKey != "" {
+		headers["Authorization"] = "Bearer " + g.APIKey
+	}
+
+	// text messages are short and paid per segment, the subject carries the essentials
+	_, err := postJSON(ctx, g.client, g.URL, headers, map[string]string{
+		"from": g.From,
+		"to":   message.To,
+		"text": message.Subject,
+	})
+
+	return err
+}
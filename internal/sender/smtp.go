@@ -0,0 +1,183 @@
+package sender
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type TLSMode string
+
+const (
+	// TLSModeStartTLS upgrades a plain connection and refuses servers without STARTTLS, usually port 587.
+	TLSModeStartTLS TLSMode = "starttls"
+	// TLSModeImplicit speaks TLS from the start, usually port 465.
+	TLSModeImplicit TLSMode = "tls"
+	// TLSModeNone never encrypts, for local relays and test servers only.
+	TLSModeNone TLSMode = "none"
+)
+
+type smtpConn struct {
+	conn     net.Conn
+	client   *smtp.Client
+	lastUsed time.Time
+}
+
+func dialSMTP(ctx context.Context, config SMTPConfig) (*smtpConn, error) {
+	address := net.JoinHostPort(config.Host, strconv.Itoa(config.Port))
+	dialer := &net.Dialer{Timeout: config.Timeout}
+	tlsConfig := &tls.Config{ServerName: config.Host, MinVersion: tls.VersionTLS12}
+
+	var conn net.Conn
+	var err error
+	if config.TLSMode == TLSModeImplicit {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", address)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	setDeadline(ctx, conn, config.Timeout)
+	client, err := smtp.NewClient(conn, config.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c := &smtpConn{conn: conn, client: client}
+
+	if config.TLSMode == TLSModeStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			c.close()
+			return nil, errors.New("smtp server does not support STARTTLS")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			c.close()
+			return nil, err
+		}
+	}
+
+	if config.User != "" {
+		if err := c.authenticate(config); err != nil {
+			c.close()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+func (c *smtpConn) authenticate(config SMTPConfig) error {
+	ok, mechanisms := c.client.Extension("AUTH")
+	if !ok {
+		return errors.New("smtp server does not support AUTH")
+	}
+
+	// PLAIN and LOGIN send the password as is, so they are only used on encrypted connections
+	// unless TLS was explicitly turned off
+	plaintext := config.TLSMode == TLSModeNone
+	var auth smtp.Auth
+	switch {
+	case slices.Contains(strings.Fields(mechanisms), "PLAIN"):
+		auth = &plainAuth{username: config.User, password: config.Password, allowed: plaintext}
+	case slices.Contains(strings.Fields(mechanisms), "LOGIN"):
+		auth = &loginAuth{username: config.User, password: config.Password, allowed: plaintext}
+	default:
+		return fmt.Errorf("smtp server supports none of the auth mechanisms PLAIN and LOGIN: %s", mechanisms)
+	}
+
+	return c.client.Auth(auth)
+}
+
+func (c *smtpConn) send(ctx context.Context, timeout time.Duration, from, to string, data []byte) error {
+	setDeadline(ctx, c.conn, timeout)
+
+	if err := c.client.Mail(from); err != nil {
+		return err
+	}
+	if err := c.client.Rcpt(to); err != nil {
+		return err
+	}
+
+	w, err := c.client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+
+	return w.Close()
+}
+
+func (c *smtpConn) quit() {
+	c.conn.SetDeadline(time.Now().Add(time.Second))
+	_ = c.client.Quit()
+	c.close()
+}
+
+func (c *smtpConn) close() {
+	_ = c.client.Close()
+}
+
+func setDeadline(ctx context.Context, conn net.Conn, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	_ = conn.SetDeadline(deadline)
+}
+
+type plainAuth struct {
+	username, password string
+	// allowed tells whether the connection is encrypted or plaintext auth was chosen explicitly
+	allowed bool
+}
+
+func (a *plainAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !a.allowed {
+		return "", nil, errors.New("refusing to send the smtp password over an unencrypted connection")
+	}
+
+	return "PLAIN", []byte("\x00" + a.username + "\x00" + a.password), nil
+}
+
+func (a *plainAuth) Next([]byte, bool) ([]byte, error) {
+	return nil, nil
+}
+
+type loginAuth struct {
+	username, password string
+	allowed            bool
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !a.allowed {
+		return "", nil, errors.New("refusing to send the smtp password over an unencrypted connection")
+	}
+
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:":
+		return []byte(a.username), nil
+	case "password:":
+		return []byte(a.password), nil
+	}
+
+	return nil, fmt.Errorf("unexpected smtp LOGIN challenge %q", fromServer)
+}
@@ -0,0 +1,317 @@
+package sender
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// Template names. Every template has a "<name>.txt.tmpl" file defining the "subject" and "text"
+// templates and a "<name>.html.tmpl" file defining "content", which is wrapped by the "layout"
+// template of layout.html.tmpl.
+const (
+	TemplateSuspiciousLogin = "suspicious_login"
+	TemplateVerifyEmail     = "verify_email"
+	TemplatePasswordReset   = "password_reset"
+	TemplateAccountUnlock   = "account_unlock"
+	TemplateNewDevice       = "new_device"
+	TemplateSecurityDigest  = "security_digest"
+	TemplateStepUpCode      = "step_up_code"
+)
+
+var TemplateNames = []string{
+	TemplateSuspiciousLogin,
+	TemplateVerifyEmail,
+	TemplatePasswordReset,
+	TemplateAccountUnlock,
+	TemplateNewDevice,
+	TemplateSecurityDigest,
+	TemplateStepUpCode,
+}
+
+// FallbackLocale always exists in the built-in templates.
+const FallbackLocale = "en"
+
+var (
+	ErrUnknownTemplate = errors.New("unknown email template")
+
+	//go:embed templates
+	builtinTemplates embed.FS
+)
+
+type SuspiciousLoginData struct {
+	IP         string
+	PreviousIP string
+	// Location is the city and country of IP, it is empty when they are unknown.
+	Location string
+	Time     time.Time
+	// ReportLink signs out every session when the user did not sign in, it may be empty.
+	ReportLink string
+}
+
+// LinkData is the data of the verify_email, password_reset and account_unlock templates.
+type LinkData struct {
+	Link string
+}
+
+type NewDeviceData struct {
+	IP        string
+	UserAgent string
+	// Device is the name the user gave the device, or its browser and OS.
+	Device string
+	// Location is the city and country of IP, it is empty when they are unknown.
+	Location string
+	Time     time.Time
+}
+
+// StepUpCodeData is the one-time code of a sign-in the risk engine held back.
+type StepUpCodeData struct {
+	Code      string
+	IP        string
+	Location  string
+	ExpiresAt time.Time
+}
+
+// DigestData summarizes the notifications held back during a burst, oldest first.
+type DigestData struct {
+	Items []DigestItem
+}
+
+type DigestItem struct {
+	Subject string
+	Time    time.Time
+}
+
+// SampleData returns example data for previews of the named template.
+func SampleData(name string) (any, error) {
+	sampleTime := time.Date(2024, 12, 30, 9, 41, 0, 0, time.UTC)
+
+	switch name {
+	case TemplateSuspiciousLogin:
+		return SuspiciousLoginData{IP: "203.0.113.7", PreviousIP: "198.51.100.23", Time: sampleTime,
+			ReportLink: "http://localhost:8080/api/v1/auth/report-login?token=sample"}, nil
+	case TemplateVerifyEmail:
+		return LinkData{Link: "http://localhost:8080/api/v1/auth/verify-email?token=sample"}, nil
+	case TemplatePasswordReset:
+		return LinkData{Link: "http://localhost:8080/reset-password?token=sample"}, nil
+	case TemplateAccountUnlock:
+		return LinkData{Link: "http://localhost:8080/api/v1/auth/unlock?token=sample"}, nil
+	case TemplateNewDevice:
+		return NewDeviceData{IP: "203.0.113.7", UserAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_2) Firefox/121.0",
+			Device: "Firefox 121 on macOS 14.2", Location: "Berlin, DE", Time: sampleTime}, nil
+	case TemplateSecurityDigest:
+		return DigestData{Items: []DigestItem{
+			{Subject: "New sign-in from 203.0.113.7", Time: sampleTime},
+			{Subject: "New sign-in from 203.0.113.8", Time: sampleTime.Add(3 * time.Minute)},
+			{Subject: "New sign-in from 203.0.113.9", Time: sampleTime.Add(7 * time.Minute)},
+		}}, nil
+	case TemplateStepUpCode:
+		return StepUpCodeData{Code: "042917", IP: "203.0.113.7", Location: "Berlin, DE", ExpiresAt: sampleTime.Add(10 * time.Minute)}, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnknownTemplate, name)
+}
+
+type localizedTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// Templates renders the email templates. A template is looked up in the user's locale, then in its
+// base language ("pt" for "pt-BR"), then in the default locale and finally in FallbackLocale.
+type Templates struct {
+	defaultLocale string
+	// templates by locale and name
+	templates map[string]map[string]localizedTemplate
+}
+
+// NewTemplates parses the built-in templates. Files in dir, laid out like the built-in templates
+// directory, replace the built-in file of the same path or add new locales. dir may be empty.
+func NewTemplates(dir, defaultLocale string) (*Templates, error) {
+	builtin, err := fs.Sub(builtinTemplates, "templates")
+	if err != nil {
+		return nil, err
+	}
+
+	sources := []fs.FS{builtin}
+	if dir != "" {
+		if _, err := os.Stat(dir); err != nil {
+			return nil, fmt.Errorf("error while opening template directory: %w", err)
+		}
+		sources = append([]fs.FS{os.DirFS(dir)}, sources...)
+	}
+
+	t := &Templates{
+		defaultLocale: NormalizeLocale(defaultLocale),
+		templates:     map[string]map[string]localizedTemplate{},
+	}
+	if t.defaultLocale == "" {
+		t.defaultLocale = FallbackLocale
+	}
+
+	layout, err := readFirst(sources, "layout.html.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, locale := range listLocales(sources) {
+		for _, name := range TemplateNames {
+			tmpl, ok, err := parseTemplate(sources, layout, locale, name)
+			if err != nil {
+				return nil, fmt.Errorf("error while parsing template %s/%s: %w", locale, name, err)
+			}
+			if !ok {
+				continue
+			}
+
+			if t.templates[locale] == nil {
+				t.templates[locale] = map[string]localizedTemplate{}
+			}
+			t.templates[locale][name] = tmpl
+		}
+	}
+
+	for _, name := range TemplateNames {
+		if _, ok := t.templates[t.defaultLocale][name]; !ok {
+			return nil, fmt.Errorf("template %s is missing in the default locale %s", name, t.defaultLocale)
+		}
+	}
+
+	return t, nil
+}
+
+// Render renders the subject, plain-text and HTML parts of the named template.
+func (t *Templates) Render(name, locale string, data any) (Message, error) {
+	tmpl, ok := t.lookup(name, locale)
+	if !ok {
+		return Message{}, fmt.Errorf("%w: %s", ErrUnknownTemplate, name)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, fmt.Errorf("error while rendering subject of %s: %w", name, err)
+	}
+	if err := tmpl.text.ExecuteTemplate(&text, "text", data); err != nil {
+		return Message{}, fmt.Errorf("error while rendering text part of %s: %w", name, err)
+	}
+	if err := tmpl.html.ExecuteTemplate(&html, "layout", data); err != nil {
+		return Message{}, fmt.Errorf("error while rendering html part of %s: %w", name, err)
+	}
+
+	return Message{
+		Kind:     name,
+		Subject:  strings.Join(strings.Fields(subject.String()), " "),
+		TextBody: strings.TrimSpace(text.String()) + "\n",
+		HTMLBody: html.String(),
+	}, nil
+}
+
+// Locales returns the locales that have at least one template, sorted.
+func (t *Templates) Locales() []string {
+	locales := make([]string, 0, len(t.templates))
+	for locale := range t.templates {
+		locales = append(locales, locale)
+	}
+	slices.Sort(locales)
+
+	return locales
+}
+
+func (t *Templates) lookup(name, locale string) (localizedTemplate, bool) {
+	locale = NormalizeLocale(locale)
+	base, _, _ := strings.Cut(locale, "-")
+
+	for _, candidate := range []string{locale, base, t.defaultLocale, FallbackLocale} {
+		if tmpl, ok := t.templates[candidate][name]; ok {
+			return tmpl, true
+		}
+	}
+
+	return localizedTemplate{}, false
+}
+
+// NormalizeLocale lowercases a locale and uses "-" as separator, "pt_BR" becomes "pt-br".
+func NormalizeLocale(locale string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(locale)), "_", "-")
+}
+
+func parseTemplate(sources []fs.FS, layout []byte, locale, name string) (localizedTemplate, bool, error) {
+	textSource, err := readFirst(sources, path.Join(locale, name+".txt.tmpl"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return localizedTemplate{}, false, nil
+	}
+	if err != nil {
+		return localizedTemplate{}, false, err
+	}
+
+	htmlSource, err := readFirst(sources, path.Join(locale, name+".html.tmpl"))
+	if err != nil {
+		return localizedTemplate{}, false, err
+	}
+
+	text, err := texttemplate.New(name).Option("missingkey=error").Parse(string(textSource))
+	if err != nil {
+		return localizedTemplate{}, false, err
+	}
+	for _, required := range []string{"subject", "text"} {
+		if text.Lookup(required) == nil {
+			return localizedTemplate{}, false, fmt.Errorf("%q is not defined", required)
+		}
+	}
+
+	html, err := htmltemplate.New(name).Option("missingkey=error").Parse(string(layout))
+	if err != nil {
+		return localizedTemplate{}, false, fmt.Errorf("error in layout: %w", err)
+	}
+	html, err = html.Parse(string(htmlSource))
+	if err != nil {
+		return localizedTemplate{}, false, err
+	}
+	if html.Lookup("content") == nil {
+		return localizedTemplate{}, false, errors.New(`"content" is not defined`)
+	}
+
+	return localizedTemplate{text: text, html: html}, true, nil
+}
+
+// readFirst reads the file from the first source that has it.
+func readFirst(sources []fs.FS, name string) ([]byte, error) {
+	for _, source := range sources {
+		data, err := fs.ReadFile(source, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		return data, err
+	}
+
+	return nil, fmt.Errorf("%s: %w", name, fs.ErrNotExist)
+}
+
+func listLocales(sources []fs.FS) []string {
+	var locales []string
+	for _, source := range sources {
+		entries, err := fs.ReadDir(source, ".")
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			locale := entry.Name()
+			if entry.IsDir() && locale == NormalizeLocale(locale) && !slices.Contains(locales, locale) {
+				locales = append(locales, locale)
+			}
+		}
+	}
+
+	return locales
+}
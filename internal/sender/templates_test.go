@@ -0,0 +1,75 @@
+package sender
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTemplates_RenderEveryTemplate(t *testing.T) {
+	templates, err := NewTemplates("", "en")
+	assert.NoError(t, err)
+
+	for _, locale := range templates.Locales() {
+		for _, name := range TemplateNames {
+			data, err := SampleData(name)
+			assert.NoError(t, err)
+
+			message, err := templates.Render(name, locale, data)
+			assert.NoError(t, err, "%s/%s", locale, name)
+			assert.Equal(t, name, message.Kind)
+			assert.NotEmpty(t, message.Subject)
+			assert.NotEmpty(t, message.TextBody)
+			assert.Contains(t, message.HTMLBody, "<html>")
+		}
+	}
+}
+
+func TestTemplates_LocaleFallback(t *testing.T) {
+	templates, err := NewTemplates("", "en")
+	assert.NoError(t, err)
+
+	data := LinkData{Link: "http://localhost/verify?token=t"}
+	english, err := templates.Render(TemplateVerifyEmail, "", data)
+	assert.NoError(t, err)
+	russian, err := templates.Render(TemplateVerifyEmail, "ru", data)
+	assert.NoError(t, err)
+	assert.NotEqual(t, english.Subject, russian.Subject)
+
+	for locale, want := range map[string]string{"ru_RU": russian.Subject, "RU-ru": russian.Subject, "de-DE": english.Subject} {
+		message, err := templates.Render(TemplateVerifyEmail, locale, data)
+		assert.NoError(t, err)
+		assert.Equal(t, want, message.Subject, locale)
+	}
+}
+
+func TestTemplates_EscapesHTML(t *testing.T) {
+	templates, err := NewTemplates("", "en")
+	assert.NoError(t, err)
+
+	message, err := templates.Render(TemplateNewDevice, "en", NewDeviceData{UserAgent: "<script>alert(1)</script>"})
+	assert.NoError(t, err)
+	assert.NotContains(t, message.HTMLBody, "<script>")
+	assert.Contains(t, message.TextBody, "<script>")
+}
+
+func TestTemplates_OverrideDir(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "en"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "en", "verify_email.txt.tmpl"),
+		[]byte(`{{define "subject"}}Welcome aboard{{end}}{{define "text"}}Confirm: {{.Link}}{{end}}`), 0o644))
+
+	templates, err := NewTemplates(dir, "en")
+	assert.NoError(t, err)
+
+	message, err := templates.Render(TemplateVerifyEmail, "en", LinkData{Link: "http://localhost/verify"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Welcome aboard", message.Subject)
+	assert.Equal(t, "Confirm: http://localhost/verify\n", message.TextBody)
+	// the html part is still the built-in one
+	assert.Contains(t, message.HTMLBody, "http://localhost/verify")
+
+	_, err = NewTemplates(dir, "fr")
+	assert.Error(t, err, "the default locale must have every template")
+}
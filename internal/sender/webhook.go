@@ -0,0 +1,45 @@
+package sender
+
+import (
+	"context"
+	"net/http"
+)
+
+// WebhookChannel posts notifications as JSON to one endpoint configured by the operator, which forwards
+// them wherever it likes. Message.To is passed through as the address the user chose for the channel.
+type WebhookChannel struct {
+	URL         string
+	BearerToken string
+	client      *http.Client
+}
+
+func NewWebhookChannel(url, bearerToken string, client *http.Client) *WebhookChannel {
+	return &WebhookChannel{
+		URL:         url,
+		BearerToken: bearerToken,
+		client:      client,
+	}
+}
+
+type webhookChannelPayload struct {
+	Kind    string `json:"kind"`
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+}
+
+func (w *WebhookChannel) Send(ctx context.Context, message Message) error {
+	headers := map[string]string{}
+	if w.BearerToken != "" {
+		headers["Authorization"] = "Bearer " + w.BearerToken
+	}
+
+	_, err := postJSON(ctx, w.client, w.URL, headers, webhookChannelPayload{
+		Kind:    message.Kind,
+		To:      message.To,
+		Subject: message.Subject,
+		Text:    message.TextBody,
+	})
+
+	return err
+}
@@ -0,0 +1,506 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt"
+	"github.com/sirupsen/logrus"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository"
+	"medods-tz/internal/repository/repoerrors"
+	"medods-tz/internal/sender"
+	"medods-tz/pkg/hasher"
+	"medods-tz/pkg/passwordpolicy"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	emailVerificationAudience = "email_verification"
+
+	// passwordResetBatchSize is how many queued password reset requests one run of the worker answers.
+	passwordResetBatchSize = 100
+)
+
+type EmailVerificationClaims struct {
+	jwt.StandardClaims
+	UserID string
+	Email  string
+}
+
+type Account struct {
+	userRepo             repository.UserRepository
+	tokenRepo            repository.TokenRepository
+	passwordResetRepo    repository.PasswordResetRepository
+	loginReportRepo      repository.LoginReportRepository
+	transactor           repository.Transactor
+	signKey              string
+	verificationTokenTTL time.Duration
+	verificationLinkURL  string
+	resetTokenTTL        time.Duration
+	resetLinkURL         string
+	securityLog          *logrus.Logger
+	audit                AuditService
+	webhooks             WebhookService
+	emailSender          sender.Email
+	passwordHasher       hasher.PasswordHasher
+	passwordPolicy       *passwordpolicy.Policy
+}
+
+func NewAccount(
+	userRepo repository.UserRepository,
+	tokenRepo repository.TokenRepository,
+	passwordResetRepo repository.PasswordResetRepository,
+	loginReportRepo repository.LoginReportRepository,
+	transactor repository.Transactor,
+	signKey string,
+	verificationTokenTTL time.Duration,
+	verificationLinkURL string,
+	resetTokenTTL time.Duration,
+	resetLinkURL string,
+	securityLog *logrus.Logger,
+	audit AuditService,
+	webhooks WebhookService,
+	emailSender sender.Email,
+	passwordHasher hasher.PasswordHasher,
+	passwordPolicy *passwordpolicy.Policy) *Account {
+	return &Account{
+		userRepo:             userRepo,
+		tokenRepo:            tokenRepo,
+		passwordResetRepo:    passwordResetRepo,
+		loginReportRepo:      loginReportRepo,
+		transactor:           transactor,
+		signKey:              signKey,
+		verificationTokenTTL: verificationTokenTTL,
+		verificationLinkURL:  verificationLinkURL,
+		resetTokenTTL:        resetTokenTTL,
+		resetLinkURL:         resetLinkURL,
+		securityLog:          securityLog,
+		audit:                audit,
+		webhooks:             webhooks,
+		emailSender:          emailSender,
+		passwordHasher:       passwordHasher,
+		passwordPolicy:       passwordPolicy,
+	}
+}
+
+// Register creates the user, locale selects the language of their emails and may be empty.
+func (s *Account) Register(ctx context.Context, email, password, locale string) (string, error) {
+	err := s.validatePassword(password, email)
+	if err != nil {
+		return "", err
+	}
+
+	passwordHash, err := s.passwordHasher.Hash(password)
+	if err != nil {
+		return "", fmt.Errorf("error while hashing password: %w", err)
+	}
+
+	// the verification email is queued in the same transaction, so no account is left without one
+	var userID string
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		user := entity.User{Email: email, PasswordHash: passwordHash, Locale: sender.NormalizeLocale(locale)}
+		userID, err = s.userRepo.CreateUser(ctx, user)
+		if err != nil {
+			if errors.Is(err, repoerrors.ErrAlreadyExists) {
+				return ErrUserAlreadyExists
+			}
+
+			return fmt.Errorf("error while creating user: %w", err)
+		}
+
+		return s.sendVerificationEmail(ctx, userID, emailRecipient(&user))
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.audit.Record(ctx, entity.AuditEvent{Type: entity.AuditUserRegistered, SubjectID: userID})
+
+	return userID, nil
+}
+
+func (s *Account) ChangeEmail(ctx context.Context, userID, email string) error {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrUserNotFound
+		}
+
+		return fmt.Errorf("error while trying to find user: %w", err)
+	}
+
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		err := s.userRepo.UpdateUserEmail(ctx, userID, email)
+		if err != nil {
+			if errors.Is(err, repoerrors.ErrNotFound) {
+				return ErrUserNotFound
+			}
+			if errors.Is(err, repoerrors.ErrAlreadyExists) {
+				return ErrUserAlreadyExists
+			}
+
+			return fmt.Errorf("error while updating user email: %w", err)
+		}
+
+		return s.sendVerificationEmail(ctx, userID, sender.Recipient{Email: email, Locale: user.Locale})
+	})
+	if err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, entity.AuditEvent{
+		Type:      entity.AuditEmailChanged,
+		SubjectID: userID,
+		Metadata:  map[string]string{"email": email},
+	})
+
+	return nil
+}
+
+func (s *Account) ResendVerificationEmail(ctx context.Context, userID string) error {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrUserNotFound
+		}
+
+		return fmt.Errorf("error while trying to find user: %w", err)
+	}
+
+	if user.EmailVerifiedAt != nil {
+		return ErrEmailAlreadyVerified
+	}
+
+	return s.sendVerificationEmail(ctx, user.ID, emailRecipient(user))
+}
+
+func (s *Account) VerifyEmail(ctx context.Context, verificationToken string) error {
+	claims, err := s.parseVerificationToken(verificationToken)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidVerificationToken, err)
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrUserNotFound
+		}
+
+		return fmt.Errorf("error while trying to find user: %w", err)
+	}
+
+	// the link was issued for an address the user has since changed
+	if user.Email != claims.Email {
+		return ErrInvalidVerificationToken
+	}
+
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	err = s.userRepo.MarkEmailVerified(ctx, user.ID, time.Now())
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrUserNotFound
+		}
+
+		return fmt.Errorf("error while marking email as verified: %w", err)
+	}
+
+	s.audit.Record(ctx, entity.AuditEvent{
+		Type:      entity.AuditEmailVerified,
+		SubjectID: user.ID,
+		Metadata:  map[string]string{"email": user.Email},
+	})
+
+	return nil
+}
+
+// ForgotPassword only queues the request, so it does the same work and answers the same way whether the
+// email is registered or not, and neither its response nor its timing reveals which.
+// ProcessPasswordResetRequests looks the email up and sends the link off the request path.
+func (s *Account) ForgotPassword(ctx context.Context, email string) error {
+	err := s.passwordResetRepo.CreatePasswordResetRequest(ctx, entity.PasswordResetRequest{Email: email, CreatedAt: time.Now()})
+	if err != nil {
+		return fmt.Errorf("error while queueing password reset request: %w", err)
+	}
+
+	return nil
+}
+
+// ProcessPasswordResetRequests sends a reset link for each request queued by ForgotPassword whose email is
+// registered and drops the others. Requests are only removed together with the tokens stored for them,
+// so a failed run leaves them queued for the next one.
+func (s *Account) ProcessPasswordResetRequests(ctx context.Context) error {
+	var users []*entity.User
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		requests, err := s.passwordResetRepo.ClaimPasswordResetRequests(ctx, passwordResetBatchSize)
+		if err != nil {
+			return fmt.Errorf("error while claiming password reset requests: %w", err)
+		}
+
+		for _, request := range requests {
+			user, err := s.userRepo.GetUserByEmail(ctx, request.Email)
+			if err != nil {
+				if errors.Is(err, repoerrors.ErrNotFound) {
+					continue
+				}
+
+				return fmt.Errorf("error while trying to find user: %w", err)
+			}
+
+			err = s.sendPasswordResetEmail(ctx, user)
+			if err != nil {
+				return err
+			}
+			users = append(users, user)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		s.securityLog.Infof("password reset requested for user_id=%s", user.ID)
+		s.audit.Record(ctx, entity.AuditEvent{Type: entity.AuditPasswordResetRequested, SubjectID: user.ID})
+	}
+
+	return nil
+}
+
+// sendPasswordResetEmail stores a new reset token and queues its link, the caller runs it in a transaction.
+func (s *Account) sendPasswordResetEmail(ctx context.Context, user *entity.User) error {
+	resetToken, resetTokenHash, err := generateResetToken()
+	if err != nil {
+		return fmt.Errorf("error while generating password reset token: %w", err)
+	}
+
+	link, err := buildLink(s.resetLinkURL, resetToken)
+	if err != nil {
+		return fmt.Errorf("error while building password reset link: %w", err)
+	}
+
+	err = s.passwordResetRepo.CreatePasswordResetToken(ctx, entity.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: resetTokenHash,
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(s.resetTokenTTL),
+	})
+	if err != nil {
+		return fmt.Errorf("error while creating password reset token: %w", err)
+	}
+
+	return s.emailSender.SendPasswordResetEmail(ctx, emailRecipient(user), link)
+}
+
+func (s *Account) ResetPassword(ctx context.Context, resetToken, newPassword string) error {
+	token, err := s.passwordResetRepo.GetPasswordResetTokenByHash(ctx, hashResetToken(resetToken))
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrInvalidPasswordResetToken
+		}
+
+		return fmt.Errorf("error while getting password reset token: %w", err)
+	}
+
+	if token.UsedAt != nil || token.ExpiresAt.Before(time.Now()) {
+		return ErrInvalidPasswordResetToken
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, token.UserID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrUserNotFound
+		}
+
+		return fmt.Errorf("error while trying to find user: %w", err)
+	}
+
+	// checked before the token is spent, so the user can retry with a stronger password
+	err = s.validatePassword(newPassword, user.Email)
+	if err != nil {
+		return err
+	}
+
+	passwordHash, err := s.passwordHasher.Hash(newPassword)
+	if err != nil {
+		return fmt.Errorf("error while hashing password: %w", err)
+	}
+
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		err := s.passwordResetRepo.MarkPasswordResetTokenUsed(ctx, token.ID)
+		if err != nil {
+			if errors.Is(err, repoerrors.ErrNotFound) {
+				return ErrInvalidPasswordResetToken
+			}
+
+			return fmt.Errorf("error while marking password reset token as used: %w", err)
+		}
+
+		err = s.userRepo.UpdateUserPassword(ctx, token.UserID, passwordHash)
+		if err != nil {
+			if errors.Is(err, repoerrors.ErrNotFound) {
+				return ErrUserNotFound
+			}
+
+			return fmt.Errorf("error while updating password: %w", err)
+		}
+
+		if user.PasswordResetRequired {
+			err = s.userRepo.SetPasswordResetRequired(ctx, token.UserID, false)
+			if err != nil {
+				return fmt.Errorf("error while clearing required password reset: %w", err)
+			}
+		}
+
+		// the sessions of the organizations of the user go too
+		err = s.transactor.WithinAllTenants(ctx, func(ctx context.Context) error {
+			return s.tokenRepo.RevokeRefreshTokensByUserID(ctx, token.UserID)
+		})
+		if err != nil {
+			return fmt.Errorf("error while revoking refresh tokens: %w", err)
+		}
+
+		return s.webhooks.Enqueue(ctx, entity.WebhookSessionRevoked, map[string]string{
+			"user_id": token.UserID,
+			"reason":  "password_reset",
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	s.securityLog.Infof("password was reset for user_id=%s, all refresh tokens revoked", token.UserID)
+	s.audit.Record(ctx, entity.AuditEvent{Type: entity.AuditPasswordReset, SubjectID: token.UserID})
+
+	return nil
+}
+
+// SetLocale changes the language of the user's emails, an empty locale selects the default one.
+func (s *Account) SetLocale(ctx context.Context, userID, locale string) error {
+	err := s.userRepo.UpdateUserLocale(ctx, userID, sender.NormalizeLocale(locale))
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrUserNotFound
+		}
+
+		return fmt.Errorf("error while updating user locale: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Account) validatePassword(password, email string) error {
+	return validatePassword(s.passwordPolicy, password, email)
+}
+
+func validatePassword(policy *passwordpolicy.Policy, password, email string) error {
+	emailLocalPart, _, _ := strings.Cut(email, "@")
+
+	err := policy.Validate(password, emailLocalPart)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrPasswordPolicyViolation, err)
+	}
+
+	return nil
+}
+
+func (s *Account) sendVerificationEmail(ctx context.Context, userID string, to sender.Recipient) error {
+	verificationToken, err := s.generateVerificationToken(userID, to.Email)
+	if err != nil {
+		return fmt.Errorf("error while generating verification token: %w", err)
+	}
+
+	link, err := buildLink(s.verificationLinkURL, verificationToken)
+	if err != nil {
+		return fmt.Errorf("error while building verification link: %w", err)
+	}
+
+	err = s.emailSender.SendVerificationEmail(ctx, to, link)
+	if err != nil {
+		return fmt.Errorf("error while queueing verification email: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Account) generateVerificationToken(userID, email string) (string, error) {
+	claims := EmailVerificationClaims{
+		jwt.StandardClaims{
+			Audience:  emailVerificationAudience,
+			ExpiresAt: time.Now().Add(s.verificationTokenTTL).Unix(),
+			IssuedAt:  time.Now().Unix(),
+		},
+		userID,
+		email,
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
+
+	return token.SignedString([]byte(s.signKey))
+}
+
+func (s *Account) parseVerificationToken(verificationToken string) (*EmailVerificationClaims, error) {
+	claims := &EmailVerificationClaims{}
+
+	_, err := jwt.ParseWithClaims(verificationToken, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+
+		return []byte(s.signKey), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// access tokens are signed with the same key, so the audience is what tells them apart
+	if !claims.VerifyAudience(emailVerificationAudience, true) {
+		return nil, errors.New("unexpected token audience")
+	}
+
+	return claims, nil
+}
+
+func emailRecipient(user *entity.User) sender.Recipient {
+	return sender.Recipient{Email: user.Email, Locale: user.Locale}
+}
+
+func buildLink(baseURL, token string) (string, error) {
+	link, err := url.Parse(baseURL)
+	if err != nil {
+		return "", err
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+
+	return link.String(), nil
+}
+
+// generateResetToken returns the token for the email link and the hash that is stored instead of it.
+// The token has enough entropy that a plain sha256 is sufficient and allows lookup by hash.
+func generateResetToken() (string, string, error) {
+	bytes := make([]byte, 32)
+	_, err := rand.Read(bytes)
+	if err != nil {
+		return "", "", err
+	}
+	resetToken := base64.RawURLEncoding.EncodeToString(bytes)
+
+	return resetToken, hashResetToken(resetToken), nil
+}
+
+func hashResetToken(resetToken string) string {
+	hash := sha256.Sum256([]byte(resetToken))
+	return hex.EncodeToString(hash[:])
+}
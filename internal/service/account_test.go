@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository/repoerrors"
+	"medods-tz/internal/sender"
+	"medods-tz/pkg/passwordpolicy"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestAccount_RegisterAndVerifyEmail(t *testing.T) {
+	ctx := context.Background()
+	mockUserRepo := new(mockUserRepo)
+	mockEmail := new(mockEmail)
+
+	account := NewAccount(
+		mockUserRepo,
+		new(mockTokenRepo),
+		new(mockPasswordResetRepo),
+		new(mockLoginReportRepo),
+		mockTransactor{},
+		"test-sign-key",
+		time.Hour,
+		"http://localhost/verify-email",
+		time.Minute*30,
+		"http://localhost/reset-password",
+		logrus.New(),
+		newTestAudit(),
+		newTestWebhooks(),
+		mockEmail,
+		newTestPasswordHasher(),
+		newTestPasswordPolicy(),
+	)
+
+	var link string
+	mockUserRepo.On("CreateUser", ctx, mock.MatchedBy(func(user entity.User) bool {
+		return user.Email == "test@example.com" && user.PasswordHash != "" && user.PasswordHash != "Tr0ub4dor&3" &&
+			user.Locale == "pt-br"
+	})).Return("user-id", nil)
+	mockEmail.On("SendVerificationEmail", ctx, sender.Recipient{Email: "test@example.com", Locale: "pt-br"}, mock.Anything).
+		Run(func(args mock.Arguments) { link = args.String(2) }).
+		Return(nil)
+
+	userID, err := account.Register(ctx, "test@example.com", "Tr0ub4dor&3", "pt_BR")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "user-id", userID)
+
+	parsedLink, err := url.Parse(link)
+	assert.NoError(t, err)
+	verificationToken := parsedLink.Query().Get("token")
+	assert.NotEmpty(t, verificationToken)
+
+	mockUserRepo.On("GetUserByID", ctx, "user-id").Return(&entity.User{ID: "user-id", Email: "test@example.com"}, nil)
+	mockUserRepo.On("MarkEmailVerified", ctx, "user-id", mock.Anything).Return(nil)
+
+	err = account.VerifyEmail(ctx, verificationToken)
+
+	assert.NoError(t, err)
+	mockUserRepo.AssertCalled(t, "MarkEmailVerified", ctx, "user-id", mock.Anything)
+}
+
+func TestAccount_VerifyEmail_RejectsAccessToken(t *testing.T) {
+	ctx := context.Background()
+	mockUserRepo := new(mockUserRepo)
+
+	auth := newTestAuth(AuthDependencies{UserRepo: mockUserRepo})
+	account := NewAccount(mockUserRepo, new(mockTokenRepo), new(mockPasswordResetRepo), new(mockLoginReportRepo), mockTransactor{}, "test-sign-key",
+		time.Hour, "http://localhost/verify-email", time.Minute*30, "http://localhost/reset-password", logrus.New(), newTestAudit(), newTestWebhooks(), new(mockEmail), newTestPasswordHasher(), newTestPasswordPolicy())
+
+	accessToken, err := auth.generateAccessToken("127.0.0.1", "user-id", nil, nil, nil, "", time.Now().Add(auth.tokenTTL))
+	assert.NoError(t, err)
+
+	err = account.VerifyEmail(ctx, accessToken)
+
+	assert.ErrorIs(t, err, ErrInvalidVerificationToken)
+	mockUserRepo.AssertNotCalled(t, "MarkEmailVerified", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAuth_Authenticate_RejectsVerificationToken(t *testing.T) {
+	ctx := context.Background()
+	mockUserRepo := new(mockUserRepo)
+
+	auth := newTestAuth(AuthDependencies{UserRepo: mockUserRepo})
+	account := NewAccount(mockUserRepo, new(mockTokenRepo), new(mockPasswordResetRepo), new(mockLoginReportRepo), mockTransactor{}, "test-sign-key",
+		time.Hour, "http://localhost/verify-email", time.Minute*30, "http://localhost/reset-password", logrus.New(), newTestAudit(), newTestWebhooks(), new(mockEmail), newTestPasswordHasher(), newTestPasswordPolicy())
+
+	verificationToken, err := account.generateVerificationToken("user-id", "test@example.com")
+	assert.NoError(t, err)
+
+	claims, err := auth.Authenticate(ctx, verificationToken)
+
+	assert.ErrorIs(t, err, ErrInvalidAccessToken)
+	assert.Nil(t, claims)
+}
+
+func TestAuth_Authenticate_RejectsLoginReportToken(t *testing.T) {
+	ctx := context.Background()
+
+	auth := newTestAuth(AuthDependencies{})
+
+	claims, err := auth.Authenticate(ctx, testLoginReportToken(t, "user-id", "127.0.0.1"))
+
+	assert.ErrorIs(t, err, ErrInvalidAccessToken)
+	assert.Nil(t, claims)
+}
+
+func TestAuth_CreateTokens_RequiresVerifiedEmail(t *testing.T) {
+	ctx := context.Background()
+	mockUserRepo := new(mockUserRepo)
+	mockTokenRepo := new(mockTokenRepo)
+
+	auth := newTestAuth(AuthDependencies{UserRepo: mockUserRepo, TokenRepo: mockTokenRepo, RequireVerifiedEmail: true})
+
+	mockUserRepo.On("GetUserByID", ctx, "user-id").Return(&entity.User{ID: "user-id", Email: "test@example.com"}, nil)
+
+	tokens, err := auth.CreateTokens(ctx, "user-id", "", "127.0.0.1", DeviceInput{})
+
+	assert.ErrorIs(t, err, ErrEmailNotVerified)
+	assert.Nil(t, tokens)
+	mockTokenRepo.AssertNotCalled(t, "CreateRefreshToken", mock.Anything, mock.Anything)
+}
+
+func TestAccount_ResetPassword(t *testing.T) {
+	ctx := context.Background()
+	mockUserRepo := new(mockUserRepo)
+	mockTokenRepo := new(mockTokenRepo)
+	mockPasswordResetRepo := new(mockPasswordResetRepo)
+
+	account := NewAccount(mockUserRepo, mockTokenRepo, mockPasswordResetRepo, new(mockLoginReportRepo), mockTransactor{}, "test-sign-key",
+		time.Hour, "http://localhost/verify-email", time.Minute*30, "http://localhost/reset-password", logrus.New(), newTestAudit(), newTestWebhooks(), new(mockEmail), newTestPasswordHasher(), newTestPasswordPolicy())
+
+	resetToken, resetTokenHash, err := generateResetToken()
+	assert.NoError(t, err)
+
+	mockPasswordResetRepo.On("GetPasswordResetTokenByHash", ctx, resetTokenHash).Return(&entity.PasswordResetToken{
+		ID:        "reset-id",
+		UserID:    "user-id",
+		TokenHash: resetTokenHash,
+		ExpiresAt: time.Now().Add(time.Minute),
+	}, nil)
+	mockUserRepo.On("GetUserByID", ctx, "user-id").Return(&entity.User{ID: "user-id", Email: "test@example.com"}, nil)
+	mockPasswordResetRepo.On("MarkPasswordResetTokenUsed", ctx, "reset-id").Return(nil)
+	mockUserRepo.On("UpdateUserPassword", ctx, "user-id", mock.Anything).Return(nil)
+	mockTokenRepo.On("RevokeRefreshTokensByUserID", ctx, "user-id").Return(nil)
+
+	err = account.ResetPassword(ctx, resetToken, "correct-Horse-7")
+
+	assert.NoError(t, err)
+	mockPasswordResetRepo.AssertCalled(t, "MarkPasswordResetTokenUsed", ctx, "reset-id")
+	mockTokenRepo.AssertCalled(t, "RevokeRefreshTokensByUserID", ctx, "user-id")
+}
+
+func TestAccount_ResetPassword_WeakPasswordKeepsToken(t *testing.T) {
+	ctx := context.Background()
+	mockUserRepo := new(mockUserRepo)
+	mockPasswordResetRepo := new(mockPasswordResetRepo)
+
+	account := NewAccount(mockUserRepo, new(mockTokenRepo), mockPasswordResetRepo, new(mockLoginReportRepo), mockTransactor{}, "test-sign-key",
+		time.Hour, "http://localhost/verify-email", time.Minute*30, "http://localhost/reset-password", logrus.New(), newTestAudit(), newTestWebhooks(), new(mockEmail), newTestPasswordHasher(), newTestPasswordPolicy())
+
+	mockPasswordResetRepo.On("GetPasswordResetTokenByHash", ctx, hashResetToken("reset")).Return(&entity.PasswordResetToken{
+		ID:        "reset-id",
+		UserID:    "user-id",
+		ExpiresAt: time.Now().Add(time.Minute),
+	}, nil)
+	mockUserRepo.On("GetUserByID", ctx, "user-id").Return(&entity.User{ID: "user-id", Email: "johnsmith@example.com"}, nil)
+
+	err := account.ResetPassword(ctx, "reset", "JohnSmith-2024")
+
+	assert.ErrorIs(t, err, ErrPasswordPolicyViolation)
+	mockPasswordResetRepo.AssertNotCalled(t, "MarkPasswordResetTokenUsed", mock.Anything, mock.Anything)
+}
+
+func TestAccount_ResetPassword_ExpiredToken(t *testing.T) {
+	ctx := context.Background()
+	mockTokenRepo := new(mockTokenRepo)
+	mockPasswordResetRepo := new(mockPasswordResetRepo)
+
+	account := NewAccount(new(mockUserRepo), mockTokenRepo, mockPasswordResetRepo, new(mockLoginReportRepo), mockTransactor{}, "test-sign-key",
+		time.Hour, "http://localhost/verify-email", time.Minute*30, "http://localhost/reset-password", logrus.New(), newTestAudit(), newTestWebhooks(), new(mockEmail), newTestPasswordHasher(), newTestPasswordPolicy())
+
+	mockPasswordResetRepo.On("GetPasswordResetTokenByHash", ctx, hashResetToken("expired")).Return(&entity.PasswordResetToken{
+		ID:        "reset-id",
+		UserID:    "user-id",
+		ExpiresAt: time.Now().Add(-time.Minute),
+	}, nil)
+
+	err := account.ResetPassword(ctx, "expired", "new-password")
+
+	assert.ErrorIs(t, err, ErrInvalidPasswordResetToken)
+	mockTokenRepo.AssertNotCalled(t, "RevokeRefreshTokensByUserID", mock.Anything, mock.Anything)
+}
+
+func TestAccount_ForgotPassword_OnlyQueuesRequest(t *testing.T) {
+	ctx := context.Background()
+	mockUserRepo := new(mockUserRepo)
+	mockPasswordResetRepo := new(mockPasswordResetRepo)
+
+	account := NewAccount(mockUserRepo, new(mockTokenRepo), mockPasswordResetRepo, new(mockLoginReportRepo), mockTransactor{}, "test-sign-key",
+		time.Hour, "http://localhost/verify-email", time.Minute*30, "http://localhost/reset-password", logrus.New(), newTestAudit(), newTestWebhooks(), new(mockEmail), newTestPasswordHasher(), newTestPasswordPolicy())
+
+	mockPasswordResetRepo.On("CreatePasswordResetRequest", ctx, mock.MatchedBy(func(request entity.PasswordResetRequest) bool {
+		return request.Email == "unknown@example.com"
+	})).Return(nil)
+
+	err := account.ForgotPassword(ctx, "unknown@example.com")
+
+	assert.NoError(t, err)
+	mockUserRepo.AssertNotCalled(t, "GetUserByEmail", mock.Anything, mock.Anything)
+	mockPasswordResetRepo.AssertNotCalled(t, "CreatePasswordResetToken", mock.Anything, mock.Anything)
+}
+
+func TestAccount_ProcessPasswordResetRequests(t *testing.T) {
+	ctx := context.Background()
+	mockUserRepo := new(mockUserRepo)
+	mockPasswordResetRepo := new(mockPasswordResetRepo)
+	mockEmail := new(mockEmail)
+
+	account := NewAccount(mockUserRepo, new(mockTokenRepo), mockPasswordResetRepo, new(mockLoginReportRepo), mockTransactor{}, "test-sign-key",
+		time.Hour, "http://localhost/verify-email", time.Minute*30, "http://localhost/reset-password", logrus.New(), newTestAudit(), newTestWebhooks(), mockEmail, newTestPasswordHasher(), newTestPasswordPolicy())
+
+	mockPasswordResetRepo.On("ClaimPasswordResetRequests", ctx, passwordResetBatchSize).Return([]entity.PasswordResetRequest{
+		{ID: 1, Email: "unknown@example.com"},
+		{ID: 2, Email: "test@example.com"},
+	}, nil)
+	mockUserRepo.On("GetUserByEmail", ctx, "unknown@example.com").Return((*entity.User)(nil), repoerrors.ErrNotFound)
+	mockUserRepo.On("GetUserByEmail", ctx, "test@example.com").Return(&entity.User{ID: "user-id", Email: "test@example.com"}, nil)
+	mockPasswordResetRepo.On("CreatePasswordResetToken", ctx, mock.MatchedBy(func(token entity.PasswordResetToken) bool {
+		return token.UserID == "user-id"
+	})).Return(nil).Once()
+	mockEmail.On("SendPasswordResetEmail", ctx, mock.Anything, mock.Anything).Return(nil).Once()
+
+	err := account.ProcessPasswordResetRequests(ctx)
+
+	assert.NoError(t, err)
+	mockPasswordResetRepo.AssertExpectations(t)
+	mockEmail.AssertExpectations(t)
+}
+
+func newTestPasswordPolicy() *passwordpolicy.Policy {
+	return passwordpolicy.New(passwordpolicy.Config{
+		MinLength:           10,
+		MinCharacterClasses: 3,
+		MaxRepeatedChars:    3,
+		BannedWords:         []string{"medods"},
+	}, nil)
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository"
+	"medods-tz/pkg/secevent"
+	"time"
+)
+
+type requestInfoKey struct{}
+
+type requestInfo struct {
+	IP        string
+	UserAgent string
+}
+
+// WithRequestInfo stores the caller's IP and user agent, so audit events can be attributed
+// without threading them through every service method.
+func WithRequestInfo(ctx context.Context, ip, userAgent string) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, requestInfo{IP: ip, UserAgent: userAgent})
+}
+
+// SecurityEventPublisher ships audit events to external sinks such as a SIEM. Publish must not block.
+type SecurityEventPublisher interface {
+	Publish(event secevent.Event)
+}
+
+type Audit struct {
+	auditRepo   repository.AuditRepository
+	signKey     string
+	securityLog *logrus.Logger
+	publisher   SecurityEventPublisher
+}
+
+// NewAudit creates the audit service, publisher may be nil when no sinks are configured.
+func NewAudit(
+	auditRepo repository.AuditRepository,
+	signKey string,
+	securityLog *logrus.Logger,
+	publisher SecurityEventPublisher) *Audit {
+	return &Audit{
+		auditRepo:   auditRepo,
+		signKey:     signKey,
+		securityLog: securityLog,
+		publisher:   publisher,
+	}
+}
+
+// Record stores an audit event and publishes it to the security event sinks. The action being audited
+// has already happened, so a failed write is logged to the security log instead of failing the request.
+func (s *Audit) Record(ctx context.Context, event entity.AuditEvent) {
+	if info, ok := ctx.Value(requestInfoKey{}).(requestInfo); ok {
+		if event.IP == "" {
+			event.IP = info.IP
+		}
+		if event.UserAgent == "" {
+			event.UserAgent = info.UserAgent
+		}
+	}
+	if event.ActorID == "" {
+		event.ActorID = event.SubjectID
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	_, err := s.auditRepo.CreateAuditEvent(ctx, event)
+	if err != nil {
+		s.securityLog.Errorf("error while recording audit event type=%s subject_id=%s: %v", event.Type, event.SubjectID, err)
+	}
+
+	if s.publisher != nil {
+		s.publisher.Publish(securityEvent(event))
+	}
+}
+
+var auditEventNames = map[string]string{
+	entity.AuditTokenIssued:            "Tokens issued",
+	entity.AuditTokenRefreshed:         "Tokens refreshed",
+	entity.AuditRefreshTokenReuse:      "Refresh token reuse detected",
+	entity.AuditIPChanged:              "Client IP changed on refresh",
+	entity.AuditLogout:                 "Logout",
+	entity.AuditLoginFailed:            "Login failed",
+	entity.AuditAccountLocked:          "Account locked",
+	entity.AuditAccountUnlocked:        "Account unlocked",
+	entity.AuditUserRegistered:         "User registered",
+	entity.AuditEmailChanged:           "Email changed",
+	entity.AuditEmailVerified:          "Email verified",
+	entity.AuditPasswordResetRequested: "Password reset requested",
+	entity.AuditPasswordReset:          "Password reset",
+	entity.AuditAdminAction:            "Admin action",
+	entity.AuditLoginReported:          "Sign-in reported by user",
+	entity.AuditRiskAssessed:           "Sign-in risk assessed",
+	entity.AuditStepUpFailed:           "Wrong step-up code",
+	entity.AuditNewDevice:              "Sign-in from a new device",
+	entity.AuditDeviceForgotten:        "Device forgotten",
+	entity.AuditIPNotAllowed:           "Sign-in from a refused IP address",
+}
+
+// auditEventSeverities uses the CEF scale, types that are not listed are informational (3).
+var auditEventSeverities = map[string]int{
+	entity.AuditRefreshTokenReuse: 8,
+	entity.AuditLoginReported:     8,
+	entity.AuditAccountLocked:     7,
+	entity.AuditIPChanged:         6,
+	entity.AuditIPNotAllowed:      6,
+	entity.AuditLoginFailed:       5,
+	entity.AuditStepUpFailed:      5,
+	entity.AuditNewDevice:         4,
+	entity.AuditPasswordReset:     5,
+	entity.AuditEmailChanged:      5,
+	entity.AuditAdminAction:       4,
+}
+
+// riskActionSeverities grade risk_assessed events by the action taken.
+var riskActionSeverities = map[string]int{
+	RiskDeny:   8,
+	RiskStepUp: 6,
+	RiskNotify: 5,
+}
+
+func securityEvent(event entity.AuditEvent) secevent.Event {
+	severity, ok := auditEventSeverities[event.Type]
+	if !ok {
+		severity = 3
+	}
+	if event.Type == entity.AuditRiskAssessed {
+		if actionSeverity, ok := riskActionSeverities[event.Metadata["action"]]; ok {
+			severity = actionSeverity
+		}
+	}
+
+	return secevent.Event{
+		Time:      event.CreatedAt,
+		Type:      event.Type,
+		Name:      auditEventNames[event.Type],
+		Severity:  severity,
+		Actor:     event.ActorID,
+		Subject:   event.SubjectID,
+		IP:        event.IP,
+		UserAgent: event.UserAgent,
+		Fields:    event.Metadata,
+	}
+}
+
+// ListEvents returns events newest first and the cursor of the next page, empty on the last page.
+func (s *Audit) ListEvents(ctx context.Context, filter entity.AuditFilter, cursor string) ([]entity.AuditEvent, string, error) {
+	if cursor != "" {
+		beforeID, err := decodeIDCursor(cursor)
+		if err != nil {
+			return nil, "", ErrInvalidCursor
+		}
+		filter.BeforeID = beforeID
+	}
+
+	events, nextCursor, err := listPage(filter.Limit, func(limit int) ([]entity.AuditEvent, error) {
+		filter.Limit = limit
+		return s.auditRepo.ListAuditEvents(ctx, filter)
+	}, func(event entity.AuditEvent) string {
+		return encodeIDCursor(event.ID)
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("error while listing audit events: %w", err)
+	}
+
+	return events, nextCursor, nil
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"medods-tz/internal/entity"
+	"medods-tz/pkg/secevent"
+	"testing"
+)
+
+func TestAudit_ListEvents_Pagination(t *testing.T) {
+	ctx := context.Background()
+	mockAuditRepo := new(mockAuditRepo)
+	audit := NewAudit(mockAuditRepo, "test-sign-key", logrus.New(), nil)
+
+	mockAuditRepo.On("ListAuditEvents", ctx, entity.AuditFilter{UserID: "user-id", Limit: 3}).
+		Return([]entity.AuditEvent{{ID: 10}, {ID: 9}, {ID: 8}}, nil)
+
+	events, nextCursor, err := audit.ListEvents(ctx, entity.AuditFilter{UserID: "user-id", Limit: 2}, "")
+
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+	assert.NotEmpty(t, nextCursor)
+
+	mockAuditRepo.On("ListAuditEvents", ctx, entity.AuditFilter{UserID: "user-id", BeforeID: 9, Limit: 3}).
+		Return([]entity.AuditEvent{{ID: 8}}, nil)
+
+	events, nextCursor, err = audit.ListEvents(ctx, entity.AuditFilter{UserID: "user-id", Limit: 2}, nextCursor)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []entity.AuditEvent{{ID: 8}}, events)
+	assert.Empty(t, nextCursor)
+}
+
+func TestAudit_ListEvents_InvalidCursor(t *testing.T) {
+	mockAuditRepo := new(mockAuditRepo)
+	audit := NewAudit(mockAuditRepo, "test-sign-key", logrus.New(), nil)
+
+	_, _, err := audit.ListEvents(context.Background(), entity.AuditFilter{}, "not a cursor")
+
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+	mockAuditRepo.AssertNotCalled(t, "ListAuditEvents", mock.Anything, mock.Anything)
+}
+
+func TestAudit_Record_FillsRequestInfo(t *testing.T) {
+	ctx := WithRequestInfo(context.Background(), "10.0.0.1", "curl/8.0")
+	mockAuditRepo := new(mockAuditRepo)
+	audit := NewAudit(mockAuditRepo, "test-sign-key", logrus.New(), nil)
+
+	mockAuditRepo.On("CreateAuditEvent", ctx, mock.MatchedBy(func(event entity.AuditEvent) bool {
+		return event.IP == "10.0.0.1" && event.UserAgent == "curl/8.0" && event.ActorID == "user-id" && !event.CreatedAt.IsZero()
+	})).Return(int64(1), nil)
+
+	audit.Record(ctx, entity.AuditEvent{Type: entity.AuditLogout, SubjectID: "user-id"})
+
+	mockAuditRepo.AssertExpectations(t)
+}
+
+type recordingPublisher struct {
+	events []secevent.Event
+}
+
+func (p *recordingPublisher) Publish(event secevent.Event) {
+	p.events = append(p.events, event)
+}
+
+func TestAudit_Record_PublishesSecurityEvent(t *testing.T) {
+	ctx := context.Background()
+	mockAuditRepo := new(mockAuditRepo)
+	publisher := &recordingPublisher{}
+	audit := NewAudit(mockAuditRepo, "test-sign-key", logrus.New(), publisher)
+
+	// sinks still get the event when the database is unavailable
+	mockAuditRepo.On("CreateAuditEvent", ctx, mock.Anything).Return(int64(0), errors.New("connection refused"))
+
+	audit.Record(ctx, entity.AuditEvent{Type: entity.AuditRefreshTokenReuse, SubjectID: "user-id"})
+
+	assert.Len(t, publisher.events, 1)
+	assert.Equal(t, entity.AuditRefreshTokenReuse, publisher.events[0].Type)
+	assert.Equal(t, 8, publisher.events[0].Severity)
+	assert.Equal(t, "user-id", publisher.events[0].Actor)
+}
+
+func newTestAudit() *Audit {
+	mockAuditRepo := new(mockAuditRepo)
+	mockAuditRepo.On("CreateAuditEvent", mock.Anything, mock.Anything).Return(int64(1), nil).Maybe()
+
+	return NewAudit(mockAuditRepo, "test-sign-key", logrus.New(), nil)
+}
@@ -0,0 +1,257 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository/repoerrors"
+	"time"
+)
+
+const (
+	// auditCheckpointDelay leaves room for events of the previous day that are still being written.
+	auditCheckpointDelay = 5 * time.Minute
+	auditVerifyBatchSize = 1000
+)
+
+type AuditChainReport struct {
+	EventsChecked      int
+	CheckpointsChecked int
+	// UnchainedEvents were written before hash chaining was introduced and cannot be verified.
+	UnchainedEvents int
+	// Break is the first broken link, nil when the whole chain is intact.
+	Break *AuditChainBreak
+}
+
+type AuditChainBreak struct {
+	Day     time.Time
+	EventID int64
+	Reason  string
+}
+
+func (b *AuditChainBreak) String() string {
+	if b.EventID == 0 {
+		return fmt.Sprintf("day %s: %s", b.Day.Format(time.DateOnly), b.Reason)
+	}
+
+	return fmt.Sprintf("day %s, event %d: %s", b.Day.Format(time.DateOnly), b.EventID, b.Reason)
+}
+
+// CreateCheckpoints signs every finished day of the chain that has no checkpoint yet.
+func (s *Audit) CreateCheckpoints(ctx context.Context) error {
+	before := time.Now().UTC().Add(-auditCheckpointDelay).Truncate(24 * time.Hour)
+
+	days, err := s.auditRepo.ListUncheckpointedAuditDays(ctx, before)
+	if err != nil {
+		return fmt.Errorf("error while listing days without audit checkpoint: %w", err)
+	}
+	if len(days) == 0 {
+		return nil
+	}
+
+	last, err := s.auditRepo.GetLastAuditCheckpoint(ctx)
+	if err != nil && !errors.Is(err, repoerrors.ErrNotFound) {
+		return fmt.Errorf("error while getting last audit checkpoint: %w", err)
+	}
+
+	for _, day := range days {
+		if last != nil && !day.After(last.Day) {
+			// nothing is written into sealed days, the verifier reports these events
+			s.securityLog.Errorf("audit events found for day %s older than the last checkpoint", day.Format(time.DateOnly))
+			continue
+		}
+
+		checkpoint, err := s.auditRepo.GetAuditDaySummary(ctx, day)
+		if err != nil {
+			return fmt.Errorf("error while summarizing audit day %s: %w", day.Format(time.DateOnly), err)
+		}
+		if last != nil {
+			checkpoint.PrevSignature = last.Signature
+		}
+		checkpoint.CreatedAt = time.Now()
+		checkpoint.Signature = s.signCheckpoint(*checkpoint)
+
+		err = s.auditRepo.CreateAuditCheckpoint(ctx, *checkpoint)
+		if err != nil {
+			if errors.Is(err, repoerrors.ErrAlreadyExists) {
+				// another replica is sealing the same days
+				return nil
+			}
+
+			return fmt.Errorf("error while creating audit checkpoint: %w", err)
+		}
+
+		s.securityLog.Infof("audit checkpoint for %s created: %d events, last_event_id=%d",
+			day.Format(time.DateOnly), checkpoint.EventCount, checkpoint.LastEventID)
+		last = checkpoint
+	}
+
+	return nil
+}
+
+// VerifyChain walks all audit events and checkpoints in order and stops at the first broken link.
+func (s *Audit) VerifyChain(ctx context.Context) (*AuditChainReport, error) {
+	report := &AuditChainReport{}
+
+	checkpoints, err := s.auditRepo.ListAuditCheckpoints(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error while listing audit checkpoints: %w", err)
+	}
+
+	var prevSignature string
+	for _, checkpoint := range checkpoints {
+		if checkpoint.PrevSignature != prevSignature {
+			report.Break = &AuditChainBreak{Day: checkpoint.Day, Reason: "checkpoint does not follow the previous checkpoint"}
+			return report, nil
+		}
+		if !hmac.Equal([]byte(checkpoint.Signature), []byte(s.signCheckpoint(checkpoint))) {
+			report.Break = &AuditChainBreak{Day: checkpoint.Day, Reason: "checkpoint signature is invalid"}
+			return report, nil
+		}
+		prevSignature = checkpoint.Signature
+		report.CheckpointsChecked++
+	}
+
+	walker := auditChainWalker{checkpoints: checkpoints, report: report}
+	var afterID int64
+	for report.Break == nil {
+		events, err := s.auditRepo.ListAuditChain(ctx, afterID, auditVerifyBatchSize)
+		if err != nil {
+			return nil, fmt.Errorf("error while listing audit events: %w", err)
+		}
+		if len(events) == 0 {
+			walker.finish()
+			break
+		}
+
+		for _, event := range events {
+			if !walker.next(event) {
+				break
+			}
+		}
+		afterID = events[len(events)-1].ID
+	}
+
+	return report, nil
+}
+
+func (s *Audit) signCheckpoint(checkpoint entity.AuditCheckpoint) string {
+	payload := fmt.Sprintf("%s|%d|%d|%d|%s|%s",
+		checkpoint.Day.Format(time.DateOnly),
+		checkpoint.FirstEventID,
+		checkpoint.LastEventID,
+		checkpoint.EventCount,
+		checkpoint.LastHash,
+		checkpoint.PrevSignature)
+
+	mac := hmac.New(sha256.New, []byte(s.signKey))
+	mac.Write([]byte(payload))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// auditChainWalker keeps the state of the current day while VerifyChain reads events page by page.
+type auditChainWalker struct {
+	checkpoints []entity.AuditCheckpoint
+	// nextCheckpoint is the index of the first checkpoint whose day has not been reached yet
+	nextCheckpoint int
+	report         *AuditChainReport
+
+	chained  bool
+	day      time.Time
+	prevHash string
+	firstID  int64
+	lastID   int64
+	count    int
+}
+
+func (w *auditChainWalker) next(event entity.AuditEvent) bool {
+	if event.Hash == "" {
+		if w.chained {
+			return w.broken(event.ChainDay(), event.ID, "event is not chained")
+		}
+		w.report.UnchainedEvents++
+		return true
+	}
+	w.chained = true
+
+	day := event.ChainDay()
+	if w.count == 0 || !day.Equal(w.day) {
+		if w.count > 0 {
+			if day.Before(w.day) {
+				return w.broken(day, event.ID, "event is out of day order")
+			}
+			if !w.finishDay() {
+				return false
+			}
+		}
+		if !w.skipMissingDays(day) {
+			return false
+		}
+
+		w.day, w.prevHash, w.firstID, w.count = day, "", event.ID, 0
+	}
+
+	if event.PrevHash != w.prevHash {
+		return w.broken(day, event.ID, "previous hash does not match, an event before it was changed or removed")
+	}
+	if event.ComputeHash() != event.Hash {
+		return w.broken(day, event.ID, "event content does not match its hash")
+	}
+
+	w.prevHash = event.Hash
+	w.lastID = event.ID
+	w.count++
+	w.report.EventsChecked++
+
+	return true
+}
+
+func (w *auditChainWalker) finish() {
+	if w.count > 0 && !w.finishDay() {
+		return
+	}
+	if w.nextCheckpoint < len(w.checkpoints) {
+		checkpoint := w.checkpoints[w.nextCheckpoint]
+		w.broken(checkpoint.Day, 0, "events of a checkpointed day are missing")
+	}
+}
+
+// finishDay compares the finished day with its checkpoint, if the day has been sealed already.
+func (w *auditChainWalker) finishDay() bool {
+	if w.nextCheckpoint < len(w.checkpoints) && w.checkpoints[w.nextCheckpoint].Day.Equal(w.day) {
+		checkpoint := w.checkpoints[w.nextCheckpoint]
+		w.nextCheckpoint++
+
+		if checkpoint.FirstEventID != w.firstID || checkpoint.LastEventID != w.lastID ||
+			checkpoint.EventCount != w.count || checkpoint.LastHash != w.prevHash {
+			return w.broken(w.day, w.lastID, "day does not match its checkpoint, events were removed or added")
+		}
+
+		return true
+	}
+
+	// checkpoints are created in day order, so a later one means this day was skipped
+	if w.nextCheckpoint < len(w.checkpoints) {
+		return w.broken(w.day, w.firstID, "day has no checkpoint although later days are sealed")
+	}
+
+	return true
+}
+
+func (w *auditChainWalker) skipMissingDays(day time.Time) bool {
+	if w.nextCheckpoint < len(w.checkpoints) && w.checkpoints[w.nextCheckpoint].Day.Before(day) {
+		return w.broken(w.checkpoints[w.nextCheckpoint].Day, 0, "events of a checkpointed day are missing")
+	}
+
+	return true
+}
+
+func (w *auditChainWalker) broken(day time.Time, eventID int64, reason string) bool {
+	w.report.Break = &AuditChainBreak{Day: day, EventID: eventID, Reason: reason}
+	return false
+}
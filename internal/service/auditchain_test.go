@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"medods-tz/internal/entity"
+	"testing"
+	"time"
+)
+
+func TestAudit_VerifyChain_Intact(t *testing.T) {
+	audit, events, checkpoints := newTestAuditChain()
+
+	report := verifyTestAuditChain(t, audit, events, checkpoints)
+
+	assert.Nil(t, report.Break)
+	assert.Equal(t, len(events), report.EventsChecked)
+	assert.Equal(t, 1, report.CheckpointsChecked)
+}
+
+func TestAudit_VerifyChain_EditedEvent(t *testing.T) {
+	audit, events, checkpoints := newTestAuditChain()
+	events[1].Metadata = map[string]string{"email": "someone-else@example.com"}
+
+	report := verifyTestAuditChain(t, audit, events, checkpoints)
+
+	assert.NotNil(t, report.Break)
+	assert.Equal(t, int64(2), report.Break.EventID)
+	assert.Contains(t, report.Break.Reason, "does not match its hash")
+}
+
+func TestAudit_VerifyChain_RemovedEvent(t *testing.T) {
+	audit, events, checkpoints := newTestAuditChain()
+	events = append(events[:1], events[2:]...)
+
+	report := verifyTestAuditChain(t, audit, events, checkpoints)
+
+	assert.NotNil(t, report.Break)
+	assert.Equal(t, int64(3), report.Break.EventID)
+	assert.Contains(t, report.Break.Reason, "previous hash does not match")
+}
+
+func TestAudit_VerifyChain_RemovedLastEventOfSealedDay(t *testing.T) {
+	audit, events, checkpoints := newTestAuditChain()
+	events = append(events[:2], events[3:]...)
+
+	report := verifyTestAuditChain(t, audit, events, checkpoints)
+
+	assert.NotNil(t, report.Break)
+	assert.Contains(t, report.Break.Reason, "does not match its checkpoint")
+}
+
+func TestAudit_VerifyChain_ForgedCheckpoint(t *testing.T) {
+	audit, events, checkpoints := newTestAuditChain()
+	checkpoints[0].EventCount = 2
+
+	report := verifyTestAuditChain(t, audit, events, checkpoints)
+
+	assert.NotNil(t, report.Break)
+	assert.Contains(t, report.Break.Reason, "checkpoint signature is invalid")
+}
+
+func TestAudit_CreateCheckpoints(t *testing.T) {
+	ctx := context.Background()
+	mockAuditRepo := new(mockAuditRepo)
+	audit := NewAudit(mockAuditRepo, "test-sign-key", logrus.New(), nil)
+
+	day := time.Date(2024, 12, 27, 0, 0, 0, 0, time.UTC)
+	previous := entity.AuditCheckpoint{Day: day.AddDate(0, 0, -1), Signature: "previous-signature"}
+	mockAuditRepo.On("ListUncheckpointedAuditDays", ctx, mock.Anything).Return([]time.Time{day}, nil)
+	mockAuditRepo.On("GetLastAuditCheckpoint", ctx).Return(&previous, nil)
+	mockAuditRepo.On("GetAuditDaySummary", ctx, day).Return(&entity.AuditCheckpoint{
+		Day: day, FirstEventID: 4, LastEventID: 9, EventCount: 6, LastHash: "last-hash",
+	}, nil)
+	mockAuditRepo.On("CreateAuditCheckpoint", ctx, mock.MatchedBy(func(checkpoint entity.AuditCheckpoint) bool {
+		return checkpoint.PrevSignature == "previous-signature" && checkpoint.Signature == audit.signCheckpoint(checkpoint)
+	})).Return(nil)
+
+	err := audit.CreateCheckpoints(ctx)
+
+	assert.NoError(t, err)
+	mockAuditRepo.AssertExpectations(t)
+}
+
+// newTestAuditChain builds a sealed day of three events and one unsealed event of the next day.
+func newTestAuditChain() (*Audit, []entity.AuditEvent, []entity.AuditCheckpoint) {
+	audit := NewAudit(new(mockAuditRepo), "test-sign-key", logrus.New(), nil)
+	day := time.Date(2024, 12, 27, 0, 0, 0, 0, time.UTC)
+
+	events := []entity.AuditEvent{
+		{ID: 1, Type: entity.AuditUserRegistered, SubjectID: "user-id", CreatedAt: day.Add(time.Hour)},
+		{ID: 2, Type: entity.AuditLoginFailed, SubjectID: "user-id", Metadata: map[string]string{"email": "test@example.com"}, CreatedAt: day.Add(2 * time.Hour)},
+		{ID: 3, Type: entity.AuditTokenIssued, SubjectID: "user-id", IP: "127.0.0.1", CreatedAt: day.Add(3 * time.Hour)},
+		{ID: 4, Type: entity.AuditLogout, SubjectID: "user-id", CreatedAt: day.Add(25 * time.Hour)},
+	}
+	var prevHash string
+	for i := range events {
+		if i == 3 {
+			prevHash = ""
+		}
+		events[i].PrevHash = prevHash
+		events[i].Hash = events[i].ComputeHash()
+		prevHash = events[i].Hash
+	}
+
+	checkpoint := entity.AuditCheckpoint{Day: day, FirstEventID: 1, LastEventID: 3, EventCount: 3, LastHash: events[2].Hash}
+	checkpoint.Signature = audit.signCheckpoint(checkpoint)
+
+	return audit, events, []entity.AuditCheckpoint{checkpoint}
+}
+
+func verifyTestAuditChain(t *testing.T, audit *Audit, events []entity.AuditEvent, checkpoints []entity.AuditCheckpoint) *AuditChainReport {
+	ctx := context.Background()
+	mockAuditRepo := new(mockAuditRepo)
+	audit.auditRepo = mockAuditRepo
+
+	mockAuditRepo.On("ListAuditCheckpoints", ctx).Return(checkpoints, nil)
+	mockAuditRepo.On("ListAuditChain", ctx, int64(0), auditVerifyBatchSize).Return(events, nil)
+	mockAuditRepo.On("ListAuditChain", ctx, events[len(events)-1].ID, auditVerifyBatchSize).Return([]entity.AuditEvent{}, nil)
+
+	report, err := audit.VerifyChain(ctx)
+	assert.NoError(t, err)
+
+	return report
+}
@@ -1,7 +1,9 @@
 package service
 
 import (
+	"cmp"
 	"context"
+	"crypto/ed25519"
 	"crypto/rand"
 	"encoding/base64"
 	"errors"
@@ -13,47 +15,122 @@ import (
 	"medods-tz/internal/repository"
 	"medods-tz/internal/repository/repoerrors"
 	"medods-tz/internal/sender"
+	"medods-tz/pkg/hasher"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// accessTokenAudience sets access tokens apart from the other tokens signed with the global key, such as
+// email verification and login report links, which Authenticate must never accept.
+const accessTokenAudience = "access"
+
 type TokenClaims struct {
 	jwt.StandardClaims
 	ClientIP string
 	UserID   string
+	// Roles and Scope are the role names of the user and the permissions they grant, space-separated,
+	// as they were when the token was issued.
+	Roles []string `json:"roles,omitempty"`
+	Scope string   `json:"scope,omitempty"`
+	// OrgID and OrgRole are the organization the session was issued for and the role of the user in it,
+	// both are empty for personal sessions.
+	OrgID   string `json:"org_id,omitempty"`
+	OrgRole string `json:"org_role,omitempty"`
+}
+
+// HasScope tells whether the token was issued with permission in its scope.
+func (c *TokenClaims) HasScope(permission string) bool {
+	return slices.Contains(strings.Fields(c.Scope), permission)
 }
 
 type Auth struct {
 	userRepo        repository.UserRepository
 	tokenRepo       repository.TokenRepository
+	transactor      repository.Transactor
 	signKey         string
 	tokenTTL        time.Duration
 	refreshTokenTTL time.Duration
 	securityLog     *logrus.Logger
-	emailSender     sender.Email
+	audit           AuditService
+	webhooks        WebhookService
+	notifications   NotificationService
+	passwordHasher  hasher.PasswordHasher
+	bruteForce      BruteForceService
+	loginReports    LoginReportConfig
+	risk            *RiskEngine
+	stepUp          StepUpService
+	devices         DeviceService
+	ipRules         IPRuleService
+	roles           RoleService
+	orgs            OrganizationService
+	policies        TokenPolicyService
+
+	requireVerifiedEmail bool
+	dummyPasswordHash    string
+}
+
+// AuthDependencies are the repositories, settings and services Auth issues and checks sessions with.
+type AuthDependencies struct {
+	UserRepo        repository.UserRepository
+	TokenRepo       repository.TokenRepository
+	Transactor      repository.Transactor
+	TokenTTL        time.Duration
+	RefreshTokenTTL time.Duration
+	SignKey         string
+	SecurityLog     *logrus.Logger
+	Audit           AuditService
+	Webhooks        WebhookService
+	Notifications   NotificationService
+	PasswordHasher  hasher.PasswordHasher
+	BruteForce      BruteForceService
+	LoginReports    LoginReportConfig
+	Risk            *RiskEngine
+	StepUp          StepUpService
+	Devices         DeviceService
+	IPRules         IPRuleService
+	Roles           RoleService
+	Orgs            OrganizationService
+	Policies        TokenPolicyService
+
+	RequireVerifiedEmail bool
 }
 
-func NewAuth(
-	userRepo repository.UserRepository,
-	tokenRepo repository.TokenRepository,
-	tokenTTL time.Duration,
-	refreshTokenTTL time.Duration,
-	signKey string,
-	securityLog *logrus.Logger,
-	emailSender sender.Email) *Auth {
+func NewAuth(dependencies AuthDependencies) *Auth {
+	// used to spend the same time on unknown emails as on real password checks
+	dummyPasswordHash, _ := dependencies.PasswordHasher.Hash("dummy-password")
+
 	return &Auth{
-		userRepo:        userRepo,
-		tokenRepo:       tokenRepo,
-		signKey:         signKey,
-		tokenTTL:        tokenTTL,
-		refreshTokenTTL: refreshTokenTTL,
-		securityLog:     securityLog,
-		emailSender:     emailSender,
+		userRepo:             dependencies.UserRepo,
+		tokenRepo:            dependencies.TokenRepo,
+		transactor:           dependencies.Transactor,
+		signKey:              dependencies.SignKey,
+		tokenTTL:             dependencies.TokenTTL,
+		refreshTokenTTL:      dependencies.RefreshTokenTTL,
+		securityLog:          dependencies.SecurityLog,
+		audit:                dependencies.Audit,
+		webhooks:             dependencies.Webhooks,
+		notifications:        dependencies.Notifications,
+		passwordHasher:       dependencies.PasswordHasher,
+		bruteForce:           dependencies.BruteForce,
+		loginReports:         dependencies.LoginReports,
+		risk:                 dependencies.Risk,
+		stepUp:               dependencies.StepUp,
+		devices:              dependencies.Devices,
+		ipRules:              dependencies.IPRules,
+		roles:                dependencies.Roles,
+		orgs:                 dependencies.Orgs,
+		policies:             dependencies.Policies,
+		requireVerifiedEmail: dependencies.RequireVerifiedEmail,
+		dummyPasswordHash:    dummyPasswordHash,
 	}
 }
 
-func (s *Auth) CreateTokens(ctx context.Context, userID, clientIP string) (*entity.Tokens, error) {
+// CreateTokens starts a session of the user, for organization orgID unless it is empty.
+func (s *Auth) CreateTokens(ctx context.Context, userID, orgID, clientIP string, device DeviceInput) (*entity.Tokens, error) {
 
-	_, err := s.userRepo.GetUserByID(ctx, userID)
+	user, err := s.userRepo.GetUserByID(ctx, userID)
 	if err != nil {
 		if errors.Is(err, repoerrors.ErrNotFound) {
 			return nil, ErrUserNotFound
@@ -62,41 +139,221 @@ func (s *Auth) CreateTokens(ctx context.Context, userID, clientIP string) (*enti
 		return nil, fmt.Errorf("error while trying to find user: %w", err)
 	}
 
-	accessToken, err := s.generateAccessToken(clientIP, userID)
+	err = userStatusError(user)
 	if err != nil {
-		return nil, fmt.Errorf("error while generating access token: %w", err)
+		return nil, err
 	}
 
-	refreshToken, refreshTokenHash, err := s.generateRefreshToken()
+	if s.requireVerifiedEmail && user.EmailVerifiedAt == nil {
+		return nil, ErrEmailNotVerified
+	}
 
-	refreshTokenEntiry := entity.RefreshToken{
-		UserID:      userID,
-		RefreshHash: refreshTokenHash,
-		IssuedAt:    time.Now(),
-		ExpiresAt:   time.Now().Add(s.refreshTokenTTL),
-		ClientIP:    clientIP,
-		Used:        false,
+	if user.PasswordResetRequired {
+		return nil, ErrPasswordResetRequired
 	}
 
-	err = s.tokenRepo.CreateRefreshToken(ctx, refreshTokenEntiry)
+	return s.startSession(ctx, user, RiskInput{
+		Operation:      RiskOperationToken,
+		OrgID:          orgID,
+		IP:             clientIP,
+		Location:       s.risk.Locate(clientIP),
+		FailedAttempts: s.failedAttempts(ctx, IPAttemptKey(clientIP)),
+		Now:            time.Now(),
+	}, device, entity.GrantUserID)
+}
+
+// Login starts a session of the user with the given credentials, for organization orgID unless it is empty.
+func (s *Auth) Login(ctx context.Context, email, password, orgID, clientIP string, device DeviceInput) (*entity.Tokens, error) {
+	attemptKeys := []string{IPAttemptKey(clientIP), AccountAttemptKey(email)}
+
+	err := s.bruteForce.Check(ctx, attemptKeys...)
 	if err != nil {
-		if errors.Is(err, repoerrors.ErrAlreadyExists) {
-			return nil, ErrSessionAlreadyExists
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			// spend the same time as for an existing user so timing does not reveal registered emails
+			_, _ = s.passwordHasher.Verify(password, s.dummyPasswordHash)
+			return nil, s.loginFailed(ctx, attemptKeys, "", email, clientIP)
 		}
 
-		return nil, fmt.Errorf("error while creating refresh token: %w", err)
+		return nil, fmt.Errorf("error while trying to find user: %w", err)
 	}
 
-	tokens := entity.Tokens{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
+	if user.PasswordHash == "" {
+		// as for unknown emails, timing must not reveal accounts without a password
+		_, _ = s.passwordHasher.Verify(password, s.dummyPasswordHash)
+		return nil, s.loginFailed(ctx, attemptKeys, user.ID, email, clientIP)
 	}
 
-	return &tokens, nil
+	ok, err := s.passwordHasher.Verify(password, user.PasswordHash)
+	if err != nil {
+		return nil, fmt.Errorf("error while comparing password hash: %w", err)
+	}
+	if !ok {
+		return nil, s.loginFailed(ctx, attemptKeys, user.ID, email, clientIP)
+	}
+
+	// counted before the reset, failures right before a successful login add to its risk
+	failedAttempts := s.failedAttempts(ctx, attemptKeys...)
+
+	err = s.bruteForce.Reset(ctx, AccountAttemptKey(email))
+	if err != nil {
+		s.securityLog.Errorf("error while resetting failed login attempts of user_id=%s: %v", user.ID, err)
+	}
+
+	err = userStatusError(user)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.PasswordResetRequired {
+		return nil, ErrPasswordResetRequired
+	}
+
+	if s.passwordHasher.NeedsRehash(user.PasswordHash) {
+		s.rehashPassword(ctx, user.ID, password)
+	}
+
+	if s.requireVerifiedEmail && user.EmailVerifiedAt == nil {
+		return nil, ErrEmailNotVerified
+	}
+
+	return s.startSession(ctx, user, RiskInput{
+		Operation:      RiskOperationLogin,
+		OrgID:          orgID,
+		IP:             clientIP,
+		Location:       s.risk.Locate(clientIP),
+		FailedAttempts: failedAttempts,
+		Now:            time.Now(),
+	}, device, entity.GrantPassword)
 }
 
-func (s *Auth) RefreshTokens(ctx context.Context, refreshToken, accessToken string) (*entity.Tokens, error) {
-	claims, err := s.parseAccessToken(accessToken)
+// CompleteStepUp issues the tokens a risky sign-in was held back for, once the emailed code is entered.
+// A challenged refresh spends the refresh token like a normal refresh.
+func (s *Auth) CompleteStepUp(ctx context.Context, challengeID, code, clientIP string, device DeviceInput) (*entity.Tokens, error) {
+	challenge, err := s.stepUp.Verify(ctx, challengeID, code, clientIP)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, challenge.UserID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return nil, ErrUserNotFound
+		}
+
+		return nil, fmt.Errorf("error while trying to find user: %w", err)
+	}
+
+	// the sign-in may have been reported, or the user disabled, in the meantime
+	err = userStatusError(user)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.PasswordResetRequired {
+		return nil, ErrPasswordResetRequired
+	}
+
+	var previous *entity.RefreshToken
+	var sessionDeviceID *string
+	orgID := ""
+	if challenge.OrgID != nil {
+		orgID = *challenge.OrgID
+	}
+	if challenge.RefreshTokenID != nil {
+		previous, err = s.getRefreshToken(ctx, user.ID, orgID, *challenge.RefreshTokenID)
+		if err != nil {
+			return nil, err
+		}
+		sessionDeviceID = previous.DeviceID
+		orgID = sessionOrgID(previous)
+	}
+
+	// and the membership, the token policy or the ip rules of the user may have changed
+	membership, err := s.membership(ctx, user.ID, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := s.tokenPolicy(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionStartedAt := time.Now()
+	if previous != nil {
+		err = checkRefresh(policy, previous, sessionStartedAt)
+		if err != nil {
+			return nil, err
+		}
+		sessionStartedAt = previous.SessionStartedAt
+	}
+
+	err = s.ipRules.Check(ctx, user.ID, orgID, clientIP, challenge.Operation)
+	if err != nil {
+		return nil, err
+	}
+
+	// the user just proved access to their email, so a new device is remembered without a notification
+	match, err := s.devices.Recognize(ctx, user.ID, device, sessionDeviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	var tokens *entity.Tokens
+	err = s.withinSession(ctx, orgID, func(ctx context.Context) error {
+		err := s.stepUp.Consume(ctx, challenge.ID)
+		if err != nil {
+			return err
+		}
+
+		if previous != nil {
+			err = s.tokenRepo.MarkRefreshTokenUsed(ctx, previous.ID)
+			if err != nil {
+				if errors.Is(err, repoerrors.ErrNotFound) {
+					return ErrRefreshTokenNotFound
+				}
+
+				return fmt.Errorf("error while marking refresh token as used: %w", err)
+			}
+		}
+
+		tokens, err = s.issueTokens(ctx, user.ID, clientIP, s.risk.Locate(clientIP), match, membership, policy, sessionStartedAt)
+		if err != nil {
+			return err
+		}
+
+		if previous != nil && previous.ClientIP != clientIP {
+			return s.webhooks.Enqueue(ctx, entity.WebhookSessionIPChanged, map[string]string{
+				"user_id":          user.ID,
+				"refresh_token_id": previous.ID,
+				"ip":               clientIP,
+				"previous_ip":      previous.ClientIP,
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, entity.AuditEvent{
+		Type:      entity.AuditTokenIssued,
+		SubjectID: user.ID,
+		IP:        clientIP,
+		Metadata:  map[string]string{"method": "step_up", "operation": challenge.Operation, "challenge_id": challenge.ID},
+	})
+
+	return tokens, nil
+}
+
+func (s *Auth) RefreshTokens(ctx context.Context, refreshToken, accessToken, clientIP string, device DeviceInput) (*entity.Tokens, error) {
+	claims, err := s.parseAccessToken(ctx, accessToken)
 	if err != nil && !errors.Is(err, ErrAccessTokenExpired) {
 		return nil, fmt.Errorf("%w: %w", ErrParsingAccessToken, err)
 	}
@@ -110,9 +367,19 @@ func (s *Auth) RefreshTokens(ctx context.Context, refreshToken, accessToken stri
 		return nil, fmt.Errorf("error while trying to find user: %w", err)
 	}
 
-	refreshTokenEntities, err := s.tokenRepo.GetRefreshTokenEntitiesByUserID(ctx, claims.UserID)
+	// locked users keep the sessions they have, disabling revokes them but a refresh may race with it
+	if user.Status == entity.UserStatusDisabled {
+		return nil, ErrUserDisabled
+	}
+
+	if s.requireVerifiedEmail && user.EmailVerifiedAt == nil {
+		return nil, ErrEmailNotVerified
+	}
+
+	// the access token names the organization of the session, only its sessions are looked at
+	refreshTokenEntities, err := s.refreshTokensOf(ctx, claims.UserID, claims.OrgID)
 	if err != nil {
-		return nil, fmt.Errorf("error while getting refresh token by userID: %w", err)
+		return nil, err
 	}
 	if len(refreshTokenEntities) < 1 {
 		return nil, ErrNoSessionsFoundWithThisUserID
@@ -128,43 +395,527 @@ func (s *Auth) RefreshTokens(ctx context.Context, refreshToken, accessToken stri
 	}
 
 	if token.Used {
+		s.securityLog.Warnf("reuse of refresh token id=%s of user_id=%s from ip=%s", token.ID, user.ID, clientIP)
+		s.audit.Record(ctx, entity.AuditEvent{
+			Type:      entity.AuditRefreshTokenReuse,
+			SubjectID: user.ID,
+			IP:        clientIP,
+			Metadata:  map[string]string{"refresh_token_id": token.ID},
+		})
+
 		return nil, ErrRefreshTokenAlreadyUsed
 	}
 
+	if token.RevokedAt != nil {
+		return nil, ErrRefreshTokenRevoked
+	}
+
 	if token.ExpiresAt.Before(time.Now()) {
 		return nil, ErrRefreshTokenExpired
 	}
 
-	if claims.ClientIP != token.ClientIP {
-		err = s.emailSender.SendWarningEmail(user.Email, "Suspicious login", fmt.Sprintf("Warning! Someone logged in from this IP: %s", claims.ClientIP))
+	// a session of an organization lasts only as long as the membership
+	orgID := sessionOrgID(token)
+	membership, err := s.membership(ctx, user.ID, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := s.tokenPolicy(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkRefresh(policy, token, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.ipRules.Check(ctx, user.ID, orgID, clientIP, RiskOperationRefresh)
+	if err != nil {
+		return nil, err
+	}
+
+	match, err := s.devices.Recognize(ctx, user.ID, device, token.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	location := s.risk.Locate(clientIP)
+	decision, err := s.assessRisk(ctx, user, RiskInput{
+		Operation:      RiskOperationRefresh,
+		OrgID:          orgID,
+		IP:             clientIP,
+		Location:       location,
+		Previous:       token,
+		FailedAttempts: s.failedAttempts(ctx, IPAttemptKey(clientIP)),
+		NewDevice:      match.New,
+		Now:            time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ipChanged := clientIP != token.ClientIP
+
+	// the rotation, its notification and its webhook event are stored together,
+	// so neither the user nor subscribers miss an IP change
+	var tokens *entity.Tokens
+	err = s.withinSession(ctx, orgID, func(ctx context.Context) error {
+		err := s.tokenRepo.MarkRefreshTokenUsed(ctx, token.ID)
 		if err != nil {
-			s.securityLog.Errorf("error while sending warning emailSender to user_id=%s", user.ID)
+			if errors.Is(err, repoerrors.ErrNotFound) {
+				return ErrRefreshTokenNotFound
+			}
+
+			return fmt.Errorf("error while marking refresh token as used: %w", err)
+		}
+
+		tokens, err = s.issueTokens(ctx, claims.UserID, clientIP, location, match, membership, policy, token.SessionStartedAt)
+		if err != nil {
+			return err
 		}
+
+		err = s.notifySignIn(ctx, user, decision, clientIP, token.ClientIP, location, match)
+		if err != nil {
+			return err
+		}
+
+		if !ipChanged {
+			return nil
+		}
+
+		return s.webhooks.Enqueue(ctx, entity.WebhookSessionIPChanged, map[string]string{
+			"user_id":          user.ID,
+			"refresh_token_id": token.ID,
+			"ip":               clientIP,
+			"previous_ip":      token.ClientIP,
+			"country":          location.Country,
+			"previous_country": token.Location.Country,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if ipChanged {
+		s.audit.Record(ctx, entity.AuditEvent{
+			Type:      entity.AuditIPChanged,
+			SubjectID: user.ID,
+			IP:        clientIP,
+			Metadata: map[string]string{
+				"previous_ip":       token.ClientIP,
+				"location":          location.String(),
+				"previous_location": token.Location.String(),
+				"location_change":   decision.LocationChange.String(),
+			},
+		})
 	}
 
-	err = s.tokenRepo.MarkRefreshTokenUsed(ctx, token.ID)
+	if match.New {
+		s.recordNewDevice(ctx, user.ID, clientIP, match)
+	}
+
+	s.audit.Record(ctx, entity.AuditEvent{
+		Type:      entity.AuditTokenRefreshed,
+		SubjectID: user.ID,
+		IP:        clientIP,
+		Metadata:  map[string]string{"refresh_token_id": token.ID},
+	})
+
+	return tokens, nil
+}
+
+// Logout revokes the session of the given refresh token. Only the owner of the session can end it, signed
+// in to the same organization, orgID, or to none for personal sessions.
+func (s *Auth) Logout(ctx context.Context, userID, orgID, refreshToken string) error {
+	refreshTokenEntities, err := s.refreshTokensOf(ctx, userID, orgID)
 	if err != nil {
-		if errors.Is(err, repoerrors.ErrNotFound) {
-			return nil, ErrRefreshTokenNotFound
+		return err
+	}
+
+	token, err := s.findMatchingRefreshTokens(refreshToken, refreshTokenEntities)
+	if err != nil {
+		if !errors.Is(err, ErrRefreshTokenNotFound) {
+			return fmt.Errorf("error while comaring token_hash and input_token: %w", err)
+		}
+
+		return err
+	}
+
+	if token.RevokedAt != nil {
+		return nil
+	}
+
+	err = s.withinSession(ctx, orgID, func(ctx context.Context) error {
+		err := s.tokenRepo.RevokeRefreshToken(ctx, token.ID)
+		if err != nil {
+			if errors.Is(err, repoerrors.ErrNotFound) {
+				return ErrRefreshTokenNotFound
+			}
+
+			return fmt.Errorf("error while revoking refresh token: %w", err)
+		}
+
+		return s.webhooks.Enqueue(ctx, entity.WebhookSessionRevoked, map[string]string{
+			"user_id":          userID,
+			"refresh_token_id": token.ID,
+			"reason":           "logout",
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, entity.AuditEvent{
+		Type:      entity.AuditLogout,
+		SubjectID: userID,
+		Metadata:  map[string]string{"refresh_token_id": token.ID},
+	})
+
+	return nil
+}
+
+// startSession issues the tokens of a new session unless the user is not a member of the organization
+// of input.OrgID, its token policy does not allow method, or the ip rules or the risk engine hold them back.
+func (s *Auth) startSession(ctx context.Context, user *entity.User, input RiskInput, device DeviceInput, method string) (*entity.Tokens, error) {
+	membership, err := s.membership(ctx, user.ID, input.OrgID)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := s.tokenPolicy(ctx, input.OrgID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !policy.AllowsGrant(method) {
+		return nil, ErrGrantNotAllowed
+	}
+
+	err = s.ipRules.Check(ctx, user.ID, input.OrgID, input.IP, input.Operation)
+	if err != nil {
+		return nil, err
+	}
+
+	match, err := s.devices.Recognize(ctx, user.ID, device, nil)
+	if err != nil {
+		return nil, err
+	}
+	input.NewDevice = match.New
+
+	decision, err := s.assessRisk(ctx, user, input)
+	if err != nil {
+		return nil, err
+	}
+
+	if policy.RequireMFA {
+		return nil, s.requireMFA(ctx, user, input)
+	}
+
+	var tokens *entity.Tokens
+	err = s.withinSession(ctx, input.OrgID, func(ctx context.Context) error {
+		tokens, err = s.issueTokens(ctx, user.ID, input.IP, input.Location, match, membership, policy, time.Now())
+		if err != nil {
+			return err
 		}
 
-		return nil, fmt.Errorf("error while marking refresh token as used: %w", err)
+		return s.notifySignIn(ctx, user, decision, input.IP, "", input.Location, match)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if match.New {
+		s.recordNewDevice(ctx, user.ID, input.IP, match)
+	}
+
+	metadata := map[string]string{"method": method}
+	if input.OrgID != "" {
+		metadata["org_id"] = input.OrgID
+	}
+	s.audit.Record(ctx, entity.AuditEvent{
+		Type:      entity.AuditTokenIssued,
+		SubjectID: user.ID,
+		IP:        input.IP,
+		Metadata:  metadata,
+	})
+
+	return tokens, nil
+}
+
+// assessRisk records the decision of the risk engine and returns ErrRiskDenied or a *StepUpRequiredError
+// when the tokens must not be issued now.
+func (s *Auth) assessRisk(ctx context.Context, user *entity.User, input RiskInput) (RiskDecision, error) {
+	decision := s.risk.Evaluate(input)
+
+	metadata := map[string]string{
+		"operation": input.Operation,
+		"score":     strconv.Itoa(decision.Score),
+		"action":    decision.Action,
+		"reasons":   strings.Join(decision.Reasons, ","),
+	}
+	if input.Previous != nil {
+		metadata["refresh_token_id"] = input.Previous.ID
+	}
+	s.audit.Record(ctx, entity.AuditEvent{
+		Type:      entity.AuditRiskAssessed,
+		SubjectID: user.ID,
+		IP:        input.IP,
+		Metadata:  metadata,
+	})
+
+	switch decision.Action {
+	case RiskDeny:
+		s.securityLog.Warnf("%s of user_id=%s from ip=%s denied with risk score %d (%s)",
+			input.Operation, user.ID, input.IP, decision.Score, metadata["reasons"])
+		return decision, ErrRiskDenied
+	case RiskStepUp:
+		var refreshTokenID *string
+		if input.Previous != nil {
+			refreshTokenID = &input.Previous.ID
+		}
+
+		stepUpErr, err := s.stepUp.Challenge(ctx, user, input.Operation, input.IP, input.Location, refreshTokenID, input.OrgID)
+		if err != nil {
+			return decision, err
+		}
+		return decision, stepUpErr
+	}
+
+	return decision, nil
+}
+
+// requireMFA holds back a new session of an organization that requires a second factor until the user
+// enters the code emailed to them, the way the risk engine holds back risky sign-ins.
+func (s *Auth) requireMFA(ctx context.Context, user *entity.User, input RiskInput) error {
+	stepUpErr, err := s.stepUp.Challenge(ctx, user, input.Operation, input.IP, input.Location, nil, input.OrgID)
+	if err != nil {
+		if errors.Is(err, ErrRiskDenied) {
+			return ErrMFAUnavailable
+		}
+
+		return err
+	}
+
+	return stepUpErr
+}
+
+// notifySignIn tells the user about a sign-in the risk engine let through: a suspicious one gets the
+// suspicious login notification, otherwise a new device gets the new device notification.
+// previousIP is empty for new sessions.
+func (s *Auth) notifySignIn(ctx context.Context, user *entity.User, decision RiskDecision, clientIP, previousIP string, location entity.GeoLocation, device *DeviceMatch) error {
+	if decision.Action == RiskNotify {
+		return s.notifyRisk(ctx, user, clientIP, previousIP, location)
+	}
+
+	if !device.New {
+		return nil
+	}
+
+	err := s.notifications.Notify(ctx, user, Notification{
+		Template: sender.TemplateNewDevice,
+		Data: sender.NewDeviceData{
+			IP:        clientIP,
+			UserAgent: device.UserAgent,
+			Device:    device.Description(),
+			Location:  location.String(),
+			Time:      time.Now(),
+		},
+		Key: hashDeviceKey(device.Key),
+	})
+	if err != nil {
+		return fmt.Errorf("error while queueing new device notification: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Auth) recordNewDevice(ctx context.Context, userID, clientIP string, device *DeviceMatch) {
+	s.securityLog.Infof("sign-in of user_id=%s from new device %q from ip=%s", userID, device.Description(), clientIP)
+	s.audit.Record(ctx, entity.AuditEvent{
+		Type:      entity.AuditNewDevice,
+		SubjectID: userID,
+		IP:        clientIP,
+		Metadata:  map[string]string{"device": device.Description()},
+	})
+}
+
+// notifyRisk sends the suspicious login notification, previousIP is empty for new sessions.
+func (s *Auth) notifyRisk(ctx context.Context, user *entity.User, clientIP, previousIP string, location entity.GeoLocation) error {
+	reportLink, err := newLoginReportLink(s.signKey, s.loginReports, user.ID, clientIP)
+	if err != nil {
+		return fmt.Errorf("error while generating login report link: %w", err)
+	}
+
+	err = s.notifications.Notify(ctx, user, Notification{
+		Template: sender.TemplateSuspiciousLogin,
+		Data: sender.SuspiciousLoginData{
+			IP:         clientIP,
+			PreviousIP: previousIP,
+			Location:   location.String(),
+			Time:       time.Now(),
+			ReportLink: reportLink,
+		},
+		Key: clientIP,
+	})
+	if err != nil {
+		return fmt.Errorf("error while queueing suspicious login notification: %w", err)
 	}
 
-	accessToken, err = s.generateAccessToken(claims.ClientIP, claims.UserID)
+	return nil
+}
+
+// failedAttempts feeds the risk engine, an unavailable counter store must not block sign-ins.
+func (s *Auth) failedAttempts(ctx context.Context, keys ...string) int {
+	failures, err := s.bruteForce.Failures(ctx, keys...)
+	if err != nil {
+		s.securityLog.Errorf("error while counting failed attempts: %v", err)
+	}
+
+	return failures
+}
+
+func (s *Auth) loginFailed(ctx context.Context, attemptKeys []string, userID, email, clientIP string) error {
+	err := s.bruteForce.RegisterFailure(ctx, attemptKeys...)
+	if err != nil {
+		s.securityLog.Errorf("error while registering failed login attempt: %v", err)
+	}
+
+	s.audit.Record(ctx, entity.AuditEvent{
+		Type:      entity.AuditLoginFailed,
+		SubjectID: userID,
+		IP:        clientIP,
+		Metadata:  map[string]string{"email": email},
+	})
+
+	return ErrInvalidCredentials
+}
+
+// rehashPassword upgrades a hash made with an older algorithm or weaker parameters.
+// The login itself already succeeded, so failures are only logged.
+func (s *Auth) rehashPassword(ctx context.Context, userID, password string) {
+	passwordHash, err := s.passwordHasher.Hash(password)
+	if err != nil {
+		s.securityLog.Errorf("error while rehashing password of user_id=%s: %v", userID, err)
+		return
+	}
+
+	err = s.userRepo.UpdateUserPassword(ctx, userID, passwordHash)
+	if err != nil {
+		s.securityLog.Errorf("error while saving rehashed password of user_id=%s: %v", userID, err)
+	}
+}
+
+// membership returns the membership of the user in the organization a session is issued for, nil for
+// personal sessions.
+func (s *Auth) membership(ctx context.Context, userID, orgID string) (*entity.Membership, error) {
+	if orgID == "" {
+		return nil, nil
+	}
+
+	return s.orgs.Membership(ctx, orgID, userID)
+}
+
+// withinSession runs fn in a transaction, scoped to the organization of the session when it has one.
+func (s *Auth) withinSession(ctx context.Context, orgID string, fn func(ctx context.Context) error) error {
+	if orgID == "" {
+		return s.transactor.WithinTransaction(ctx, fn)
+	}
+
+	return s.transactor.WithinTenant(ctx, orgID, fn)
+}
+
+// tokenPolicy returns the token policy sessions of organization orgID are issued with, the global settings
+// filling in what it leaves open. Personal sessions get the global settings.
+func (s *Auth) tokenPolicy(ctx context.Context, orgID string) (*entity.TokenPolicy, error) {
+	policy := entity.TokenPolicy{}
+	if orgID != "" {
+		stored, err := s.policies.GetTokenPolicy(ctx, orgID)
+		if err != nil {
+			return nil, err
+		}
+		policy = *stored
+	}
+
+	policy.AccessTokenTTL = cmp.Or(policy.AccessTokenTTL, s.tokenTTL)
+	policy.RefreshTokenTTL = cmp.Or(policy.RefreshTokenTTL, s.refreshTokenTTL)
+
+	return &policy, nil
+}
+
+// checkRefresh returns an error when policy no longer lets the session of token be extended.
+func checkRefresh(policy *entity.TokenPolicy, token *entity.RefreshToken, now time.Time) error {
+	if !policy.AllowsGrant(entity.GrantRefreshToken) {
+		return ErrGrantNotAllowed
+	}
+
+	if policy.MaxSessionLifetime > 0 && now.After(token.SessionStartedAt.Add(policy.MaxSessionLifetime)) {
+		return ErrSessionLifetimeExceeded
+	}
+
+	return nil
+}
+
+// issueTokens remembers the device and issues a session bound to it, and to the organization of
+// membership unless it is nil. Neither token outlives the maximum lifetime policy gives the session
+// started at sessionStartedAt.
+func (s *Auth) issueTokens(
+	ctx context.Context,
+	userID, clientIP string,
+	location entity.GeoLocation,
+	device *DeviceMatch,
+	membership *entity.Membership,
+	policy *entity.TokenPolicy,
+	sessionStartedAt time.Time) (*entity.Tokens, error) {
+	deviceID, err := s.devices.Remember(ctx, userID, device, clientIP)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, permissions, err := s.roles.Grants(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	accessTokenExpiresAt := now.Add(policy.AccessTokenTTL)
+	refreshTokenExpiresAt := now.Add(policy.RefreshTokenTTL)
+	if policy.MaxSessionLifetime > 0 {
+		sessionEndsAt := sessionStartedAt.Add(policy.MaxSessionLifetime)
+		if accessTokenExpiresAt.After(sessionEndsAt) {
+			accessTokenExpiresAt = sessionEndsAt
+		}
+		if refreshTokenExpiresAt.After(sessionEndsAt) {
+			refreshTokenExpiresAt = sessionEndsAt
+		}
+	}
+
+	accessToken, err := s.generateAccessToken(clientIP, userID, roles, permissions, membership, policy.SigningKey, accessTokenExpiresAt)
 	if err != nil {
 		return nil, fmt.Errorf("error while generating access token: %w", err)
 	}
 
 	refreshToken, refreshTokenHash, err := s.generateRefreshToken()
+	if err != nil {
+		return nil, fmt.Errorf("error while generating refresh token: %w", err)
+	}
 
 	refreshTokenEntiry := entity.RefreshToken{
-		UserID:      claims.UserID,
-		RefreshHash: refreshTokenHash,
-		IssuedAt:    time.Now(),
-		ExpiresAt:   time.Now().Add(s.refreshTokenTTL),
-		ClientIP:    claims.ClientIP,
-		Used:        false,
+		UserID:           userID,
+		RefreshHash:      refreshTokenHash,
+		IssuedAt:         now,
+		SessionStartedAt: sessionStartedAt,
+		ExpiresAt:        refreshTokenExpiresAt,
+		ClientIP:         clientIP,
+		Location:         location,
+		Used:             false,
+	}
+	if deviceID != "" {
+		refreshTokenEntiry.DeviceID = &deviceID
+	}
+	if membership != nil {
+		refreshTokenEntiry.OrgID = &membership.OrgID
 	}
 
 	err = s.tokenRepo.CreateRefreshToken(ctx, refreshTokenEntiry)
@@ -179,26 +930,58 @@ func (s *Auth) RefreshTokens(ctx context.Context, refreshToken, accessToken stri
 	tokens := entity.Tokens{
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
+		DeviceKey:    device.Key,
 	}
 
 	return &tokens, nil
 }
 
-func (s *Auth) generateAccessToken(clientIP string, userID string) (string, error) {
+// Authenticate returns the claims of a valid access token. Tokens of an organization are only valid while
+// their user is a member of it.
+func (s *Auth) Authenticate(ctx context.Context, accessToken string) (*TokenClaims, error) {
+	claims, err := s.parseAccessToken(ctx, accessToken)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidAccessToken, err)
+	}
+
+	return claims, nil
+}
+
+func (s *Auth) generateAccessToken(clientIP string, userID string, roles, permissions []string, membership *entity.Membership, signingKey string, expiresAt time.Time) (string, error) {
 	claims := TokenClaims{
-		jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(s.tokenTTL).Unix(),
+		StandardClaims: jwt.StandardClaims{
+			Audience:  accessTokenAudience,
+			ExpiresAt: expiresAt.Unix(),
 			IssuedAt:  time.Now().Unix(),
 		},
-		clientIP,
-		userID,
+		ClientIP: clientIP,
+		UserID:   userID,
+		Roles:    roles,
+		Scope:    strings.Join(permissions, " "),
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
-	accessToken, err := token.SignedString([]byte(s.signKey))
+	if membership != nil {
+		claims.OrgID = membership.OrgID
+		claims.OrgRole = membership.Role
+	}
+	return s.signAccessToken(claims, signingKey)
+}
+
+// signAccessToken signs claims with signingKey, the Ed25519 key of an organization, which is named in the
+// kid header. Without one the global key signs them.
+func (s *Auth) signAccessToken(claims TokenClaims, signingKey string) (string, error) {
+	if signingKey == "" {
+		return jwt.NewWithClaims(jwt.SigningMethodHS512, claims).SignedString([]byte(s.signKey))
+	}
+
+	privateKey, err := parseSigningKey(signingKey)
 	if err != nil {
 		return "", err
 	}
-	return accessToken, nil
+
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = signingKeyID(privateKey.Public().(ed25519.PublicKey))
+
+	return token.SignedString(privateKey)
 }
 
 func (s *Auth) generateRefreshToken() (string, string, error) {
@@ -232,27 +1015,157 @@ func (s *Auth) findMatchingRefreshTokens(inputToken string, refreshTokenEntities
 	return nil, ErrRefreshTokenNotFound
 }
 
-func (s *Auth) parseAccessToken(accessToken string) (*TokenClaims, error) {
+// sessionOrgID returns the organization of a session, empty for personal sessions.
+func sessionOrgID(token *entity.RefreshToken) string {
+	if token.OrgID == nil {
+		return ""
+	}
+
+	return *token.OrgID
+}
+
+// refreshTokensOf returns the sessions of the user in organization orgID, the personal ones when it is empty.
+func (s *Auth) refreshTokensOf(ctx context.Context, userID, orgID string) ([]entity.RefreshToken, error) {
+	var refreshTokenEntities []entity.RefreshToken
+	err := s.withinSession(ctx, orgID, func(ctx context.Context) error {
+		var err error
+		refreshTokenEntities, err = s.tokenRepo.GetRefreshTokenEntitiesByUserID(ctx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error while getting refresh token by userID: %w", err)
+	}
+
+	return refreshTokenEntities, nil
+}
+
+// getRefreshToken returns a session of the user in organization orgID that can still be refreshed.
+func (s *Auth) getRefreshToken(ctx context.Context, userID, orgID, tokenID string) (*entity.RefreshToken, error) {
+	refreshTokenEntities, err := s.refreshTokensOf(ctx, userID, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, token := range refreshTokenEntities {
+		if token.ID != tokenID {
+			continue
+		}
+
+		switch {
+		case token.Used:
+			return nil, ErrRefreshTokenAlreadyUsed
+		case token.RevokedAt != nil:
+			return nil, ErrRefreshTokenRevoked
+		case token.ExpiresAt.Before(time.Now()):
+			return nil, ErrRefreshTokenExpired
+		}
+
+		return &token, nil
+	}
+
+	return nil, ErrRefreshTokenNotFound
+}
+
+// parseAccessToken verifies an access token with the signing key of the organization it was issued for
+// that its kid header names, the global key for personal sessions. Tokens of an organization whose user
+// is no longer a member of it are refused.
+func (s *Auth) parseAccessToken(ctx context.Context, accessToken string) (*TokenClaims, error) {
+	unverified := &TokenClaims{}
+	_, _, err := new(jwt.Parser).ParseUnverified(accessToken, unverified)
+	if err != nil {
+		return nil, fmt.Errorf("token validation error: %w", err)
+	}
+
+	keys, err := s.verificationKeys(ctx, unverified.OrgID)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := verifyAccessToken(accessToken, keys)
+	if err != nil && !errors.Is(err, ErrAccessTokenExpired) {
+		return nil, err
+	}
+
+	if claims.OrgID != "" {
+		_, memberErr := s.orgs.Membership(ctx, claims.OrgID, claims.UserID)
+		if memberErr != nil {
+			return nil, memberErr
+		}
+	}
+
+	return claims, err
+}
+
+// verificationKeys returns the keys access tokens of organization orgID may be verified with by their kid:
+// the current one, and the one it replaced until that one expires. The global key has no kid.
+func (s *Auth) verificationKeys(ctx context.Context, orgID string) (map[string]interface{}, error) {
+	policy, err := s.tokenPolicy(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	signingKeys := []string{policy.SigningKey}
+	if policy.PreviousKeyExpiresAt != nil && time.Now().Before(*policy.PreviousKeyExpiresAt) {
+		signingKeys = append(signingKeys, policy.PreviousSigningKey)
+	}
+
+	keys := make(map[string]interface{}, len(signingKeys))
+	for _, signingKey := range signingKeys {
+		if signingKey == "" {
+			keys[""] = []byte(s.signKey)
+			continue
+		}
+
+		publicKey, err := publicSigningKey(signingKey)
+		if err != nil {
+			return nil, err
+		}
+		keys[publicKey.ID] = publicKey.Key
+	}
+
+	return keys, nil
+}
+
+func verifyAccessToken(accessToken string, keys map[string]interface{}) (*TokenClaims, error) {
 	claims := &TokenClaims{}
 
 	_, err := jwt.ParseWithClaims(accessToken, claims, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		keyID, _ := token.Header["kid"].(string)
+
+		// the signing method has to match the key, so a public key never verifies an HMAC signature
+		switch key := keys[keyID].(type) {
+		case []byte:
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+				return key, nil
+			}
+		case ed25519.PublicKey:
+			if _, ok := token.Method.(*jwt.SigningMethodEd25519); ok {
+				return key, nil
+			}
+		default:
+			return nil, fmt.Errorf("unknown signing key %q", keyID)
 		}
 
-		return []byte(s.signKey), nil
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 	})
 
 	if err != nil {
 		if ve, ok := err.(*jwt.ValidationError); ok {
-			// check for token expiration
-			if ve.Errors&jwt.ValidationErrorExpired != 0 {
-				return claims, ErrAccessTokenExpired
+			// check for token expiration, an expired token still has to carry a valid signature
+			if ve.Errors == jwt.ValidationErrorExpired {
+				err = ErrAccessTokenExpired
+			} else {
+				return nil, fmt.Errorf("token validation error: %w", err)
 			}
-			return nil, fmt.Errorf("token validation error: %w", err)
+		} else {
+			return nil, fmt.Errorf("unexpected token parsing error: %w", err)
 		}
-		return nil, fmt.Errorf("unexpected token parsing error: %w", err)
 	}
 
-	return claims, nil
+	// other tokens are signed with the same key, so the audience is what tells an access token apart
+	if !claims.VerifyAudience(accessTokenAudience, true) {
+		return nil, errors.New("unexpected token audience")
+	}
+
+	return claims, err
 }
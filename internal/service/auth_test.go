@@ -1,6 +1,7 @@
 package service
 
 import (
+	"cmp"
 	"context"
 	"github.com/golang-jwt/jwt"
 	"github.com/sirupsen/logrus"
@@ -8,6 +9,7 @@ import (
 	"github.com/stretchr/testify/mock"
 	"golang.org/x/crypto/bcrypt"
 	"medods-tz/internal/entity"
+	"medods-tz/pkg/hasher"
 	"testing"
 	"time"
 )
@@ -16,23 +18,22 @@ func TestAuth_CreateTokens(t *testing.T) {
 	ctx := context.Background()
 	mockUserRepo := new(mockUserRepo)
 	mockTokenRepo := new(mockTokenRepo)
-	mockSender := new(mockEmail)
+	mockNotifications := new(mockNotifications)
 
 	log := logrus.New()
-	auth := NewAuth(
-		mockUserRepo,
-		mockTokenRepo,
-		time.Minute*15,
-		time.Hour*24,
-		"test-sign-key",
-		log,
-		mockSender,
-	)
+	auth := newTestAuth(AuthDependencies{
+		UserRepo:        mockUserRepo,
+		TokenRepo:       mockTokenRepo,
+		TokenTTL:        time.Minute * 15,
+		RefreshTokenTTL: time.Hour * 24,
+		SecurityLog:     log,
+		Notifications:   mockNotifications,
+	})
 
 	mockUserRepo.On("GetUserByID", ctx, "user-id").Return(&entity.User{ID: "user-id", Email: "test@example.com"}, nil)
 	mockTokenRepo.On("CreateRefreshToken", ctx, mock.Anything).Return(nil)
 
-	tokens, err := auth.CreateTokens(ctx, "user-id", "127.0.0.1")
+	tokens, err := auth.CreateTokens(ctx, "user-id", "", "127.0.0.1", DeviceInput{})
 
 	assert.NoError(t, err)
 	assert.NotNil(t, tokens)
@@ -46,18 +47,17 @@ func TestAuth_RefreshTokens(t *testing.T) {
 	ctx := context.Background()
 	mockUserRepo := new(mockUserRepo)
 	mockTokenRepo := new(mockTokenRepo)
-	mockEmail := new(mockEmail)
+	mockNotifications := new(mockNotifications)
 
 	log := logrus.New()
-	auth := NewAuth(
-		mockUserRepo,
-		mockTokenRepo,
-		time.Minute*15,
-		time.Hour*24,
-		"test-sign-key",
-		log,
-		mockEmail,
-	)
+	auth := newTestAuth(AuthDependencies{
+		UserRepo:        mockUserRepo,
+		TokenRepo:       mockTokenRepo,
+		TokenTTL:        time.Minute * 15,
+		RefreshTokenTTL: time.Hour * 24,
+		SecurityLog:     log,
+		Notifications:   mockNotifications,
+	})
 
 	claims := TokenClaims{
 		ClientIP: "127.0.0.1",
@@ -68,7 +68,7 @@ func TestAuth_RefreshTokens(t *testing.T) {
 		},
 	}
 
-	accessToken, _ := auth.generateAccessToken(claims.ClientIP, claims.UserID)
+	accessToken, _ := auth.generateAccessToken(claims.ClientIP, claims.UserID, nil, nil, nil, "", time.Unix(claims.ExpiresAt, 0))
 
 	mockUserRepo.On("GetUserByID", ctx, "user-id").Return(&entity.User{ID: "user-id", Email: "test@example.com"}, nil)
 	mockTokenRepo.On("GetRefreshTokenEntitiesByUserID", ctx, "user-id").Return([]entity.RefreshToken{
@@ -84,7 +84,7 @@ func TestAuth_RefreshTokens(t *testing.T) {
 	mockTokenRepo.On("MarkRefreshTokenUsed", ctx, "token-id").Return(nil)
 	mockTokenRepo.On("CreateRefreshToken", ctx, mock.Anything).Return(nil)
 
-	tokens, err := auth.RefreshTokens(ctx, "valid-refresh-token", accessToken)
+	tokens, err := auth.RefreshTokens(ctx, "valid-refresh-token", accessToken, "127.0.0.1", DeviceInput{})
 
 	assert.NoError(t, err)
 	assert.NotNil(t, tokens)
@@ -96,6 +96,126 @@ func TestAuth_RefreshTokens(t *testing.T) {
 	mockTokenRepo.AssertCalled(t, "CreateRefreshToken", ctx, mock.Anything)
 }
 
+func TestAuth_Login_RehashesWeakerPassword(t *testing.T) {
+	ctx := context.Background()
+	mockUserRepo := new(mockUserRepo)
+	mockTokenRepo := new(mockTokenRepo)
+
+	passwordHasher := newTestPasswordHasher()
+	auth := newTestAuth(AuthDependencies{UserRepo: mockUserRepo, TokenRepo: mockTokenRepo, PasswordHasher: passwordHasher})
+
+	// imported with a lower bcrypt cost than the test hasher uses
+	importedHash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+
+	mockUserRepo.On("GetUserByEmail", ctx, "test@example.com").Return(&entity.User{ID: "user-id", Email: "test@example.com", PasswordHash: string(importedHash)}, nil)
+	mockUserRepo.On("UpdateUserPassword", ctx, "user-id", mock.MatchedBy(func(passwordHash string) bool {
+		return !passwordHasher.NeedsRehash(passwordHash)
+	})).Return(nil)
+	mockTokenRepo.On("CreateRefreshToken", ctx, mock.Anything).Return(nil)
+
+	tokens, err := auth.Login(ctx, "test@example.com", "password123", "", "127.0.0.1", DeviceInput{})
+
+	assert.NoError(t, err)
+	assert.NotNil(t, tokens)
+	mockUserRepo.AssertCalled(t, "UpdateUserPassword", ctx, "user-id", mock.Anything)
+}
+
+func TestAuth_Login_InvalidPassword(t *testing.T) {
+	ctx := context.Background()
+	mockUserRepo := new(mockUserRepo)
+	mockTokenRepo := new(mockTokenRepo)
+
+	passwordHasher := newTestPasswordHasher()
+	auth := newTestAuth(AuthDependencies{UserRepo: mockUserRepo, TokenRepo: mockTokenRepo, PasswordHasher: passwordHasher})
+
+	passwordHash, _ := passwordHasher.Hash("password123")
+	mockUserRepo.On("GetUserByEmail", ctx, "test@example.com").Return(&entity.User{ID: "user-id", Email: "test@example.com", PasswordHash: passwordHash}, nil)
+
+	tokens, err := auth.Login(ctx, "test@example.com", "wrong-password", "", "127.0.0.1", DeviceInput{})
+
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	assert.Nil(t, tokens)
+	mockTokenRepo.AssertNotCalled(t, "CreateRefreshToken", mock.Anything, mock.Anything)
+}
+
+// newTestAuth returns an Auth built from dependencies, with test doubles for the ones left unset.
+func newTestAuth(dependencies AuthDependencies) *Auth {
+	defaults := AuthDependencies{
+		UserRepo:        new(mockUserRepo),
+		TokenRepo:       new(mockTokenRepo),
+		Transactor:      mockTransactor{},
+		TokenTTL:        time.Minute,
+		RefreshTokenTTL: time.Hour,
+		SignKey:         "test-sign-key",
+		SecurityLog:     logrus.New(),
+		Audit:           newTestAudit(),
+		Webhooks:        newTestWebhooks(),
+		Notifications:   new(mockNotifications),
+		PasswordHasher:  newTestPasswordHasher(),
+		BruteForce:      newTestBruteForce(),
+		LoginReports:    testLoginReportConfig,
+		Risk:            newTestRiskEngine(),
+		StepUp:          newTestStepUp(),
+		Devices:         newTestDevices(),
+		IPRules:         newTestIPRules(),
+		Roles:           newTestRoles(),
+		Orgs:            newTestOrganizations(),
+		Policies:        newTestTokenPolicies(),
+	}
+
+	dependencies.UserRepo = cmp.Or(dependencies.UserRepo, defaults.UserRepo)
+	dependencies.TokenRepo = cmp.Or(dependencies.TokenRepo, defaults.TokenRepo)
+	dependencies.Transactor = cmp.Or(dependencies.Transactor, defaults.Transactor)
+	dependencies.TokenTTL = cmp.Or(dependencies.TokenTTL, defaults.TokenTTL)
+	dependencies.RefreshTokenTTL = cmp.Or(dependencies.RefreshTokenTTL, defaults.RefreshTokenTTL)
+	dependencies.SignKey = cmp.Or(dependencies.SignKey, defaults.SignKey)
+	dependencies.SecurityLog = cmp.Or(dependencies.SecurityLog, defaults.SecurityLog)
+	dependencies.Audit = cmp.Or(dependencies.Audit, defaults.Audit)
+	dependencies.Webhooks = cmp.Or(dependencies.Webhooks, defaults.Webhooks)
+	dependencies.Notifications = cmp.Or(dependencies.Notifications, defaults.Notifications)
+	dependencies.PasswordHasher = cmp.Or(dependencies.PasswordHasher, defaults.PasswordHasher)
+	dependencies.BruteForce = cmp.Or(dependencies.BruteForce, defaults.BruteForce)
+	dependencies.LoginReports = cmp.Or(dependencies.LoginReports, defaults.LoginReports)
+	dependencies.Risk = cmp.Or(dependencies.Risk, defaults.Risk)
+	dependencies.StepUp = cmp.Or(dependencies.StepUp, defaults.StepUp)
+	dependencies.Devices = cmp.Or(dependencies.Devices, defaults.Devices)
+	dependencies.IPRules = cmp.Or(dependencies.IPRules, defaults.IPRules)
+	dependencies.Roles = cmp.Or(dependencies.Roles, defaults.Roles)
+	dependencies.Orgs = cmp.Or(dependencies.Orgs, defaults.Orgs)
+	dependencies.Policies = cmp.Or(dependencies.Policies, defaults.Policies)
+
+	return NewAuth(dependencies)
+}
+
+func newTestPasswordHasher() *hasher.Hasher {
+	h, _ := hasher.New("argon2id",
+		hasher.NewArgon2id(hasher.Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}),
+		hasher.NewBcrypt(bcrypt.MinCost+1))
+	return h
+}
+
+func TestAuth_Logout(t *testing.T) {
+	ctx := context.Background()
+	mockTokenRepo := new(mockTokenRepo)
+
+	auth := newTestAuth(AuthDependencies{TokenRepo: mockTokenRepo})
+
+	mockTokenRepo.On("GetRefreshTokenEntitiesByUserID", ctx, "user-id").Return([]entity.RefreshToken{
+		{ID: "other-token-id", UserID: "user-id", RefreshHash: string(hashRefreshToken("other-refresh-token"))},
+		{ID: "token-id", UserID: "user-id", RefreshHash: string(hashRefreshToken("valid-refresh-token"))},
+	}, nil)
+	mockTokenRepo.On("RevokeRefreshToken", ctx, "token-id").Return(nil)
+
+	err := auth.Logout(ctx, "user-id", "", "valid-refresh-token")
+
+	assert.NoError(t, err)
+	mockTokenRepo.AssertNotCalled(t, "RevokeRefreshToken", ctx, "other-token-id")
+
+	err = auth.Logout(ctx, "user-id", "", "unknown-refresh-token")
+
+	assert.ErrorIs(t, err, ErrRefreshTokenNotFound)
+}
+
 func hashRefreshToken(token string) []byte {
 	hash, _ := bcrypt.GenerateFromPassword([]byte(token), bcrypt.DefaultCost)
 	return hash
@@ -0,0 +1,294 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt"
+	"github.com/sirupsen/logrus"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository"
+	"medods-tz/internal/repository/repoerrors"
+	"medods-tz/internal/sender"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	accountUnlockAudience = "account_unlock"
+
+	ipAttemptKeyPrefix      = "ip:"
+	accountAttemptKeyPrefix = "account:"
+)
+
+func IPAttemptKey(ip string) string {
+	return ipAttemptKeyPrefix + ip
+}
+
+func AccountAttemptKey(email string) string {
+	return accountAttemptKeyPrefix + strings.ToLower(email)
+}
+
+type BruteForceConfig struct {
+	// Window is how long a failure is remembered when no further failures follow.
+	Window time.Duration
+	// FreeAttempts failures are allowed before exponential backoff starts.
+	FreeAttempts int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	// LockoutThreshold failures lock an account for LockoutDuration and send an unlock email.
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	UnlockLinkURL    string
+}
+
+type BruteForce struct {
+	attemptRepo repository.LoginAttemptRepository
+	userRepo    repository.UserRepository
+	unlockRepo  repository.AccountUnlockRepository
+	signKey     string
+	config      BruteForceConfig
+	securityLog *logrus.Logger
+	audit       AuditService
+	emailSender sender.Email
+}
+
+func NewBruteForce(
+	attemptRepo repository.LoginAttemptRepository,
+	userRepo repository.UserRepository,
+	unlockRepo repository.AccountUnlockRepository,
+	signKey string,
+	config BruteForceConfig,
+	securityLog *logrus.Logger,
+	audit AuditService,
+	emailSender sender.Email) *BruteForce {
+	return &BruteForce{
+		attemptRepo: attemptRepo,
+		userRepo:    userRepo,
+		unlockRepo:  unlockRepo,
+		signKey:     signKey,
+		config:      config,
+		securityLog: securityLog,
+		audit:       audit,
+		emailSender: emailSender,
+	}
+}
+
+// Check returns a *TooManyAttemptsError when any of the keys is currently blocked.
+func (s *BruteForce) Check(ctx context.Context, keys ...string) error {
+	var retryAfter time.Duration
+	for _, key := range keys {
+		attempts, err := s.attemptRepo.GetLoginAttempts(ctx, key)
+		if err != nil {
+			if errors.Is(err, repoerrors.ErrNotFound) {
+				continue
+			}
+
+			return fmt.Errorf("error while getting login attempts: %w", err)
+		}
+
+		if attempts.LockedUntil != nil {
+			if wait := time.Until(*attempts.LockedUntil); wait > retryAfter {
+				retryAfter = wait
+			}
+		}
+	}
+
+	if retryAfter > 0 {
+		return &TooManyAttemptsError{RetryAfter: retryAfter}
+	}
+
+	return nil
+}
+
+func (s *BruteForce) RegisterFailure(ctx context.Context, keys ...string) error {
+	now := time.Now()
+	for _, key := range keys {
+		attempts, err := s.attemptRepo.IncrementLoginFailures(ctx, key, now, s.config.Window)
+		if err != nil {
+			return fmt.Errorf("error while counting failed login attempt: %w", err)
+		}
+
+		email, isAccount := strings.CutPrefix(key, accountAttemptKeyPrefix)
+		if isAccount && s.config.LockoutThreshold > 0 && attempts.Failures >= s.config.LockoutThreshold {
+			err = s.attemptRepo.LockLoginAttempts(ctx, key, now.Add(s.config.LockoutDuration))
+			if err != nil {
+				return fmt.Errorf("error while locking account: %w", err)
+			}
+
+			if attempts.Failures == s.config.LockoutThreshold {
+				s.securityLog.Warnf("account %s locked after %d failed login attempts", email, attempts.Failures)
+				s.lockedOut(ctx, email, attempts.Failures)
+			}
+			continue
+		}
+
+		if attempts.Failures > s.config.FreeAttempts {
+			err = s.attemptRepo.LockLoginAttempts(ctx, key, now.Add(s.backoff(attempts.Failures)))
+			if err != nil {
+				return fmt.Errorf("error while delaying login attempts: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Failures returns the number of failures of all keys that are still within the window.
+func (s *BruteForce) Failures(ctx context.Context, keys ...string) (int, error) {
+	failures := 0
+	for _, key := range keys {
+		attempts, err := s.attemptRepo.GetLoginAttempts(ctx, key)
+		if err != nil {
+			if errors.Is(err, repoerrors.ErrNotFound) {
+				continue
+			}
+
+			return 0, fmt.Errorf("error while getting login attempts: %w", err)
+		}
+
+		if time.Since(attempts.LastFailureAt) < s.config.Window {
+			failures += attempts.Failures
+		}
+	}
+
+	return failures, nil
+}
+
+func (s *BruteForce) Reset(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		err := s.attemptRepo.DeleteLoginAttempts(ctx, key)
+		if err != nil {
+			return fmt.Errorf("error while resetting login attempts: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *BruteForce) Unlock(ctx context.Context, unlockToken string) error {
+	claims := &jwt.StandardClaims{}
+	_, err := jwt.ParseWithClaims(unlockToken, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+
+		return []byte(s.signKey), nil
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidUnlockToken, err)
+	}
+
+	if !claims.VerifyAudience(accountUnlockAudience, true) || claims.Subject == "" || claims.Id == "" {
+		return ErrInvalidUnlockToken
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, claims.Subject)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrInvalidUnlockToken
+		}
+
+		return fmt.Errorf("error while trying to find user: %w", err)
+	}
+
+	// spends the link, it works once
+	err = s.unlockRepo.CreateAccountUnlock(ctx, entity.AccountUnlock{
+		TokenID:    claims.Id,
+		UserID:     user.ID,
+		UnlockedAt: time.Now(),
+	})
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrAlreadyExists) {
+			return ErrInvalidUnlockToken
+		}
+
+		return fmt.Errorf("error while storing account unlock: %w", err)
+	}
+
+	err = s.Reset(ctx, AccountAttemptKey(user.Email))
+	if err != nil {
+		return err
+	}
+
+	s.securityLog.Infof("account of user_id=%s unlocked via email link", user.ID)
+	s.audit.Record(ctx, entity.AuditEvent{
+		Type:      entity.AuditAccountUnlocked,
+		SubjectID: user.ID,
+		Metadata:  map[string]string{"email": user.Email, "method": "email_link", "unlock_token_id": claims.Id},
+	})
+
+	return nil
+}
+
+// backoff doubles the delay with every failure past the free attempts, up to MaxDelay.
+func (s *BruteForce) backoff(failures int) time.Duration {
+	delay := s.config.BaseDelay
+	for i := s.config.FreeAttempts + 1; i < failures; i++ {
+		delay *= 2
+		if delay >= s.config.MaxDelay {
+			return s.config.MaxDelay
+		}
+	}
+
+	return min(delay, s.config.MaxDelay)
+}
+
+// lockedOut records the lockout and sends the unlock email when the email belongs to a registered user.
+func (s *BruteForce) lockedOut(ctx context.Context, email string, failures int) {
+	event := entity.AuditEvent{
+		Type:     entity.AuditAccountLocked,
+		ActorID:  entity.AuditActorSystem,
+		Metadata: map[string]string{"email": email, "failures": strconv.Itoa(failures)},
+	}
+
+	user, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		if !errors.Is(err, repoerrors.ErrNotFound) {
+			s.securityLog.Errorf("error while looking up locked account %s: %v", email, err)
+		}
+		s.audit.Record(ctx, event)
+		return
+	}
+
+	event.SubjectID = user.ID
+	s.audit.Record(ctx, event)
+	s.sendUnlockEmail(ctx, user)
+}
+
+// sendUnlockEmail sends a link that unlocks the account of user. The token id makes it single-use.
+func (s *BruteForce) sendUnlockEmail(ctx context.Context, user *entity.User) {
+	tokenID := make([]byte, 16)
+	_, err := rand.Read(tokenID)
+	if err != nil {
+		s.securityLog.Errorf("error while generating unlock token id for user_id=%s: %v", user.ID, err)
+		return
+	}
+
+	claims := jwt.StandardClaims{
+		Audience:  accountUnlockAudience,
+		Id:        hex.EncodeToString(tokenID),
+		Subject:   user.ID,
+		ExpiresAt: time.Now().Add(s.config.LockoutDuration).Unix(),
+		IssuedAt:  time.Now().Unix(),
+	}
+	unlockToken, err := jwt.NewWithClaims(jwt.SigningMethodHS512, claims).SignedString([]byte(s.signKey))
+	if err != nil {
+		s.securityLog.Errorf("error while generating unlock token for user_id=%s: %v", user.ID, err)
+		return
+	}
+
+	link, err := buildLink(s.config.UnlockLinkURL, unlockToken)
+	if err != nil {
+		s.securityLog.Errorf("error while building unlock link for user_id=%s: %v", user.ID, err)
+		return
+	}
+
+	err = s.emailSender.SendAccountUnlockEmail(ctx, emailRecipient(user), link)
+	if err != nil {
+		s.securityLog.Errorf("error while sending unlock email to user_id=%s: %v", user.ID, err)
+	}
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository/memory"
+	"medods-tz/internal/repository/repoerrors"
+	"medods-tz/internal/sender"
+	"net/url"
+	"testing"
+	"time"
+)
+
+var testBruteForceConfig = BruteForceConfig{
+	Window:           time.Minute * 15,
+	FreeAttempts:     2,
+	BaseDelay:        time.Second,
+	MaxDelay:         time.Minute,
+	LockoutThreshold: 5,
+	LockoutDuration:  time.Minute * 30,
+	UnlockLinkURL:    "http://localhost/unlock",
+}
+
+func newTestBruteForce() *BruteForce {
+	return NewBruteForce(memory.NewLoginAttemptMemory(), new(mockUserRepo), new(mockAccountUnlockRepo), "test-sign-key", testBruteForceConfig, logrus.New(), newTestAudit(), new(mockEmail))
+}
+
+func TestBruteForce_Backoff(t *testing.T) {
+	ctx := context.Background()
+	bruteForce := newTestBruteForce()
+	key := IPAttemptKey("127.0.0.1")
+
+	for i := 0; i < testBruteForceConfig.FreeAttempts; i++ {
+		assert.NoError(t, bruteForce.RegisterFailure(ctx, key))
+		assert.NoError(t, bruteForce.Check(ctx, key))
+	}
+
+	assert.NoError(t, bruteForce.RegisterFailure(ctx, key))
+	err := bruteForce.Check(ctx, key)
+	assert.ErrorIs(t, err, ErrTooManyAttempts)
+
+	var tooManyAttemptsErr *TooManyAttemptsError
+	assert.ErrorAs(t, err, &tooManyAttemptsErr)
+	assert.LessOrEqual(t, tooManyAttemptsErr.RetryAfter, time.Second)
+
+	assert.Equal(t, time.Second*4, bruteForce.backoff(5))
+	assert.Equal(t, time.Minute, bruteForce.backoff(50))
+}
+
+func TestBruteForce_LockoutAndUnlock(t *testing.T) {
+	ctx := context.Background()
+	mockUserRepo := new(mockUserRepo)
+	mockEmail := new(mockEmail)
+	mockUnlockRepo := new(mockAccountUnlockRepo)
+	bruteForce := NewBruteForce(memory.NewLoginAttemptMemory(), mockUserRepo, mockUnlockRepo, "test-sign-key", testBruteForceConfig, logrus.New(), newTestAudit(), mockEmail)
+	key := AccountAttemptKey("Test@example.com")
+
+	links := make(chan string, 1)
+	user := &entity.User{ID: "user-id", Email: "Test@example.com"}
+	mockUserRepo.On("GetUserByEmail", ctx, "test@example.com").Return(user, nil)
+	mockUserRepo.On("GetUserByID", ctx, "user-id").Return(user, nil)
+	mockEmail.On("SendAccountUnlockEmail", ctx, sender.Recipient{Email: "Test@example.com"}, mock.Anything).
+		Run(func(args mock.Arguments) { links <- args.String(2) }).
+		Return(nil)
+
+	for i := 0; i < testBruteForceConfig.LockoutThreshold; i++ {
+		assert.NoError(t, bruteForce.RegisterFailure(ctx, key))
+	}
+
+	var tooManyAttemptsErr *TooManyAttemptsError
+	assert.ErrorAs(t, bruteForce.Check(ctx, key), &tooManyAttemptsErr)
+	assert.Greater(t, tooManyAttemptsErr.RetryAfter, time.Minute*29)
+
+	link, err := url.Parse(<-links)
+	assert.NoError(t, err)
+
+	var unlock entity.AccountUnlock
+	mockUnlockRepo.On("CreateAccountUnlock", ctx, mock.Anything).
+		Run(func(args mock.Arguments) { unlock = args.Get(1).(entity.AccountUnlock) }).
+		Return(nil).Once()
+	mockUnlockRepo.On("CreateAccountUnlock", ctx, mock.Anything).Return(repoerrors.ErrAlreadyExists)
+
+	assert.NoError(t, bruteForce.Unlock(ctx, link.Query().Get("token")))
+	assert.NoError(t, bruteForce.Check(ctx, key))
+	assert.Equal(t, "user-id", unlock.UserID)
+	assert.NotEmpty(t, unlock.TokenID)
+
+	// the link works once
+	assert.ErrorIs(t, bruteForce.Unlock(ctx, link.Query().Get("token")), ErrInvalidUnlockToken)
+}
+
+func TestAuth_Login_BlockedAfterFailures(t *testing.T) {
+	ctx := context.Background()
+	mockUserRepo := new(mockUserRepo)
+
+	auth := newTestAuth(AuthDependencies{UserRepo: mockUserRepo})
+
+	mockUserRepo.On("GetUserByEmail", ctx, "test@example.com").Return((*entity.User)(nil), repoerrors.ErrNotFound)
+
+	for i := 0; i <= testBruteForceConfig.FreeAttempts; i++ {
+		_, err := auth.Login(ctx, "test@example.com", "wrong-password", "", "127.0.0.1", DeviceInput{})
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	}
+
+	_, err := auth.Login(ctx, "test@example.com", "wrong-password", "", "127.0.0.1", DeviceInput{})
+	assert.ErrorIs(t, err, ErrTooManyAttempts)
+}
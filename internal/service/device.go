@@ -0,0 +1,262 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository"
+	"medods-tz/internal/repository/repoerrors"
+	"medods-tz/pkg/useragent"
+	"strings"
+	"time"
+)
+
+// DeviceInput is what a request tells about the device it comes from.
+type DeviceInput struct {
+	// Key is the device ID the client sent or the key of a valid device cookie, empty when there is neither.
+	Key       string
+	UserAgent string
+}
+
+// DeviceMatch is the device of a sign-in, as far as it is known.
+type DeviceMatch struct {
+	// Key identifies the device from now on, clients without one get a new key.
+	Key string
+	// Device is the stored device, nil when the key was not seen with the user before.
+	Device    *entity.Device
+	UserAgent string
+	// New is set for an unknown device of a user who signed in with other devices before.
+	// The first device of a user is not new.
+	New bool
+}
+
+// Description is how the device is shown in notifications.
+func (m DeviceMatch) Description() string {
+	if m.Device != nil {
+		return m.Device.Description()
+	}
+
+	if agent := useragent.Parse(m.UserAgent).String(); agent != "" {
+		return agent
+	}
+
+	return m.UserAgent
+}
+
+// Devices recognizes the devices users sign in with by a long-lived key. Browsers keep the key in a
+// signed cookie, other clients send their own device ID. Only the hash of a key is stored.
+type Devices struct {
+	deviceRepo repository.DeviceRepository
+	tokenRepo  repository.TokenRepository
+	transactor repository.Transactor
+	signKey    string
+	audit      AuditService
+	webhooks   WebhookService
+}
+
+func NewDevices(
+	deviceRepo repository.DeviceRepository,
+	tokenRepo repository.TokenRepository,
+	transactor repository.Transactor,
+	signKey string,
+	audit AuditService,
+	webhooks WebhookService) *Devices {
+	return &Devices{
+		deviceRepo: deviceRepo,
+		tokenRepo:  tokenRepo,
+		transactor: transactor,
+		signKey:    signKey,
+		audit:      audit,
+		webhooks:   webhooks,
+	}
+}
+
+// Recognize finds the device of a sign-in without storing anything. sessionDeviceID is the device of
+// the session being refreshed: a refresh without a key stays on it.
+func (s *Devices) Recognize(ctx context.Context, userID string, input DeviceInput, sessionDeviceID *string) (*DeviceMatch, error) {
+	match := &DeviceMatch{Key: input.Key, UserAgent: input.UserAgent}
+
+	switch {
+	case input.Key != "":
+		device, err := s.deviceRepo.GetDeviceByKeyHash(ctx, userID, hashDeviceKey(input.Key))
+		if err != nil && !errors.Is(err, repoerrors.ErrNotFound) {
+			return nil, fmt.Errorf("error while getting device: %w", err)
+		}
+		match.Device = device
+	case sessionDeviceID != nil:
+		device, err := s.deviceRepo.GetDevice(ctx, userID, *sessionDeviceID)
+		if err == nil {
+			match.Device = device
+			return match, nil
+		}
+		if !errors.Is(err, repoerrors.ErrNotFound) {
+			return nil, fmt.Errorf("error while getting device: %w", err)
+		}
+	}
+
+	if match.Device != nil {
+		return match, nil
+	}
+
+	if match.Key == "" {
+		key, err := generateDeviceKey()
+		if err != nil {
+			return nil, fmt.Errorf("error while generating device key: %w", err)
+		}
+		match.Key = key
+	}
+
+	known, err := s.deviceRepo.CountDevices(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error while counting devices: %w", err)
+	}
+	match.New = known > 0
+
+	return match, nil
+}
+
+// Remember stores a recognized device or updates when it was last seen, and returns its ID. The ID is
+// empty when a device without a key was forgotten in the meantime.
+func (s *Devices) Remember(ctx context.Context, userID string, match *DeviceMatch, clientIP string) (string, error) {
+	now := time.Now()
+
+	if match.Device != nil {
+		err := s.deviceRepo.TouchDevice(ctx, match.Device.ID, clientIP, now)
+		if err == nil {
+			return match.Device.ID, nil
+		}
+		if !errors.Is(err, repoerrors.ErrNotFound) {
+			return "", fmt.Errorf("error while updating device: %w", err)
+		}
+		if match.Key == "" {
+			return "", nil
+		}
+	}
+
+	agent := useragent.Parse(match.UserAgent)
+	id, err := s.deviceRepo.UpsertDevice(ctx, entity.Device{
+		UserID:     userID,
+		KeyHash:    hashDeviceKey(match.Key),
+		Browser:    agent.Browser,
+		OS:         agent.OS,
+		UserAgent:  match.UserAgent,
+		LastIP:     clientIP,
+		LastSeenAt: now,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error while storing device: %w", err)
+	}
+
+	return id, nil
+}
+
+func (s *Devices) ListDevices(ctx context.Context, userID string) ([]entity.Device, error) {
+	devices, err := s.deviceRepo.ListDevices(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error while listing devices: %w", err)
+	}
+
+	if devices == nil {
+		devices = []entity.Device{}
+	}
+
+	return devices, nil
+}
+
+func (s *Devices) RenameDevice(ctx context.Context, userID, deviceID, name string) error {
+	err := s.deviceRepo.RenameDevice(ctx, userID, deviceID, strings.TrimSpace(name))
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrDeviceNotFound
+		}
+
+		return fmt.Errorf("error while renaming device: %w", err)
+	}
+
+	return nil
+}
+
+// ForgetDevice signs out the sessions of the device and removes it, so its next sign-in counts as a new device.
+func (s *Devices) ForgetDevice(ctx context.Context, userID, deviceID string) error {
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		// the device may have signed in to organizations as well
+		err := s.transactor.WithinAllTenants(ctx, func(ctx context.Context) error {
+			return s.tokenRepo.RevokeRefreshTokensByDeviceID(ctx, deviceID)
+		})
+		if err != nil {
+			return fmt.Errorf("error while revoking refresh tokens of device: %w", err)
+		}
+
+		err = s.deviceRepo.DeleteDevice(ctx, userID, deviceID)
+		if err != nil {
+			if errors.Is(err, repoerrors.ErrNotFound) {
+				return ErrDeviceNotFound
+			}
+
+			return fmt.Errorf("error while deleting device: %w", err)
+		}
+
+		return s.webhooks.Enqueue(ctx, entity.WebhookSessionRevoked, map[string]string{
+			"user_id":   userID,
+			"device_id": deviceID,
+			"reason":    "device_forgotten",
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, entity.AuditEvent{
+		Type:      entity.AuditDeviceForgotten,
+		SubjectID: userID,
+		Metadata:  map[string]string{"device_id": deviceID},
+	})
+
+	return nil
+}
+
+// SignDeviceKey returns the value of the device cookie for key.
+func (s *Devices) SignDeviceKey(key string) string {
+	return key + "." + s.deviceKeyMAC(key)
+}
+
+// ParseDeviceCookie returns the key of a device cookie, cookies the service did not sign are ignored.
+func (s *Devices) ParseDeviceCookie(value string) (string, bool) {
+	key, mac, ok := strings.Cut(value, ".")
+	if !ok || key == "" {
+		return "", false
+	}
+
+	if !hmac.Equal([]byte(mac), []byte(s.deviceKeyMAC(key))) {
+		return "", false
+	}
+
+	return key, true
+}
+
+func (s *Devices) deviceKeyMAC(key string) string {
+	mac := hmac.New(sha256.New, []byte(s.signKey))
+	mac.Write([]byte("device:" + key))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func hashDeviceKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+func generateDeviceKey() (string, error) {
+	bytes := make([]byte, 24)
+	_, err := rand.Read(bytes)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository/repoerrors"
+	"medods-tz/internal/sender"
+	"testing"
+)
+
+// newTestDevices knows no devices, so every sign-in is the first device of its user.
+func newTestDevices() *Devices {
+	mockDeviceRepo := new(mockDeviceRepo)
+	mockDeviceRepo.On("GetDeviceByKeyHash", mock.Anything, mock.Anything, mock.Anything).Return((*entity.Device)(nil), repoerrors.ErrNotFound).Maybe()
+	mockDeviceRepo.On("GetDevice", mock.Anything, mock.Anything, mock.Anything).Return((*entity.Device)(nil), repoerrors.ErrNotFound).Maybe()
+	mockDeviceRepo.On("CountDevices", mock.Anything, mock.Anything).Return(0, nil).Maybe()
+	mockDeviceRepo.On("UpsertDevice", mock.Anything, mock.Anything).Return("device-id", nil).Maybe()
+
+	return NewDevices(mockDeviceRepo, new(mockTokenRepo), mockTransactor{}, "test-sign-key", newTestAudit(), newTestWebhooks())
+}
+
+func TestDevices_Recognize(t *testing.T) {
+	ctx := context.Background()
+	mockDeviceRepo := new(mockDeviceRepo)
+	devices := NewDevices(mockDeviceRepo, new(mockTokenRepo), mockTransactor{}, "test-sign-key", newTestAudit(), newTestWebhooks())
+
+	laptop := &entity.Device{ID: "laptop-id", UserID: "user-id", KeyHash: hashDeviceKey("laptop-key"), Browser: "Firefox 121", OS: "macOS 14.2"}
+	mockDeviceRepo.On("GetDeviceByKeyHash", ctx, "user-id", hashDeviceKey("laptop-key")).Return(laptop, nil)
+	mockDeviceRepo.On("GetDeviceByKeyHash", ctx, "user-id", hashDeviceKey("phone-key")).Return((*entity.Device)(nil), repoerrors.ErrNotFound)
+	mockDeviceRepo.On("GetDevice", ctx, "user-id", "laptop-id").Return(laptop, nil)
+	mockDeviceRepo.On("CountDevices", ctx, "user-id").Return(1, nil)
+	mockDeviceRepo.On("CountDevices", ctx, "other-user-id").Return(0, nil)
+	mockDeviceRepo.On("GetDeviceByKeyHash", ctx, "other-user-id", hashDeviceKey("phone-key")).Return((*entity.Device)(nil), repoerrors.ErrNotFound)
+
+	match, err := devices.Recognize(ctx, "user-id", DeviceInput{Key: "laptop-key"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, laptop, match.Device)
+	assert.False(t, match.New)
+	assert.Equal(t, "Firefox 121 on macOS 14.2", match.Description())
+
+	match, err = devices.Recognize(ctx, "user-id", DeviceInput{Key: "phone-key", UserAgent: "curl/8.4.0"}, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, match.Device)
+	assert.True(t, match.New)
+	assert.Equal(t, "phone-key", match.Key)
+	assert.Equal(t, "curl 8", match.Description())
+
+	// the first device of a user is not announced
+	match, err = devices.Recognize(ctx, "other-user-id", DeviceInput{Key: "phone-key"}, nil)
+	assert.NoError(t, err)
+	assert.False(t, match.New)
+
+	// a refresh without a key stays on the device of the session
+	sessionDeviceID := "laptop-id"
+	match, err = devices.Recognize(ctx, "user-id", DeviceInput{}, &sessionDeviceID)
+	assert.NoError(t, err)
+	assert.Equal(t, laptop, match.Device)
+	assert.Empty(t, match.Key)
+
+	// a sign-in without a key gets a new one
+	match, err = devices.Recognize(ctx, "user-id", DeviceInput{}, nil)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, match.Key)
+	assert.True(t, match.New)
+}
+
+func TestDevices_DeviceCookie(t *testing.T) {
+	devices := newTestDevices()
+
+	cookie := devices.SignDeviceKey("device-key")
+	key, ok := devices.ParseDeviceCookie(cookie)
+	assert.True(t, ok)
+	assert.Equal(t, "device-key", key)
+
+	for _, value := range []string{"", "device-key", "other-key" + cookie[len("device-key"):], cookie + "x"} {
+		_, ok = devices.ParseDeviceCookie(value)
+		assert.False(t, ok, value)
+	}
+}
+
+func TestDevices_ForgetDevice(t *testing.T) {
+	ctx := context.Background()
+	mockDeviceRepo := new(mockDeviceRepo)
+	mockTokenRepo := new(mockTokenRepo)
+	devices := NewDevices(mockDeviceRepo, mockTokenRepo, mockTransactor{}, "test-sign-key", newTestAudit(), newTestWebhooks())
+
+	mockTokenRepo.On("RevokeRefreshTokensByDeviceID", ctx, mock.Anything).Return(nil)
+	mockDeviceRepo.On("DeleteDevice", ctx, "user-id", "device-id").Return(nil)
+	mockDeviceRepo.On("DeleteDevice", ctx, "user-id", "other-device-id").Return(repoerrors.ErrNotFound)
+
+	assert.NoError(t, devices.ForgetDevice(ctx, "user-id", "device-id"))
+	mockTokenRepo.AssertCalled(t, "RevokeRefreshTokensByDeviceID", ctx, "device-id")
+
+	assert.ErrorIs(t, devices.ForgetDevice(ctx, "user-id", "other-device-id"), ErrDeviceNotFound)
+}
+
+func TestAuth_Login_NewDevice(t *testing.T) {
+	ctx := context.Background()
+	mockUserRepo := new(mockUserRepo)
+	mockTokenRepo := new(mockTokenRepo)
+	mockDeviceRepo := new(mockDeviceRepo)
+	mockNotifications := new(mockNotifications)
+
+	devices := NewDevices(mockDeviceRepo, mockTokenRepo, mockTransactor{}, "test-sign-key", newTestAudit(), newTestWebhooks())
+	passwordHasher := newTestPasswordHasher()
+	auth := newTestAuth(AuthDependencies{
+		UserRepo:       mockUserRepo,
+		TokenRepo:      mockTokenRepo,
+		Notifications:  mockNotifications,
+		PasswordHasher: passwordHasher,
+		Devices:        devices,
+	})
+
+	passwordHash, _ := passwordHasher.Hash("password123")
+	user := &entity.User{ID: "user-id", Email: "test@example.com", PasswordHash: passwordHash}
+	mockUserRepo.On("GetUserByEmail", ctx, "test@example.com").Return(user, nil)
+	mockDeviceRepo.On("GetDeviceByKeyHash", ctx, "user-id", hashDeviceKey("phone-key")).Return((*entity.Device)(nil), repoerrors.ErrNotFound)
+	mockDeviceRepo.On("CountDevices", ctx, "user-id").Return(1, nil)
+	mockDeviceRepo.On("UpsertDevice", ctx, mock.MatchedBy(func(device entity.Device) bool {
+		return device.KeyHash == hashDeviceKey("phone-key") && device.Browser == "Safari 17" && device.OS == "iOS 17.1"
+	})).Return("device-id", nil)
+	mockTokenRepo.On("CreateRefreshToken", ctx, mock.MatchedBy(func(token entity.RefreshToken) bool {
+		return token.DeviceID != nil && *token.DeviceID == "device-id"
+	})).Return(nil)
+	mockNotifications.On("Notify", ctx, user, mock.MatchedBy(func(notification Notification) bool {
+		data, ok := notification.Data.(sender.NewDeviceData)
+		return notification.Template == sender.TemplateNewDevice && ok && data.Device == "Safari 17 on iOS 17.1"
+	})).Return(nil)
+
+	tokens, err := auth.Login(ctx, "test@example.com", "password123", "", "127.0.0.1", DeviceInput{
+		Key:       "phone-key",
+		UserAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Mobile/15E148 Safari/604.1",
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "phone-key", tokens.DeviceKey)
+	mockTokenRepo.AssertExpectations(t)
+	mockNotifications.AssertExpectations(t)
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository"
+	"medods-tz/internal/repository/repoerrors"
+	"medods-tz/internal/sender"
+	"time"
+)
+
+// errChannelNotConfigured fails messages queued for a channel that has been disabled since.
+var errChannelNotConfigured = errors.New("notification channel is not configured")
+
+type EmailOutboxConfig struct {
+	// MaxAttempts failed sends mark a message as failed.
+	MaxAttempts int
+	// BaseBackoff is the delay after the first failure, it doubles with every further one up to MaxBackoff.
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	BatchSize   int
+	// Lease is how long a claimed batch is reserved for one worker, it must cover sending the whole batch.
+	Lease time.Duration
+}
+
+// EmailOutbox implements sender.Email by rendering messages from the templates and writing them
+// to the outbox table. When called in a transaction, the message is stored if and only if the change
+// it announces is. DeliverPending sends them in the background through the transport of their channel.
+type EmailOutbox struct {
+	emailRepo   repository.EmailRepository
+	transports  map[string]sender.Transport
+	templates   *sender.Templates
+	config      EmailOutboxConfig
+	securityLog *logrus.Logger
+}
+
+func NewEmailOutbox(
+	emailRepo repository.EmailRepository,
+	transports map[string]sender.Transport,
+	templates *sender.Templates,
+	config EmailOutboxConfig,
+	securityLog *logrus.Logger) *EmailOutbox {
+	return &EmailOutbox{
+		emailRepo:   emailRepo,
+		transports:  transports,
+		templates:   templates,
+		config:      config,
+		securityLog: securityLog,
+	}
+}
+
+func (s *EmailOutbox) SendVerificationEmail(ctx context.Context, to sender.Recipient, link string) error {
+	return s.enqueue(ctx, sender.TemplateVerifyEmail, to, sender.LinkData{Link: link})
+}
+
+func (s *EmailOutbox) SendPasswordResetEmail(ctx context.Context, to sender.Recipient, link string) error {
+	return s.enqueue(ctx, sender.TemplatePasswordReset, to, sender.LinkData{Link: link})
+}
+
+func (s *EmailOutbox) SendAccountUnlockEmail(ctx context.Context, to sender.Recipient, link string) error {
+	return s.enqueue(ctx, sender.TemplateAccountUnlock, to, sender.LinkData{Link: link})
+}
+
+func (s *EmailOutbox) SendStepUpCodeEmail(ctx context.Context, to sender.Recipient, data sender.StepUpCodeData) error {
+	return s.enqueue(ctx, sender.TemplateStepUpCode, to, data)
+}
+
+// enqueue renders the message right away, so later template changes do not alter queued emails
+// and a broken template fails the request instead of every delivery attempt.
+func (s *EmailOutbox) enqueue(ctx context.Context, templateName string, to sender.Recipient, data any) error {
+	message, err := s.render(templateName, to.Locale, data)
+	if err != nil {
+		return err
+	}
+
+	return s.queue(ctx, sender.ChannelEmail, to.Email, message)
+}
+
+func (s *EmailOutbox) render(templateName, locale string, data any) (sender.Message, error) {
+	message, err := s.templates.Render(templateName, locale, data)
+	if err != nil {
+		return sender.Message{}, fmt.Errorf("error while rendering email: %w", err)
+	}
+
+	return message, nil
+}
+
+// queue writes a rendered message for the address in the channel to the outbox.
+func (s *EmailOutbox) queue(ctx context.Context, channel, address string, message sender.Message) error {
+	now := time.Now()
+	_, err := s.emailRepo.CreateEmailMessage(ctx, entity.EmailMessage{
+		Kind:          message.Kind,
+		Channel:       channel,
+		Recipient:     address,
+		Subject:       message.Subject,
+		TextBody:      message.TextBody,
+		HTMLBody:      message.HTMLBody,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	})
+	if err != nil {
+		return fmt.Errorf("error while writing email to the outbox: %w", err)
+	}
+
+	return nil
+}
+
+// DeliverPending sends due messages until none are left.
+func (s *EmailOutbox) DeliverPending(ctx context.Context) error {
+	for ctx.Err() == nil {
+		now := time.Now()
+		messages, err := s.emailRepo.ClaimEmailMessages(ctx, now, now.Add(s.config.Lease), s.config.BatchSize)
+		if err != nil {
+			return fmt.Errorf("error while claiming emails: %w", err)
+		}
+
+		for _, message := range messages {
+			s.deliver(ctx, message)
+		}
+
+		if len(messages) < s.config.BatchSize {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (s *EmailOutbox) deliver(ctx context.Context, message entity.EmailMessage) {
+	err := errChannelNotConfigured
+	if transport, ok := s.transports[message.Channel]; ok {
+		err = transport.Send(ctx, sender.Message{
+			Kind:     message.Kind,
+			To:       message.Recipient,
+			Subject:  message.Subject,
+			TextBody: message.TextBody,
+			HTMLBody: message.HTMLBody,
+		})
+	}
+
+	now := time.Now()
+	// nothing was sent while the transport is known to be down, so that does not count as an attempt
+	if !errors.Is(err, sender.ErrTransportUnavailable) {
+		message.Attempts++
+	}
+	switch {
+	case err == nil:
+		message.Status = entity.EmailSent
+		message.LastError = ""
+		message.SentAt = &now
+		// the bodies may hold single-use links, they are not kept once they have been delivered
+		message.TextBody = ""
+		message.HTMLBody = ""
+	case message.Attempts >= s.config.MaxAttempts:
+		message.Status = entity.EmailFailed
+		message.LastError = err.Error()
+		s.securityLog.Errorf("email id=%d kind=%s channel=%s failed %d times, giving up: %v", message.ID, message.Kind, message.Channel, message.Attempts, err)
+	default:
+		message.Status = entity.EmailPending
+		message.LastError = err.Error()
+		message.NextAttemptAt = now.Add(retryBackoff(s.config.BaseBackoff, s.config.MaxBackoff, message.Attempts))
+	}
+
+	// the outcome is stored even when the worker is stopping, otherwise the message would be sent again
+	err = s.emailRepo.UpdateEmailMessage(context.WithoutCancel(ctx), message)
+	if err != nil {
+		s.securityLog.Errorf("error while updating email id=%d: %v", message.ID, err)
+	}
+}
+
+// ListMessages returns messages newest first and the cursor of the next page, empty on the last page.
+func (s *EmailOutbox) ListMessages(ctx context.Context, filter entity.EmailMessageFilter, cursor string) ([]entity.EmailMessage, string, error) {
+	if cursor != "" {
+		beforeID, err := decodeIDCursor(cursor)
+		if err != nil {
+			return nil, "", ErrInvalidCursor
+		}
+		filter.BeforeID = beforeID
+	}
+
+	messages, nextCursor, err := listPage(filter.Limit, func(limit int) ([]entity.EmailMessage, error) {
+		filter.Limit = limit
+		return s.emailRepo.ListEmailMessages(ctx, filter)
+	}, func(message entity.EmailMessage) string {
+		return encodeIDCursor(message.ID)
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("error while listing emails: %w", err)
+	}
+
+	return messages, nextCursor, nil
+}
+
+// Retry queues a pending or failed message for an immediate attempt with a fresh set of retries.
+func (s *EmailOutbox) Retry(ctx context.Context, id int64) error {
+	err := s.emailRepo.RequeueEmailMessage(ctx, id, time.Now())
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrEmailNotFound
+		}
+
+		return fmt.Errorf("error while requeueing email: %w", err)
+	}
+
+	return nil
+}
+
+// ListTemplates returns the names of the email templates and the locales they are available in.
+func (s *EmailOutbox) ListTemplates() ([]string, []string) {
+	return sender.TemplateNames, s.templates.Locales()
+}
+
+// PreviewTemplate renders the named template with sample data in the given locale or its fallback.
+func (s *EmailOutbox) PreviewTemplate(name, locale string) (sender.Message, error) {
+	data, err := sender.SampleData(name)
+	if err != nil {
+		return sender.Message{}, ErrEmailTemplateNotFound
+	}
+
+	message, err := s.templates.Render(name, locale, data)
+	if err != nil {
+		return sender.Message{}, fmt.Errorf("error while rendering email template: %w", err)
+	}
+
+	return message, nil
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/sender"
+	"medods-tz/pkg/breaker"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testEmailOutboxConfig = EmailOutboxConfig{
+	MaxAttempts: 3,
+	BaseBackoff: time.Minute,
+	MaxBackoff:  time.Hour,
+	BatchSize:   10,
+	Lease:       time.Minute,
+}
+
+func newTestEmailTemplates(t *testing.T) *sender.Templates {
+	templates, err := sender.NewTemplates("", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return templates
+}
+
+func TestEmailOutbox_SendQueuesMessage(t *testing.T) {
+	ctx := context.Background()
+	mockEmailRepo := new(mockEmailRepo)
+	mockTransport := new(mockEmailTransport)
+	outbox := NewEmailOutbox(mockEmailRepo, map[string]sender.Transport{sender.ChannelEmail: mockTransport}, newTestEmailTemplates(t), testEmailOutboxConfig, logrus.New())
+
+	link := "http://localhost/reset-password?token=t"
+	mockEmailRepo.On("CreateEmailMessage", ctx, mock.MatchedBy(func(message entity.EmailMessage) bool {
+		return message.Kind == sender.TemplatePasswordReset && message.Channel == sender.ChannelEmail && message.Recipient == "test@example.com" &&
+			message.Status == "" && strings.Contains(message.TextBody, link) && strings.Contains(message.HTMLBody, link)
+	})).Return(int64(1), nil)
+
+	err := outbox.SendPasswordResetEmail(ctx, sender.Recipient{Email: "test@example.com", Locale: "ru-RU"}, link)
+
+	assert.NoError(t, err)
+	mockTransport.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+}
+
+func TestEmailOutbox_DeliverPending(t *testing.T) {
+	ctx := context.Background()
+	mockEmailRepo := new(mockEmailRepo)
+	mockTransport := new(mockEmailTransport)
+	outbox := NewEmailOutbox(mockEmailRepo, map[string]sender.Transport{sender.ChannelEmail: mockTransport}, newTestEmailTemplates(t), testEmailOutboxConfig, logrus.New())
+
+	mockEmailRepo.On("ClaimEmailMessages", ctx, mock.Anything, mock.Anything, testEmailOutboxConfig.BatchSize).Return([]entity.EmailMessage{
+		{ID: 1, Channel: sender.ChannelEmail, Recipient: "sent@example.com", HTMLBody: "<p>link</p>"},
+		{ID: 2, Channel: sender.ChannelEmail, Recipient: "retry@example.com", HTMLBody: "<p>link</p>"},
+		{ID: 3, Channel: sender.ChannelEmail, Recipient: "failed@example.com", HTMLBody: "<p>link</p>", Attempts: testEmailOutboxConfig.MaxAttempts - 1},
+		{ID: 4, Channel: sender.ChannelSMS, Recipient: "+15555550100"},
+		{ID: 5, Channel: sender.ChannelEmail, Recipient: "unavailable@example.com", HTMLBody: "<p>link</p>", Attempts: 2},
+	}, nil)
+	mockTransport.On("Send", ctx, mock.MatchedBy(func(message sender.Message) bool { return message.To == "sent@example.com" })).Return(nil)
+	mockTransport.On("Send", ctx, mock.MatchedBy(func(message sender.Message) bool { return message.To == "unavailable@example.com" })).
+		Return(fmt.Errorf("%w: smtp server: %w", sender.ErrTransportUnavailable, breaker.ErrOpen))
+	mockTransport.On("Send", ctx, mock.Anything).Return(errors.New("smtp: connection refused"))
+
+	updated := map[int64]entity.EmailMessage{}
+	mockEmailRepo.On("UpdateEmailMessage", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			message := args.Get(1).(entity.EmailMessage)
+			updated[message.ID] = message
+		}).
+		Return(nil)
+
+	start := time.Now()
+	err := outbox.DeliverPending(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, entity.EmailSent, updated[1].Status)
+	assert.NotNil(t, updated[1].SentAt)
+	assert.Empty(t, updated[1].HTMLBody)
+
+	assert.Equal(t, entity.EmailPending, updated[2].Status)
+	assert.Equal(t, 1, updated[2].Attempts)
+	assert.Equal(t, "smtp: connection refused", updated[2].LastError)
+	assert.True(t, updated[2].NextAttemptAt.After(start))
+	assert.NotEmpty(t, updated[2].HTMLBody)
+
+	assert.Equal(t, entity.EmailFailed, updated[3].Status)
+	assert.Equal(t, testEmailOutboxConfig.MaxAttempts, updated[3].Attempts)
+
+	assert.Equal(t, entity.EmailPending, updated[4].Status)
+	assert.Equal(t, errChannelNotConfigured.Error(), updated[4].LastError)
+
+	assert.Equal(t, entity.EmailPending, updated[5].Status)
+	assert.Equal(t, 2, updated[5].Attempts)
+	assert.True(t, updated[5].NextAttemptAt.After(start))
+	mockTransport.AssertNumberOfCalls(t, "Send", 4)
+}
+
+func TestEmailOutbox_PreviewTemplate(t *testing.T) {
+	outbox := NewEmailOutbox(new(mockEmailRepo), nil, newTestEmailTemplates(t), testEmailOutboxConfig, logrus.New())
+
+	message, err := outbox.PreviewTemplate(sender.TemplateSuspiciousLogin, "ru")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, message.Subject)
+	assert.Contains(t, message.TextBody, "203.0.113.7")
+
+	_, err = outbox.PreviewTemplate("unknown", "en")
+	assert.ErrorIs(t, err, ErrEmailTemplateNotFound)
+}
@@ -1,14 +1,100 @@
 package service
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 var (
-	ErrUserNotFound                  = errors.New("user not found")
-	ErrSessionAlreadyExists          = errors.New("session with this refresh_token and user_id already exists")
-	ErrRefreshTokenNotFound          = errors.New("refresh token not found")
-	ErrRefreshTokenAlreadyUsed       = errors.New("refresh token already used")
-	ErrRefreshTokenExpired           = errors.New("refresh token expired")
-	ErrAccessTokenExpired            = errors.New("token is expired")
-	ErrParsingAccessToken            = errors.New("error parsing access token")
-	ErrNoSessionsFoundWithThisUserID = errors.New("no sessions found with this user_id")
+	ErrUserNotFound                   = errors.New("user not found")
+	ErrSessionAlreadyExists           = errors.New("session with this refresh_token and user_id already exists")
+	ErrRefreshTokenNotFound           = errors.New("refresh token not found")
+	ErrRefreshTokenAlreadyUsed        = errors.New("refresh token already used")
+	ErrRefreshTokenExpired            = errors.New("refresh token expired")
+	ErrAccessTokenExpired             = errors.New("token is expired")
+	ErrParsingAccessToken             = errors.New("error parsing access token")
+	ErrNoSessionsFoundWithThisUserID  = errors.New("no sessions found with this user_id")
+	ErrUserAlreadyExists              = errors.New("user with this email already exists")
+	ErrEmailNotVerified               = errors.New("email is not verified")
+	ErrEmailAlreadyVerified           = errors.New("email is already verified")
+	ErrInvalidVerificationToken       = errors.New("invalid email verification token")
+	ErrInvalidAccessToken             = errors.New("invalid access token")
+	ErrInvalidCredentials             = errors.New("invalid email or password")
+	ErrInvalidPasswordResetToken      = errors.New("invalid or expired password reset token")
+	ErrRefreshTokenRevoked            = errors.New("refresh token revoked")
+	ErrPasswordPolicyViolation        = errors.New("password does not meet the password policy")
+	ErrTooManyAttempts                = errors.New("too many failed attempts, try again later")
+	ErrInvalidUnlockToken             = errors.New("invalid or expired unlock token")
+	ErrInvalidCursor                  = errors.New("invalid cursor")
+	ErrWebhookNotFound                = errors.New("webhook subscription not found")
+	ErrWebhookDeliveryNotFound        = errors.New("webhook delivery not found or already delivered")
+	ErrUnknownWebhookEventType        = errors.New("unknown webhook event type")
+	ErrEmailNotFound                  = errors.New("email not found or already sent")
+	ErrEmailTemplateNotFound          = errors.New("email template not found")
+	ErrNotificationChannelUnavailable = errors.New("notification channel is not available")
+	ErrInvalidNotificationPreference  = errors.New("invalid notification preference")
+	ErrInvalidWebhookURL              = errors.New("webhook url must be an absolute http or https url")
+	ErrInvalidLoginReportToken        = errors.New("invalid, expired or already used report link")
+	ErrPasswordResetRequired          = errors.New("password must be reset before signing in")
+	ErrRiskDenied                     = errors.New("sign-in refused by the risk policy")
+	ErrStepUpRequired                 = errors.New("additional verification required, enter the code sent by email")
+	ErrInvalidStepUpCode              = errors.New("invalid, expired or already used verification code")
+	ErrDeviceNotFound                 = errors.New("device not found")
+	ErrIPNotAllowed                   = errors.New("sign-in from this ip address is not allowed")
+	ErrInvalidIPRule                  = errors.New("ip rule action must be allow or deny and cidr an ip address or range")
+	ErrIPRuleNotFound                 = errors.New("ip rule not found")
+	ErrIPRuleAlreadyExists            = errors.New("ip rule already exists")
+	ErrInvalidPermissionName          = errors.New("permission name must start with a lowercase letter and contain only lowercase letters, digits and _ . : -")
+	ErrInvalidRoleName                = errors.New("role name must start with a lowercase letter and contain only lowercase letters, digits and _ . : -")
+	ErrPermissionNotFound             = errors.New("permission not found")
+	ErrPermissionAlreadyExists        = errors.New("permission already exists")
+	ErrRoleNotFound                   = errors.New("role not found")
+	ErrRoleAlreadyExists              = errors.New("role with this name already exists")
+	ErrRelationsDisabled              = errors.New("relation tuples are not configured")
+	ErrInvalidRelationTuple           = errors.New("invalid relation tuple")
+	ErrUnknownRelation                = errors.New("relation is not declared in the namespace configuration")
+	ErrNoRelationTuples               = errors.New("no relation tuples to write or delete")
+	ErrRelationDepthExceeded          = errors.New("relation is nested too deep")
+	ErrInvalidConsistencyToken        = errors.New("invalid consistency token")
+	ErrSnapshotExpired                = errors.New("snapshot of the consistency token is no longer retained")
+	ErrInvalidOrganization            = errors.New("organization name must not be empty")
+	ErrOrganizationNotFound           = errors.New("organization not found")
+	ErrInvalidOrgRole                 = errors.New("membership role must be owner, admin or member")
+	ErrMembershipNotFound             = errors.New("membership not found")
+	ErrNotOrgMember                   = errors.New("user is not a member of the organization")
+	ErrNoActiveOrg                    = errors.New("the access token was not issued for an organization")
+	ErrInvalidTokenPolicy             = errors.New("token lifetimes must not be negative and allowed grants must be user_id, password or refresh_token")
+	ErrGrantNotAllowed                = errors.New("the token policy of the organization does not allow this grant")
+	ErrSessionLifetimeExceeded        = errors.New("the session reached the maximum lifetime of the organization, sign in again")
+	ErrMFAUnavailable                 = errors.New("the organization requires a second factor, which needs a verified email address")
+	ErrInvalidUserStatus              = errors.New("user status must be active, disabled or locked")
+	ErrUserDisabled                   = errors.New("user is disabled")
+	ErrUserLocked                     = errors.New("user is locked by an administrator")
 )
+
+// TooManyAttemptsError is ErrTooManyAttempts with the time left until the next attempt is accepted.
+type TooManyAttemptsError struct {
+	RetryAfter time.Duration
+}
+
+func (e *TooManyAttemptsError) Error() string {
+	return ErrTooManyAttempts.Error()
+}
+
+func (e *TooManyAttemptsError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+// StepUpRequiredError is ErrStepUpRequired with the challenge the emailed code completes.
+type StepUpRequiredError struct {
+	ChallengeID string
+	ExpiresAt   time.Time
+}
+
+func (e *StepUpRequiredError) Error() string {
+	return ErrStepUpRequired.Error()
+}
+
+func (e *StepUpRequiredError) Unwrap() error {
+	return ErrStepUpRequired
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"github.com/sirupsen/logrus"
+	"math"
+	"medods-tz/internal/entity"
+	"medods-tz/pkg/geoip"
+	"net"
+	"time"
+)
+
+const earthRadiusKm = 6371
+
+// GeoLocator resolves IP addresses offline, *geoip.Reader implements it.
+type GeoLocator interface {
+	Lookup(ip net.IP) (geoip.Location, bool, error)
+}
+
+type GeoPolicyConfig struct {
+	// MaxTravelSpeed in km/h, a session moving faster between two refreshes travels impossibly.
+	MaxTravelSpeed float64
+}
+
+// LocationChange is the verdict of GeoPolicy on a session seen from another IP address.
+type LocationChange int
+
+const (
+	LocationSame LocationChange = iota
+	// LocationSameNetwork is another address in the same /24 (/64 for IPv6) or autonomous system.
+	LocationSameNetwork
+	LocationSameCountry
+	// LocationNew is a new country or an address that cannot be located.
+	LocationNew
+	// LocationImpossible cannot be reached in the elapsed time.
+	LocationImpossible
+)
+
+var locationChangeNames = map[LocationChange]string{
+	LocationSame:        "same",
+	LocationSameNetwork: "same_network",
+	LocationSameCountry: "same_country",
+	LocationNew:         "new",
+	LocationImpossible:  "impossible",
+}
+
+func (c LocationChange) String() string {
+	return locationChangeNames[c]
+}
+
+// GeoPolicy classifies how far a session moved when it is refreshed from another IP address.
+type GeoPolicy struct {
+	locator GeoLocator
+	config  GeoPolicyConfig
+	log     *logrus.Logger
+}
+
+// NewGeoPolicy creates the policy, without a locator every change of network counts as a new location.
+func NewGeoPolicy(locator GeoLocator, config GeoPolicyConfig, log *logrus.Logger) *GeoPolicy {
+	return &GeoPolicy{
+		locator: locator,
+		config:  config,
+		log:     log,
+	}
+}
+
+// Locate returns the location of ip, it is empty when ip is unknown or no databases are configured.
+func (p *GeoPolicy) Locate(ip string) entity.GeoLocation {
+	parsed := net.ParseIP(ip)
+	if p.locator == nil || parsed == nil {
+		return entity.GeoLocation{}
+	}
+
+	location, ok, err := p.locator.Lookup(parsed)
+	if err != nil {
+		p.log.Errorf("error while looking up location of ip=%s: %v", ip, err)
+		return entity.GeoLocation{}
+	}
+	if !ok {
+		return entity.GeoLocation{}
+	}
+
+	geoLocation := entity.GeoLocation{
+		Country: location.Country,
+		City:    location.City,
+		ASN:     location.ASN,
+	}
+	if location.HasCoordinates {
+		geoLocation.Latitude = &location.Latitude
+		geoLocation.Longitude = &location.Longitude
+		geoLocation.AccuracyRadius = location.AccuracyRadius
+	}
+
+	return geoLocation
+}
+
+// Assess compares the session of previous with a refresh from ip at location. The time since the
+// previous token was issued is the time the user had to travel.
+func (p *GeoPolicy) Assess(previous entity.RefreshToken, ip string, location entity.GeoLocation, now time.Time) LocationChange {
+	if ip == previous.ClientIP {
+		return LocationSame
+	}
+
+	if sameNetwork(ip, previous.ClientIP) || (location.ASN != 0 && location.ASN == previous.Location.ASN) {
+		return LocationSameNetwork
+	}
+
+	if distance, ok := Distance(previous.Location, location); ok && p.config.MaxTravelSpeed > 0 {
+		if distance > 0 && distance > p.config.MaxTravelSpeed*now.Sub(previous.IssuedAt).Hours() {
+			return LocationImpossible
+		}
+	}
+
+	if location.Country != "" && location.Country == previous.Location.Country {
+		return LocationSameCountry
+	}
+
+	return LocationNew
+}
+
+// Distance is the shortest distance in km the user may have moved between a and b, the accuracy
+// radius is how far the user may really be from the coordinates. It is false without coordinates.
+func Distance(a, b entity.GeoLocation) (float64, bool) {
+	if !hasCoordinates(a) || !hasCoordinates(b) {
+		return 0, false
+	}
+
+	distance := distanceKm(a, b) - float64(a.AccuracyRadius) - float64(b.AccuracyRadius)
+	return max(distance, 0), true
+}
+
+// sameNetwork reports whether both addresses are in one /24 for IPv4 or one /64 for IPv6.
+func sameNetwork(a, b string) bool {
+	ipA, ipB := net.ParseIP(a), net.ParseIP(b)
+	if ipA == nil || ipB == nil {
+		return false
+	}
+
+	if ipA4, ipB4 := ipA.To4(), ipB.To4(); ipA4 != nil || ipB4 != nil {
+		mask := net.CIDRMask(24, 32)
+		return ipA4 != nil && ipB4 != nil && ipA4.Mask(mask).Equal(ipB4.Mask(mask))
+	}
+
+	mask := net.CIDRMask(64, 128)
+	return ipA.Mask(mask).Equal(ipB.Mask(mask))
+}
+
+func hasCoordinates(location entity.GeoLocation) bool {
+	return location.Latitude != nil && location.Longitude != nil
+}
+
+// distanceKm is the great-circle distance by the haversine formula.
+func distanceKm(a, b entity.GeoLocation) float64 {
+	toRadians := func(degrees float64) float64 { return degrees * math.Pi / 180 }
+
+	latA, latB := toRadians(*a.Latitude), toRadians(*b.Latitude)
+	deltaLat := latB - latA
+	deltaLon := toRadians(*b.Longitude - *a.Longitude)
+
+	h := math.Sin(deltaLat/2)*math.Sin(deltaLat/2) +
+		math.Cos(latA)*math.Cos(latB)*math.Sin(deltaLon/2)*math.Sin(deltaLon/2)
+
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
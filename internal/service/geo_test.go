@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"medods-tz/internal/entity"
+	"medods-tz/pkg/geoip"
+	"net"
+	"testing"
+	"time"
+)
+
+type fakeGeoLocator map[string]geoip.Location
+
+func (l fakeGeoLocator) Lookup(ip net.IP) (geoip.Location, bool, error) {
+	location, ok := l[ip.String()]
+	return location, ok, nil
+}
+
+var testGeoLocator = fakeGeoLocator{
+	"203.0.113.7":  {Country: "DE", City: "Berlin", Latitude: 52.52, Longitude: 13.4, HasCoordinates: true, AccuracyRadius: 20, ASN: 64500},
+	"198.51.100.1": {Country: "DE", City: "Munich", Latitude: 48.14, Longitude: 11.58, HasCoordinates: true, AccuracyRadius: 20, ASN: 64501},
+	"192.0.2.1":    {Country: "RU", City: "Moscow", Latitude: 55.75, Longitude: 37.62, HasCoordinates: true, AccuracyRadius: 50, ASN: 64502},
+	"192.0.2.200":  {Country: "RU", ASN: 64503},
+	"100.64.0.1":   {Country: "DE", ASN: 64500},
+}
+
+func newTestGeoPolicy() *GeoPolicy {
+	return NewGeoPolicy(testGeoLocator, GeoPolicyConfig{MaxTravelSpeed: 1000}, logrus.New())
+}
+
+func TestGeoPolicy_Assess(t *testing.T) {
+	policy := newTestGeoPolicy()
+	now := time.Now()
+
+	for _, tc := range []struct {
+		name     string
+		previous string
+		ip       string
+		elapsed  time.Duration
+		expected LocationChange
+	}{
+		{"same address", "203.0.113.7", "203.0.113.7", time.Minute, LocationSame},
+		{"same /24", "203.0.113.7", "203.0.113.99", time.Minute, LocationSameNetwork},
+		{"same /64", "2001:db8::1", "2001:db8::ffff:1", time.Minute, LocationSameNetwork},
+		{"other /64", "2001:db8::1", "2001:db8:0:1::1", time.Minute, LocationNew},
+		{"same provider", "203.0.113.7", "100.64.0.1", time.Minute, LocationSameNetwork},
+		{"same country by train", "203.0.113.7", "198.51.100.1", 5 * time.Hour, LocationSameCountry},
+		{"same country too fast", "203.0.113.7", "198.51.100.1", time.Minute, LocationImpossible},
+		{"new country by plane", "203.0.113.7", "192.0.2.1", 5 * time.Hour, LocationNew},
+		{"new country too fast", "203.0.113.7", "192.0.2.1", 10 * time.Minute, LocationImpossible},
+		{"new country without coordinates", "203.0.113.7", "192.0.2.200", time.Minute, LocationNew},
+		{"unknown address", "203.0.113.7", "233.252.0.1", time.Minute, LocationNew},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			previous := entity.RefreshToken{
+				ClientIP: tc.previous,
+				Location: policy.Locate(tc.previous),
+				IssuedAt: now.Add(-tc.elapsed),
+			}
+
+			assert.Equal(t, tc.expected, policy.Assess(previous, tc.ip, policy.Locate(tc.ip), now))
+		})
+	}
+}
+
+func TestGeoPolicy_WithoutDatabases(t *testing.T) {
+	policy := NewGeoPolicy(nil, GeoPolicyConfig{MaxTravelSpeed: 1000}, logrus.New())
+	previous := entity.RefreshToken{ClientIP: "203.0.113.7", IssuedAt: time.Now()}
+
+	assert.Equal(t, entity.GeoLocation{}, policy.Locate("203.0.113.7"))
+	assert.Equal(t, LocationSameNetwork, policy.Assess(previous, "203.0.113.8", entity.GeoLocation{}, time.Now()))
+	assert.Equal(t, LocationNew, policy.Assess(previous, "192.0.2.1", entity.GeoLocation{}, time.Now()))
+}
+
+func TestAuth_RefreshTokens_StoresLocation(t *testing.T) {
+	ctx := context.Background()
+	mockUserRepo := new(mockUserRepo)
+	mockTokenRepo := new(mockTokenRepo)
+	mockNotifications := new(mockNotifications)
+	policy := newTestGeoPolicy()
+
+	auth := newTestAuth(AuthDependencies{UserRepo: mockUserRepo, TokenRepo: mockTokenRepo, Notifications: mockNotifications})
+
+	accessToken, _ := auth.generateAccessToken("203.0.113.7", "user-id", nil, nil, nil, "", time.Now().Add(auth.tokenTTL))
+
+	mockUserRepo.On("GetUserByID", ctx, "user-id").Return(&entity.User{ID: "user-id", Email: "test@example.com"}, nil)
+	mockTokenRepo.On("GetRefreshTokenEntitiesByUserID", ctx, "user-id").Return([]entity.RefreshToken{
+		{
+			ID:          "token-id",
+			UserID:      "user-id",
+			RefreshHash: string(hashRefreshToken("valid-refresh-token")),
+			ClientIP:    "203.0.113.7",
+			Location:    policy.Locate("203.0.113.7"),
+			IssuedAt:    time.Now().Add(-5 * time.Hour),
+			ExpiresAt:   time.Now().Add(time.Hour),
+		},
+	}, nil)
+	mockTokenRepo.On("MarkRefreshTokenUsed", ctx, "token-id").Return(nil)
+	mockTokenRepo.On("CreateRefreshToken", ctx, mock.MatchedBy(func(token entity.RefreshToken) bool {
+		return token.ClientIP == "198.51.100.1" && token.Location.City == "Munich" && token.Location.ASN == 64501
+	})).Return(nil)
+
+	tokens, err := auth.RefreshTokens(ctx, "valid-refresh-token", accessToken, "198.51.100.1", DeviceInput{})
+
+	assert.NoError(t, err)
+	assert.NotNil(t, tokens)
+	mockTokenRepo.AssertExpectations(t)
+	// a move within the country is not announced
+	mockNotifications.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,260 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository"
+	"medods-tz/internal/repository/repoerrors"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// IPRules keeps the addresses users, and the members of organizations, may sign in from. Users without
+// rules may sign in from anywhere.
+type IPRules struct {
+	ipRuleRepo  repository.IPRuleRepository
+	userRepo    repository.UserRepository
+	orgRepo     repository.OrganizationRepository
+	transactor  repository.Transactor
+	securityLog *logrus.Logger
+	audit       AuditService
+}
+
+func NewIPRules(
+	ipRuleRepo repository.IPRuleRepository,
+	userRepo repository.UserRepository,
+	orgRepo repository.OrganizationRepository,
+	transactor repository.Transactor,
+	securityLog *logrus.Logger,
+	audit AuditService) *IPRules {
+	return &IPRules{
+		ipRuleRepo:  ipRuleRepo,
+		userRepo:    userRepo,
+		orgRepo:     orgRepo,
+		transactor:  transactor,
+		securityLog: securityLog,
+		audit:       audit,
+	}
+}
+
+// Check returns ErrIPNotAllowed when the rules of the user, or those of organization orgID for sessions
+// of an organization, refuse clientIP. A deny rule refuses the addresses it covers, and allow rules refuse
+// every address none of them covers. The rules of the user and of the organization must both let it through.
+func (s *IPRules) Check(ctx context.Context, userID, orgID, clientIP, operation string) error {
+	rules, err := s.ipRuleRepo.ListIPRules(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("error while listing ip rules: %w", err)
+	}
+
+	rule, allowed := matchIPRules(rules, clientIP)
+	if allowed && orgID != "" {
+		rules, err = s.ListOrgIPRules(ctx, orgID)
+		if err != nil {
+			return err
+		}
+
+		rule, allowed = matchIPRules(rules, clientIP)
+	}
+	if allowed {
+		return nil
+	}
+
+	metadata := map[string]string{"operation": operation}
+	if orgID != "" {
+		metadata["org_id"] = orgID
+	}
+	if rule != nil {
+		metadata["ip_rule_id"] = rule.ID
+		metadata["cidr"] = rule.CIDR
+	}
+
+	s.securityLog.Warnf("%s of user_id=%s from ip=%s refused by ip rules", operation, userID, clientIP)
+	s.audit.Record(ctx, entity.AuditEvent{
+		Type:      entity.AuditIPNotAllowed,
+		SubjectID: userID,
+		IP:        clientIP,
+		Metadata:  metadata,
+	})
+
+	return ErrIPNotAllowed
+}
+
+// matchIPRules tells whether rules let clientIP through, and returns the deny rule that refused it.
+// Addresses that cannot be parsed are only let through when there are no allow rules.
+func matchIPRules(rules []entity.IPRule, clientIP string) (*entity.IPRule, bool) {
+	addr, err := netip.ParseAddr(clientIP)
+	if err == nil {
+		// IPv4 clients of dual stack listeners show up as ::ffff:a.b.c.d
+		addr = addr.Unmap()
+	}
+
+	hasAllowRules, allowed := false, false
+	for i, rule := range rules {
+		prefix, parseErr := netip.ParsePrefix(rule.CIDR)
+		matches := err == nil && parseErr == nil && prefix.Contains(addr)
+
+		switch rule.Action {
+		case entity.IPRuleDeny:
+			if matches {
+				return &rules[i], false
+			}
+		case entity.IPRuleAllow:
+			hasAllowRules = true
+			allowed = allowed || matches
+		}
+	}
+
+	return nil, allowed || !hasAllowRules
+}
+
+func (s *IPRules) ListIPRules(ctx context.Context, userID string) ([]entity.IPRule, error) {
+	rules, err := s.ipRuleRepo.ListIPRules(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error while listing ip rules: %w", err)
+	}
+
+	if rules == nil {
+		rules = []entity.IPRule{}
+	}
+
+	return rules, nil
+}
+
+func (s *IPRules) ListOrgIPRules(ctx context.Context, orgID string) ([]entity.IPRule, error) {
+	var rules []entity.IPRule
+	err := s.transactor.WithinTenant(ctx, orgID, func(ctx context.Context) error {
+		var err error
+		rules, err = s.ipRuleRepo.ListOrgIPRules(ctx, orgID)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error while listing ip rules of organization: %w", err)
+	}
+
+	if rules == nil {
+		rules = []entity.IPRule{}
+	}
+
+	return rules, nil
+}
+
+// CreateIPRule stores a rule of rule.UserID or, when it is set, of organization rule.OrgID. Single
+// addresses are accepted and stored as /32 or /128 ranges.
+func (s *IPRules) CreateIPRule(ctx context.Context, rule entity.IPRule) (*entity.IPRule, error) {
+	if rule.Action != entity.IPRuleAllow && rule.Action != entity.IPRuleDeny {
+		return nil, ErrInvalidIPRule
+	}
+
+	cidr, err := normalizeCIDR(rule.CIDR)
+	if err != nil {
+		return nil, ErrInvalidIPRule
+	}
+	rule.CIDR = cidr
+	rule.Description = strings.TrimSpace(rule.Description)
+	rule.CreatedAt = time.Now()
+
+	if rule.OrgID != "" {
+		rule.UserID = ""
+		err = s.transactor.WithinTenant(ctx, rule.OrgID, func(ctx context.Context) error {
+			_, err := s.orgRepo.GetOrganization(ctx, rule.OrgID)
+			if err != nil {
+				if errors.Is(err, repoerrors.ErrNotFound) {
+					return ErrOrganizationNotFound
+				}
+
+				return fmt.Errorf("error while getting organization: %w", err)
+			}
+
+			return s.createIPRule(ctx, &rule)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return &rule, nil
+	}
+
+	_, err = s.userRepo.GetUserByID(ctx, rule.UserID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return nil, ErrUserNotFound
+		}
+
+		return nil, fmt.Errorf("error while trying to find user: %w", err)
+	}
+
+	err = s.createIPRule(ctx, &rule)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rule, nil
+}
+
+func (s *IPRules) createIPRule(ctx context.Context, rule *entity.IPRule) error {
+	id, err := s.ipRuleRepo.CreateIPRule(ctx, *rule)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrAlreadyExists) {
+			return ErrIPRuleAlreadyExists
+		}
+
+		return fmt.Errorf("error while creating ip rule: %w", err)
+	}
+	rule.ID = id
+
+	return nil
+}
+
+func (s *IPRules) DeleteIPRule(ctx context.Context, userID, id string) error {
+	err := s.ipRuleRepo.DeleteIPRule(ctx, userID, id)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrIPRuleNotFound
+		}
+
+		return fmt.Errorf("error while deleting ip rule: %w", err)
+	}
+
+	return nil
+}
+
+func (s *IPRules) DeleteOrgIPRule(ctx context.Context, orgID, id string) error {
+	return s.transactor.WithinTenant(ctx, orgID, func(ctx context.Context) error {
+		err := s.ipRuleRepo.DeleteOrgIPRule(ctx, orgID, id)
+		if err != nil {
+			if errors.Is(err, repoerrors.ErrNotFound) {
+				return ErrIPRuleNotFound
+			}
+
+			return fmt.Errorf("error while deleting ip rule: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// normalizeCIDR returns the canonical form of an IPv4 or IPv6 range or address, host bits are cleared.
+func normalizeCIDR(value string) (string, error) {
+	value = strings.TrimSpace(value)
+
+	if !strings.Contains(value, "/") {
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return "", err
+		}
+		addr = addr.Unmap()
+
+		return netip.PrefixFrom(addr, addr.BitLen()).String(), nil
+	}
+
+	prefix, err := netip.ParsePrefix(value)
+	if err != nil {
+		return "", err
+	}
+
+	return prefix.Masked().String(), nil
+}
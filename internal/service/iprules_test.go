@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"medods-tz/internal/entity"
+	"testing"
+)
+
+func newTestIPRules() *IPRules {
+	mockIPRuleRepo := new(mockIPRuleRepo)
+	mockIPRuleRepo.On("ListIPRules", mock.Anything, mock.Anything).Return([]entity.IPRule(nil), nil).Maybe()
+	mockIPRuleRepo.On("ListOrgIPRules", mock.Anything, mock.Anything).Return([]entity.IPRule(nil), nil).Maybe()
+
+	return NewIPRules(mockIPRuleRepo, new(mockUserRepo), new(mockOrganizationRepo), mockTransactor{}, logrus.New(), newTestAudit())
+}
+
+func TestMatchIPRules(t *testing.T) {
+	office := []entity.IPRule{
+		{ID: "office-v4", Action: entity.IPRuleAllow, CIDR: "203.0.113.0/24"},
+		{ID: "office-v6", Action: entity.IPRuleAllow, CIDR: "2001:db8:10::/48"},
+		{ID: "guest-wifi", Action: entity.IPRuleDeny, CIDR: "203.0.113.128/25"},
+	}
+	denyOnly := []entity.IPRule{
+		{ID: "tor-exit", Action: entity.IPRuleDeny, CIDR: "198.51.100.7/32"},
+	}
+
+	for _, tc := range []struct {
+		name      string
+		rules     []entity.IPRule
+		ip        string
+		allowed   bool
+		refusedBy string
+	}{
+		{"no rules", nil, "198.51.100.7", true, ""},
+		{"inside an allow rule", office, "203.0.113.10", true, ""},
+		{"inside an ipv6 allow rule", office, "2001:db8:10:1::5", true, ""},
+		{"ipv4 mapped ipv6 address", office, "::ffff:203.0.113.10", true, ""},
+		{"outside the allow rules", office, "198.51.100.7", false, ""},
+		{"outside the ipv6 allow rule", office, "2001:db8:11::5", false, ""},
+		{"deny rule inside an allow rule", office, "203.0.113.200", false, "guest-wifi"},
+		{"unparsable address", office, "unknown", false, ""},
+		{"deny rule only", denyOnly, "198.51.100.7", false, "tor-exit"},
+		{"outside the deny rules", denyOnly, "198.51.100.8", true, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rule, allowed := matchIPRules(tc.rules, tc.ip)
+
+			assert.Equal(t, tc.allowed, allowed)
+			if tc.refusedBy == "" {
+				assert.Nil(t, rule)
+			} else if assert.NotNil(t, rule) {
+				assert.Equal(t, tc.refusedBy, rule.ID)
+			}
+		})
+	}
+}
+
+func TestIPRules_CreateIPRule(t *testing.T) {
+	ctx := context.Background()
+	mockIPRuleRepo := new(mockIPRuleRepo)
+	mockUserRepo := new(mockUserRepo)
+	ipRules := NewIPRules(mockIPRuleRepo, mockUserRepo, new(mockOrganizationRepo), mockTransactor{}, logrus.New(), newTestAudit())
+
+	mockUserRepo.On("GetUserByID", ctx, "user-id").Return(&entity.User{ID: "user-id"}, nil)
+	mockIPRuleRepo.On("CreateIPRule", ctx, mock.Anything).Return("rule-id", nil)
+
+	for _, tc := range []struct {
+		cidr     string
+		expected string
+	}{
+		{"203.0.113.10", "203.0.113.10/32"},
+		{"203.0.113.10/24", "203.0.113.0/24"},
+		{" 2001:db8::1 ", "2001:db8::1/128"},
+		{"2001:db8:10::1/48", "2001:db8:10::/48"},
+	} {
+		rule, err := ipRules.CreateIPRule(ctx, entity.IPRule{UserID: "user-id", Action: entity.IPRuleAllow, CIDR: tc.cidr})
+
+		if assert.NoError(t, err, tc.cidr) {
+			assert.Equal(t, tc.expected, rule.CIDR)
+			assert.Equal(t, "rule-id", rule.ID)
+		}
+	}
+
+	for _, rule := range []entity.IPRule{
+		{UserID: "user-id", Action: entity.IPRuleAllow, CIDR: "203.0.113.0/33"},
+		{UserID: "user-id", Action: entity.IPRuleAllow, CIDR: "office"},
+		{UserID: "user-id", Action: "block", CIDR: "203.0.113.0/24"},
+	} {
+		_, err := ipRules.CreateIPRule(ctx, rule)
+		assert.ErrorIs(t, err, ErrInvalidIPRule)
+	}
+}
+
+func TestAuth_CreateTokens_IPNotAllowed(t *testing.T) {
+	ctx := context.Background()
+	mockUserRepo := new(mockUserRepo)
+	mockTokenRepo := new(mockTokenRepo)
+	mockIPRuleRepo := new(mockIPRuleRepo)
+	mockAuditRepo := new(mockAuditRepo)
+
+	audit := NewAudit(mockAuditRepo, "test-sign-key", logrus.New(), nil)
+	ipRules := NewIPRules(mockIPRuleRepo, mockUserRepo, new(mockOrganizationRepo), mockTransactor{}, logrus.New(), audit)
+	auth := newTestAuth(AuthDependencies{UserRepo: mockUserRepo, TokenRepo: mockTokenRepo, IPRules: ipRules})
+
+	mockUserRepo.On("GetUserByID", ctx, "user-id").Return(&entity.User{ID: "user-id", Email: "test@example.com"}, nil)
+	mockIPRuleRepo.On("ListIPRules", ctx, "user-id").Return([]entity.IPRule{
+		{ID: "office", UserID: "user-id", Action: entity.IPRuleAllow, CIDR: "203.0.113.0/24"},
+	}, nil)
+	mockAuditRepo.On("CreateAuditEvent", ctx, mock.MatchedBy(func(event entity.AuditEvent) bool {
+		return event.Type == entity.AuditIPNotAllowed && event.SubjectID == "user-id" && event.IP == "198.51.100.7"
+	})).Return(int64(1), nil)
+	mockTokenRepo.On("CreateRefreshToken", ctx, mock.Anything).Return(nil)
+
+	tokens, err := auth.CreateTokens(ctx, "user-id", "", "198.51.100.7", DeviceInput{})
+
+	assert.Nil(t, tokens)
+	assert.ErrorIs(t, err, ErrIPNotAllowed)
+	mockAuditRepo.AssertExpectations(t)
+	mockTokenRepo.AssertNotCalled(t, "CreateRefreshToken", mock.Anything, mock.Anything)
+
+	tokens, err = auth.CreateTokens(ctx, "user-id", "", "203.0.113.10", DeviceInput{})
+
+	assert.NoError(t, err)
+	assert.NotNil(t, tokens)
+}
+
+func TestIPRules_Check_Organization(t *testing.T) {
+	ctx := context.Background()
+	mockIPRuleRepo := new(mockIPRuleRepo)
+	ipRules := NewIPRules(mockIPRuleRepo, new(mockUserRepo), new(mockOrganizationRepo), mockTransactor{}, logrus.New(), newTestAudit())
+
+	mockIPRuleRepo.On("ListIPRules", ctx, "user-id").Return([]entity.IPRule{
+		{ID: "home", UserID: "user-id", Action: entity.IPRuleAllow, CIDR: "198.51.100.0/24"},
+		{ID: "office", UserID: "user-id", Action: entity.IPRuleAllow, CIDR: "203.0.113.0/24"},
+	}, nil)
+	mockIPRuleRepo.On("ListOrgIPRules", ctx, "org-id").Return([]entity.IPRule{
+		{ID: "org-office", OrgID: "org-id", Action: entity.IPRuleAllow, CIDR: "203.0.113.0/24"},
+	}, nil)
+
+	// personal sessions only follow the rules of the user
+	assert.NoError(t, ipRules.Check(ctx, "user-id", "", "198.51.100.7", RiskOperationLogin))
+	// sessions of the organization follow both
+	assert.ErrorIs(t, ipRules.Check(ctx, "user-id", "org-id", "198.51.100.7", RiskOperationLogin), ErrIPNotAllowed)
+	assert.NoError(t, ipRules.Check(ctx, "user-id", "org-id", "203.0.113.7", RiskOperationLogin))
+	assert.ErrorIs(t, ipRules.Check(ctx, "user-id", "org-id", "192.0.2.7", RiskOperationLogin), ErrIPNotAllowed)
+}
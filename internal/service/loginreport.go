@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository/repoerrors"
+	"time"
+)
+
+const loginReportAudience = "login_report"
+
+// LoginReportConfig configures the "this wasn't me" link of suspicious login emails.
+type LoginReportConfig struct {
+	// TokenTTL should cover the time the email may wait in the outbox and in the inbox.
+	TokenTTL time.Duration
+	LinkURL  string
+}
+
+type loginReportClaims struct {
+	jwt.StandardClaims
+	// IP is the address of the reported sign-in.
+	IP string `json:"ip"`
+}
+
+// newLoginReportLink signs a link reporting the sign-in of the user from ip. The token id makes it single-use.
+func newLoginReportLink(signKey string, config LoginReportConfig, userID, ip string) (string, error) {
+	tokenID := make([]byte, 16)
+	_, err := rand.Read(tokenID)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := loginReportClaims{
+		StandardClaims: jwt.StandardClaims{
+			Audience:  loginReportAudience,
+			Id:        hex.EncodeToString(tokenID),
+			Subject:   userID,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(config.TokenTTL).Unix(),
+		},
+		IP: ip,
+	}
+	reportToken, err := jwt.NewWithClaims(jwt.SigningMethodHS512, claims).SignedString([]byte(signKey))
+	if err != nil {
+		return "", err
+	}
+
+	return buildLink(config.LinkURL, reportToken)
+}
+
+// ReportSuspiciousLogin handles the "this wasn't me" link: it signs out every session of the user
+// and, when the user has a password, refuses password logins until it is reset and sends a reset
+// link. All of it is stored together with the report, which spends the link.
+func (s *Account) ReportSuspiciousLogin(ctx context.Context, reportToken string) error {
+	claims := &loginReportClaims{}
+	_, err := jwt.ParseWithClaims(reportToken, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+
+		return []byte(s.signKey), nil
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidLoginReportToken, err)
+	}
+
+	if !claims.VerifyAudience(loginReportAudience, true) || claims.Subject == "" || claims.Id == "" {
+		return ErrInvalidLoginReportToken
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, claims.Subject)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrUserNotFound
+		}
+
+		return fmt.Errorf("error while trying to find user: %w", err)
+	}
+
+	passwordReset := user.PasswordHash != ""
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		err := s.loginReportRepo.CreateLoginReport(ctx, entity.LoginReport{
+			TokenID:    claims.Id,
+			UserID:     user.ID,
+			IP:         claims.IP,
+			ReportedAt: time.Now(),
+		})
+		if err != nil {
+			if errors.Is(err, repoerrors.ErrAlreadyExists) {
+				return ErrInvalidLoginReportToken
+			}
+
+			return fmt.Errorf("error while storing login report: %w", err)
+		}
+
+		// the sessions of the organizations of the user go too
+		err = s.transactor.WithinAllTenants(ctx, func(ctx context.Context) error {
+			return s.tokenRepo.RevokeRefreshTokensByUserID(ctx, user.ID)
+		})
+		if err != nil {
+			return fmt.Errorf("error while revoking refresh tokens: %w", err)
+		}
+
+		if passwordReset {
+			err = s.userRepo.SetPasswordResetRequired(ctx, user.ID, true)
+			if err != nil {
+				return fmt.Errorf("error while requiring password reset: %w", err)
+			}
+
+			err = s.sendPasswordResetEmail(ctx, user)
+			if err != nil {
+				return err
+			}
+		}
+
+		return s.webhooks.Enqueue(ctx, entity.WebhookSessionRevoked, map[string]string{
+			"user_id": user.ID,
+			"reason":  "login_reported",
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	s.securityLog.Warnf("user_id=%s reported the sign-in from ip=%s, all refresh tokens revoked", user.ID, claims.IP)
+	s.audit.Record(ctx, entity.AuditEvent{
+		Type:      entity.AuditLoginReported,
+		SubjectID: user.ID,
+		IP:        claims.IP,
+		Metadata: map[string]string{
+			"report_token_id":         claims.Id,
+			"password_reset_required": fmt.Sprint(passwordReset),
+		},
+	})
+
+	return nil
+}
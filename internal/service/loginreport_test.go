@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository/repoerrors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+var testLoginReportConfig = LoginReportConfig{
+	TokenTTL: time.Hour,
+	LinkURL:  "http://localhost/report-login",
+}
+
+func newTestLoginReportAccount(userRepo *mockUserRepo, tokenRepo *mockTokenRepo, passwordResetRepo *mockPasswordResetRepo,
+	loginReportRepo *mockLoginReportRepo, emailSender *mockEmail) *Account {
+	return NewAccount(userRepo, tokenRepo, passwordResetRepo, loginReportRepo, mockTransactor{}, "test-sign-key",
+		time.Hour, "http://localhost/verify-email", time.Minute*30, "http://localhost/reset-password", logrus.New(), newTestAudit(), newTestWebhooks(), emailSender, newTestPasswordHasher(), newTestPasswordPolicy())
+}
+
+func testLoginReportToken(t *testing.T, userID, ip string) string {
+	link, err := newLoginReportLink("test-sign-key", testLoginReportConfig, userID, ip)
+	assert.NoError(t, err)
+
+	parsed, err := url.Parse(link)
+	assert.NoError(t, err)
+
+	return parsed.Query().Get("token")
+}
+
+func TestAccount_ReportSuspiciousLogin(t *testing.T) {
+	ctx := context.Background()
+	mockUserRepo := new(mockUserRepo)
+	mockTokenRepo := new(mockTokenRepo)
+	mockPasswordResetRepo := new(mockPasswordResetRepo)
+	mockLoginReportRepo := new(mockLoginReportRepo)
+	mockEmail := new(mockEmail)
+	account := newTestLoginReportAccount(mockUserRepo, mockTokenRepo, mockPasswordResetRepo, mockLoginReportRepo, mockEmail)
+
+	mockUserRepo.On("GetUserByID", ctx, "user-id").Return(&entity.User{ID: "user-id", Email: "test@example.com", PasswordHash: "hash"}, nil)
+	mockLoginReportRepo.On("CreateLoginReport", ctx, mock.MatchedBy(func(report entity.LoginReport) bool {
+		return report.UserID == "user-id" && report.IP == "203.0.113.7" && report.TokenID != ""
+	})).Return(nil)
+	mockTokenRepo.On("RevokeRefreshTokensByUserID", ctx, "user-id").Return(nil)
+	mockUserRepo.On("SetPasswordResetRequired", ctx, "user-id", true).Return(nil)
+	mockPasswordResetRepo.On("CreatePasswordResetToken", ctx, mock.Anything).Return(nil)
+	mockEmail.On("SendPasswordResetEmail", ctx, mock.Anything, mock.Anything).Return(nil)
+
+	err := account.ReportSuspiciousLogin(ctx, testLoginReportToken(t, "user-id", "203.0.113.7"))
+
+	assert.NoError(t, err)
+	mockLoginReportRepo.AssertExpectations(t)
+	mockTokenRepo.AssertExpectations(t)
+	mockUserRepo.AssertExpectations(t)
+	mockEmail.AssertExpectations(t)
+}
+
+func TestAccount_ReportSuspiciousLogin_WithoutPassword(t *testing.T) {
+	ctx := context.Background()
+	mockUserRepo := new(mockUserRepo)
+	mockTokenRepo := new(mockTokenRepo)
+	mockLoginReportRepo := new(mockLoginReportRepo)
+	mockEmail := new(mockEmail)
+	account := newTestLoginReportAccount(mockUserRepo, mockTokenRepo, new(mockPasswordResetRepo), mockLoginReportRepo, mockEmail)
+
+	mockUserRepo.On("GetUserByID", ctx, "user-id").Return(&entity.User{ID: "user-id", Email: "test@example.com"}, nil)
+	mockLoginReportRepo.On("CreateLoginReport", ctx, mock.Anything).Return(nil)
+	mockTokenRepo.On("RevokeRefreshTokensByUserID", ctx, "user-id").Return(nil)
+
+	err := account.ReportSuspiciousLogin(ctx, testLoginReportToken(t, "user-id", "203.0.113.7"))
+
+	assert.NoError(t, err)
+	mockTokenRepo.AssertExpectations(t)
+	mockUserRepo.AssertNotCalled(t, "SetPasswordResetRequired", mock.Anything, mock.Anything, mock.Anything)
+	mockEmail.AssertNotCalled(t, "SendPasswordResetEmail", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAccount_ReportSuspiciousLogin_SingleUse(t *testing.T) {
+	ctx := context.Background()
+	mockUserRepo := new(mockUserRepo)
+	mockTokenRepo := new(mockTokenRepo)
+	mockLoginReportRepo := new(mockLoginReportRepo)
+	account := newTestLoginReportAccount(mockUserRepo, mockTokenRepo, new(mockPasswordResetRepo), mockLoginReportRepo, new(mockEmail))
+
+	mockUserRepo.On("GetUserByID", ctx, "user-id").Return(&entity.User{ID: "user-id", Email: "test@example.com", PasswordHash: "hash"}, nil)
+	mockLoginReportRepo.On("CreateLoginReport", ctx, mock.Anything).Return(repoerrors.ErrAlreadyExists)
+
+	err := account.ReportSuspiciousLogin(ctx, testLoginReportToken(t, "user-id", "203.0.113.7"))
+
+	assert.ErrorIs(t, err, ErrInvalidLoginReportToken)
+	mockTokenRepo.AssertNotCalled(t, "RevokeRefreshTokensByUserID", mock.Anything, mock.Anything)
+}
+
+func TestAccount_ReportSuspiciousLogin_RejectsOtherTokens(t *testing.T) {
+	ctx := context.Background()
+	mockUserRepo := new(mockUserRepo)
+	account := newTestLoginReportAccount(mockUserRepo, new(mockTokenRepo), new(mockPasswordResetRepo), new(mockLoginReportRepo), new(mockEmail))
+
+	auth := newTestAuth(AuthDependencies{UserRepo: mockUserRepo})
+	accessToken, err := auth.generateAccessToken("127.0.0.1", "user-id", nil, nil, nil, "", time.Now().Add(auth.tokenTTL))
+	assert.NoError(t, err)
+
+	for _, token := range []string{accessToken, testLoginReportToken(t, "user-id", "203.0.113.7") + "x"} {
+		assert.ErrorIs(t, account.ReportSuspiciousLogin(ctx, token), ErrInvalidLoginReportToken)
+	}
+	mockUserRepo.AssertNotCalled(t, "GetUserByID", mock.Anything, mock.Anything)
+}
+
+func TestAuth_Login_PasswordResetRequired(t *testing.T) {
+	ctx := context.Background()
+	mockUserRepo := new(mockUserRepo)
+	mockTokenRepo := new(mockTokenRepo)
+	passwordHasher := newTestPasswordHasher()
+	auth := newTestAuth(AuthDependencies{UserRepo: mockUserRepo, TokenRepo: mockTokenRepo, PasswordHasher: passwordHasher})
+
+	passwordHash, err := passwordHasher.Hash("correct-Horse-7")
+	assert.NoError(t, err)
+	mockUserRepo.On("GetUserByEmail", ctx, "test@example.com").Return(&entity.User{
+		ID: "user-id", Email: "test@example.com", PasswordHash: passwordHash, PasswordResetRequired: true,
+	}, nil)
+
+	tokens, err := auth.Login(ctx, "test@example.com", "correct-Horse-7", "", "127.0.0.1", DeviceInput{})
+
+	assert.ErrorIs(t, err, ErrPasswordResetRequired)
+	assert.Nil(t, tokens)
+	mockTokenRepo.AssertNotCalled(t, "CreateRefreshToken", mock.Anything, mock.Anything)
+}
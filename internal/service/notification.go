@@ -0,0 +1,295 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository"
+	"medods-tz/internal/repository/repoerrors"
+	"medods-tz/internal/sender"
+	"regexp"
+	"slices"
+	"time"
+)
+
+// notificationSeverities are the severities of the security notifications, by template.
+var notificationSeverities = map[string]string{
+	sender.TemplateSuspiciousLogin: entity.SeverityWarning,
+	sender.TemplateNewDevice:       entity.SeverityInfo,
+}
+
+var phoneNumberRegexp = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+// defaultNotificationPreferences apply to users who have not chosen any channel.
+var defaultNotificationPreferences = []entity.NotificationPreference{
+	{Channel: sender.ChannelEmail, MinSeverity: entity.SeverityInfo},
+}
+
+type NotificationConfig struct {
+	// Channels are the configured channels and the lowest severity each carries, whatever users prefer.
+	Channels map[string]string
+	// ThrottleWindow is how long a notification suppresses later ones of the same template and key.
+	ThrottleWindow time.Duration
+	// BurstLimit notifications of one template per window are sent right away, later ones wait for
+	// a digest that is sent DigestDelay after the first of them. Zero disables digests.
+	BurstLimit  int
+	DigestDelay time.Duration
+}
+
+type Notification struct {
+	// Template is the name of the template, it also determines the severity.
+	Template string
+	Data     any
+	// Key identifies what the notification is about, such as the IP of a suspicious login. Only
+	// notifications with a key are throttled.
+	Key string
+}
+
+// Notifier routes security notifications to the channels the user chose, as far as the severity of
+// the notification reaches the minimum of both the user and the channel. Notifications are queued in
+// the outbox, so they are sent with the change they announce.
+//
+// A notification is sent once per throttle window for the same template and key, and a burst of
+// notifications is summarized in a digest. The throttle state is stored, so restarts do not reset it.
+type Notifier struct {
+	notificationRepo repository.NotificationRepository
+	userRepo         repository.UserRepository
+	transactor       repository.Transactor
+	outbox           *EmailOutbox
+	config           NotificationConfig
+	securityLog      *logrus.Logger
+}
+
+func NewNotifier(
+	notificationRepo repository.NotificationRepository,
+	userRepo repository.UserRepository,
+	transactor repository.Transactor,
+	outbox *EmailOutbox,
+	config NotificationConfig,
+	securityLog *logrus.Logger) *Notifier {
+	return &Notifier{
+		notificationRepo: notificationRepo,
+		userRepo:         userRepo,
+		transactor:       transactor,
+		outbox:           outbox,
+		config:           config,
+		securityLog:      securityLog,
+	}
+}
+
+func (s *Notifier) Notify(ctx context.Context, user *entity.User, notification Notification) error {
+	severity, ok := notificationSeverities[notification.Template]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrEmailTemplateNotFound, notification.Template)
+	}
+
+	if notification.Key == "" || s.config.ThrottleWindow <= 0 {
+		return s.route(ctx, user, severity, notification.Template, notification.Data)
+	}
+
+	now := time.Now()
+	windowStart := now.Add(-s.config.ThrottleWindow)
+	first, err := s.notificationRepo.ThrottleNotification(ctx, user.ID, notification.Template, notification.Key, now, windowStart)
+	if err != nil {
+		return fmt.Errorf("error while throttling notification: %w", err)
+	}
+	if !first {
+		s.securityLog.Infof("%s notification for user_id=%s key=%s suppressed, one was sent within %s",
+			notification.Template, user.ID, notification.Key, s.config.ThrottleWindow)
+		return nil
+	}
+
+	if s.config.BurstLimit > 0 {
+		// the count includes this notification
+		count, err := s.notificationRepo.CountNotificationsSince(ctx, user.ID, notification.Template, windowStart)
+		if err != nil {
+			return fmt.Errorf("error while counting notifications: %w", err)
+		}
+		if count > s.config.BurstLimit {
+			return s.holdForDigest(ctx, user, severity, notification, now)
+		}
+	}
+
+	return s.route(ctx, user, severity, notification.Template, notification.Data)
+}
+
+// holdForDigest stores the subject of the notification for the next digest of the user.
+func (s *Notifier) holdForDigest(ctx context.Context, user *entity.User, severity string, notification Notification, now time.Time) error {
+	message, err := s.outbox.render(notification.Template, user.Locale, notification.Data)
+	if err != nil {
+		return err
+	}
+
+	err = s.notificationRepo.CreateNotificationDigestItem(ctx, entity.NotificationDigestItem{
+		UserID:    user.ID,
+		Template:  notification.Template,
+		Severity:  severity,
+		Subject:   message.Subject,
+		CreatedAt: now,
+	})
+	if err != nil {
+		return fmt.Errorf("error while holding notification for digest: %w", err)
+	}
+
+	return nil
+}
+
+// SendDigests sends a digest to every user whose oldest held back notification is DigestDelay old,
+// with the highest severity among its notifications. It also forgets throttle state of past windows.
+func (s *Notifier) SendDigests(ctx context.Context) error {
+	now := time.Now()
+	userIDs, err := s.notificationRepo.ListDueNotificationDigests(ctx, now.Add(-s.config.DigestDelay))
+	if err != nil {
+		return fmt.Errorf("error while listing due digests: %w", err)
+	}
+
+	for _, userID := range userIDs {
+		if err := s.sendDigest(ctx, userID); err != nil {
+			s.securityLog.Errorf("error while sending notification digest to user_id=%s: %v", userID, err)
+		}
+	}
+
+	err = s.notificationRepo.DeleteNotificationThrottles(ctx, now.Add(-s.config.ThrottleWindow))
+	if err != nil {
+		return fmt.Errorf("error while deleting notification throttles: %w", err)
+	}
+
+	return nil
+}
+
+// sendDigest takes the held back notifications and queues their digest in one transaction, so a
+// failure keeps them for the next run.
+func (s *Notifier) sendDigest(ctx context.Context, userID string) error {
+	return s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		items, err := s.notificationRepo.TakeNotificationDigestItems(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("error while taking digest items: %w", err)
+		}
+		if len(items) == 0 {
+			return nil
+		}
+
+		user, err := s.userRepo.GetUserByID(ctx, userID)
+		if err != nil {
+			if errors.Is(err, repoerrors.ErrNotFound) {
+				return nil
+			}
+
+			return fmt.Errorf("error while getting user: %w", err)
+		}
+
+		severity := entity.SeverityInfo
+		data := sender.DigestData{}
+		for _, item := range items {
+			if entity.SeverityRank(item.Severity) > entity.SeverityRank(severity) {
+				severity = item.Severity
+			}
+			data.Items = append(data.Items, sender.DigestItem{Subject: item.Subject, Time: item.CreatedAt})
+		}
+
+		return s.route(ctx, user, severity, sender.TemplateSecurityDigest, data)
+	})
+}
+
+// route queues the notification for every channel of the user its severity reaches.
+func (s *Notifier) route(ctx context.Context, user *entity.User, severity, template string, data any) error {
+	preferences, err := s.GetPreferences(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	var message *sender.Message
+	for _, preference := range preferences {
+		channelMinSeverity, enabled := s.config.Channels[preference.Channel]
+		if !enabled {
+			continue
+		}
+
+		rank := entity.SeverityRank(severity)
+		if rank < entity.SeverityRank(preference.MinSeverity) || rank < entity.SeverityRank(channelMinSeverity) {
+			continue
+		}
+
+		if message == nil {
+			rendered, err := s.outbox.render(template, user.Locale, data)
+			if err != nil {
+				return err
+			}
+			message = &rendered
+		}
+
+		address := preference.Address
+		if preference.Channel == sender.ChannelEmail {
+			address = user.Email
+		}
+
+		err = s.outbox.queue(ctx, preference.Channel, address, *message)
+		if err != nil {
+			return fmt.Errorf("error while queueing %s notification: %w", preference.Channel, err)
+		}
+	}
+
+	if message == nil {
+		s.securityLog.Infof("%s notification for user_id=%s matched no channel", template, user.ID)
+	}
+
+	return nil
+}
+
+// Channels returns the configured channels and the lowest severity each carries.
+func (s *Notifier) Channels() map[string]string {
+	return s.config.Channels
+}
+
+// GetPreferences returns the channels of the user, the defaults when the user has not chosen any.
+func (s *Notifier) GetPreferences(ctx context.Context, userID string) ([]entity.NotificationPreference, error) {
+	preferences, err := s.notificationRepo.ListNotificationPreferences(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting notification preferences: %w", err)
+	}
+
+	if len(preferences) == 0 {
+		return defaultNotificationPreferences, nil
+	}
+
+	return preferences, nil
+}
+
+// SetPreferences replaces the channels of the user, no channels restore the defaults. Email
+// notifications always go to the user's own address, so that a stolen session cannot redirect them.
+func (s *Notifier) SetPreferences(ctx context.Context, userID string, preferences []entity.NotificationPreference) error {
+	var channels []string
+	now := time.Now()
+	for i, preference := range preferences {
+		if _, enabled := s.config.Channels[preference.Channel]; !enabled {
+			return fmt.Errorf("%w: %s", ErrNotificationChannelUnavailable, preference.Channel)
+		}
+		if slices.Contains(channels, preference.Channel) {
+			return fmt.Errorf("%w: %s is listed twice", ErrInvalidNotificationPreference, preference.Channel)
+		}
+		if entity.SeverityRank(preference.MinSeverity) == 0 {
+			return fmt.Errorf("%w: unknown severity %q", ErrInvalidNotificationPreference, preference.MinSeverity)
+		}
+
+		switch {
+		case preference.Channel == sender.ChannelEmail && preference.Address != "":
+			return fmt.Errorf("%w: email notifications go to the account email, address must be empty", ErrInvalidNotificationPreference)
+		case preference.Channel != sender.ChannelEmail && preference.Address == "":
+			return fmt.Errorf("%w: %s requires an address", ErrInvalidNotificationPreference, preference.Channel)
+		case preference.Channel == sender.ChannelSMS && !phoneNumberRegexp.MatchString(preference.Address):
+			return fmt.Errorf("%w: sms address must be a phone number in E.164 format", ErrInvalidNotificationPreference)
+		}
+
+		channels = append(channels, preference.Channel)
+		preferences[i].UpdatedAt = now
+	}
+
+	err := s.notificationRepo.ReplaceNotificationPreferences(ctx, userID, preferences)
+	if err != nil {
+		return fmt.Errorf("error while saving notification preferences: %w", err)
+	}
+
+	return nil
+}
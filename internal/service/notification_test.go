@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/sender"
+	"testing"
+	"time"
+)
+
+var testNotificationConfig = NotificationConfig{
+	Channels: map[string]string{
+		sender.ChannelEmail:    entity.SeverityInfo,
+		sender.ChannelTelegram: entity.SeverityInfo,
+		sender.ChannelSMS:      entity.SeverityCritical,
+	},
+	ThrottleWindow: time.Hour,
+	BurstLimit:     2,
+	DigestDelay:    15 * time.Minute,
+}
+
+func newTestNotifier(t *testing.T, notificationRepo *mockNotificationRepo, emailRepo *mockEmailRepo) *Notifier {
+	return newTestNotifierWithUsers(t, notificationRepo, new(mockUserRepo), emailRepo)
+}
+
+func newTestNotifierWithUsers(t *testing.T, notificationRepo *mockNotificationRepo, userRepo *mockUserRepo, emailRepo *mockEmailRepo) *Notifier {
+	outbox := NewEmailOutbox(emailRepo, nil, newTestEmailTemplates(t), testEmailOutboxConfig, logrus.New())
+	return NewNotifier(notificationRepo, userRepo, mockTransactor{}, outbox, testNotificationConfig, logrus.New())
+}
+
+func TestNotifier_Notify_RoutesBySeverity(t *testing.T) {
+	ctx := context.Background()
+	mockNotificationRepo := new(mockNotificationRepo)
+	mockEmailRepo := new(mockEmailRepo)
+	notifier := newTestNotifier(t, mockNotificationRepo, mockEmailRepo)
+	user := &entity.User{ID: "user-id", Email: "test@example.com", Locale: "ru"}
+
+	mockNotificationRepo.On("ListNotificationPreferences", ctx, "user-id").Return([]entity.NotificationPreference{
+		{Channel: sender.ChannelEmail, MinSeverity: entity.SeverityCritical},
+		{Channel: sender.ChannelTelegram, Address: "123456", MinSeverity: entity.SeverityWarning},
+		// below the minimum of the channel
+		{Channel: sender.ChannelSMS, Address: "+15555550100", MinSeverity: entity.SeverityInfo},
+		// no longer configured
+		{Channel: sender.ChannelSlack, Address: "C123", MinSeverity: entity.SeverityInfo},
+	}, nil)
+	var queued []entity.EmailMessage
+	mockEmailRepo.On("CreateEmailMessage", ctx, mock.Anything).
+		Run(func(args mock.Arguments) { queued = append(queued, args.Get(1).(entity.EmailMessage)) }).
+		Return(int64(1), nil)
+
+	err := notifier.Notify(ctx, user, Notification{
+		Template: sender.TemplateSuspiciousLogin,
+		Data:     sender.SuspiciousLoginData{IP: "203.0.113.7", PreviousIP: "198.51.100.23", Time: time.Now()},
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, queued, 1)
+	assert.Equal(t, sender.ChannelTelegram, queued[0].Channel)
+	assert.Equal(t, "123456", queued[0].Recipient)
+	assert.Contains(t, queued[0].TextBody, "203.0.113.7")
+}
+
+func TestNotifier_Notify_DefaultsToEmail(t *testing.T) {
+	ctx := context.Background()
+	mockNotificationRepo := new(mockNotificationRepo)
+	mockEmailRepo := new(mockEmailRepo)
+	notifier := newTestNotifier(t, mockNotificationRepo, mockEmailRepo)
+
+	mockNotificationRepo.On("ListNotificationPreferences", ctx, "user-id").Return([]entity.NotificationPreference{}, nil)
+	mockEmailRepo.On("CreateEmailMessage", ctx, mock.MatchedBy(func(message entity.EmailMessage) bool {
+		return message.Channel == sender.ChannelEmail && message.Recipient == "test@example.com"
+	})).Return(int64(1), nil)
+
+	err := notifier.Notify(ctx, &entity.User{ID: "user-id", Email: "test@example.com"}, Notification{
+		Template: sender.TemplateSuspiciousLogin,
+		Data:     sender.SuspiciousLoginData{IP: "203.0.113.7", Time: time.Now()},
+	})
+
+	assert.NoError(t, err)
+	mockEmailRepo.AssertNumberOfCalls(t, "CreateEmailMessage", 1)
+}
+
+func TestNotifier_Notify_SuppressesDuplicate(t *testing.T) {
+	ctx := context.Background()
+	mockNotificationRepo := new(mockNotificationRepo)
+	mockEmailRepo := new(mockEmailRepo)
+	notifier := newTestNotifier(t, mockNotificationRepo, mockEmailRepo)
+
+	mockNotificationRepo.On("ThrottleNotification", ctx, "user-id", sender.TemplateSuspiciousLogin, "203.0.113.7", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			assert.Equal(t, time.Hour, args.Get(4).(time.Time).Sub(args.Get(5).(time.Time)))
+		}).
+		Return(false, nil)
+
+	err := notifier.Notify(ctx, &entity.User{ID: "user-id", Email: "test@example.com"}, Notification{
+		Template: sender.TemplateSuspiciousLogin,
+		Data:     sender.SuspiciousLoginData{IP: "203.0.113.7", Time: time.Now()},
+		Key:      "203.0.113.7",
+	})
+
+	assert.NoError(t, err)
+	mockNotificationRepo.AssertNotCalled(t, "ListNotificationPreferences", mock.Anything, mock.Anything)
+	mockEmailRepo.AssertNotCalled(t, "CreateEmailMessage", mock.Anything, mock.Anything)
+}
+
+func TestNotifier_Notify_HoldsBurstForDigest(t *testing.T) {
+	ctx := context.Background()
+	mockNotificationRepo := new(mockNotificationRepo)
+	mockEmailRepo := new(mockEmailRepo)
+	notifier := newTestNotifier(t, mockNotificationRepo, mockEmailRepo)
+
+	mockNotificationRepo.On("ThrottleNotification", ctx, "user-id", sender.TemplateSuspiciousLogin, "203.0.113.9", mock.Anything, mock.Anything).Return(true, nil)
+	mockNotificationRepo.On("CountNotificationsSince", ctx, "user-id", sender.TemplateSuspiciousLogin, mock.Anything).Return(testNotificationConfig.BurstLimit+1, nil)
+	mockNotificationRepo.On("CreateNotificationDigestItem", ctx, mock.MatchedBy(func(item entity.NotificationDigestItem) bool {
+		return item.UserID == "user-id" && item.Severity == entity.SeverityWarning && item.Subject == "Новый вход с адреса 203.0.113.9"
+	})).Return(nil)
+
+	err := notifier.Notify(ctx, &entity.User{ID: "user-id", Email: "test@example.com", Locale: "ru"}, Notification{
+		Template: sender.TemplateSuspiciousLogin,
+		Data:     sender.SuspiciousLoginData{IP: "203.0.113.9", Time: time.Now()},
+		Key:      "203.0.113.9",
+	})
+
+	assert.NoError(t, err)
+	mockNotificationRepo.AssertExpectations(t)
+	mockEmailRepo.AssertNotCalled(t, "CreateEmailMessage", mock.Anything, mock.Anything)
+}
+
+func TestNotifier_SendDigests(t *testing.T) {
+	ctx := context.Background()
+	mockNotificationRepo := new(mockNotificationRepo)
+	mockUserRepo := new(mockUserRepo)
+	mockEmailRepo := new(mockEmailRepo)
+	notifier := newTestNotifierWithUsers(t, mockNotificationRepo, mockUserRepo, mockEmailRepo)
+
+	start := time.Now()
+	mockNotificationRepo.On("ListDueNotificationDigests", ctx, mock.Anything).Return([]string{"user-id"}, nil)
+	mockNotificationRepo.On("TakeNotificationDigestItems", ctx, "user-id").Return([]entity.NotificationDigestItem{
+		{Template: sender.TemplateNewDevice, Severity: entity.SeverityInfo, Subject: "New device signed in to your account", CreatedAt: start},
+		{Template: sender.TemplateSuspiciousLogin, Severity: entity.SeverityWarning, Subject: "New sign-in from 203.0.113.9", CreatedAt: start},
+	}, nil)
+	mockNotificationRepo.On("DeleteNotificationThrottles", ctx, mock.MatchedBy(func(sentBefore time.Time) bool {
+		return sentBefore.Before(start.Add(-testNotificationConfig.ThrottleWindow + time.Minute))
+	})).Return(nil)
+	mockUserRepo.On("GetUserByID", ctx, "user-id").Return(&entity.User{ID: "user-id", Email: "test@example.com"}, nil)
+	mockNotificationRepo.On("ListNotificationPreferences", ctx, "user-id").Return([]entity.NotificationPreference{
+		{Channel: sender.ChannelEmail, MinSeverity: entity.SeverityWarning},
+	}, nil)
+	var queued []entity.EmailMessage
+	mockEmailRepo.On("CreateEmailMessage", ctx, mock.Anything).
+		Run(func(args mock.Arguments) { queued = append(queued, args.Get(1).(entity.EmailMessage)) }).
+		Return(int64(1), nil)
+
+	err := notifier.SendDigests(ctx)
+
+	assert.NoError(t, err)
+	mockNotificationRepo.AssertExpectations(t)
+	if assert.Len(t, queued, 1) {
+		assert.Equal(t, sender.TemplateSecurityDigest, queued[0].Kind)
+		assert.Equal(t, "2 security alerts for your account", queued[0].Subject)
+		assert.Contains(t, queued[0].TextBody, "New sign-in from 203.0.113.9")
+	}
+}
+
+func TestNotifier_SetPreferences_Validates(t *testing.T) {
+	ctx := context.Background()
+	mockNotificationRepo := new(mockNotificationRepo)
+	notifier := newTestNotifier(t, mockNotificationRepo, new(mockEmailRepo))
+
+	for _, preferences := range [][]entity.NotificationPreference{
+		{{Channel: sender.ChannelSlack, Address: "C123", MinSeverity: entity.SeverityInfo}},
+	} {
+		assert.ErrorIs(t, notifier.SetPreferences(ctx, "user-id", preferences), ErrNotificationChannelUnavailable)
+	}
+
+	for _, preferences := range [][]entity.NotificationPreference{
+		{{Channel: sender.ChannelEmail, Address: "attacker@example.com", MinSeverity: entity.SeverityInfo}},
+		{{Channel: sender.ChannelTelegram, MinSeverity: entity.SeverityInfo}},
+		{{Channel: sender.ChannelSMS, Address: "5550100", MinSeverity: entity.SeverityCritical}},
+		{{Channel: sender.ChannelEmail, MinSeverity: "urgent"}},
+		{{Channel: sender.ChannelEmail, MinSeverity: entity.SeverityInfo}, {Channel: sender.ChannelEmail, MinSeverity: entity.SeverityWarning}},
+	} {
+		assert.ErrorIs(t, notifier.SetPreferences(ctx, "user-id", preferences), ErrInvalidNotificationPreference)
+	}
+
+	mockNotificationRepo.AssertNotCalled(t, "ReplaceNotificationPreferences", mock.Anything, mock.Anything, mock.Anything)
+}
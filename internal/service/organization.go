@@ -0,0 +1,383 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository"
+	"medods-tz/internal/repository/repoerrors"
+	"strings"
+	"time"
+)
+
+// Organizations keeps the organizations users sign in to and their members. Everything that belongs to
+// one organization is read and written within its tenant scope, so row-level security backs up the
+// org_id conditions of the queries.
+type Organizations struct {
+	orgRepo    repository.OrganizationRepository
+	tokenRepo  repository.TokenRepository
+	transactor repository.Transactor
+	webhooks   WebhookService
+}
+
+func NewOrganizations(
+	orgRepo repository.OrganizationRepository,
+	tokenRepo repository.TokenRepository,
+	transactor repository.Transactor,
+	webhooks WebhookService) *Organizations {
+	return &Organizations{
+		orgRepo:    orgRepo,
+		tokenRepo:  tokenRepo,
+		transactor: transactor,
+		webhooks:   webhooks,
+	}
+}
+
+func (s *Organizations) CreateOrganization(ctx context.Context, org entity.Organization) (*entity.Organization, error) {
+	org.Name = strings.TrimSpace(org.Name)
+	if org.Name == "" {
+		return nil, ErrInvalidOrganization
+	}
+	org.CreatedAt = time.Now()
+
+	// the organization has no id to scope the transaction to before it is stored
+	err := s.transactor.WithinAllTenants(ctx, func(ctx context.Context) error {
+		id, err := s.orgRepo.CreateOrganization(ctx, org)
+		if err != nil {
+			return fmt.Errorf("error while creating organization: %w", err)
+		}
+		org.ID = id
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &org, nil
+}
+
+func (s *Organizations) GetOrganization(ctx context.Context, id string) (*entity.Organization, error) {
+	var org *entity.Organization
+	err := s.transactor.WithinTenant(ctx, id, func(ctx context.Context) error {
+		var err error
+		org, err = s.getOrganization(ctx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return org, nil
+}
+
+func (s *Organizations) ListOrganizations(ctx context.Context) ([]entity.Organization, error) {
+	var orgs []entity.Organization
+	err := s.transactor.WithinAllTenants(ctx, func(ctx context.Context) error {
+		var err error
+		orgs, err = s.orgRepo.ListOrganizations(ctx)
+		if err != nil {
+			return fmt.Errorf("error while listing organizations: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if orgs == nil {
+		orgs = []entity.Organization{}
+	}
+
+	return orgs, nil
+}
+
+func (s *Organizations) UpdateOrganization(ctx context.Context, org entity.Organization) (*entity.Organization, error) {
+	org.Name = strings.TrimSpace(org.Name)
+	if org.Name == "" {
+		return nil, ErrInvalidOrganization
+	}
+
+	var updated *entity.Organization
+	err := s.transactor.WithinTenant(ctx, org.ID, func(ctx context.Context) error {
+		err := s.orgRepo.UpdateOrganization(ctx, org)
+		if err != nil {
+			if errors.Is(err, repoerrors.ErrNotFound) {
+				return ErrOrganizationNotFound
+			}
+
+			return fmt.Errorf("error while updating organization: %w", err)
+		}
+
+		updated, err = s.getOrganization(ctx, org.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// DeleteOrganization removes the organization, its sessions and ip rules go with it.
+func (s *Organizations) DeleteOrganization(ctx context.Context, id string) error {
+	return s.transactor.WithinTenant(ctx, id, func(ctx context.Context) error {
+		err := s.orgRepo.DeleteOrganization(ctx, id)
+		if err != nil {
+			if errors.Is(err, repoerrors.ErrNotFound) {
+				return ErrOrganizationNotFound
+			}
+
+			return fmt.Errorf("error while deleting organization: %w", err)
+		}
+
+		return s.webhooks.Enqueue(ctx, entity.WebhookSessionRevoked, map[string]string{
+			"org_id": id,
+			"reason": "organization_deleted",
+		})
+	})
+}
+
+// SetMember adds a user to an organization with role, or changes the role of a member. Sessions
+// pick up a new role when they are refreshed.
+func (s *Organizations) SetMember(ctx context.Context, orgID, userID, role string) (*entity.Membership, error) {
+	if !validOrgRole(role) {
+		return nil, ErrInvalidOrgRole
+	}
+
+	var membership *entity.Membership
+	err := s.transactor.WithinTenant(ctx, orgID, func(ctx context.Context) error {
+		_, err := s.getOrganization(ctx, orgID)
+		if err != nil {
+			return err
+		}
+
+		err = s.orgRepo.UpsertMembership(ctx, entity.Membership{
+			OrgID:     orgID,
+			UserID:    userID,
+			Role:      role,
+			CreatedAt: time.Now(),
+		})
+		if err != nil {
+			if errors.Is(err, repoerrors.ErrNotFound) {
+				return ErrUserNotFound
+			}
+
+			return fmt.Errorf("error while storing membership: %w", err)
+		}
+
+		membership, err = s.orgRepo.GetMembership(ctx, orgID, userID)
+		if err != nil {
+			return fmt.Errorf("error while getting membership: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return membership, nil
+}
+
+// RemoveMember takes a user out of an organization and signs them out of its sessions.
+func (s *Organizations) RemoveMember(ctx context.Context, orgID, userID string) error {
+	return s.transactor.WithinTenant(ctx, orgID, func(ctx context.Context) error {
+		err := s.orgRepo.DeleteMembership(ctx, orgID, userID)
+		if err != nil {
+			if errors.Is(err, repoerrors.ErrNotFound) {
+				return ErrMembershipNotFound
+			}
+
+			return fmt.Errorf("error while deleting membership: %w", err)
+		}
+
+		err = s.tokenRepo.RevokeOrgRefreshTokensByUserID(ctx, orgID, userID)
+		if err != nil {
+			return fmt.Errorf("error while revoking refresh tokens of member: %w", err)
+		}
+
+		return s.webhooks.Enqueue(ctx, entity.WebhookSessionRevoked, map[string]string{
+			"user_id": userID,
+			"org_id":  orgID,
+			"reason":  "membership_removed",
+		})
+	})
+}
+
+func (s *Organizations) ListMembers(ctx context.Context, orgID string) ([]entity.Membership, error) {
+	var memberships []entity.Membership
+	err := s.transactor.WithinTenant(ctx, orgID, func(ctx context.Context) error {
+		_, err := s.getOrganization(ctx, orgID)
+		if err != nil {
+			return err
+		}
+
+		memberships, err = s.orgRepo.ListMemberships(ctx, orgID)
+		if err != nil {
+			return fmt.Errorf("error while listing memberships: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if memberships == nil {
+		memberships = []entity.Membership{}
+	}
+
+	return memberships, nil
+}
+
+// ListUserOrganizations returns the memberships of a user, the organizations they can sign in to. They
+// span organizations, so they are read across all tenants.
+func (s *Organizations) ListUserOrganizations(ctx context.Context, userID string) ([]entity.Membership, error) {
+	var memberships []entity.Membership
+	err := s.transactor.WithinAllTenants(ctx, func(ctx context.Context) error {
+		var err error
+		memberships, err = s.orgRepo.ListUserMemberships(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("error while listing memberships: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if memberships == nil {
+		memberships = []entity.Membership{}
+	}
+
+	return memberships, nil
+}
+
+// Membership returns the membership of a user in an organization, or ErrNotOrgMember.
+func (s *Organizations) Membership(ctx context.Context, orgID, userID string) (*entity.Membership, error) {
+	var membership *entity.Membership
+	err := s.transactor.WithinTenant(ctx, orgID, func(ctx context.Context) error {
+		var err error
+		membership, err = s.orgRepo.GetMembership(ctx, orgID, userID)
+		if err != nil {
+			if errors.Is(err, repoerrors.ErrNotFound) {
+				return ErrNotOrgMember
+			}
+
+			return fmt.Errorf("error while getting membership: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return membership, nil
+}
+
+// ListSessions returns the active sessions of all members of an organization.
+func (s *Organizations) ListSessions(ctx context.Context, orgID string) ([]entity.Session, error) {
+	var tokens []entity.RefreshToken
+	err := s.transactor.WithinTenant(ctx, orgID, func(ctx context.Context) error {
+		_, err := s.getOrganization(ctx, orgID)
+		if err != nil {
+			return err
+		}
+
+		tokens, err = s.tokenRepo.ListOrgRefreshTokens(ctx, orgID, time.Now())
+		if err != nil {
+			return fmt.Errorf("error while listing refresh tokens of organization: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return newSessions(tokens, ""), nil
+}
+
+// ListMemberSessions returns the active sessions of an organization a member may see: owners and admins
+// see the sessions of all members, other members only their own.
+func (s *Organizations) ListMemberSessions(ctx context.Context, orgID, userID string) ([]entity.Session, error) {
+	var tokens []entity.RefreshToken
+	var membership *entity.Membership
+	err := s.transactor.WithinTenant(ctx, orgID, func(ctx context.Context) error {
+		var err error
+		membership, err = s.Membership(ctx, orgID, userID)
+		if err != nil {
+			return err
+		}
+
+		tokens, err = s.tokenRepo.ListOrgRefreshTokens(ctx, orgID, time.Now())
+		if err != nil {
+			return fmt.Errorf("error while listing refresh tokens of organization: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if membership.ManagesOrg() {
+		return newSessions(tokens, ""), nil
+	}
+
+	return newSessions(tokens, userID), nil
+}
+
+func (s *Organizations) getOrganization(ctx context.Context, id string) (*entity.Organization, error) {
+	org, err := s.orgRepo.GetOrganization(ctx, id)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return nil, ErrOrganizationNotFound
+		}
+
+		return nil, fmt.Errorf("error while getting organization: %w", err)
+	}
+
+	return org, nil
+}
+
+// newSessions lists tokens as sessions, only those of userID unless it is empty.
+func newSessions(tokens []entity.RefreshToken, userID string) []entity.Session {
+	sessions := []entity.Session{}
+	for _, token := range tokens {
+		if userID != "" && token.UserID != userID {
+			continue
+		}
+
+		session := entity.Session{
+			ID:        token.ID,
+			UserID:    token.UserID,
+			DeviceID:  token.DeviceID,
+			ClientIP:  token.ClientIP,
+			Location:  token.Location.String(),
+			IssuedAt:  token.IssuedAt,
+			ExpiresAt: token.ExpiresAt,
+		}
+		if token.OrgID != nil {
+			session.OrgID = *token.OrgID
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions
+}
+
+func validOrgRole(role string) bool {
+	switch role {
+	case entity.OrgRoleOwner, entity.OrgRoleAdmin, entity.OrgRoleMember:
+		return true
+	}
+
+	return false
+}
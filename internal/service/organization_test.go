@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository/repoerrors"
+	"testing"
+	"time"
+)
+
+func newTestOrganizations() *Organizations {
+	return NewOrganizations(new(mockOrganizationRepo), new(mockTokenRepo), mockTransactor{}, newTestWebhooks())
+}
+
+func TestAuth_CreateTokens_Organization(t *testing.T) {
+	ctx := context.Background()
+	mockUserRepo := new(mockUserRepo)
+	mockTokenRepo := new(mockTokenRepo)
+	mockOrgRepo := new(mockOrganizationRepo)
+
+	orgs := NewOrganizations(mockOrgRepo, mockTokenRepo, mockTransactor{}, newTestWebhooks())
+	auth := newTestAuth(AuthDependencies{UserRepo: mockUserRepo, TokenRepo: mockTokenRepo, Orgs: orgs})
+
+	mockUserRepo.On("GetUserByID", ctx, "user-id").Return(&entity.User{ID: "user-id", Email: "test@example.com"}, nil)
+	mockOrgRepo.On("GetMembership", ctx, "org-id", "user-id").Return(&entity.Membership{OrgID: "org-id", UserID: "user-id", Role: entity.OrgRoleAdmin}, nil)
+	mockOrgRepo.On("GetMembership", ctx, "other-org-id", "user-id").Return((*entity.Membership)(nil), repoerrors.ErrNotFound)
+	mockTokenRepo.On("CreateRefreshToken", ctx, mock.MatchedBy(func(token entity.RefreshToken) bool {
+		return token.OrgID != nil && *token.OrgID == "org-id"
+	})).Return(nil)
+
+	tokens, err := auth.CreateTokens(ctx, "user-id", "org-id", "127.0.0.1", DeviceInput{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	claims, err := auth.Authenticate(ctx, tokens.AccessToken)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "org-id", claims.OrgID)
+	assert.Equal(t, entity.OrgRoleAdmin, claims.OrgRole)
+	mockTokenRepo.AssertNumberOfCalls(t, "CreateRefreshToken", 1)
+
+	tokens, err = auth.CreateTokens(ctx, "user-id", "other-org-id", "127.0.0.1", DeviceInput{})
+
+	assert.Nil(t, tokens)
+	assert.ErrorIs(t, err, ErrNotOrgMember)
+	mockTokenRepo.AssertNumberOfCalls(t, "CreateRefreshToken", 1)
+}
+
+func TestAuth_Authenticate_FormerOrgMember(t *testing.T) {
+	ctx := context.Background()
+	mockOrgRepo := new(mockOrganizationRepo)
+
+	orgs := NewOrganizations(mockOrgRepo, new(mockTokenRepo), mockTransactor{}, newTestWebhooks())
+	auth := newTestAuth(AuthDependencies{Orgs: orgs})
+
+	mockOrgRepo.On("GetMembership", ctx, "org-id", "user-id").Return((*entity.Membership)(nil), repoerrors.ErrNotFound)
+
+	membership := &entity.Membership{OrgID: "org-id", UserID: "user-id", Role: entity.OrgRoleMember}
+	accessToken, err := auth.generateAccessToken("127.0.0.1", "user-id", nil, nil, membership, "", time.Now().Add(time.Minute))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	claims, err := auth.Authenticate(ctx, accessToken)
+
+	assert.Nil(t, claims)
+	assert.ErrorIs(t, err, ErrInvalidAccessToken)
+	assert.ErrorIs(t, err, ErrNotOrgMember)
+}
+
+func TestOrganizations_ListMemberSessions(t *testing.T) {
+	ctx := context.Background()
+	mockOrgRepo := new(mockOrganizationRepo)
+	mockTokenRepo := new(mockTokenRepo)
+	orgs := NewOrganizations(mockOrgRepo, mockTokenRepo, mockTransactor{}, newTestWebhooks())
+
+	orgID := "org-id"
+	mockOrgRepo.On("GetMembership", ctx, orgID, "admin-id").Return(&entity.Membership{OrgID: orgID, UserID: "admin-id", Role: entity.OrgRoleAdmin}, nil)
+	mockOrgRepo.On("GetMembership", ctx, orgID, "member-id").Return(&entity.Membership{OrgID: orgID, UserID: "member-id", Role: entity.OrgRoleMember}, nil)
+	mockOrgRepo.On("GetMembership", ctx, orgID, "outsider-id").Return((*entity.Membership)(nil), repoerrors.ErrNotFound)
+	mockTokenRepo.On("ListOrgRefreshTokens", ctx, orgID, mock.Anything).Return([]entity.RefreshToken{
+		{ID: "admin-session", UserID: "admin-id", OrgID: &orgID, ClientIP: "203.0.113.7"},
+		{ID: "member-session", UserID: "member-id", OrgID: &orgID, ClientIP: "198.51.100.7"},
+	}, nil)
+
+	sessions, err := orgs.ListMemberSessions(ctx, orgID, "admin-id")
+	if assert.NoError(t, err) && assert.Len(t, sessions, 2) {
+		assert.Equal(t, orgID, sessions[0].OrgID)
+	}
+
+	sessions, err = orgs.ListMemberSessions(ctx, orgID, "member-id")
+	if assert.NoError(t, err) && assert.Len(t, sessions, 1) {
+		assert.Equal(t, "member-session", sessions[0].ID)
+	}
+
+	_, err = orgs.ListMemberSessions(ctx, orgID, "outsider-id")
+	assert.ErrorIs(t, err, ErrNotOrgMember)
+}
+
+func TestOrganizations_RemoveMember(t *testing.T) {
+	ctx := context.Background()
+	mockOrgRepo := new(mockOrganizationRepo)
+	mockTokenRepo := new(mockTokenRepo)
+	orgs := NewOrganizations(mockOrgRepo, mockTokenRepo, mockTransactor{}, newTestWebhooks())
+
+	mockOrgRepo.On("DeleteMembership", ctx, "org-id", "user-id").Return(nil)
+	mockOrgRepo.On("DeleteMembership", ctx, "org-id", "outsider-id").Return(repoerrors.ErrNotFound)
+	mockTokenRepo.On("RevokeOrgRefreshTokensByUserID", ctx, "org-id", "user-id").Return(nil)
+
+	err := orgs.RemoveMember(ctx, "org-id", "user-id")
+	assert.NoError(t, err)
+	mockTokenRepo.AssertExpectations(t)
+
+	err = orgs.RemoveMember(ctx, "org-id", "outsider-id")
+	assert.ErrorIs(t, err, ErrMembershipNotFound)
+	mockTokenRepo.AssertNumberOfCalls(t, "RevokeOrgRefreshTokensByUserID", 1)
+}
+
+func TestOrganizations_SetMember(t *testing.T) {
+	ctx := context.Background()
+	mockOrgRepo := new(mockOrganizationRepo)
+	orgs := NewOrganizations(mockOrgRepo, new(mockTokenRepo), mockTransactor{}, newTestWebhooks())
+
+	_, err := orgs.SetMember(ctx, "org-id", "user-id", "superuser")
+	assert.ErrorIs(t, err, ErrInvalidOrgRole)
+
+	mockOrgRepo.On("GetOrganization", ctx, "missing-org-id").Return((*entity.Organization)(nil), repoerrors.ErrNotFound)
+	_, err = orgs.SetMember(ctx, "missing-org-id", "user-id", entity.OrgRoleMember)
+	assert.ErrorIs(t, err, ErrOrganizationNotFound)
+
+	mockOrgRepo.On("GetOrganization", ctx, "org-id").Return(&entity.Organization{ID: "org-id", Name: "Acme"}, nil)
+	mockOrgRepo.On("UpsertMembership", ctx, mock.MatchedBy(func(membership entity.Membership) bool {
+		return membership.UserID == "missing-user-id"
+	})).Return(repoerrors.ErrNotFound)
+	_, err = orgs.SetMember(ctx, "org-id", "missing-user-id", entity.OrgRoleMember)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"encoding/base64"
+	"strconv"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// listPage lists one page of at most limit rows, defaultPageSize when limit is not positive, and returns
+// the cursor of the next page, empty on the last page. list is asked for one row more than the page
+// holds, the extra row tells whether there is a next page. cursorOf gives the cursor after a row.
+func listPage[T any](limit int, list func(limit int) ([]T, error), cursorOf func(row T) string) ([]T, string, error) {
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	pageSize := min(limit, maxPageSize)
+
+	rows, err := list(pageSize + 1)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var nextCursor string
+	if len(rows) > pageSize {
+		rows = rows[:pageSize]
+		nextCursor = cursorOf(rows[pageSize-1])
+	}
+
+	return rows, nextCursor, nil
+}
+
+// encodeIDCursor hides the id the next page starts before, so clients treat cursors as opaque.
+func encodeIDCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+func decodeIDCursor(cursor string) (int64, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(string(decoded), 10, 64)
+}
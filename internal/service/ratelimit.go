@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"medods-tz/internal/repository"
+	"time"
+)
+
+// RateLimitPolicy is a token bucket: Limit requests per Period on average, with bursts of up to Burst requests.
+type RateLimitPolicy struct {
+	Name   string
+	Limit  int
+	Period time.Duration
+	Burst  int
+}
+
+func (p RateLimitPolicy) capacity() float64 {
+	if p.Burst > 0 {
+		return float64(p.Burst)
+	}
+	return float64(p.Limit)
+}
+
+func (p RateLimitPolicy) refillPerSecond() float64 {
+	return float64(p.Limit) / p.Period.Seconds()
+}
+
+// RefillTime is how long an empty bucket takes to fill up again.
+func (p RateLimitPolicy) RefillTime() time.Duration {
+	return secondsToDuration(p.capacity() / p.refillPerSecond())
+}
+
+type RateLimitConfig struct {
+	// IdleTime is the longest RefillTime of the policies in use. A bucket nobody took from for that long
+	// is full, so dropping it changes nothing.
+	IdleTime time.Duration
+}
+
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed, zero when Allowed.
+	RetryAfter time.Duration
+}
+
+type RateLimiter struct {
+	bucketRepo repository.RateLimitRepository
+	config     RateLimitConfig
+}
+
+func NewRateLimiter(bucketRepo repository.RateLimitRepository, config RateLimitConfig) *RateLimiter {
+	return &RateLimiter{bucketRepo: bucketRepo, config: config}
+}
+
+func (s *RateLimiter) Take(ctx context.Context, policy RateLimitPolicy, key string) (*RateLimitResult, error) {
+	capacity := policy.capacity()
+	refillPerSecond := policy.refillPerSecond()
+
+	bucket, err := s.bucketRepo.TakeRateLimitToken(ctx, policy.Name+":"+key, capacity, refillPerSecond, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("error while taking rate limit token: %w", err)
+	}
+
+	result := &RateLimitResult{
+		Allowed:   bucket.Allowed,
+		Limit:     int(capacity),
+		Remaining: int(math.Floor(bucket.Tokens)),
+		Reset:     secondsToDuration((capacity - bucket.Tokens) / refillPerSecond),
+	}
+	if !bucket.Allowed {
+		result.RetryAfter = secondsToDuration((1 - bucket.Tokens) / refillPerSecond)
+	}
+
+	return result, nil
+}
+
+// PruneBuckets drops the buckets idle for longer than any policy takes to refill them.
+func (s *RateLimiter) PruneBuckets(ctx context.Context) error {
+	err := s.bucketRepo.PruneRateLimitBuckets(ctx, time.Now().Add(-s.config.IdleTime))
+	if err != nil {
+		return fmt.Errorf("error while pruning rate limit buckets: %w", err)
+	}
+
+	return nil
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(math.Max(seconds, 0) * float64(time.Second))
+}
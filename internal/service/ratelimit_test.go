@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"medods-tz/internal/repository/memory"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_Take(t *testing.T) {
+	ctx := context.Background()
+	rateLimiter := NewRateLimiter(memory.NewRateLimitMemory(), RateLimitConfig{})
+	policy := RateLimitPolicy{Name: "POST /api/v1/auth/login", Limit: 60, Period: time.Minute, Burst: 3}
+
+	for i := 2; i >= 0; i-- {
+		result, err := rateLimiter.Take(ctx, policy, "ip:127.0.0.1")
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 3, result.Limit)
+		assert.Equal(t, i, result.Remaining)
+	}
+
+	result, err := rateLimiter.Take(ctx, policy, "ip:127.0.0.1")
+	assert.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+	assert.Greater(t, result.RetryAfter, time.Duration(0))
+	assert.LessOrEqual(t, result.RetryAfter, time.Second)
+
+	// buckets are per key and per policy
+	result, err = rateLimiter.Take(ctx, policy, "ip:127.0.0.2")
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+
+	result, err = rateLimiter.Take(ctx, RateLimitPolicy{Name: "default", Limit: 1, Period: time.Minute}, "ip:127.0.0.1")
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+}
+
+func TestRateLimiter_PruneBuckets_KeepsBucketsInUse(t *testing.T) {
+	ctx := context.Background()
+	policy := RateLimitPolicy{Name: "POST /api/v1/auth/login", Limit: 60, Period: time.Minute, Burst: 3}
+	rateLimiter := NewRateLimiter(memory.NewRateLimitMemory(), RateLimitConfig{IdleTime: policy.RefillTime()})
+
+	assert.Equal(t, 3*time.Second, policy.RefillTime())
+
+	for i := 0; i < 3; i++ {
+		_, err := rateLimiter.Take(ctx, policy, "ip:127.0.0.1")
+		assert.NoError(t, err)
+	}
+
+	err := rateLimiter.PruneBuckets(ctx)
+	assert.NoError(t, err)
+
+	// the empty bucket was taken from within its refill time, so it is kept
+	result, err := rateLimiter.Take(ctx, policy, "ip:127.0.0.1")
+	assert.NoError(t, err)
+	assert.False(t, result.Allowed)
+}
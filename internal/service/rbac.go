@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository"
+	"medods-tz/internal/repository/repoerrors"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// namePattern restricts role and permission names so they can be joined into the space-separated
+// scope claim, permissions are namespaced like "documents:write".
+var namePattern = regexp.MustCompile(`^[a-z][a-z0-9_.:-]*$`)
+
+// Roles keeps the roles users have and the permissions they grant. Access tokens carry both, so
+// downstream services can authorize without calling back, and CheckPermission answers from the
+// current assignments for the ones that cannot wait for a refresh.
+type Roles struct {
+	roleRepo   repository.RoleRepository
+	userRepo   repository.UserRepository
+	transactor repository.Transactor
+}
+
+func NewRoles(roleRepo repository.RoleRepository, userRepo repository.UserRepository, transactor repository.Transactor) *Roles {
+	return &Roles{
+		roleRepo:   roleRepo,
+		userRepo:   userRepo,
+		transactor: transactor,
+	}
+}
+
+func (s *Roles) CreatePermission(ctx context.Context, permission entity.Permission) (*entity.Permission, error) {
+	if !namePattern.MatchString(permission.Name) {
+		return nil, ErrInvalidPermissionName
+	}
+	permission.Description = strings.TrimSpace(permission.Description)
+	permission.CreatedAt = time.Now()
+
+	err := s.roleRepo.CreatePermission(ctx, permission)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrAlreadyExists) {
+			return nil, ErrPermissionAlreadyExists
+		}
+
+		return nil, fmt.Errorf("error while creating permission: %w", err)
+	}
+
+	return &permission, nil
+}
+
+func (s *Roles) ListPermissions(ctx context.Context) ([]entity.Permission, error) {
+	permissions, err := s.roleRepo.ListPermissions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error while listing permissions: %w", err)
+	}
+
+	if permissions == nil {
+		permissions = []entity.Permission{}
+	}
+
+	return permissions, nil
+}
+
+// DeletePermission removes a permission from every role that grants it.
+func (s *Roles) DeletePermission(ctx context.Context, name string) error {
+	err := s.roleRepo.DeletePermission(ctx, name)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrPermissionNotFound
+		}
+
+		return fmt.Errorf("error while deleting permission: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Roles) CreateRole(ctx context.Context, role entity.Role) (*entity.Role, error) {
+	if !namePattern.MatchString(role.Name) {
+		return nil, ErrInvalidRoleName
+	}
+	role.Description = strings.TrimSpace(role.Description)
+	role.Permissions = uniqueSorted(role.Permissions)
+	role.CreatedAt = time.Now()
+
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		role.ID, err = s.roleRepo.CreateRole(ctx, role)
+		if err != nil {
+			if errors.Is(err, repoerrors.ErrAlreadyExists) {
+				return ErrRoleAlreadyExists
+			}
+
+			return fmt.Errorf("error while creating role: %w", err)
+		}
+
+		return s.setRolePermissions(ctx, role.ID, role.Permissions)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &role, nil
+}
+
+func (s *Roles) GetRole(ctx context.Context, id string) (*entity.Role, error) {
+	role, err := s.roleRepo.GetRole(ctx, id)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return nil, ErrRoleNotFound
+		}
+
+		return nil, fmt.Errorf("error while getting role: %w", err)
+	}
+
+	return role, nil
+}
+
+func (s *Roles) ListRoles(ctx context.Context) ([]entity.Role, error) {
+	roles, err := s.roleRepo.ListRoles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error while listing roles: %w", err)
+	}
+
+	if roles == nil {
+		roles = []entity.Role{}
+	}
+
+	return roles, nil
+}
+
+// UpdateRole replaces the name, description and permissions of a role. Access tokens issued before
+// keep the old permissions until they are refreshed.
+func (s *Roles) UpdateRole(ctx context.Context, role entity.Role) (*entity.Role, error) {
+	if !namePattern.MatchString(role.Name) {
+		return nil, ErrInvalidRoleName
+	}
+	role.Description = strings.TrimSpace(role.Description)
+	role.Permissions = uniqueSorted(role.Permissions)
+
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		err := s.roleRepo.UpdateRole(ctx, role)
+		if err != nil {
+			if errors.Is(err, repoerrors.ErrNotFound) {
+				return ErrRoleNotFound
+			}
+			if errors.Is(err, repoerrors.ErrAlreadyExists) {
+				return ErrRoleAlreadyExists
+			}
+
+			return fmt.Errorf("error while updating role: %w", err)
+		}
+
+		return s.setRolePermissions(ctx, role.ID, role.Permissions)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetRole(ctx, role.ID)
+}
+
+func (s *Roles) DeleteRole(ctx context.Context, id string) error {
+	err := s.roleRepo.DeleteRole(ctx, id)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrRoleNotFound
+		}
+
+		return fmt.Errorf("error while deleting role: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Roles) setRolePermissions(ctx context.Context, roleID string, permissions []string) error {
+	err := s.roleRepo.SetRolePermissions(ctx, roleID, permissions)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrPermissionNotFound
+		}
+
+		return fmt.Errorf("error while setting role permissions: %w", err)
+	}
+
+	return nil
+}
+
+// AssignRole gives a role to a user, assigning a role twice changes nothing.
+func (s *Roles) AssignRole(ctx context.Context, userID, roleID string) error {
+	_, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrUserNotFound
+		}
+
+		return fmt.Errorf("error while trying to find user: %w", err)
+	}
+
+	err = s.roleRepo.AssignRole(ctx, userID, roleID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrAlreadyExists) {
+			return nil
+		}
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrRoleNotFound
+		}
+
+		return fmt.Errorf("error while assigning role: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Roles) UnassignRole(ctx context.Context, userID, roleID string) error {
+	err := s.roleRepo.UnassignRole(ctx, userID, roleID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrRoleNotFound
+		}
+
+		return fmt.Errorf("error while unassigning role: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Roles) ListUserRoles(ctx context.Context, userID string) ([]entity.Role, error) {
+	roles, err := s.roleRepo.ListUserRoles(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error while listing roles of user: %w", err)
+	}
+
+	if roles == nil {
+		roles = []entity.Role{}
+	}
+
+	return roles, nil
+}
+
+// Grants returns the role names of a user and the permissions they grant together, both sorted.
+func (s *Roles) Grants(ctx context.Context, userID string) (roles, permissions []string, err error) {
+	userRoles, err := s.roleRepo.ListUserRoles(ctx, userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error while listing roles of user: %w", err)
+	}
+
+	for _, role := range userRoles {
+		roles = append(roles, role.Name)
+		permissions = append(permissions, role.Permissions...)
+	}
+
+	return uniqueSorted(roles), uniqueSorted(permissions), nil
+}
+
+// CheckPermission tells whether one of the roles of a user grants permission right now.
+func (s *Roles) CheckPermission(ctx context.Context, userID, permission string) (bool, error) {
+	_, permissions, err := s.Grants(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	i := sort.SearchStrings(permissions, permission)
+	return i < len(permissions) && permissions[i] == permission, nil
+}
+
+func uniqueSorted(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+
+	sorted := append([]string(nil), values...)
+	sort.Strings(sorted)
+
+	unique := sorted[:1]
+	for _, value := range sorted[1:] {
+		if value != unique[len(unique)-1] {
+			unique = append(unique, value)
+		}
+	}
+
+	return unique
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository/repoerrors"
+	"testing"
+)
+
+func newTestRoles() *Roles {
+	mockRoleRepo := new(mockRoleRepo)
+	mockRoleRepo.On("ListUserRoles", mock.Anything, mock.Anything).Return([]entity.Role(nil), nil).Maybe()
+
+	return NewRoles(mockRoleRepo, new(mockUserRepo), mockTransactor{})
+}
+
+var testUserRoles = []entity.Role{
+	{ID: "editor-id", Name: "editor", Permissions: []string{"documents:read", "documents:write"}},
+	{ID: "billing-id", Name: "billing", Permissions: []string{"documents:read", "invoices:read"}},
+}
+
+func TestRoles_Grants(t *testing.T) {
+	ctx := context.Background()
+	mockRoleRepo := new(mockRoleRepo)
+	roles := NewRoles(mockRoleRepo, new(mockUserRepo), mockTransactor{})
+
+	mockRoleRepo.On("ListUserRoles", ctx, "user-id").Return(testUserRoles, nil)
+	mockRoleRepo.On("ListUserRoles", ctx, "other-user-id").Return([]entity.Role(nil), nil)
+
+	names, permissions, err := roles.Grants(ctx, "user-id")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"billing", "editor"}, names)
+	assert.Equal(t, []string{"documents:read", "documents:write", "invoices:read"}, permissions)
+
+	for _, tc := range []struct {
+		userID     string
+		permission string
+		allowed    bool
+	}{
+		{"user-id", "documents:write", true},
+		{"user-id", "invoices:read", true},
+		{"user-id", "invoices:write", false},
+		{"user-id", "documents", false},
+		{"other-user-id", "documents:read", false},
+	} {
+		allowed, err := roles.CheckPermission(ctx, tc.userID, tc.permission)
+
+		assert.NoError(t, err)
+		assert.Equal(t, tc.allowed, allowed, "%s %s", tc.userID, tc.permission)
+	}
+}
+
+func TestRoles_CreateRole(t *testing.T) {
+	ctx := context.Background()
+	mockRoleRepo := new(mockRoleRepo)
+	roles := NewRoles(mockRoleRepo, new(mockUserRepo), mockTransactor{})
+
+	mockRoleRepo.On("CreateRole", ctx, mock.Anything).Return("role-id", nil)
+	mockRoleRepo.On("SetRolePermissions", ctx, "role-id", []string{"documents:read", "documents:write"}).Return(nil).Once()
+	mockRoleRepo.On("SetRolePermissions", ctx, "role-id", []string{"unknown:permission"}).Return(repoerrors.ErrNotFound).Once()
+
+	role, err := roles.CreateRole(ctx, entity.Role{Name: "editor", Permissions: []string{"documents:write", "documents:read", "documents:write"}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "role-id", role.ID)
+	assert.Equal(t, []string{"documents:read", "documents:write"}, role.Permissions)
+
+	_, err = roles.CreateRole(ctx, entity.Role{Name: "viewer", Permissions: []string{"unknown:permission"}})
+	assert.ErrorIs(t, err, ErrPermissionNotFound)
+
+	_, err = roles.CreateRole(ctx, entity.Role{Name: "Content Editors"})
+	assert.ErrorIs(t, err, ErrInvalidRoleName)
+}
+
+func TestAuth_CreateTokens_Roles(t *testing.T) {
+	ctx := context.Background()
+	mockUserRepo := new(mockUserRepo)
+	mockTokenRepo := new(mockTokenRepo)
+	mockRoleRepo := new(mockRoleRepo)
+
+	roles := NewRoles(mockRoleRepo, mockUserRepo, mockTransactor{})
+	auth := newTestAuth(AuthDependencies{UserRepo: mockUserRepo, TokenRepo: mockTokenRepo, Roles: roles})
+
+	mockUserRepo.On("GetUserByID", ctx, "user-id").Return(&entity.User{ID: "user-id", Email: "test@example.com"}, nil)
+	mockTokenRepo.On("CreateRefreshToken", ctx, mock.Anything).Return(nil)
+	mockRoleRepo.On("ListUserRoles", ctx, "user-id").Return(testUserRoles, nil)
+
+	tokens, err := auth.CreateTokens(ctx, "user-id", "", "127.0.0.1", DeviceInput{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	claims, err := auth.Authenticate(ctx, tokens.AccessToken)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "user-id", claims.UserID)
+	assert.Equal(t, []string{"billing", "editor"}, claims.Roles)
+	assert.Equal(t, "documents:read documents:write invoices:read", claims.Scope)
+}
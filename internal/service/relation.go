@@ -0,0 +1,525 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository"
+	"medods-tz/pkg/relation"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type RelationConfig struct {
+	// Schema is the namespace configuration, the relation API is disabled without it.
+	Schema *relation.Schema
+	// MaxDepth bounds how many usersets deep a check, an expansion or an object listing follows.
+	MaxDepth int
+	// SnapshotRetention is how long consistency tokens can be read at their exact snapshot.
+	SnapshotRetention time.Duration
+}
+
+// Consistency chooses the snapshot of relation tuples a read evaluates. Without a token the latest
+// snapshot is read.
+type Consistency struct {
+	// AtLeastAsFresh is a token of an earlier write or read, the read sees everything it saw.
+	AtLeastAsFresh string
+	// AtSnapshot is a token of an earlier write or read, the read sees exactly what it saw.
+	AtSnapshot string
+}
+
+// ExpandNode is the userset tree of a relation: the subjects of its own tuples and the usersets it
+// includes. Subjects that are usersets themselves are not expanded further.
+type ExpandNode struct {
+	Userset  string        `json:"userset"`
+	Subjects []string      `json:"subjects,omitempty"`
+	Children []*ExpandNode `json:"children,omitempty"`
+}
+
+// tupleToUserset is a relation that includes computed of the objects its tupleset tuples point to.
+type tupleToUserset struct {
+	namespace string
+	tupleset  string
+	relation  string
+}
+
+// Relations stores relation tuples and answers who has which relation to which object, following
+// the namespace configuration. Every write creates a revision that consistency tokens point to.
+type Relations struct {
+	relationRepo repository.RelationRepository
+	transactor   repository.Transactor
+	config       RelationConfig
+
+	// computedBy maps "namespace#relation" to the relations of the namespace that include it.
+	computedBy map[string][]string
+	// tuplesetsOf maps a relation to the relations that include it through a tupleset.
+	tuplesetsOf map[string][]tupleToUserset
+}
+
+func NewRelations(relationRepo repository.RelationRepository, transactor repository.Transactor, config RelationConfig) *Relations {
+	s := &Relations{
+		relationRepo: relationRepo,
+		transactor:   transactor,
+		config:       config,
+		computedBy:   map[string][]string{},
+		tuplesetsOf:  map[string][]tupleToUserset{},
+	}
+
+	if config.Schema == nil {
+		return s
+	}
+
+	for _, name := range config.Schema.Namespaces() {
+		namespace, _ := config.Schema.Namespace(name)
+		for _, rel := range namespace.Relations {
+			for _, userset := range rel.Rewrite {
+				if userset.Tupleset == "" {
+					key := name + "#" + userset.Relation
+					s.computedBy[key] = append(s.computedBy[key], rel.Name)
+					continue
+				}
+
+				s.tuplesetsOf[userset.Relation] = append(s.tuplesetsOf[userset.Relation], tupleToUserset{
+					namespace: name,
+					tupleset:  userset.Tupleset,
+					relation:  rel.Name,
+				})
+			}
+		}
+	}
+
+	return s
+}
+
+// WriteTuples deletes and then writes tuples in one revision and returns its consistency token.
+func (s *Relations) WriteTuples(ctx context.Context, writes, deletes []entity.RelationTuple) (string, error) {
+	if s.config.Schema == nil {
+		return "", ErrRelationsDisabled
+	}
+	if len(writes) == 0 && len(deletes) == 0 {
+		return "", ErrNoRelationTuples
+	}
+
+	for _, tuple := range append(append([]entity.RelationTuple(nil), writes...), deletes...) {
+		err := s.validateTuple(tuple)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	var revision int64
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		revision, err = s.relationRepo.CreateRelationRevision(ctx)
+		if err != nil {
+			return fmt.Errorf("error while creating relation revision: %w", err)
+		}
+
+		err = s.relationRepo.WriteRelationTuples(ctx, writes, deletes, revision)
+		if err != nil {
+			return fmt.Errorf("error while writing relation tuples: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return encodeConsistencyToken(revision), nil
+}
+
+func (s *Relations) validateTuple(tuple entity.RelationTuple) error {
+	if _, ok := s.config.Schema.Relation(tuple.Namespace, tuple.Relation); !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownRelation, tuple.Userset())
+	}
+
+	if tuple.Subject.Relation == "" {
+		if _, ok := s.config.Schema.Namespace(tuple.Subject.Namespace); !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownRelation, tuple.Subject)
+		}
+	} else if _, ok := s.config.Schema.Relation(tuple.Subject.Namespace, tuple.Subject.Relation); !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownRelation, tuple.Subject)
+	}
+
+	return nil
+}
+
+// Check tells whether subject has the relation of userset, such as document:readme#viewer.
+// It returns the consistency token of the snapshot it read.
+func (s *Relations) Check(ctx context.Context, userset, subject entity.RelationSubject, consistency Consistency) (bool, string, error) {
+	revision, err := s.snapshot(ctx, userset, consistency)
+	if err != nil {
+		return false, "", err
+	}
+
+	check := &relationCheck{relations: s, revision: revision, subject: subject, visited: map[entity.RelationSubject]bool{}}
+	allowed, err := check.check(ctx, userset, 0)
+	if err != nil {
+		return false, "", err
+	}
+
+	return allowed, encodeConsistencyToken(revision), nil
+}
+
+type relationCheck struct {
+	relations *Relations
+	revision  int64
+	subject   entity.RelationSubject
+	visited   map[entity.RelationSubject]bool
+}
+
+func (c *relationCheck) check(ctx context.Context, userset entity.RelationSubject, depth int) (bool, error) {
+	if userset == c.subject {
+		return true, nil
+	}
+	if c.visited[userset] {
+		return false, nil
+	}
+	c.visited[userset] = true
+
+	if depth > c.relations.config.MaxDepth {
+		return false, ErrRelationDepthExceeded
+	}
+
+	// objects reached through a tupleset may not have the relation at all
+	rel, ok := c.relations.config.Schema.Relation(userset.Namespace, userset.Relation)
+	if !ok {
+		return false, nil
+	}
+
+	subjects, err := c.relations.listSubjects(ctx, userset, c.revision)
+	if err != nil {
+		return false, err
+	}
+
+	for _, subject := range subjects {
+		if subject == c.subject {
+			return true, nil
+		}
+	}
+
+	for _, subject := range subjects {
+		if subject.Relation == "" {
+			continue
+		}
+
+		allowed, err := c.check(ctx, subject, depth+1)
+		if allowed || err != nil {
+			return allowed, err
+		}
+	}
+
+	for _, userset := range c.relations.rewrite(ctx, userset, rel, c.revision) {
+		if userset.err != nil {
+			return false, userset.err
+		}
+
+		allowed, err := c.check(ctx, userset.subject, depth+1)
+		if allowed || err != nil {
+			return allowed, err
+		}
+	}
+
+	return false, nil
+}
+
+type rewrittenUserset struct {
+	subject entity.RelationSubject
+	err     error
+}
+
+// rewrite returns the usersets the rewrite of rel includes for the object of userset.
+func (s *Relations) rewrite(ctx context.Context, userset entity.RelationSubject, rel *relation.Relation, revision int64) []rewrittenUserset {
+	var usersets []rewrittenUserset
+	for _, rewrite := range rel.Rewrite {
+		if rewrite.Tupleset == "" {
+			usersets = append(usersets, rewrittenUserset{subject: entity.RelationSubject{
+				Namespace: userset.Namespace,
+				ObjectID:  userset.ObjectID,
+				Relation:  rewrite.Relation,
+			}})
+			continue
+		}
+
+		tupleset := entity.RelationSubject{Namespace: userset.Namespace, ObjectID: userset.ObjectID, Relation: rewrite.Tupleset}
+		objects, err := s.listSubjects(ctx, tupleset, revision)
+		if err != nil {
+			return append(usersets, rewrittenUserset{err: err})
+		}
+
+		for _, object := range objects {
+			usersets = append(usersets, rewrittenUserset{subject: entity.RelationSubject{
+				Namespace: object.Namespace,
+				ObjectID:  object.ObjectID,
+				Relation:  rewrite.Relation,
+			}})
+		}
+	}
+
+	return usersets
+}
+
+// Expand returns the userset tree of userset and the consistency token of the snapshot it read.
+func (s *Relations) Expand(ctx context.Context, userset entity.RelationSubject, consistency Consistency) (*ExpandNode, string, error) {
+	revision, err := s.snapshot(ctx, userset, consistency)
+	if err != nil {
+		return nil, "", err
+	}
+
+	tree, err := s.expand(ctx, userset, revision, map[entity.RelationSubject]bool{}, 0)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return tree, encodeConsistencyToken(revision), nil
+}
+
+// expand builds the tree of userset, a userset that is already being expanded higher up the tree is
+// left without children.
+func (s *Relations) expand(ctx context.Context, userset entity.RelationSubject, revision int64, expanding map[entity.RelationSubject]bool, depth int) (*ExpandNode, error) {
+	node := &ExpandNode{Userset: userset.String()}
+
+	rel, ok := s.config.Schema.Relation(userset.Namespace, userset.Relation)
+	if !ok || expanding[userset] {
+		return node, nil
+	}
+	if depth > s.config.MaxDepth {
+		return nil, ErrRelationDepthExceeded
+	}
+	expanding[userset] = true
+	defer delete(expanding, userset)
+
+	subjects, err := s.listSubjects(ctx, userset, revision)
+	if err != nil {
+		return nil, err
+	}
+	for _, subject := range subjects {
+		node.Subjects = append(node.Subjects, subject.String())
+	}
+
+	for _, child := range s.rewrite(ctx, userset, rel, revision) {
+		if child.err != nil {
+			return nil, child.err
+		}
+
+		childNode, err := s.expand(ctx, child.subject, revision, expanding, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		node.Children = append(node.Children, childNode)
+	}
+
+	return node, nil
+}
+
+// ListObjects returns the IDs of the objects of namespace subject has relation to, sorted, and the
+// consistency token of the snapshot it read. It walks from the subject up to the usersets that
+// include it, so it reads only the tuples around the subject.
+func (s *Relations) ListObjects(ctx context.Context, namespace, relationName string, subject entity.RelationSubject, consistency Consistency) ([]string, string, error) {
+	revision, err := s.snapshot(ctx, entity.RelationSubject{Namespace: namespace, Relation: relationName}, consistency)
+	if err != nil {
+		return nil, "", err
+	}
+
+	reached := map[entity.RelationSubject]bool{subject: true}
+	layer := []entity.RelationSubject{subject}
+	for depth := 0; len(layer) > 0; depth++ {
+		if depth > s.config.MaxDepth {
+			return nil, "", ErrRelationDepthExceeded
+		}
+
+		var next []entity.RelationSubject
+		for _, userset := range layer {
+			including, err := s.includingUsersets(ctx, userset, revision)
+			if err != nil {
+				return nil, "", err
+			}
+
+			for _, candidate := range including {
+				if !reached[candidate] {
+					reached[candidate] = true
+					next = append(next, candidate)
+				}
+			}
+		}
+		layer = next
+	}
+
+	objectIDs := []string{}
+	for userset := range reached {
+		if userset.Namespace == namespace && userset.Relation == relationName && userset != subject {
+			objectIDs = append(objectIDs, userset.ObjectID)
+		}
+	}
+	sort.Strings(objectIDs)
+
+	return objectIDs, encodeConsistencyToken(revision), nil
+}
+
+// includingUsersets returns the usersets that directly include userset: the ones with a tuple granting
+// it, the relations of the same object computed from it and, through tuplesets, the relations of the
+// objects pointing to its object.
+func (s *Relations) includingUsersets(ctx context.Context, userset entity.RelationSubject, revision int64) ([]entity.RelationSubject, error) {
+	tuples, err := s.relationRepo.ListRelationTuplesBySubject(ctx, userset, revision)
+	if err != nil {
+		return nil, fmt.Errorf("error while listing relation tuples by subject: %w", err)
+	}
+
+	var including []entity.RelationSubject
+	for _, tuple := range tuples {
+		including = append(including, tuple.Userset())
+	}
+
+	if userset.Relation == "" {
+		return including, nil
+	}
+
+	for _, rel := range s.computedBy[userset.Namespace+"#"+userset.Relation] {
+		including = append(including, entity.RelationSubject{Namespace: userset.Namespace, ObjectID: userset.ObjectID, Relation: rel})
+	}
+
+	tuplesets := s.tuplesetsOf[userset.Relation]
+	if len(tuplesets) == 0 {
+		return including, nil
+	}
+
+	object := entity.RelationSubject{Namespace: userset.Namespace, ObjectID: userset.ObjectID}
+	pointing, err := s.relationRepo.ListRelationTuplesBySubject(ctx, object, revision)
+	if err != nil {
+		return nil, fmt.Errorf("error while listing relation tuples by subject: %w", err)
+	}
+
+	for _, tuple := range pointing {
+		for _, tupleset := range tuplesets {
+			if tuple.Namespace == tupleset.namespace && tuple.Relation == tupleset.tupleset {
+				including = append(including, entity.RelationSubject{Namespace: tuple.Namespace, ObjectID: tuple.ObjectID, Relation: tupleset.relation})
+			}
+		}
+	}
+
+	return including, nil
+}
+
+func (s *Relations) listSubjects(ctx context.Context, userset entity.RelationSubject, revision int64) ([]entity.RelationSubject, error) {
+	subjects, err := s.relationRepo.ListRelationSubjects(ctx, userset.Namespace, userset.ObjectID, userset.Relation, revision)
+	if err != nil {
+		return nil, fmt.Errorf("error while listing relation subjects: %w", err)
+	}
+
+	return subjects, nil
+}
+
+// snapshot returns the revision a read evaluates. The relation of userset must be declared.
+func (s *Relations) snapshot(ctx context.Context, userset entity.RelationSubject, consistency Consistency) (int64, error) {
+	if s.config.Schema == nil {
+		return 0, ErrRelationsDisabled
+	}
+	if _, ok := s.config.Schema.Relation(userset.Namespace, userset.Relation); !ok {
+		return 0, fmt.Errorf("%w: %s#%s", ErrUnknownRelation, userset.Namespace, userset.Relation)
+	}
+
+	oldest, latest, err := s.relationRepo.GetRelationRevisions(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error while getting relation revisions: %w", err)
+	}
+
+	if consistency.AtSnapshot != "" {
+		revision, err := decodeConsistencyToken(consistency.AtSnapshot)
+		if err != nil || revision > latest {
+			return 0, ErrInvalidConsistencyToken
+		}
+		if revision < oldest {
+			return 0, ErrSnapshotExpired
+		}
+
+		return revision, nil
+	}
+
+	// the latest revision is always as fresh as any token of this database
+	if consistency.AtLeastAsFresh != "" {
+		revision, err := decodeConsistencyToken(consistency.AtLeastAsFresh)
+		if err != nil || revision > latest {
+			return 0, ErrInvalidConsistencyToken
+		}
+	}
+
+	return latest, nil
+}
+
+// PruneSnapshots forgets the revisions older than the snapshot retention and the deleted tuples only
+// they could see.
+func (s *Relations) PruneSnapshots(ctx context.Context) error {
+	err := s.relationRepo.PruneRelationRevisions(ctx, time.Now().Add(-s.config.SnapshotRetention))
+	if err != nil {
+		return fmt.Errorf("error while pruning relation revisions: %w", err)
+	}
+
+	return nil
+}
+
+const consistencyTokenPrefix = "rev:"
+
+func encodeConsistencyToken(revision int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(consistencyTokenPrefix + strconv.FormatInt(revision, 10)))
+}
+
+func decodeConsistencyToken(token string) (int64, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, err
+	}
+
+	revision, ok := strings.CutPrefix(string(decoded), consistencyTokenPrefix)
+	if !ok {
+		return 0, ErrInvalidConsistencyToken
+	}
+
+	return strconv.ParseInt(revision, 10, 64)
+}
+
+// ParseRelationTuple parses a tuple written as document:readme#viewer@user:alice or
+// document:readme#viewer@group:eng#member.
+func ParseRelationTuple(value string) (entity.RelationTuple, error) {
+	userset, subject, ok := strings.Cut(value, "@")
+	if !ok {
+		return entity.RelationTuple{}, fmt.Errorf("%w: %q", ErrInvalidRelationTuple, value)
+	}
+
+	object, err := ParseRelationSubject(userset)
+	if err != nil || object.Relation == "" {
+		return entity.RelationTuple{}, fmt.Errorf("%w: %q", ErrInvalidRelationTuple, value)
+	}
+
+	tupleSubject, err := ParseRelationSubject(subject)
+	if err != nil {
+		return entity.RelationTuple{}, fmt.Errorf("%w: %q", ErrInvalidRelationTuple, value)
+	}
+
+	return entity.RelationTuple{
+		Namespace: object.Namespace,
+		ObjectID:  object.ObjectID,
+		Relation:  object.Relation,
+		Subject:   tupleSubject,
+	}, nil
+}
+
+// ParseRelationSubject parses an object such as user:alice or a userset such as group:eng#member.
+func ParseRelationSubject(value string) (entity.RelationSubject, error) {
+	namespace, rest, ok := strings.Cut(value, ":")
+	if !ok {
+		return entity.RelationSubject{}, fmt.Errorf("%w: %q", ErrInvalidRelationTuple, value)
+	}
+
+	objectID, rel, _ := strings.Cut(rest, "#")
+	subject := entity.RelationSubject{Namespace: namespace, ObjectID: objectID, Relation: rel}
+	if namespace == "" || objectID == "" || len(objectID) > 255 || strings.ContainsAny(value, "@ \t\n") ||
+		strings.Contains(rest, "#") && rel == "" || strings.Contains(rel, "#") {
+		return entity.RelationSubject{}, fmt.Errorf("%w: %q", ErrInvalidRelationTuple, value)
+	}
+
+	return subject, nil
+}
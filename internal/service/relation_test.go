@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"medods-tz/internal/entity"
+	"medods-tz/pkg/relation"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testRelationSchema = `
+namespace user {}
+
+namespace group {
+    relation member
+}
+
+namespace folder {
+    relation owner
+    relation viewer = owner
+}
+
+namespace document {
+    relation parent
+    relation owner
+    relation editor = owner
+    relation viewer = editor | parent->viewer
+}
+`
+
+func newTestRelations(t *testing.T, tuples ...string) *Relations {
+	schema, err := relation.Parse(strings.NewReader(testRelationSchema))
+	require.NoError(t, err)
+
+	relations := NewRelations(&mockRelationRepo{}, mockTransactor{}, RelationConfig{Schema: schema, MaxDepth: 10, SnapshotRetention: time.Hour})
+	if len(tuples) > 0 {
+		_, err = relations.WriteTuples(context.Background(), parseTestTuples(t, tuples...), nil)
+		require.NoError(t, err)
+	}
+
+	return relations
+}
+
+func parseTestTuples(t *testing.T, values ...string) []entity.RelationTuple {
+	var tuples []entity.RelationTuple
+	for _, value := range values {
+		tuple, err := ParseRelationTuple(value)
+		require.NoError(t, err)
+		tuples = append(tuples, tuple)
+	}
+
+	return tuples
+}
+
+func parseTestSubject(t *testing.T, value string) entity.RelationSubject {
+	subject, err := ParseRelationSubject(value)
+	require.NoError(t, err)
+
+	return subject
+}
+
+var testRelationTuples = []string{
+	"group:eng#member@user:alice",
+	"group:eng#member@user:bob",
+	"folder:specs#owner@user:carol",
+	"folder:specs#viewer@group:eng#member",
+	"document:readme#parent@folder:specs",
+	"document:readme#owner@user:dave",
+	"document:roadmap#owner@user:alice",
+	"document:secret#owner@user:erin",
+}
+
+func TestRelations_Check(t *testing.T) {
+	ctx := context.Background()
+	relations := newTestRelations(t, testRelationTuples...)
+
+	for _, tc := range []struct {
+		userset string
+		subject string
+		allowed bool
+	}{
+		{"document:readme#owner", "user:dave", true},
+		{"document:readme#editor", "user:dave", true},
+		{"document:readme#viewer", "user:dave", true},
+		{"document:readme#viewer", "user:carol", true},
+		{"document:readme#viewer", "user:alice", true},
+		{"document:readme#viewer", "group:eng#member", true},
+		{"document:readme#editor", "user:alice", false},
+		{"document:secret#viewer", "user:alice", false},
+		{"document:readme#viewer", "user:mallory", false},
+		{"folder:specs#viewer", "user:bob", true},
+	} {
+		t.Run(tc.userset+"@"+tc.subject, func(t *testing.T) {
+			allowed, token, err := relations.Check(ctx, parseTestSubject(t, tc.userset), parseTestSubject(t, tc.subject), Consistency{})
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.allowed, allowed)
+			assert.NotEmpty(t, token)
+		})
+	}
+
+	_, _, err := relations.Check(ctx, parseTestSubject(t, "document:readme#commenter"), parseTestSubject(t, "user:alice"), Consistency{})
+	assert.ErrorIs(t, err, ErrUnknownRelation)
+}
+
+func TestRelations_Check_Consistency(t *testing.T) {
+	ctx := context.Background()
+	relations := newTestRelations(t, testRelationTuples...)
+	readme := parseTestSubject(t, "document:readme#viewer")
+	bob := parseTestSubject(t, "user:bob")
+
+	_, before, err := relations.Check(ctx, readme, bob, Consistency{})
+	require.NoError(t, err)
+
+	after, err := relations.WriteTuples(ctx, nil, parseTestTuples(t, "group:eng#member@user:bob"))
+	require.NoError(t, err)
+
+	allowed, token, err := relations.Check(ctx, readme, bob, Consistency{AtLeastAsFresh: after})
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+	assert.Equal(t, after, token)
+
+	allowed, token, err = relations.Check(ctx, readme, bob, Consistency{AtSnapshot: before})
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+	assert.Equal(t, before, token)
+
+	_, _, err = relations.Check(ctx, readme, bob, Consistency{AtLeastAsFresh: encodeConsistencyToken(99)})
+	assert.ErrorIs(t, err, ErrInvalidConsistencyToken)
+	_, _, err = relations.Check(ctx, readme, bob, Consistency{AtSnapshot: "garbage"})
+	assert.ErrorIs(t, err, ErrInvalidConsistencyToken)
+
+	relations.relationRepo.(*mockRelationRepo).revisions[0] = time.Now().Add(-2 * time.Hour)
+	require.NoError(t, relations.PruneSnapshots(ctx))
+
+	_, _, err = relations.Check(ctx, readme, bob, Consistency{AtSnapshot: before})
+	assert.ErrorIs(t, err, ErrSnapshotExpired)
+}
+
+func TestRelations_Expand(t *testing.T) {
+	relations := newTestRelations(t, testRelationTuples...)
+
+	tree, _, err := relations.Expand(context.Background(), parseTestSubject(t, "document:readme#viewer"), Consistency{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, &ExpandNode{
+		Userset: "document:readme#viewer",
+		Children: []*ExpandNode{
+			{
+				Userset: "document:readme#editor",
+				Children: []*ExpandNode{
+					{Userset: "document:readme#owner", Subjects: []string{"user:dave"}},
+				},
+			},
+			{
+				Userset:  "folder:specs#viewer",
+				Subjects: []string{"group:eng#member"},
+				Children: []*ExpandNode{
+					{Userset: "folder:specs#owner", Subjects: []string{"user:carol"}},
+				},
+			},
+		},
+	}, tree)
+}
+
+func TestRelations_ListObjects(t *testing.T) {
+	ctx := context.Background()
+	relations := newTestRelations(t, testRelationTuples...)
+
+	for _, tc := range []struct {
+		relation string
+		subject  string
+		expected []string
+	}{
+		{"viewer", "user:alice", []string{"readme", "roadmap"}},
+		{"editor", "user:alice", []string{"roadmap"}},
+		{"viewer", "user:carol", []string{"readme"}},
+		{"viewer", "user:erin", []string{"secret"}},
+		{"viewer", "user:mallory", []string{}},
+	} {
+		objectIDs, _, err := relations.ListObjects(ctx, "document", tc.relation, parseTestSubject(t, tc.subject), Consistency{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, objectIDs, "%s %s", tc.relation, tc.subject)
+	}
+}
+
+func TestRelations_WriteTuples_Validates(t *testing.T) {
+	relations := newTestRelations(t)
+
+	for _, value := range []string{
+		"document:readme#commenter@user:alice",
+		"spreadsheet:budget#viewer@user:alice",
+		"document:readme#viewer@robot:r2d2",
+		"document:readme#viewer@group:eng#admin",
+	} {
+		_, err := relations.WriteTuples(context.Background(), parseTestTuples(t, value), nil)
+		assert.ErrorIs(t, err, ErrUnknownRelation, value)
+	}
+
+	_, err := relations.WriteTuples(context.Background(), nil, nil)
+	assert.ErrorIs(t, err, ErrNoRelationTuples)
+}
+
+func TestParseRelationTuple(t *testing.T) {
+	tuple, err := ParseRelationTuple("document:readme#viewer@group:eng#member")
+	assert.NoError(t, err)
+	assert.Equal(t, entity.RelationTuple{
+		Namespace: "document",
+		ObjectID:  "readme",
+		Relation:  "viewer",
+		Subject:   entity.RelationSubject{Namespace: "group", ObjectID: "eng", Relation: "member"},
+	}, tuple)
+	assert.Equal(t, "document:readme#viewer@group:eng#member", tuple.String())
+
+	for _, value := range []string{
+		"document:readme#viewer",
+		"document:readme@user:alice",
+		"document#viewer@user:alice",
+		"document:readme#viewer@user",
+		"document:readme#viewer@user:alice#",
+		"document:readme#viewer@user:alice@bob",
+		"document:read me#viewer@user:alice",
+	} {
+		_, err := ParseRelationTuple(value)
+		assert.ErrorIs(t, err, ErrInvalidRelationTuple, value)
+	}
+}
@@ -0,0 +1,24 @@
+package service
+
+import (
+	"math/rand"
+	"time"
+)
+
+// retryBackoff doubles the delay with every failed attempt, starting at base and capped at max.
+// Half of it is random, so messages that failed together during an outage of the receiver
+// do not all retry at the same moment.
+func retryBackoff(base, max time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+	delay = min(delay, max)
+
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+
+	return half + time.Duration(rand.Int63n(int64(half)))
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"medods-tz/internal/entity"
+	"medods-tz/pkg/iplist"
+	"time"
+)
+
+// Risk actions, from the mildest to the strictest.
+const (
+	RiskAllow  = "allow"
+	RiskNotify = "notify"
+	RiskStepUp = "step_up"
+	RiskDeny   = "deny"
+)
+
+// Risk operations, the ways tokens are issued.
+const (
+	RiskOperationToken   = "token"
+	RiskOperationLogin   = "login"
+	RiskOperationRefresh = "refresh"
+)
+
+// RiskRules are the points each signal adds to the score, 0 or less disables a signal.
+type RiskRules struct {
+	// IPChanged applies to refreshes from another network than the one of the session.
+	IPChanged        int
+	NewLocation      int
+	ImpossibleTravel int
+	// DistancePer1000Km is added for every 1000 km between the previous and the current location.
+	DistancePer1000Km int
+	// FailedAttempt is added for every recent failed attempt of the client IP and the account.
+	FailedAttempt int
+	// Idle applies to sessions that were not refreshed for IdleAfter.
+	Idle      int
+	IdleAfter time.Duration
+	// BadIP applies to addresses of the bad IP list, such as Tor exit nodes.
+	BadIP int
+	// NewDevice applies to sign-ins from a device the user has not signed in with before.
+	NewDevice int
+}
+
+// RiskConfig maps the score to an action: the strictest band whose threshold it reaches wins.
+// A threshold of 0 or less disables its band.
+type RiskConfig struct {
+	Rules       RiskRules
+	NotifyScore int
+	StepUpScore int
+	DenyScore   int
+}
+
+// RiskInput describes a token issuance.
+type RiskInput struct {
+	Operation string
+	// OrgID is the organization the session is issued for, empty for personal sessions.
+	OrgID    string
+	IP       string
+	Location entity.GeoLocation
+	// Previous is the session being refreshed, nil when a new session starts.
+	Previous       *entity.RefreshToken
+	FailedAttempts int
+	// NewDevice is set for a device the user has not signed in with before.
+	NewDevice bool
+	Now       time.Time
+}
+
+type RiskDecision struct {
+	Score  int
+	Action string
+	// Reasons are the signals that added to the score.
+	Reasons []string
+	// LocationChange is the move of a refreshed session, LocationSame for new sessions.
+	LocationChange LocationChange
+}
+
+// RiskEngine scores token issuances and refreshes and decides what to do about them.
+type RiskEngine struct {
+	geo    *GeoPolicy
+	badIPs *iplist.List
+	config RiskConfig
+}
+
+// NewRiskEngine creates the engine, badIPs may be nil.
+func NewRiskEngine(geo *GeoPolicy, badIPs *iplist.List, config RiskConfig) *RiskEngine {
+	return &RiskEngine{
+		geo:    geo,
+		badIPs: badIPs,
+		config: config,
+	}
+}
+
+func (e *RiskEngine) Locate(ip string) entity.GeoLocation {
+	return e.geo.Locate(ip)
+}
+
+func (e *RiskEngine) Evaluate(input RiskInput) RiskDecision {
+	rules := e.config.Rules
+	decision := RiskDecision{LocationChange: LocationSame}
+
+	add := func(reason string, points int) {
+		if points > 0 {
+			decision.Score += points
+			decision.Reasons = append(decision.Reasons, reason)
+		}
+	}
+
+	if input.Previous != nil {
+		decision.LocationChange = e.geo.Assess(*input.Previous, input.IP, input.Location, input.Now)
+
+		switch decision.LocationChange {
+		case LocationSameCountry:
+			add("ip_changed", rules.IPChanged)
+		case LocationNew:
+			add("ip_changed", rules.IPChanged)
+			add("new_location", rules.NewLocation)
+		case LocationImpossible:
+			add("ip_changed", rules.IPChanged)
+			add("impossible_travel", rules.ImpossibleTravel)
+		}
+
+		if distance, ok := Distance(input.Previous.Location, input.Location); ok {
+			add("distance", int(distance/1000*float64(rules.DistancePer1000Km)))
+		}
+
+		if rules.IdleAfter > 0 && input.Now.Sub(input.Previous.IssuedAt) >= rules.IdleAfter {
+			add("idle", rules.Idle)
+		}
+	}
+
+	add("failed_attempts", input.FailedAttempts*rules.FailedAttempt)
+
+	if input.NewDevice {
+		add("new_device", rules.NewDevice)
+	}
+
+	if e.badIPs != nil && e.badIPs.Contains(input.IP) {
+		add("bad_ip", rules.BadIP)
+	}
+
+	decision.Action = e.action(decision.Score)
+
+	return decision
+}
+
+func (e *RiskEngine) action(score int) string {
+	reached := func(threshold int) bool {
+		return threshold > 0 && score >= threshold
+	}
+
+	switch {
+	case reached(e.config.DenyScore):
+		return RiskDeny
+	case reached(e.config.StepUpScore):
+		return RiskStepUp
+	case reached(e.config.NotifyScore):
+		return RiskNotify
+	}
+
+	return RiskAllow
+}
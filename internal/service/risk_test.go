@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"medods-tz/internal/entity"
+	"medods-tz/pkg/iplist"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testRiskConfig = RiskConfig{
+	Rules: RiskRules{
+		IPChanged:         10,
+		NewLocation:       30,
+		ImpossibleTravel:  100,
+		DistancePer1000Km: 5,
+		FailedAttempt:     10,
+		Idle:              10,
+		IdleAfter:         720 * time.Hour,
+		BadIP:             60,
+		NewDevice:         20,
+	},
+	NotifyScore: 30,
+	StepUpScore: 60,
+	DenyScore:   100,
+}
+
+func newTestRiskEngine() *RiskEngine {
+	return NewRiskEngine(newTestGeoPolicy(), nil, testRiskConfig)
+}
+
+func TestRiskEngine_Evaluate(t *testing.T) {
+	badIPs, err := iplist.Parse(strings.NewReader("198.51.100.0/24\n"))
+	assert.NoError(t, err)
+	engine := NewRiskEngine(newTestGeoPolicy(), badIPs, testRiskConfig)
+	now := time.Now()
+
+	session := func(ip string, age time.Duration) *entity.RefreshToken {
+		return &entity.RefreshToken{ID: "token-id", ClientIP: ip, Location: engine.Locate(ip), IssuedAt: now.Add(-age)}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		input    RiskInput
+		action   string
+		reasons  []string
+		minScore int
+	}{
+		{
+			name:   "new session",
+			input:  RiskInput{Operation: RiskOperationLogin, IP: "203.0.113.7"},
+			action: RiskAllow,
+		},
+		{
+			name:     "failed attempts before login",
+			input:    RiskInput{Operation: RiskOperationLogin, IP: "203.0.113.7", FailedAttempts: 3},
+			action:   RiskNotify,
+			reasons:  []string{"failed_attempts"},
+			minScore: 30,
+		},
+		{
+			name:     "bad ip",
+			input:    RiskInput{Operation: RiskOperationLogin, IP: "198.51.100.1"},
+			action:   RiskStepUp,
+			reasons:  []string{"bad_ip"},
+			minScore: 60,
+		},
+		{
+			name:    "refresh within the country",
+			input:   RiskInput{Operation: RiskOperationRefresh, IP: "198.51.100.1", Previous: session("203.0.113.7", 5*time.Hour)},
+			action:  RiskStepUp,
+			reasons: []string{"ip_changed", "distance", "bad_ip"},
+		},
+		{
+			name:     "refresh from a new country",
+			input:    RiskInput{Operation: RiskOperationRefresh, IP: "192.0.2.1", Previous: session("203.0.113.7", 5*time.Hour)},
+			action:   RiskNotify,
+			reasons:  []string{"ip_changed", "new_location", "distance"},
+			minScore: 45,
+		},
+		{
+			name:     "impossible travel",
+			input:    RiskInput{Operation: RiskOperationRefresh, IP: "192.0.2.1", Previous: session("203.0.113.7", 10*time.Minute)},
+			action:   RiskDeny,
+			reasons:  []string{"ip_changed", "impossible_travel", "distance"},
+			minScore: 110,
+		},
+		{
+			name:     "new device",
+			input:    RiskInput{Operation: RiskOperationLogin, IP: "203.0.113.7", FailedAttempts: 1, NewDevice: true},
+			action:   RiskNotify,
+			reasons:  []string{"failed_attempts", "new_device"},
+			minScore: 30,
+		},
+		{
+			name:     "idle session",
+			input:    RiskInput{Operation: RiskOperationRefresh, IP: "203.0.113.7", Previous: session("203.0.113.7", 800*time.Hour)},
+			action:   RiskAllow,
+			reasons:  []string{"idle"},
+			minScore: 10,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.input.Location = engine.Locate(tc.input.IP)
+			tc.input.Now = now
+
+			decision := engine.Evaluate(tc.input)
+
+			assert.Equal(t, tc.action, decision.Action)
+			assert.Equal(t, tc.reasons, decision.Reasons)
+			assert.GreaterOrEqual(t, decision.Score, tc.minScore)
+		})
+	}
+}
+
+func TestRiskEngine_DisabledBands(t *testing.T) {
+	config := testRiskConfig
+	config.StepUpScore = -1
+	config.DenyScore = 0
+	engine := NewRiskEngine(newTestGeoPolicy(), nil, config)
+
+	decision := engine.Evaluate(RiskInput{Operation: RiskOperationLogin, IP: "203.0.113.7", FailedAttempts: 20, Now: time.Now()})
+
+	assert.Equal(t, 200, decision.Score)
+	assert.Equal(t, RiskNotify, decision.Action)
+}
+
+func TestAuth_RefreshTokens_ImpossibleTravelDenied(t *testing.T) {
+	ctx := context.Background()
+	mockUserRepo := new(mockUserRepo)
+	mockTokenRepo := new(mockTokenRepo)
+	risk := newTestRiskEngine()
+
+	auth := newTestAuth(AuthDependencies{UserRepo: mockUserRepo, TokenRepo: mockTokenRepo, Risk: risk})
+
+	accessToken, _ := auth.generateAccessToken("203.0.113.7", "user-id", nil, nil, nil, "", time.Now().Add(auth.tokenTTL))
+
+	mockUserRepo.On("GetUserByID", ctx, "user-id").Return(&entity.User{ID: "user-id", Email: "test@example.com"}, nil)
+	mockTokenRepo.On("GetRefreshTokenEntitiesByUserID", ctx, "user-id").Return([]entity.RefreshToken{
+		{
+			ID:          "token-id",
+			UserID:      "user-id",
+			RefreshHash: string(hashRefreshToken("valid-refresh-token")),
+			ClientIP:    "203.0.113.7",
+			Location:    risk.Locate("203.0.113.7"),
+			IssuedAt:    time.Now().Add(-10 * time.Minute),
+			ExpiresAt:   time.Now().Add(time.Hour),
+		},
+	}, nil)
+
+	tokens, err := auth.RefreshTokens(ctx, "valid-refresh-token", accessToken, "192.0.2.1", DeviceInput{})
+
+	assert.ErrorIs(t, err, ErrRiskDenied)
+	assert.Nil(t, tokens)
+	mockTokenRepo.AssertNotCalled(t, "MarkRefreshTokenUsed", mock.Anything, mock.Anything)
+	mockTokenRepo.AssertNotCalled(t, "CreateRefreshToken", mock.Anything, mock.Anything)
+}
+
+func TestAuth_RefreshTokens_NotifiesNewCountry(t *testing.T) {
+	ctx := context.Background()
+	mockUserRepo := new(mockUserRepo)
+	mockTokenRepo := new(mockTokenRepo)
+	mockNotifications := new(mockNotifications)
+	risk := newTestRiskEngine()
+
+	auth := newTestAuth(AuthDependencies{
+		UserRepo:      mockUserRepo,
+		TokenRepo:     mockTokenRepo,
+		Notifications: mockNotifications,
+		Risk:          risk,
+	})
+
+	accessToken, _ := auth.generateAccessToken("203.0.113.7", "user-id", nil, nil, nil, "", time.Now().Add(auth.tokenTTL))
+
+	user := &entity.User{ID: "user-id", Email: "test@example.com"}
+	mockUserRepo.On("GetUserByID", ctx, "user-id").Return(user, nil)
+	mockTokenRepo.On("GetRefreshTokenEntitiesByUserID", ctx, "user-id").Return([]entity.RefreshToken{
+		{
+			ID:          "token-id",
+			UserID:      "user-id",
+			RefreshHash: string(hashRefreshToken("valid-refresh-token")),
+			ClientIP:    "203.0.113.7",
+			Location:    risk.Locate("203.0.113.7"),
+			IssuedAt:    time.Now().Add(-5 * time.Hour),
+			ExpiresAt:   time.Now().Add(time.Hour),
+		},
+	}, nil)
+	mockTokenRepo.On("MarkRefreshTokenUsed", ctx, "token-id").Return(nil)
+	mockTokenRepo.On("CreateRefreshToken", ctx, mock.Anything).Return(nil)
+	mockNotifications.On("Notify", ctx, user, mock.MatchedBy(func(notification Notification) bool {
+		return notification.Key == "192.0.2.1"
+	})).Return(nil)
+
+	tokens, err := auth.RefreshTokens(ctx, "valid-refresh-token", accessToken, "192.0.2.1", DeviceInput{})
+
+	assert.NoError(t, err)
+	assert.NotNil(t, tokens)
+	mockNotifications.AssertExpectations(t)
+}
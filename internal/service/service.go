@@ -6,12 +6,159 @@ import (
 	"medods-tz/internal/entity"
 	"medods-tz/internal/repository"
 	"medods-tz/internal/sender"
+	"medods-tz/pkg/hasher"
+	"medods-tz/pkg/iplist"
+	"medods-tz/pkg/passwordpolicy"
 	"time"
 )
 
 type AuthService interface {
-	CreateTokens(ctx context.Context, userID, clientIP string) (*entity.Tokens, error)
-	RefreshTokens(ctx context.Context, refreshToken, accessToken string) (*entity.Tokens, error)
+	CreateTokens(ctx context.Context, userID, orgID, clientIP string, device DeviceInput) (*entity.Tokens, error)
+	RefreshTokens(ctx context.Context, refreshToken, accessToken, clientIP string, device DeviceInput) (*entity.Tokens, error)
+	Login(ctx context.Context, email, password, orgID, clientIP string, device DeviceInput) (*entity.Tokens, error)
+	CompleteStepUp(ctx context.Context, challengeID, code, clientIP string, device DeviceInput) (*entity.Tokens, error)
+	Logout(ctx context.Context, userID, orgID, refreshToken string) error
+	Authenticate(ctx context.Context, accessToken string) (*TokenClaims, error)
+}
+
+type AccountService interface {
+	Register(ctx context.Context, email, password, locale string) (string, error)
+	ChangeEmail(ctx context.Context, userID, email string) error
+	ResendVerificationEmail(ctx context.Context, userID string) error
+	VerifyEmail(ctx context.Context, verificationToken string) error
+	ForgotPassword(ctx context.Context, email string) error
+	ProcessPasswordResetRequests(ctx context.Context) error
+	ResetPassword(ctx context.Context, resetToken, newPassword string) error
+	SetLocale(ctx context.Context, userID, locale string) error
+	ReportSuspiciousLogin(ctx context.Context, reportToken string) error
+}
+
+type UserService interface {
+	CreateUser(ctx context.Context, user entity.User, password string) (*entity.User, error)
+	GetUser(ctx context.Context, id string) (*entity.User, error)
+	ListUsers(ctx context.Context, filter entity.UserFilter, cursor string) ([]entity.User, string, error)
+	UpdateUser(ctx context.Context, user entity.User) (*entity.User, error)
+	DeleteUser(ctx context.Context, id string) error
+}
+
+type BruteForceService interface {
+	Check(ctx context.Context, keys ...string) error
+	RegisterFailure(ctx context.Context, keys ...string) error
+	Failures(ctx context.Context, keys ...string) (int, error)
+	Reset(ctx context.Context, keys ...string) error
+	Unlock(ctx context.Context, unlockToken string) error
+}
+
+type StepUpService interface {
+	Challenge(ctx context.Context, user *entity.User, operation, clientIP string, location entity.GeoLocation, refreshTokenID *string, orgID string) (*StepUpRequiredError, error)
+	Verify(ctx context.Context, challengeID, code, clientIP string) (*entity.StepUpChallenge, error)
+	Consume(ctx context.Context, challengeID string) error
+}
+
+type DeviceService interface {
+	Recognize(ctx context.Context, userID string, input DeviceInput, sessionDeviceID *string) (*DeviceMatch, error)
+	Remember(ctx context.Context, userID string, match *DeviceMatch, clientIP string) (string, error)
+	ListDevices(ctx context.Context, userID string) ([]entity.Device, error)
+	RenameDevice(ctx context.Context, userID, deviceID, name string) error
+	ForgetDevice(ctx context.Context, userID, deviceID string) error
+	SignDeviceKey(key string) string
+	ParseDeviceCookie(value string) (string, bool)
+}
+
+type IPRuleService interface {
+	Check(ctx context.Context, userID, orgID, clientIP, operation string) error
+	ListIPRules(ctx context.Context, userID string) ([]entity.IPRule, error)
+	ListOrgIPRules(ctx context.Context, orgID string) ([]entity.IPRule, error)
+	CreateIPRule(ctx context.Context, rule entity.IPRule) (*entity.IPRule, error)
+	DeleteIPRule(ctx context.Context, userID, id string) error
+	DeleteOrgIPRule(ctx context.Context, orgID, id string) error
+}
+
+type OrganizationService interface {
+	CreateOrganization(ctx context.Context, org entity.Organization) (*entity.Organization, error)
+	GetOrganization(ctx context.Context, id string) (*entity.Organization, error)
+	ListOrganizations(ctx context.Context) ([]entity.Organization, error)
+	UpdateOrganization(ctx context.Context, org entity.Organization) (*entity.Organization, error)
+	DeleteOrganization(ctx context.Context, id string) error
+	SetMember(ctx context.Context, orgID, userID, role string) (*entity.Membership, error)
+	RemoveMember(ctx context.Context, orgID, userID string) error
+	ListMembers(ctx context.Context, orgID string) ([]entity.Membership, error)
+	ListUserOrganizations(ctx context.Context, userID string) ([]entity.Membership, error)
+	Membership(ctx context.Context, orgID, userID string) (*entity.Membership, error)
+	ListSessions(ctx context.Context, orgID string) ([]entity.Session, error)
+	ListMemberSessions(ctx context.Context, orgID, userID string) ([]entity.Session, error)
+}
+
+type TokenPolicyService interface {
+	GetTokenPolicy(ctx context.Context, orgID string) (*entity.TokenPolicy, error)
+	SetTokenPolicy(ctx context.Context, policy entity.TokenPolicy) (*entity.TokenPolicy, error)
+	RotateSigningKey(ctx context.Context, orgID string) (*entity.PublicSigningKey, error)
+	RemoveSigningKey(ctx context.Context, orgID string) error
+	PublicSigningKeys(ctx context.Context, orgID string) ([]entity.PublicSigningKey, error)
+}
+
+type RoleService interface {
+	CreatePermission(ctx context.Context, permission entity.Permission) (*entity.Permission, error)
+	ListPermissions(ctx context.Context) ([]entity.Permission, error)
+	DeletePermission(ctx context.Context, name string) error
+	CreateRole(ctx context.Context, role entity.Role) (*entity.Role, error)
+	GetRole(ctx context.Context, id string) (*entity.Role, error)
+	ListRoles(ctx context.Context) ([]entity.Role, error)
+	UpdateRole(ctx context.Context, role entity.Role) (*entity.Role, error)
+	DeleteRole(ctx context.Context, id string) error
+	AssignRole(ctx context.Context, userID, roleID string) error
+	UnassignRole(ctx context.Context, userID, roleID string) error
+	ListUserRoles(ctx context.Context, userID string) ([]entity.Role, error)
+	Grants(ctx context.Context, userID string) (roles, permissions []string, err error)
+	CheckPermission(ctx context.Context, userID, permission string) (bool, error)
+}
+
+type RelationService interface {
+	WriteTuples(ctx context.Context, writes, deletes []entity.RelationTuple) (string, error)
+	Check(ctx context.Context, userset, subject entity.RelationSubject, consistency Consistency) (bool, string, error)
+	Expand(ctx context.Context, userset entity.RelationSubject, consistency Consistency) (*ExpandNode, string, error)
+	ListObjects(ctx context.Context, namespace, relation string, subject entity.RelationSubject, consistency Consistency) ([]string, string, error)
+	PruneSnapshots(ctx context.Context) error
+}
+
+type RateLimitService interface {
+	Take(ctx context.Context, policy RateLimitPolicy, key string) (*RateLimitResult, error)
+	PruneBuckets(ctx context.Context) error
+}
+
+type AuditService interface {
+	Record(ctx context.Context, event entity.AuditEvent)
+	ListEvents(ctx context.Context, filter entity.AuditFilter, cursor string) ([]entity.AuditEvent, string, error)
+	CreateCheckpoints(ctx context.Context) error
+	VerifyChain(ctx context.Context) (*AuditChainReport, error)
+}
+
+type WebhookService interface {
+	CreateSubscription(ctx context.Context, subscription entity.WebhookSubscription) (*entity.WebhookSubscription, error)
+	GetSubscription(ctx context.Context, id string) (*entity.WebhookSubscription, error)
+	ListSubscriptions(ctx context.Context) ([]entity.WebhookSubscription, error)
+	UpdateSubscription(ctx context.Context, subscription entity.WebhookSubscription) (*entity.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id string) error
+	ListDeliveries(ctx context.Context, filter entity.WebhookDeliveryFilter, cursor string) ([]entity.WebhookDelivery, string, error)
+	Redeliver(ctx context.Context, id int64) error
+	Enqueue(ctx context.Context, eventType string, data map[string]string) error
+	DeliverPending(ctx context.Context) error
+}
+
+type EmailService interface {
+	ListMessages(ctx context.Context, filter entity.EmailMessageFilter, cursor string) ([]entity.EmailMessage, string, error)
+	Retry(ctx context.Context, id int64) error
+	DeliverPending(ctx context.Context) error
+	ListTemplates() (names []string, locales []string)
+	PreviewTemplate(name, locale string) (sender.Message, error)
+}
+
+type NotificationService interface {
+	Notify(ctx context.Context, user *entity.User, notification Notification) error
+	Channels() map[string]string
+	GetPreferences(ctx context.Context, userID string) ([]entity.NotificationPreference, error)
+	SetPreferences(ctx context.Context, userID string, preferences []entity.NotificationPreference) error
+	SendDigests(ctx context.Context) error
 }
 
 type ServicesDependencies struct {
@@ -20,20 +167,196 @@ type ServicesDependencies struct {
 	RefreshTokenTTL time.Duration
 	SignKey         string
 	SecurityLog     *logrus.Logger
-	Sender          *sender.Sender
+	SecurityEvents  SecurityEventPublisher
+	Transports      map[string]sender.Transport // by channel
+	EmailTemplates  *sender.Templates
+	EmailOutbox     EmailOutboxConfig
+	PasswordHasher  hasher.PasswordHasher
+	PasswordPolicy  *passwordpolicy.Policy
+	BruteForce      BruteForceConfig
+	Webhooks        WebhookConfig
+	Notifications   NotificationConfig
+	GeoLocator      GeoLocator
+	Geo             GeoPolicyConfig
+	BadIPs          *iplist.List
+	Risk            RiskConfig
+	StepUp          StepUpConfig
+	Relations       RelationConfig
+	RateLimit       RateLimitConfig
+
+	RequireVerifiedEmail bool
+	VerificationTokenTTL time.Duration
+	VerificationLinkURL  string
+	PasswordResetTTL     time.Duration
+	PasswordResetLinkURL string
+	LoginReport          LoginReportConfig
 }
 
 type Service struct {
 	AuthService
+	AccountService
+	UserService
+	BruteForceService
+	RateLimitService
+	AuditService
+	WebhookService
+	EmailService
+	NotificationService
+	DeviceService
+	IPRuleService
+	OrganizationService
+	TokenPolicyService
+	RoleService
+	RelationService
 }
 
 func NewService(dependencies ServicesDependencies) *Service {
-	return &Service{AuthService: NewAuth(
+	audit := NewAudit(
+		dependencies.Repository.AuditRepository,
+		dependencies.SignKey,
+		dependencies.SecurityLog,
+		dependencies.SecurityEvents)
+
+	emails := NewEmailOutbox(
+		dependencies.Repository.EmailRepository,
+		dependencies.Transports,
+		dependencies.EmailTemplates,
+		dependencies.EmailOutbox,
+		dependencies.SecurityLog)
+
+	notifications := NewNotifier(
+		dependencies.Repository.NotificationRepository,
+		dependencies.Repository.UserRepository,
+		dependencies.Repository.Transactor,
+		emails,
+		dependencies.Notifications,
+		dependencies.SecurityLog)
+
+	webhooks := NewWebhook(
+		dependencies.Repository.WebhookRepository,
+		dependencies.Webhooks,
+		dependencies.SecurityLog)
+
+	bruteForce := NewBruteForce(
+		dependencies.Repository.LoginAttemptRepository,
 		dependencies.Repository.UserRepository,
+		dependencies.Repository.AccountUnlockRepository,
+		dependencies.SignKey,
+		dependencies.BruteForce,
+		dependencies.SecurityLog,
+		audit,
+		emails)
+
+	risk := NewRiskEngine(
+		NewGeoPolicy(dependencies.GeoLocator, dependencies.Geo, dependencies.SecurityLog),
+		dependencies.BadIPs,
+		dependencies.Risk)
+
+	stepUp := NewStepUp(
+		dependencies.Repository.StepUpRepository,
+		dependencies.Repository.Transactor,
+		dependencies.SignKey,
+		dependencies.StepUp,
+		dependencies.SecurityLog,
+		audit,
+		emails)
+
+	devices := NewDevices(
+		dependencies.Repository.DeviceRepository,
 		dependencies.Repository.TokenRepository,
-		dependencies.TokenTTL,
-		dependencies.RefreshTokenTTL,
+		dependencies.Repository.Transactor,
 		dependencies.SignKey,
+		audit,
+		webhooks)
+
+	ipRules := NewIPRules(
+		dependencies.Repository.IPRuleRepository,
+		dependencies.Repository.UserRepository,
+		dependencies.Repository.OrganizationRepository,
+		dependencies.Repository.Transactor,
 		dependencies.SecurityLog,
-		dependencies.Sender.Email)}
+		audit)
+
+	orgs := NewOrganizations(
+		dependencies.Repository.OrganizationRepository,
+		dependencies.Repository.TokenRepository,
+		dependencies.Repository.Transactor,
+		webhooks)
+
+	policies := NewTokenPolicies(
+		dependencies.Repository.TokenPolicyRepository,
+		dependencies.Repository.OrganizationRepository,
+		dependencies.Repository.Transactor,
+		dependencies.RefreshTokenTTL)
+
+	roles := NewRoles(
+		dependencies.Repository.RoleRepository,
+		dependencies.Repository.UserRepository,
+		dependencies.Repository.Transactor)
+
+	return &Service{
+		AuthService: NewAuth(AuthDependencies{
+			UserRepo:             dependencies.Repository.UserRepository,
+			TokenRepo:            dependencies.Repository.TokenRepository,
+			Transactor:           dependencies.Repository.Transactor,
+			TokenTTL:             dependencies.TokenTTL,
+			RefreshTokenTTL:      dependencies.RefreshTokenTTL,
+			SignKey:              dependencies.SignKey,
+			SecurityLog:          dependencies.SecurityLog,
+			Audit:                audit,
+			Webhooks:             webhooks,
+			Notifications:        notifications,
+			PasswordHasher:       dependencies.PasswordHasher,
+			BruteForce:           bruteForce,
+			LoginReports:         dependencies.LoginReport,
+			Risk:                 risk,
+			StepUp:               stepUp,
+			Devices:              devices,
+			IPRules:              ipRules,
+			Roles:                roles,
+			Orgs:                 orgs,
+			Policies:             policies,
+			RequireVerifiedEmail: dependencies.RequireVerifiedEmail,
+		}),
+		AccountService: NewAccount(
+			dependencies.Repository.UserRepository,
+			dependencies.Repository.TokenRepository,
+			dependencies.Repository.PasswordResetRepository,
+			dependencies.Repository.LoginReportRepository,
+			dependencies.Repository.Transactor,
+			dependencies.SignKey,
+			dependencies.VerificationTokenTTL,
+			dependencies.VerificationLinkURL,
+			dependencies.PasswordResetTTL,
+			dependencies.PasswordResetLinkURL,
+			dependencies.SecurityLog,
+			audit,
+			webhooks,
+			emails,
+			dependencies.PasswordHasher,
+			dependencies.PasswordPolicy),
+		UserService: NewUsers(
+			dependencies.Repository.UserRepository,
+			dependencies.Repository.TokenRepository,
+			dependencies.Repository.Transactor,
+			dependencies.SecurityLog,
+			webhooks,
+			dependencies.PasswordHasher,
+			dependencies.PasswordPolicy),
+		BruteForceService:   bruteForce,
+		RateLimitService:    NewRateLimiter(dependencies.Repository.RateLimitRepository, dependencies.RateLimit),
+		AuditService:        audit,
+		WebhookService:      webhooks,
+		EmailService:        emails,
+		NotificationService: notifications,
+		DeviceService:       devices,
+		IPRuleService:       ipRules,
+		OrganizationService: orgs,
+		TokenPolicyService:  policies,
+		RoleService:         roles,
+		RelationService: NewRelations(
+			dependencies.Repository.RelationRepository,
+			dependencies.Repository.Transactor,
+			dependencies.Relations),
+	}
 }
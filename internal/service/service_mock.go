@@ -4,17 +4,69 @@ import (
 	"context"
 	"github.com/stretchr/testify/mock"
 	"medods-tz/internal/entity"
+	"medods-tz/internal/sender"
+	"time"
 )
 
 type mockUserRepo struct {
 	mock.Mock
 }
 
+func (m *mockUserRepo) CreateUser(ctx context.Context, user entity.User) (string, error) {
+	args := m.Called(ctx, user)
+	return args.String(0), args.Error(1)
+}
+
 func (m *mockUserRepo) GetUserByID(ctx context.Context, userID string) (*entity.User, error) {
 	args := m.Called(ctx, userID)
 	return args.Get(0).(*entity.User), args.Error(1)
 }
 
+func (m *mockUserRepo) GetUserByEmail(ctx context.Context, email string) (*entity.User, error) {
+	args := m.Called(ctx, email)
+	return args.Get(0).(*entity.User), args.Error(1)
+}
+
+func (m *mockUserRepo) UpdateUserPassword(ctx context.Context, userID, passwordHash string) error {
+	args := m.Called(ctx, userID, passwordHash)
+	return args.Error(0)
+}
+
+func (m *mockUserRepo) UpdateUserEmail(ctx context.Context, userID, email string) error {
+	args := m.Called(ctx, userID, email)
+	return args.Error(0)
+}
+
+func (m *mockUserRepo) MarkEmailVerified(ctx context.Context, userID string, verifiedAt time.Time) error {
+	args := m.Called(ctx, userID, verifiedAt)
+	return args.Error(0)
+}
+
+func (m *mockUserRepo) UpdateUserLocale(ctx context.Context, userID, locale string) error {
+	args := m.Called(ctx, userID, locale)
+	return args.Error(0)
+}
+
+func (m *mockUserRepo) SetPasswordResetRequired(ctx context.Context, userID string, required bool) error {
+	args := m.Called(ctx, userID, required)
+	return args.Error(0)
+}
+
+func (m *mockUserRepo) ListUsers(ctx context.Context, filter entity.UserFilter) ([]entity.User, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]entity.User), args.Error(1)
+}
+
+func (m *mockUserRepo) UpdateUser(ctx context.Context, user entity.User) error {
+	args := m.Called(ctx, user)
+	return args.Error(0)
+}
+
+func (m *mockUserRepo) DeleteUser(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
 type mockTokenRepo struct {
 	mock.Mock
 }
@@ -34,16 +86,637 @@ func (m *mockTokenRepo) MarkRefreshTokenUsed(ctx context.Context, tokenID string
 	return args.Error(0)
 }
 
+func (m *mockTokenRepo) RevokeRefreshToken(ctx context.Context, tokenID string) error {
+	args := m.Called(ctx, tokenID)
+	return args.Error(0)
+}
+
+func (m *mockTokenRepo) RevokeRefreshTokensByUserID(ctx context.Context, userID string) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *mockTokenRepo) RevokeRefreshTokensByDeviceID(ctx context.Context, deviceID string) error {
+	args := m.Called(ctx, deviceID)
+	return args.Error(0)
+}
+
+func (m *mockTokenRepo) ListOrgRefreshTokens(ctx context.Context, orgID string, at time.Time) ([]entity.RefreshToken, error) {
+	args := m.Called(ctx, orgID, at)
+	return args.Get(0).([]entity.RefreshToken), args.Error(1)
+}
+
+func (m *mockTokenRepo) RevokeOrgRefreshTokensByUserID(ctx context.Context, orgID, userID string) error {
+	args := m.Called(ctx, orgID, userID)
+	return args.Error(0)
+}
+
+type mockAuditRepo struct {
+	mock.Mock
+}
+
+func (m *mockAuditRepo) CreateAuditEvent(ctx context.Context, event entity.AuditEvent) (int64, error) {
+	args := m.Called(ctx, event)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *mockAuditRepo) ListAuditEvents(ctx context.Context, filter entity.AuditFilter) ([]entity.AuditEvent, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]entity.AuditEvent), args.Error(1)
+}
+
+func (m *mockAuditRepo) ListAuditChain(ctx context.Context, afterID int64, limit int) ([]entity.AuditEvent, error) {
+	args := m.Called(ctx, afterID, limit)
+	return args.Get(0).([]entity.AuditEvent), args.Error(1)
+}
+
+func (m *mockAuditRepo) ListUncheckpointedAuditDays(ctx context.Context, before time.Time) ([]time.Time, error) {
+	args := m.Called(ctx, before)
+	return args.Get(0).([]time.Time), args.Error(1)
+}
+
+func (m *mockAuditRepo) GetAuditDaySummary(ctx context.Context, day time.Time) (*entity.AuditCheckpoint, error) {
+	args := m.Called(ctx, day)
+	return args.Get(0).(*entity.AuditCheckpoint), args.Error(1)
+}
+
+func (m *mockAuditRepo) CreateAuditCheckpoint(ctx context.Context, checkpoint entity.AuditCheckpoint) error {
+	args := m.Called(ctx, checkpoint)
+	return args.Error(0)
+}
+
+func (m *mockAuditRepo) GetLastAuditCheckpoint(ctx context.Context) (*entity.AuditCheckpoint, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(*entity.AuditCheckpoint), args.Error(1)
+}
+
+func (m *mockAuditRepo) ListAuditCheckpoints(ctx context.Context) ([]entity.AuditCheckpoint, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]entity.AuditCheckpoint), args.Error(1)
+}
+
+type mockPasswordResetRepo struct {
+	mock.Mock
+}
+
+func (m *mockPasswordResetRepo) CreatePasswordResetToken(ctx context.Context, token entity.PasswordResetToken) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+func (m *mockPasswordResetRepo) GetPasswordResetTokenByHash(ctx context.Context, tokenHash string) (*entity.PasswordResetToken, error) {
+	args := m.Called(ctx, tokenHash)
+	return args.Get(0).(*entity.PasswordResetToken), args.Error(1)
+}
+
+func (m *mockPasswordResetRepo) MarkPasswordResetTokenUsed(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *mockPasswordResetRepo) CreatePasswordResetRequest(ctx context.Context, request entity.PasswordResetRequest) error {
+	args := m.Called(ctx, request)
+	return args.Error(0)
+}
+
+func (m *mockPasswordResetRepo) ClaimPasswordResetRequests(ctx context.Context, limit int) ([]entity.PasswordResetRequest, error) {
+	args := m.Called(ctx, limit)
+	return args.Get(0).([]entity.PasswordResetRequest), args.Error(1)
+}
+
 type mockEmail struct {
 	mock.Mock
 }
 
-func (m *mockEmail) SendWarningEmail(toEmail, subject, body string) error {
-	args := m.Called(toEmail, subject, body)
+func (m *mockEmail) SendSuspiciousLoginEmail(ctx context.Context, to sender.Recipient, data sender.SuspiciousLoginData) error {
+	args := m.Called(ctx, to, data)
+	return args.Error(0)
+}
+
+func (m *mockEmail) SendVerificationEmail(ctx context.Context, to sender.Recipient, link string) error {
+	args := m.Called(ctx, to, link)
+	return args.Error(0)
+}
+
+func (m *mockEmail) SendPasswordResetEmail(ctx context.Context, to sender.Recipient, link string) error {
+	args := m.Called(ctx, to, link)
 	return args.Error(0)
 }
 
-func (m *mockEmail) EnsureSMTPConnection() error {
+func (m *mockEmail) SendAccountUnlockEmail(ctx context.Context, to sender.Recipient, link string) error {
+	args := m.Called(ctx, to, link)
+	return args.Error(0)
+}
+
+func (m *mockEmail) SendStepUpCodeEmail(ctx context.Context, to sender.Recipient, data sender.StepUpCodeData) error {
+	args := m.Called(ctx, to, data)
+	return args.Error(0)
+}
+
+type mockNotifications struct {
+	mock.Mock
+}
+
+func (m *mockNotifications) Notify(ctx context.Context, user *entity.User, notification Notification) error {
+	args := m.Called(ctx, user, notification)
+	return args.Error(0)
+}
+
+func (m *mockNotifications) Channels() map[string]string {
 	args := m.Called()
+	return args.Get(0).(map[string]string)
+}
+
+func (m *mockNotifications) GetPreferences(ctx context.Context, userID string) ([]entity.NotificationPreference, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]entity.NotificationPreference), args.Error(1)
+}
+
+func (m *mockNotifications) SetPreferences(ctx context.Context, userID string, preferences []entity.NotificationPreference) error {
+	args := m.Called(ctx, userID, preferences)
+	return args.Error(0)
+}
+
+func (m *mockNotifications) SendDigests(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+type mockNotificationRepo struct {
+	mock.Mock
+}
+
+func (m *mockNotificationRepo) ListNotificationPreferences(ctx context.Context, userID string) ([]entity.NotificationPreference, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]entity.NotificationPreference), args.Error(1)
+}
+
+func (m *mockNotificationRepo) ReplaceNotificationPreferences(ctx context.Context, userID string, preferences []entity.NotificationPreference) error {
+	args := m.Called(ctx, userID, preferences)
+	return args.Error(0)
+}
+
+func (m *mockNotificationRepo) ThrottleNotification(ctx context.Context, userID, template, key string, now, windowStart time.Time) (bool, error) {
+	args := m.Called(ctx, userID, template, key, now, windowStart)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockNotificationRepo) CountNotificationsSince(ctx context.Context, userID, template string, since time.Time) (int, error) {
+	args := m.Called(ctx, userID, template, since)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *mockNotificationRepo) DeleteNotificationThrottles(ctx context.Context, sentBefore time.Time) error {
+	args := m.Called(ctx, sentBefore)
+	return args.Error(0)
+}
+
+func (m *mockNotificationRepo) CreateNotificationDigestItem(ctx context.Context, item entity.NotificationDigestItem) error {
+	args := m.Called(ctx, item)
+	return args.Error(0)
+}
+
+func (m *mockNotificationRepo) ListDueNotificationDigests(ctx context.Context, createdBefore time.Time) ([]string, error) {
+	args := m.Called(ctx, createdBefore)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *mockNotificationRepo) TakeNotificationDigestItems(ctx context.Context, userID string) ([]entity.NotificationDigestItem, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]entity.NotificationDigestItem), args.Error(1)
+}
+
+// mockTransactor runs fn without a transaction.
+type mockTransactor struct{}
+
+func (mockTransactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func (mockTransactor) WithinTenant(ctx context.Context, orgID string, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func (mockTransactor) WithinAllTenants(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+type mockWebhookRepo struct {
+	mock.Mock
+}
+
+func (m *mockWebhookRepo) CreateWebhookSubscription(ctx context.Context, subscription entity.WebhookSubscription) (string, error) {
+	args := m.Called(ctx, subscription)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockWebhookRepo) GetWebhookSubscription(ctx context.Context, id string) (*entity.WebhookSubscription, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*entity.WebhookSubscription), args.Error(1)
+}
+
+func (m *mockWebhookRepo) ListWebhookSubscriptions(ctx context.Context) ([]entity.WebhookSubscription, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]entity.WebhookSubscription), args.Error(1)
+}
+
+func (m *mockWebhookRepo) ListWebhookSubscriptionsForEvent(ctx context.Context, eventType string) ([]entity.WebhookSubscription, error) {
+	args := m.Called(ctx, eventType)
+	return args.Get(0).([]entity.WebhookSubscription), args.Error(1)
+}
+
+func (m *mockWebhookRepo) UpdateWebhookSubscription(ctx context.Context, subscription entity.WebhookSubscription) error {
+	args := m.Called(ctx, subscription)
+	return args.Error(0)
+}
+
+func (m *mockWebhookRepo) DeleteWebhookSubscription(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *mockWebhookRepo) CreateWebhookDeliveries(ctx context.Context, deliveries []entity.WebhookDelivery) error {
+	args := m.Called(ctx, deliveries)
+	return args.Error(0)
+}
+
+func (m *mockWebhookRepo) ClaimWebhookDeliveries(ctx context.Context, now, lockedUntil time.Time, limit int) ([]entity.WebhookDelivery, error) {
+	args := m.Called(ctx, now, lockedUntil, limit)
+	return args.Get(0).([]entity.WebhookDelivery), args.Error(1)
+}
+
+func (m *mockWebhookRepo) UpdateWebhookDelivery(ctx context.Context, delivery entity.WebhookDelivery) error {
+	args := m.Called(ctx, delivery)
+	return args.Error(0)
+}
+
+func (m *mockWebhookRepo) RequeueWebhookDelivery(ctx context.Context, id int64, at time.Time) error {
+	args := m.Called(ctx, id, at)
+	return args.Error(0)
+}
+
+func (m *mockWebhookRepo) ListWebhookDeliveries(ctx context.Context, filter entity.WebhookDeliveryFilter) ([]entity.WebhookDelivery, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]entity.WebhookDelivery), args.Error(1)
+}
+
+type mockEmailRepo struct {
+	mock.Mock
+}
+
+func (m *mockEmailRepo) CreateEmailMessage(ctx context.Context, message entity.EmailMessage) (int64, error) {
+	args := m.Called(ctx, message)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *mockEmailRepo) ClaimEmailMessages(ctx context.Context, now, lockedUntil time.Time, limit int) ([]entity.EmailMessage, error) {
+	args := m.Called(ctx, now, lockedUntil, limit)
+	return args.Get(0).([]entity.EmailMessage), args.Error(1)
+}
+
+func (m *mockEmailRepo) UpdateEmailMessage(ctx context.Context, message entity.EmailMessage) error {
+	args := m.Called(ctx, message)
+	return args.Error(0)
+}
+
+func (m *mockEmailRepo) RequeueEmailMessage(ctx context.Context, id int64, at time.Time) error {
+	args := m.Called(ctx, id, at)
+	return args.Error(0)
+}
+
+func (m *mockEmailRepo) ListEmailMessages(ctx context.Context, filter entity.EmailMessageFilter) ([]entity.EmailMessage, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]entity.EmailMessage), args.Error(1)
+}
+
+type mockEmailTransport struct {
+	mock.Mock
+}
+
+func (m *mockEmailTransport) Send(ctx context.Context, message sender.Message) error {
+	args := m.Called(ctx, message)
+	return args.Error(0)
+}
+
+type mockAccountUnlockRepo struct {
+	mock.Mock
+}
+
+func (m *mockAccountUnlockRepo) CreateAccountUnlock(ctx context.Context, unlock entity.AccountUnlock) error {
+	args := m.Called(ctx, unlock)
+	return args.Error(0)
+}
+
+type mockLoginReportRepo struct {
+	mock.Mock
+}
+
+func (m *mockLoginReportRepo) CreateLoginReport(ctx context.Context, report entity.LoginReport) error {
+	args := m.Called(ctx, report)
+	return args.Error(0)
+}
+
+type mockStepUpRepo struct {
+	mock.Mock
+}
+
+func (m *mockStepUpRepo) CreateStepUpChallenge(ctx context.Context, challenge entity.StepUpChallenge) (string, error) {
+	args := m.Called(ctx, challenge)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockStepUpRepo) ReserveStepUpAttempt(ctx context.Context, id string, maxAttempts int) (*entity.StepUpChallenge, error) {
+	args := m.Called(ctx, id, maxAttempts)
+	return args.Get(0).(*entity.StepUpChallenge), args.Error(1)
+}
+
+func (m *mockStepUpRepo) MarkStepUpChallengeUsed(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+type mockDeviceRepo struct {
+	mock.Mock
+}
+
+func (m *mockDeviceRepo) UpsertDevice(ctx context.Context, device entity.Device) (string, error) {
+	args := m.Called(ctx, device)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockDeviceRepo) GetDevice(ctx context.Context, userID, id string) (*entity.Device, error) {
+	args := m.Called(ctx, userID, id)
+	return args.Get(0).(*entity.Device), args.Error(1)
+}
+
+func (m *mockDeviceRepo) GetDeviceByKeyHash(ctx context.Context, userID, keyHash string) (*entity.Device, error) {
+	args := m.Called(ctx, userID, keyHash)
+	return args.Get(0).(*entity.Device), args.Error(1)
+}
+
+func (m *mockDeviceRepo) ListDevices(ctx context.Context, userID string) ([]entity.Device, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]entity.Device), args.Error(1)
+}
+
+func (m *mockDeviceRepo) CountDevices(ctx context.Context, userID string) (int, error) {
+	args := m.Called(ctx, userID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *mockDeviceRepo) TouchDevice(ctx context.Context, id, ip string, at time.Time) error {
+	args := m.Called(ctx, id, ip, at)
+	return args.Error(0)
+}
+
+func (m *mockDeviceRepo) RenameDevice(ctx context.Context, userID, id, name string) error {
+	args := m.Called(ctx, userID, id, name)
+	return args.Error(0)
+}
+
+func (m *mockDeviceRepo) DeleteDevice(ctx context.Context, userID, id string) error {
+	args := m.Called(ctx, userID, id)
+	return args.Error(0)
+}
+
+type mockIPRuleRepo struct {
+	mock.Mock
+}
+
+func (m *mockIPRuleRepo) CreateIPRule(ctx context.Context, rule entity.IPRule) (string, error) {
+	args := m.Called(ctx, rule)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockIPRuleRepo) ListIPRules(ctx context.Context, userID string) ([]entity.IPRule, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]entity.IPRule), args.Error(1)
+}
+
+func (m *mockIPRuleRepo) DeleteIPRule(ctx context.Context, userID, id string) error {
+	args := m.Called(ctx, userID, id)
+	return args.Error(0)
+}
+
+func (m *mockIPRuleRepo) ListOrgIPRules(ctx context.Context, orgID string) ([]entity.IPRule, error) {
+	args := m.Called(ctx, orgID)
+	return args.Get(0).([]entity.IPRule), args.Error(1)
+}
+
+func (m *mockIPRuleRepo) DeleteOrgIPRule(ctx context.Context, orgID, id string) error {
+	args := m.Called(ctx, orgID, id)
 	return args.Error(0)
 }
+
+type mockOrganizationRepo struct {
+	mock.Mock
+}
+
+func (m *mockOrganizationRepo) CreateOrganization(ctx context.Context, org entity.Organization) (string, error) {
+	args := m.Called(ctx, org)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockOrganizationRepo) GetOrganization(ctx context.Context, id string) (*entity.Organization, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*entity.Organization), args.Error(1)
+}
+
+func (m *mockOrganizationRepo) ListOrganizations(ctx context.Context) ([]entity.Organization, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]entity.Organization), args.Error(1)
+}
+
+func (m *mockOrganizationRepo) UpdateOrganization(ctx context.Context, org entity.Organization) error {
+	args := m.Called(ctx, org)
+	return args.Error(0)
+}
+
+func (m *mockOrganizationRepo) DeleteOrganization(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *mockOrganizationRepo) UpsertMembership(ctx context.Context, membership entity.Membership) error {
+	args := m.Called(ctx, membership)
+	return args.Error(0)
+}
+
+func (m *mockOrganizationRepo) GetMembership(ctx context.Context, orgID, userID string) (*entity.Membership, error) {
+	args := m.Called(ctx, orgID, userID)
+	return args.Get(0).(*entity.Membership), args.Error(1)
+}
+
+func (m *mockOrganizationRepo) ListMemberships(ctx context.Context, orgID string) ([]entity.Membership, error) {
+	args := m.Called(ctx, orgID)
+	return args.Get(0).([]entity.Membership), args.Error(1)
+}
+
+func (m *mockOrganizationRepo) ListUserMemberships(ctx context.Context, userID string) ([]entity.Membership, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]entity.Membership), args.Error(1)
+}
+
+func (m *mockOrganizationRepo) DeleteMembership(ctx context.Context, orgID, userID string) error {
+	args := m.Called(ctx, orgID, userID)
+	return args.Error(0)
+}
+
+type mockTokenPolicyRepo struct {
+	mock.Mock
+}
+
+func (m *mockTokenPolicyRepo) GetTokenPolicy(ctx context.Context, orgID string) (*entity.TokenPolicy, error) {
+	args := m.Called(ctx, orgID)
+	return args.Get(0).(*entity.TokenPolicy), args.Error(1)
+}
+
+func (m *mockTokenPolicyRepo) UpsertTokenPolicy(ctx context.Context, policy entity.TokenPolicy) error {
+	args := m.Called(ctx, policy)
+	return args.Error(0)
+}
+
+func (m *mockTokenPolicyRepo) UpdateTokenPolicyKeys(ctx context.Context, policy entity.TokenPolicy) error {
+	args := m.Called(ctx, policy)
+	return args.Error(0)
+}
+
+type mockRoleRepo struct {
+	mock.Mock
+}
+
+func (m *mockRoleRepo) CreatePermission(ctx context.Context, permission entity.Permission) error {
+	args := m.Called(ctx, permission)
+	return args.Error(0)
+}
+
+func (m *mockRoleRepo) ListPermissions(ctx context.Context) ([]entity.Permission, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]entity.Permission), args.Error(1)
+}
+
+func (m *mockRoleRepo) DeletePermission(ctx context.Context, name string) error {
+	args := m.Called(ctx, name)
+	return args.Error(0)
+}
+
+func (m *mockRoleRepo) CreateRole(ctx context.Context, role entity.Role) (string, error) {
+	args := m.Called(ctx, role)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockRoleRepo) GetRole(ctx context.Context, id string) (*entity.Role, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*entity.Role), args.Error(1)
+}
+
+func (m *mockRoleRepo) ListRoles(ctx context.Context) ([]entity.Role, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]entity.Role), args.Error(1)
+}
+
+func (m *mockRoleRepo) UpdateRole(ctx context.Context, role entity.Role) error {
+	args := m.Called(ctx, role)
+	return args.Error(0)
+}
+
+func (m *mockRoleRepo) DeleteRole(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *mockRoleRepo) SetRolePermissions(ctx context.Context, roleID string, permissions []string) error {
+	args := m.Called(ctx, roleID, permissions)
+	return args.Error(0)
+}
+
+func (m *mockRoleRepo) AssignRole(ctx context.Context, userID, roleID string) error {
+	args := m.Called(ctx, userID, roleID)
+	return args.Error(0)
+}
+
+func (m *mockRoleRepo) UnassignRole(ctx context.Context, userID, roleID string) error {
+	args := m.Called(ctx, userID, roleID)
+	return args.Error(0)
+}
+
+func (m *mockRoleRepo) ListUserRoles(ctx context.Context, userID string) ([]entity.Role, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]entity.Role), args.Error(1)
+}
+
+// mockRelationRepo keeps relation tuples in memory with their revisions, so checks can walk real graphs.
+type mockRelationRepo struct {
+	revisions []time.Time
+	oldest    int64
+	tuples    []mockRelationTuple
+}
+
+type mockRelationTuple struct {
+	entity.RelationTuple
+	created int64
+	deleted int64
+}
+
+func (t mockRelationTuple) visibleAt(revision int64) bool {
+	return t.created <= revision && (t.deleted == 0 || t.deleted > revision)
+}
+
+func (m *mockRelationRepo) CreateRelationRevision(_ context.Context) (int64, error) {
+	m.revisions = append(m.revisions, time.Now())
+	if m.oldest == 0 {
+		m.oldest = 1
+	}
+
+	return int64(len(m.revisions)), nil
+}
+
+func (m *mockRelationRepo) GetRelationRevisions(_ context.Context) (int64, int64, error) {
+	return m.oldest, int64(len(m.revisions)), nil
+}
+
+func (m *mockRelationRepo) WriteRelationTuples(_ context.Context, writes, deletes []entity.RelationTuple, revision int64) error {
+	for _, tuple := range deletes {
+		for i := range m.tuples {
+			if m.tuples[i].RelationTuple == tuple && m.tuples[i].deleted == 0 {
+				m.tuples[i].deleted = revision
+			}
+		}
+	}
+
+	for _, tuple := range writes {
+		m.tuples = append(m.tuples, mockRelationTuple{RelationTuple: tuple, created: revision})
+	}
+
+	return nil
+}
+
+func (m *mockRelationRepo) ListRelationSubjects(_ context.Context, namespace, objectID, relation string, revision int64) ([]entity.RelationSubject, error) {
+	var subjects []entity.RelationSubject
+	for _, tuple := range m.tuples {
+		if tuple.visibleAt(revision) && tuple.Namespace == namespace && tuple.ObjectID == objectID && tuple.Relation == relation {
+			subjects = append(subjects, tuple.Subject)
+		}
+	}
+
+	return subjects, nil
+}
+
+func (m *mockRelationRepo) ListRelationTuplesBySubject(_ context.Context, subject entity.RelationSubject, revision int64) ([]entity.RelationTuple, error) {
+	var tuples []entity.RelationTuple
+	for _, tuple := range m.tuples {
+		if tuple.visibleAt(revision) && tuple.Subject == subject {
+			tuples = append(tuples, tuple.RelationTuple)
+		}
+	}
+
+	return tuples, nil
+}
+
+func (m *mockRelationRepo) PruneRelationRevisions(_ context.Context, before time.Time) error {
+	for m.oldest < int64(len(m.revisions)) && m.revisions[m.oldest-1].Before(before) {
+		m.oldest++
+	}
+
+	return nil
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"math/big"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository"
+	"medods-tz/internal/repository/repoerrors"
+	"medods-tz/internal/sender"
+	"strconv"
+	"time"
+)
+
+const stepUpCodeDigits = 6
+
+type StepUpConfig struct {
+	CodeTTL time.Duration
+	// MaxAttempts wrong codes spend a challenge.
+	MaxAttempts int
+}
+
+// StepUp holds back risky sign-ins until the user enters a one-time code sent to their email address.
+type StepUp struct {
+	stepUpRepo  repository.StepUpRepository
+	transactor  repository.Transactor
+	signKey     string
+	config      StepUpConfig
+	securityLog *logrus.Logger
+	audit       AuditService
+	emailSender sender.Email
+}
+
+func NewStepUp(
+	stepUpRepo repository.StepUpRepository,
+	transactor repository.Transactor,
+	signKey string,
+	config StepUpConfig,
+	securityLog *logrus.Logger,
+	audit AuditService,
+	emailSender sender.Email) *StepUp {
+	return &StepUp{
+		stepUpRepo:  stepUpRepo,
+		transactor:  transactor,
+		signKey:     signKey,
+		config:      config,
+		securityLog: securityLog,
+		audit:       audit,
+		emailSender: emailSender,
+	}
+}
+
+// Challenge stores a challenge for the sign-in of user from clientIP and emails its code. The returned
+// error tells the client which challenge to complete. refreshTokenID is the session being refreshed, if any,
+// and orgID the organization a new session is started for.
+func (s *StepUp) Challenge(ctx context.Context, user *entity.User, operation, clientIP string, location entity.GeoLocation, refreshTokenID *string, orgID string) (*StepUpRequiredError, error) {
+	// without a verified way to reach the user there is no second factor to ask for
+	if user.Email == "" || user.EmailVerifiedAt == nil {
+		return nil, ErrRiskDenied
+	}
+
+	code, err := generateStepUpCode()
+	if err != nil {
+		return nil, fmt.Errorf("error while generating step-up code: %w", err)
+	}
+
+	now := time.Now()
+	challenge := entity.StepUpChallenge{
+		UserID:         user.ID,
+		CodeHash:       s.hashCode(code),
+		ClientIP:       clientIP,
+		Operation:      operation,
+		RefreshTokenID: refreshTokenID,
+		CreatedAt:      now,
+		ExpiresAt:      now.Add(s.config.CodeTTL),
+	}
+	if orgID != "" {
+		challenge.OrgID = &orgID
+	}
+
+	var challengeID string
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		challengeID, err = s.stepUpRepo.CreateStepUpChallenge(ctx, challenge)
+		if err != nil {
+			return fmt.Errorf("error while creating step-up challenge: %w", err)
+		}
+
+		return s.emailSender.SendStepUpCodeEmail(ctx, emailRecipient(user), sender.StepUpCodeData{
+			Code:      code,
+			IP:        clientIP,
+			Location:  location.String(),
+			ExpiresAt: challenge.ExpiresAt,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &StepUpRequiredError{ChallengeID: challengeID, ExpiresAt: challenge.ExpiresAt}, nil
+}
+
+// Verify checks the code of a challenge from clientIP. Every attempt counts against the challenge and is
+// counted before the code is compared, so concurrent guesses cannot exceed the maximum.
+// The challenge is not spent, Consume does that together with issuing the tokens.
+func (s *StepUp) Verify(ctx context.Context, challengeID, code, clientIP string) (*entity.StepUpChallenge, error) {
+	challenge, err := s.stepUpRepo.ReserveStepUpAttempt(ctx, challengeID, s.config.MaxAttempts)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return nil, ErrInvalidStepUpCode
+		}
+
+		return nil, fmt.Errorf("error while counting step-up attempt: %w", err)
+	}
+
+	// the code only completes the sign-in it was sent for
+	if clientIP == challenge.ClientIP && hmac.Equal([]byte(s.hashCode(code)), []byte(challenge.CodeHash)) {
+		return challenge, nil
+	}
+
+	s.securityLog.Warnf("wrong step-up code for challenge id=%s of user_id=%s from ip=%s", challenge.ID, challenge.UserID, clientIP)
+	s.audit.Record(ctx, entity.AuditEvent{
+		Type:      entity.AuditStepUpFailed,
+		SubjectID: challenge.UserID,
+		IP:        clientIP,
+		Metadata: map[string]string{
+			"challenge_id": challenge.ID,
+			"attempts":     strconv.Itoa(challenge.Attempts),
+		},
+	})
+
+	return nil, ErrInvalidStepUpCode
+}
+
+// Consume spends a verified challenge, it fails when the challenge was already used.
+func (s *StepUp) Consume(ctx context.Context, challengeID string) error {
+	err := s.stepUpRepo.MarkStepUpChallengeUsed(ctx, challengeID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrInvalidStepUpCode
+		}
+
+		return fmt.Errorf("error while marking step-up challenge as used: %w", err)
+	}
+
+	return nil
+}
+
+// hashCode keys the hash with the sign key, a plain hash of six digits would be reversed instantly.
+func (s *StepUp) hashCode(code string) string {
+	mac := hmac.New(sha256.New, []byte(s.signKey))
+	mac.Write([]byte(code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func generateStepUpCode() (string, error) {
+	limit := big.NewInt(1)
+	for i := 0; i < stepUpCodeDigits; i++ {
+		limit.Mul(limit, big.NewInt(10))
+	}
+
+	n, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%0*d", stepUpCodeDigits, n), nil
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository/repoerrors"
+	"medods-tz/internal/sender"
+	"medods-tz/pkg/iplist"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testStepUpConfig = StepUpConfig{
+	CodeTTL:     10 * time.Minute,
+	MaxAttempts: 3,
+}
+
+func newTestStepUp() *StepUp {
+	return NewStepUp(new(mockStepUpRepo), mockTransactor{}, "test-sign-key", testStepUpConfig, logrus.New(), newTestAudit(), new(mockEmail))
+}
+
+func TestAuth_Login_StepUp(t *testing.T) {
+	ctx := context.Background()
+	mockUserRepo := new(mockUserRepo)
+	mockTokenRepo := new(mockTokenRepo)
+	mockStepUpRepo := new(mockStepUpRepo)
+	mockEmail := new(mockEmail)
+
+	badIPs, _ := iplist.Parse(strings.NewReader("198.51.100.0/24\n"))
+	stepUp := NewStepUp(mockStepUpRepo, mockTransactor{}, "test-sign-key", testStepUpConfig, logrus.New(), newTestAudit(), mockEmail)
+	passwordHasher := newTestPasswordHasher()
+	auth := newTestAuth(AuthDependencies{
+		UserRepo:       mockUserRepo,
+		TokenRepo:      mockTokenRepo,
+		PasswordHasher: passwordHasher,
+		Risk:           NewRiskEngine(newTestGeoPolicy(), badIPs, testRiskConfig),
+		StepUp:         stepUp,
+	})
+
+	verifiedAt := time.Now()
+	passwordHash, _ := passwordHasher.Hash("password123")
+	user := &entity.User{ID: "user-id", Email: "test@example.com", PasswordHash: passwordHash, EmailVerifiedAt: &verifiedAt}
+	mockUserRepo.On("GetUserByEmail", ctx, "test@example.com").Return(user, nil)
+	mockUserRepo.On("GetUserByID", ctx, "user-id").Return(user, nil)
+
+	var challenge entity.StepUpChallenge
+	mockStepUpRepo.On("CreateStepUpChallenge", ctx, mock.Anything).
+		Run(func(args mock.Arguments) { challenge = args.Get(1).(entity.StepUpChallenge) }).
+		Return("challenge-id", nil)
+	var code string
+	mockEmail.On("SendStepUpCodeEmail", ctx, sender.Recipient{Email: "test@example.com"}, mock.Anything).
+		Run(func(args mock.Arguments) { code = args.Get(2).(sender.StepUpCodeData).Code }).
+		Return(nil)
+
+	tokens, err := auth.Login(ctx, "test@example.com", "password123", "", "198.51.100.7", DeviceInput{})
+
+	assert.Nil(t, tokens)
+	var stepUpErr *StepUpRequiredError
+	if assert.ErrorAs(t, err, &stepUpErr) {
+		assert.Equal(t, "challenge-id", stepUpErr.ChallengeID)
+	}
+	assert.Len(t, code, stepUpCodeDigits)
+	assert.NotContains(t, challenge.CodeHash, code)
+	mockTokenRepo.AssertNotCalled(t, "CreateRefreshToken", mock.Anything, mock.Anything)
+
+	challenge.ID = "challenge-id"
+	mockStepUpRepo.On("ReserveStepUpAttempt", ctx, "challenge-id", testStepUpConfig.MaxAttempts).Return(&challenge, nil)
+	mockStepUpRepo.On("MarkStepUpChallengeUsed", ctx, "challenge-id").Return(nil)
+	mockTokenRepo.On("CreateRefreshToken", ctx, mock.MatchedBy(func(token entity.RefreshToken) bool {
+		return token.UserID == "user-id" && token.ClientIP == "198.51.100.7"
+	})).Return(nil)
+
+	tokens, err = auth.CompleteStepUp(ctx, "challenge-id", code, "198.51.100.7", DeviceInput{})
+
+	assert.NoError(t, err)
+	assert.NotNil(t, tokens)
+	mockStepUpRepo.AssertExpectations(t)
+}
+
+func TestStepUp_Verify_RejectsWrongCode(t *testing.T) {
+	ctx := context.Background()
+	mockStepUpRepo := new(mockStepUpRepo)
+	stepUp := NewStepUp(mockStepUpRepo, mockTransactor{}, "test-sign-key", testStepUpConfig, logrus.New(), newTestAudit(), new(mockEmail))
+
+	challenge := &entity.StepUpChallenge{
+		ID:        "challenge-id",
+		UserID:    "user-id",
+		CodeHash:  stepUp.hashCode("123456"),
+		ClientIP:  "198.51.100.7",
+		ExpiresAt: time.Now().Add(time.Minute),
+	}
+	mockStepUpRepo.On("ReserveStepUpAttempt", ctx, "challenge-id", testStepUpConfig.MaxAttempts).Return(challenge, nil).Twice()
+
+	_, err := stepUp.Verify(ctx, "challenge-id", "654321", "198.51.100.7")
+	assert.ErrorIs(t, err, ErrInvalidStepUpCode)
+
+	// the right code from another address
+	_, err = stepUp.Verify(ctx, "challenge-id", "123456", "203.0.113.7")
+	assert.ErrorIs(t, err, ErrInvalidStepUpCode)
+
+	// no attempt left, the right code does not help either
+	mockStepUpRepo.On("ReserveStepUpAttempt", ctx, "challenge-id", testStepUpConfig.MaxAttempts).
+		Return((*entity.StepUpChallenge)(nil), repoerrors.ErrNotFound).Once()
+	_, err = stepUp.Verify(ctx, "challenge-id", "123456", "198.51.100.7")
+	assert.ErrorIs(t, err, ErrInvalidStepUpCode)
+
+	// an attempt left on the challenge
+	mockStepUpRepo.On("ReserveStepUpAttempt", ctx, "challenge-id", testStepUpConfig.MaxAttempts).Return(challenge, nil).Once()
+	verified, err := stepUp.Verify(ctx, "challenge-id", "123456", "198.51.100.7")
+	assert.NoError(t, err)
+	assert.Equal(t, "challenge-id", verified.ID)
+}
+
+func TestStepUp_Challenge_RequiresVerifiedEmail(t *testing.T) {
+	stepUp := newTestStepUp()
+
+	_, err := stepUp.Challenge(context.Background(), &entity.User{ID: "user-id", Email: "test@example.com"}, RiskOperationLogin, "198.51.100.7", entity.GeoLocation{}, nil, "")
+
+	assert.ErrorIs(t, err, ErrRiskDenied)
+}
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository"
+	"medods-tz/internal/repository/repoerrors"
+	"slices"
+	"time"
+)
+
+// TokenPolicies keeps the token policies of organizations: the lifetimes of their sessions, how they may
+// sign in and the keys their access tokens are signed with. Whatever a policy leaves open falls back to
+// the global settings.
+type TokenPolicies struct {
+	policyRepo      repository.TokenPolicyRepository
+	orgRepo         repository.OrganizationRepository
+	transactor      repository.Transactor
+	refreshTokenTTL time.Duration
+}
+
+func NewTokenPolicies(
+	policyRepo repository.TokenPolicyRepository,
+	orgRepo repository.OrganizationRepository,
+	transactor repository.Transactor,
+	refreshTokenTTL time.Duration) *TokenPolicies {
+	return &TokenPolicies{
+		policyRepo:      policyRepo,
+		orgRepo:         orgRepo,
+		transactor:      transactor,
+		refreshTokenTTL: refreshTokenTTL,
+	}
+}
+
+// GetTokenPolicy returns the token policy of an organization, an empty one when it has none.
+func (s *TokenPolicies) GetTokenPolicy(ctx context.Context, orgID string) (*entity.TokenPolicy, error) {
+	var policy *entity.TokenPolicy
+	err := s.transactor.WithinTenant(ctx, orgID, func(ctx context.Context) error {
+		var err error
+		policy, err = s.getTokenPolicy(ctx, orgID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+// SetTokenPolicy replaces the settings of the token policy of an organization, its signing keys stay.
+// Sessions pick up the new settings when they are issued or refreshed.
+func (s *TokenPolicies) SetTokenPolicy(ctx context.Context, policy entity.TokenPolicy) (*entity.TokenPolicy, error) {
+	if !validTokenPolicy(policy) {
+		return nil, ErrInvalidTokenPolicy
+	}
+	policy.AllowedGrants = slices.Clone(policy.AllowedGrants)
+	slices.Sort(policy.AllowedGrants)
+	policy.AllowedGrants = slices.Compact(policy.AllowedGrants)
+	policy.UpdatedAt = time.Now()
+
+	var updated *entity.TokenPolicy
+	err := s.transactor.WithinTenant(ctx, policy.OrgID, func(ctx context.Context) error {
+		err := s.policyRepo.UpsertTokenPolicy(ctx, policy)
+		if err != nil {
+			if errors.Is(err, repoerrors.ErrNotFound) {
+				return ErrOrganizationNotFound
+			}
+
+			return fmt.Errorf("error while storing token policy: %w", err)
+		}
+
+		updated, err = s.getTokenPolicy(ctx, policy.OrgID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// RotateSigningKey gives an organization a new Ed25519 signing key and returns its public key, the private
+// key never leaves the service. The key it replaces, or the global key, still verifies access tokens for
+// a refresh token lifetime so sessions can be refreshed onto the new key. Rotating again within that time
+// signs out the sessions still on the oldest key.
+func (s *TokenPolicies) RotateSigningKey(ctx context.Context, orgID string) (*entity.PublicSigningKey, error) {
+	signingKey, err := generateSigningKey()
+	if err != nil {
+		return nil, fmt.Errorf("error while generating signing key: %w", err)
+	}
+
+	err = s.replaceSigningKey(ctx, orgID, signingKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return publicSigningKey(signingKey)
+}
+
+// PublicSigningKeys returns the public keys the access tokens of an organization are verified with: that
+// of its signing key, and that of the key it replaced until it expires. It is empty while the global key
+// signs them.
+func (s *TokenPolicies) PublicSigningKeys(ctx context.Context, orgID string) ([]entity.PublicSigningKey, error) {
+	policy, err := s.GetTokenPolicy(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []entity.PublicSigningKey{}
+	if policy.SigningKey != "" {
+		key, err := publicSigningKey(policy.SigningKey)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+
+	if policy.PreviousSigningKey != "" && policy.PreviousKeyExpiresAt != nil && time.Now().Before(*policy.PreviousKeyExpiresAt) {
+		key, err := publicSigningKey(policy.PreviousSigningKey)
+		if err != nil {
+			return nil, err
+		}
+		key.ExpiresAt = policy.PreviousKeyExpiresAt
+		keys = append(keys, *key)
+	}
+
+	return keys, nil
+}
+
+// RemoveSigningKey signs the access tokens of an organization with the global key again, the removed key
+// still verifies them for a refresh token lifetime like after a rotation.
+func (s *TokenPolicies) RemoveSigningKey(ctx context.Context, orgID string) error {
+	return s.replaceSigningKey(ctx, orgID, "")
+}
+
+func (s *TokenPolicies) replaceSigningKey(ctx context.Context, orgID, signingKey string) error {
+	return s.transactor.WithinTenant(ctx, orgID, func(ctx context.Context) error {
+		policy, err := s.getTokenPolicy(ctx, orgID)
+		if err != nil {
+			return err
+		}
+
+		if policy.SigningKey == signingKey {
+			return nil
+		}
+
+		now := time.Now()
+		previousKeyExpiresAt := now.Add(cmp.Or(policy.RefreshTokenTTL, s.refreshTokenTTL))
+		policy.PreviousSigningKey = policy.SigningKey
+		policy.PreviousKeyExpiresAt = &previousKeyExpiresAt
+		policy.SigningKey = signingKey
+		policy.UpdatedAt = now
+
+		err = s.policyRepo.UpdateTokenPolicyKeys(ctx, *policy)
+		if err != nil {
+			if errors.Is(err, repoerrors.ErrNotFound) {
+				return ErrOrganizationNotFound
+			}
+
+			return fmt.Errorf("error while storing signing keys: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (s *TokenPolicies) getTokenPolicy(ctx context.Context, orgID string) (*entity.TokenPolicy, error) {
+	policy, err := s.policyRepo.GetTokenPolicy(ctx, orgID)
+	if err == nil {
+		return policy, nil
+	}
+	if !errors.Is(err, repoerrors.ErrNotFound) {
+		return nil, fmt.Errorf("error while getting token policy: %w", err)
+	}
+
+	_, err = s.orgRepo.GetOrganization(ctx, orgID)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return nil, ErrOrganizationNotFound
+		}
+
+		return nil, fmt.Errorf("error while getting organization: %w", err)
+	}
+
+	return &entity.TokenPolicy{OrgID: orgID}, nil
+}
+
+func validTokenPolicy(policy entity.TokenPolicy) bool {
+	if policy.AccessTokenTTL < 0 || policy.RefreshTokenTTL < 0 || policy.MaxSessionLifetime < 0 {
+		return false
+	}
+
+	for _, grant := range policy.AllowedGrants {
+		switch grant {
+		case entity.GrantUserID, entity.GrantPassword, entity.GrantRefreshToken:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// generateSigningKey returns a new Ed25519 private key, stored as its base64url encoded seed.
+func generateSigningKey() (string, error) {
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(seed), nil
+}
+
+func parseSigningKey(signingKey string) (ed25519.PrivateKey, error) {
+	seed, err := base64.RawURLEncoding.DecodeString(signingKey)
+	if err != nil {
+		return nil, fmt.Errorf("error while decoding signing key: %w", err)
+	}
+
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("signing key is %d bytes long instead of %d", len(seed), ed25519.SeedSize)
+	}
+
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// publicSigningKey returns the public key of a signing key.
+func publicSigningKey(signingKey string) (*entity.PublicSigningKey, error) {
+	privateKey, err := parseSigningKey(signingKey)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+
+	return &entity.PublicSigningKey{ID: signingKeyID(publicKey), Key: publicKey}, nil
+}
+
+// signingKeyID derives the kid of a key from its public key, so it needs no storage of its own.
+func signingKeyID(publicKey ed25519.PublicKey) string {
+	sum := sha256.Sum256(publicKey)
+
+	return base64.RawURLEncoding.EncodeToString(sum[:16])
+}
@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"github.com/golang-jwt/jwt"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository/repoerrors"
+	"testing"
+	"time"
+)
+
+func newTestTokenPolicies() *TokenPolicies {
+	mockPolicyRepo := new(mockTokenPolicyRepo)
+	mockPolicyRepo.On("GetTokenPolicy", mock.Anything, mock.Anything).Return((*entity.TokenPolicy)(nil), repoerrors.ErrNotFound).Maybe()
+	mockOrgRepo := new(mockOrganizationRepo)
+	mockOrgRepo.On("GetOrganization", mock.Anything, mock.Anything).Return(&entity.Organization{ID: "org-id", Name: "Acme"}, nil).Maybe()
+
+	return NewTokenPolicies(mockPolicyRepo, mockOrgRepo, mockTransactor{}, time.Hour)
+}
+
+// newTestPolicyAuth returns an Auth whose user-id is a member of org-id, which has policy.
+func newTestPolicyAuth(userRepo *mockUserRepo, tokenRepo *mockTokenRepo, stepUp StepUpService, policy *entity.TokenPolicy) *Auth {
+	mockOrgRepo := new(mockOrganizationRepo)
+	mockOrgRepo.On("GetMembership", mock.Anything, "org-id", "user-id").Return(&entity.Membership{OrgID: "org-id", UserID: "user-id", Role: entity.OrgRoleMember}, nil)
+	mockPolicyRepo := new(mockTokenPolicyRepo)
+	mockPolicyRepo.On("GetTokenPolicy", mock.Anything, "org-id").Return(policy, nil)
+
+	orgs := NewOrganizations(mockOrgRepo, tokenRepo, mockTransactor{}, newTestWebhooks())
+	policies := NewTokenPolicies(mockPolicyRepo, mockOrgRepo, mockTransactor{}, time.Hour)
+
+	return newTestAuth(AuthDependencies{
+		UserRepo:  userRepo,
+		TokenRepo: tokenRepo,
+		StepUp:    stepUp,
+		Orgs:      orgs,
+		Policies:  policies,
+	})
+}
+
+func TestAuth_CreateTokens_TokenPolicy(t *testing.T) {
+	ctx := context.Background()
+	mockUserRepo := new(mockUserRepo)
+	mockTokenRepo := new(mockTokenRepo)
+
+	signingKey, _ := generateSigningKey()
+	policy := &entity.TokenPolicy{
+		OrgID:           "org-id",
+		AccessTokenTTL:  5 * time.Minute,
+		RefreshTokenTTL: 2 * time.Hour,
+		AllowedGrants:   []string{entity.GrantPassword, entity.GrantRefreshToken},
+		SigningKey:      signingKey,
+	}
+	auth := newTestPolicyAuth(mockUserRepo, mockTokenRepo, newTestStepUp(), policy)
+
+	mockUserRepo.On("GetUserByID", ctx, "user-id").Return(&entity.User{ID: "user-id", Email: "test@example.com"}, nil)
+
+	_, err := auth.CreateTokens(ctx, "user-id", "org-id", "127.0.0.1", DeviceInput{})
+	assert.ErrorIs(t, err, ErrGrantNotAllowed)
+
+	policy.AllowedGrants = append(policy.AllowedGrants, entity.GrantUserID)
+	var refreshToken entity.RefreshToken
+	mockTokenRepo.On("CreateRefreshToken", ctx, mock.Anything).
+		Run(func(args mock.Arguments) { refreshToken = args.Get(1).(entity.RefreshToken) }).
+		Return(nil)
+
+	tokens, err := auth.CreateTokens(ctx, "user-id", "org-id", "127.0.0.1", DeviceInput{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	claims, err := auth.Authenticate(ctx, tokens.AccessToken)
+	if assert.NoError(t, err) {
+		assert.WithinDuration(t, time.Now().Add(5*time.Minute), time.Unix(claims.ExpiresAt, 0), 5*time.Second)
+	}
+	assert.WithinDuration(t, time.Now().Add(2*time.Hour), refreshToken.ExpiresAt, 5*time.Second)
+
+	publicKey, _ := publicSigningKey(signingKey)
+	_, err = verifyAccessToken(tokens.AccessToken, map[string]interface{}{publicKey.ID: publicKey.Key})
+	assert.NoError(t, err)
+	_, err = verifyAccessToken(tokens.AccessToken, map[string]interface{}{"": []byte("test-sign-key")})
+	assert.Error(t, err)
+}
+
+func TestAuth_CreateTokens_TokenPolicyRequiresMFA(t *testing.T) {
+	ctx := context.Background()
+	mockUserRepo := new(mockUserRepo)
+	mockTokenRepo := new(mockTokenRepo)
+	mockStepUpRepo := new(mockStepUpRepo)
+	mockEmail := new(mockEmail)
+
+	stepUp := NewStepUp(mockStepUpRepo, mockTransactor{}, "test-sign-key", testStepUpConfig, logrus.New(), newTestAudit(), mockEmail)
+	auth := newTestPolicyAuth(mockUserRepo, mockTokenRepo, stepUp, &entity.TokenPolicy{OrgID: "org-id", RequireMFA: true})
+
+	mockUserRepo.On("GetUserByID", ctx, "unverified-user-id").Return(&entity.User{ID: "user-id", Email: "test@example.com"}, nil)
+
+	_, err := auth.CreateTokens(ctx, "unverified-user-id", "org-id", "127.0.0.1", DeviceInput{})
+	assert.ErrorIs(t, err, ErrMFAUnavailable)
+
+	verifiedAt := time.Now()
+	mockUserRepo.On("GetUserByID", ctx, "user-id").Return(&entity.User{ID: "user-id", Email: "test@example.com", EmailVerifiedAt: &verifiedAt}, nil)
+	mockStepUpRepo.On("CreateStepUpChallenge", ctx, mock.MatchedBy(func(challenge entity.StepUpChallenge) bool {
+		return challenge.OrgID != nil && *challenge.OrgID == "org-id"
+	})).Return("challenge-id", nil)
+	mockEmail.On("SendStepUpCodeEmail", ctx, mock.Anything, mock.Anything).Return(nil)
+
+	tokens, err := auth.CreateTokens(ctx, "user-id", "org-id", "127.0.0.1", DeviceInput{})
+
+	assert.Nil(t, tokens)
+	var stepUpErr *StepUpRequiredError
+	if assert.ErrorAs(t, err, &stepUpErr) {
+		assert.Equal(t, "challenge-id", stepUpErr.ChallengeID)
+	}
+	mockTokenRepo.AssertNotCalled(t, "CreateRefreshToken", mock.Anything, mock.Anything)
+
+	// personal sessions are not subject to the policy of the organization
+	mockTokenRepo.On("CreateRefreshToken", ctx, mock.Anything).Return(nil)
+	tokens, err = auth.CreateTokens(ctx, "user-id", "", "127.0.0.1", DeviceInput{})
+	assert.NoError(t, err)
+	assert.NotNil(t, tokens)
+}
+
+func TestAuth_RefreshTokens_MaxSessionLifetime(t *testing.T) {
+	ctx := context.Background()
+	mockUserRepo := new(mockUserRepo)
+	mockTokenRepo := new(mockTokenRepo)
+
+	auth := newTestPolicyAuth(mockUserRepo, mockTokenRepo, newTestStepUp(), &entity.TokenPolicy{OrgID: "org-id", MaxSessionLifetime: 90 * time.Minute})
+	membership := &entity.Membership{OrgID: "org-id", UserID: "user-id", Role: entity.OrgRoleMember}
+	accessToken, _ := auth.generateAccessToken("127.0.0.1", "user-id", nil, nil, membership, "", time.Now().Add(-time.Minute))
+
+	orgID := "org-id"
+	mockUserRepo.On("GetUserByID", ctx, "user-id").Return(&entity.User{ID: "user-id", Email: "test@example.com"}, nil)
+	sessionStartedAt := time.Now().Add(-time.Hour)
+	mockTokenRepo.On("GetRefreshTokenEntitiesByUserID", ctx, "user-id").Return([]entity.RefreshToken{
+		{
+			ID:               "old-token-id",
+			UserID:           "user-id",
+			RefreshHash:      string(hashRefreshToken("old-refresh-token")),
+			ClientIP:         "127.0.0.1",
+			SessionStartedAt: time.Now().Add(-2 * time.Hour),
+			ExpiresAt:        time.Now().Add(time.Hour),
+			OrgID:            &orgID,
+		},
+		{
+			ID:               "token-id",
+			UserID:           "user-id",
+			RefreshHash:      string(hashRefreshToken("valid-refresh-token")),
+			ClientIP:         "127.0.0.1",
+			SessionStartedAt: sessionStartedAt,
+			ExpiresAt:        time.Now().Add(time.Hour),
+			OrgID:            &orgID,
+		},
+	}, nil)
+
+	_, err := auth.RefreshTokens(ctx, "old-refresh-token", accessToken, "127.0.0.1", DeviceInput{})
+	assert.ErrorIs(t, err, ErrSessionLifetimeExceeded)
+
+	mockTokenRepo.On("MarkRefreshTokenUsed", ctx, "token-id").Return(nil)
+	mockTokenRepo.On("CreateRefreshToken", ctx, mock.MatchedBy(func(token entity.RefreshToken) bool {
+		return token.SessionStartedAt.Equal(sessionStartedAt) && token.ExpiresAt.Equal(sessionStartedAt.Add(90*time.Minute))
+	})).Return(nil)
+
+	tokens, err := auth.RefreshTokens(ctx, "valid-refresh-token", accessToken, "127.0.0.1", DeviceInput{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	claims, err := auth.Authenticate(ctx, tokens.AccessToken)
+	if assert.NoError(t, err) {
+		assert.LessOrEqual(t, claims.ExpiresAt, sessionStartedAt.Add(90*time.Minute).Unix())
+	}
+}
+
+func TestTokenPolicies_RotateSigningKey(t *testing.T) {
+	ctx := context.Background()
+	mockPolicyRepo := new(mockTokenPolicyRepo)
+	policies := NewTokenPolicies(mockPolicyRepo, new(mockOrganizationRepo), mockTransactor{}, time.Hour)
+
+	oldSigningKey, _ := generateSigningKey()
+	mockPolicyRepo.On("GetTokenPolicy", ctx, "org-id").Return(&entity.TokenPolicy{OrgID: "org-id", SigningKey: oldSigningKey}, nil)
+	var stored entity.TokenPolicy
+	mockPolicyRepo.On("UpdateTokenPolicyKeys", ctx, mock.Anything).
+		Run(func(args mock.Arguments) { stored = args.Get(1).(entity.TokenPolicy) }).
+		Return(nil)
+
+	publicKey, err := policies.RotateSigningKey(ctx, "org-id")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// only the public key of the new signing key is returned
+	storedPublicKey, err := publicSigningKey(stored.SigningKey)
+	if assert.NoError(t, err) {
+		assert.Equal(t, storedPublicKey, publicKey)
+	}
+	assert.Equal(t, oldSigningKey, stored.PreviousSigningKey)
+	if assert.NotNil(t, stored.PreviousKeyExpiresAt) {
+		assert.WithinDuration(t, time.Now().Add(time.Hour), *stored.PreviousKeyExpiresAt, 5*time.Second)
+	}
+
+	// tokens signed with the previous key are accepted until it expires
+	auth := newTestPolicyAuth(new(mockUserRepo), new(mockTokenRepo), newTestStepUp(), &stored)
+	membership := &entity.Membership{OrgID: "org-id", UserID: "user-id", Role: entity.OrgRoleMember}
+	accessToken, _ := auth.generateAccessToken("127.0.0.1", "user-id", nil, nil, membership, oldSigningKey, time.Now().Add(time.Minute))
+
+	_, err = auth.Authenticate(ctx, accessToken)
+	assert.NoError(t, err)
+
+	expiredAt := time.Now().Add(-time.Second)
+	stored.PreviousKeyExpiresAt = &expiredAt
+	_, err = auth.Authenticate(ctx, accessToken)
+	assert.ErrorIs(t, err, ErrInvalidAccessToken)
+
+	// and an organization token never verifies with the global key once the organization has its own
+	accessToken, _ = auth.generateAccessToken("127.0.0.1", "user-id", nil, nil, membership, "", time.Now().Add(time.Minute))
+	_, err = auth.Authenticate(ctx, accessToken)
+	assert.ErrorIs(t, err, ErrInvalidAccessToken)
+
+	// nor with the public key used as an HMAC secret
+	claims := TokenClaims{StandardClaims: jwt.StandardClaims{ExpiresAt: time.Now().Add(time.Minute).Unix()}, UserID: "user-id", OrgID: "org-id"}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
+	token.Header["kid"] = publicKey.ID
+	accessToken, _ = token.SignedString([]byte(publicKey.Key))
+	_, err = auth.Authenticate(ctx, accessToken)
+	assert.ErrorIs(t, err, ErrInvalidAccessToken)
+}
+
+func TestTokenPolicies_PublicSigningKeys(t *testing.T) {
+	ctx := context.Background()
+	mockPolicyRepo := new(mockTokenPolicyRepo)
+	policies := NewTokenPolicies(mockPolicyRepo, new(mockOrganizationRepo), mockTransactor{}, time.Hour)
+
+	signingKey, _ := generateSigningKey()
+	previousSigningKey, _ := generateSigningKey()
+	previousKeyExpiresAt := time.Now().Add(time.Hour)
+	policy := &entity.TokenPolicy{OrgID: "org-id", SigningKey: signingKey, PreviousSigningKey: previousSigningKey, PreviousKeyExpiresAt: &previousKeyExpiresAt}
+	mockPolicyRepo.On("GetTokenPolicy", ctx, "org-id").Return(policy, nil)
+
+	keys, err := policies.PublicSigningKeys(ctx, "org-id")
+	if assert.NoError(t, err) && assert.Len(t, keys, 2) {
+		current, _ := publicSigningKey(signingKey)
+		assert.Equal(t, *current, keys[0])
+		assert.Equal(t, &previousKeyExpiresAt, keys[1].ExpiresAt)
+	}
+
+	// a replaced global key has no public key to publish
+	policy.PreviousSigningKey = ""
+	keys, err = policies.PublicSigningKeys(ctx, "org-id")
+	if assert.NoError(t, err) {
+		assert.Len(t, keys, 1)
+	}
+}
+
+func TestTokenPolicies_SetTokenPolicy(t *testing.T) {
+	ctx := context.Background()
+	mockPolicyRepo := new(mockTokenPolicyRepo)
+	policies := NewTokenPolicies(mockPolicyRepo, new(mockOrganizationRepo), mockTransactor{}, time.Hour)
+
+	_, err := policies.SetTokenPolicy(ctx, entity.TokenPolicy{OrgID: "org-id", AccessTokenTTL: -time.Minute})
+	assert.ErrorIs(t, err, ErrInvalidTokenPolicy)
+
+	_, err = policies.SetTokenPolicy(ctx, entity.TokenPolicy{OrgID: "org-id", AllowedGrants: []string{"client_credentials"}})
+	assert.ErrorIs(t, err, ErrInvalidTokenPolicy)
+
+	mockPolicyRepo.On("UpsertTokenPolicy", ctx, mock.MatchedBy(func(policy entity.TokenPolicy) bool {
+		return policy.OrgID == "missing-org-id"
+	})).Return(repoerrors.ErrNotFound)
+	_, err = policies.SetTokenPolicy(ctx, entity.TokenPolicy{OrgID: "missing-org-id"})
+	assert.ErrorIs(t, err, ErrOrganizationNotFound)
+	mockPolicyRepo.AssertNotCalled(t, "GetTokenPolicy", mock.Anything, mock.Anything)
+}
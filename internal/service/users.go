@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository"
+	"medods-tz/internal/repository/repoerrors"
+	"medods-tz/internal/sender"
+	"medods-tz/pkg/hasher"
+	"medods-tz/pkg/passwordpolicy"
+)
+
+// Users is how admins manage users: creating, listing, changing, disabling and deleting them.
+type Users struct {
+	userRepo       repository.UserRepository
+	tokenRepo      repository.TokenRepository
+	transactor     repository.Transactor
+	securityLog    *logrus.Logger
+	webhooks       WebhookService
+	passwordHasher hasher.PasswordHasher
+	passwordPolicy *passwordpolicy.Policy
+}
+
+func NewUsers(
+	userRepo repository.UserRepository,
+	tokenRepo repository.TokenRepository,
+	transactor repository.Transactor,
+	securityLog *logrus.Logger,
+	webhooks WebhookService,
+	passwordHasher hasher.PasswordHasher,
+	passwordPolicy *passwordpolicy.Policy) *Users {
+	return &Users{
+		userRepo:       userRepo,
+		tokenRepo:      tokenRepo,
+		transactor:     transactor,
+		securityLog:    securityLog,
+		webhooks:       webhooks,
+		passwordHasher: passwordHasher,
+		passwordPolicy: passwordPolicy,
+	}
+}
+
+// CreateUser creates a user, active unless user.Status says otherwise. Without a password the user
+// cannot log in until they reset it. No verification email is sent, user.EmailVerifiedAt is kept as given.
+func (s *Users) CreateUser(ctx context.Context, user entity.User, password string) (*entity.User, error) {
+	if user.Status == "" {
+		user.Status = entity.UserStatusActive
+	}
+	if !validUserStatus(user.Status) {
+		return nil, ErrInvalidUserStatus
+	}
+
+	if password != "" {
+		err := validatePassword(s.passwordPolicy, password, user.Email)
+		if err != nil {
+			return nil, err
+		}
+
+		user.PasswordHash, err = s.passwordHasher.Hash(password)
+		if err != nil {
+			return nil, fmt.Errorf("error while hashing password: %w", err)
+		}
+	}
+	user.Locale = sender.NormalizeLocale(user.Locale)
+
+	id, err := s.userRepo.CreateUser(ctx, user)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrAlreadyExists) {
+			return nil, ErrUserAlreadyExists
+		}
+
+		return nil, fmt.Errorf("error while creating user: %w", err)
+	}
+
+	return s.GetUser(ctx, id)
+}
+
+func (s *Users) GetUser(ctx context.Context, id string) (*entity.User, error) {
+	user, err := s.userRepo.GetUserByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return nil, ErrUserNotFound
+		}
+
+		return nil, fmt.Errorf("error while trying to find user: %w", err)
+	}
+
+	return user, nil
+}
+
+// ListUsers returns users ordered by email and the cursor of the next page, empty on the last page.
+func (s *Users) ListUsers(ctx context.Context, filter entity.UserFilter, cursor string) ([]entity.User, string, error) {
+	if filter.Status != "" && !validUserStatus(filter.Status) {
+		return nil, "", ErrInvalidUserStatus
+	}
+
+	if cursor != "" {
+		afterEmail, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil || len(afterEmail) == 0 {
+			return nil, "", ErrInvalidCursor
+		}
+		filter.AfterEmail = string(afterEmail)
+	}
+
+	users, nextCursor, err := listPage(filter.Limit, func(limit int) ([]entity.User, error) {
+		filter.Limit = limit
+		return s.userRepo.ListUsers(ctx, filter)
+	}, func(user entity.User) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(user.Email))
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("error while listing users: %w", err)
+	}
+
+	if users == nil {
+		users = []entity.User{}
+	}
+
+	return users, nextCursor, nil
+}
+
+// UpdateUser replaces the email, locale and status of a user. A new email has to be verified again,
+// and disabling a user revokes all their refresh tokens right away.
+func (s *Users) UpdateUser(ctx context.Context, user entity.User) (*entity.User, error) {
+	if !validUserStatus(user.Status) {
+		return nil, ErrInvalidUserStatus
+	}
+	user.Locale = sender.NormalizeLocale(user.Locale)
+
+	var previous, updated *entity.User
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		previous, err = s.GetUser(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+
+		err = s.userRepo.UpdateUser(ctx, user)
+		if err != nil {
+			if errors.Is(err, repoerrors.ErrNotFound) {
+				return ErrUserNotFound
+			}
+			if errors.Is(err, repoerrors.ErrAlreadyExists) {
+				return ErrUserAlreadyExists
+			}
+
+			return fmt.Errorf("error while updating user: %w", err)
+		}
+
+		if user.Status == entity.UserStatusDisabled && previous.Status != entity.UserStatusDisabled {
+			err = s.revokeSessions(ctx, user.ID, "user_disabled")
+			if err != nil {
+				return err
+			}
+		}
+
+		updated, err = s.GetUser(ctx, user.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if updated.Status != previous.Status {
+		s.securityLog.Warnf("user_id=%s changed from %s to %s by an admin", user.ID, previous.Status, updated.Status)
+	}
+
+	return updated, nil
+}
+
+// DeleteUser removes a user with their sessions, devices and everything else that belongs to them.
+func (s *Users) DeleteUser(ctx context.Context, id string) error {
+	return s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		err := s.userRepo.DeleteUser(ctx, id)
+		if err != nil {
+			if errors.Is(err, repoerrors.ErrNotFound) {
+				return ErrUserNotFound
+			}
+
+			return fmt.Errorf("error while deleting user: %w", err)
+		}
+
+		return s.webhooks.Enqueue(ctx, entity.WebhookSessionRevoked, map[string]string{
+			"user_id": id,
+			"reason":  "user_deleted",
+		})
+	})
+}
+
+func (s *Users) revokeSessions(ctx context.Context, userID, reason string) error {
+	// the sessions of the organizations of the user go too
+	err := s.transactor.WithinAllTenants(ctx, func(ctx context.Context) error {
+		return s.tokenRepo.RevokeRefreshTokensByUserID(ctx, userID)
+	})
+	if err != nil {
+		return fmt.Errorf("error while revoking refresh tokens of user: %w", err)
+	}
+
+	return s.webhooks.Enqueue(ctx, entity.WebhookSessionRevoked, map[string]string{
+		"user_id": userID,
+		"reason":  reason,
+	})
+}
+
+func validUserStatus(status string) bool {
+	switch status {
+	case entity.UserStatusActive, entity.UserStatusDisabled, entity.UserStatusLocked:
+		return true
+	}
+
+	return false
+}
+
+// userStatusError returns why the user may not start a session, nil for active users.
+func userStatusError(user *entity.User) error {
+	switch user.Status {
+	case entity.UserStatusDisabled:
+		return ErrUserDisabled
+	case entity.UserStatusLocked:
+		return ErrUserLocked
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"medods-tz/internal/entity"
+	"testing"
+)
+
+func newTestUsers(userRepo *mockUserRepo, tokenRepo *mockTokenRepo) *Users {
+	return NewUsers(userRepo, tokenRepo, mockTransactor{}, logrus.New(), newTestWebhooks(), newTestPasswordHasher(), newTestPasswordPolicy())
+}
+
+func TestUsers_UpdateUser_DisableRevokesSessions(t *testing.T) {
+	ctx := context.Background()
+	mockUserRepo := new(mockUserRepo)
+	mockTokenRepo := new(mockTokenRepo)
+	users := newTestUsers(mockUserRepo, mockTokenRepo)
+
+	active := &entity.User{ID: "user-id", Email: "test@example.com", Locale: "en", Status: entity.UserStatusActive}
+	disabled := &entity.User{ID: "user-id", Email: "test@example.com", Locale: "en", Status: entity.UserStatusDisabled}
+	mockUserRepo.On("GetUserByID", ctx, "user-id").Return(active, nil).Once()
+	mockUserRepo.On("GetUserByID", ctx, "user-id").Return(disabled, nil)
+	mockUserRepo.On("UpdateUser", ctx, *disabled).Return(nil)
+	mockTokenRepo.On("RevokeRefreshTokensByUserID", ctx, "user-id").Return(nil)
+
+	user, err := users.UpdateUser(ctx, *disabled)
+
+	assert.NoError(t, err)
+	assert.Equal(t, entity.UserStatusDisabled, user.Status)
+	mockTokenRepo.AssertExpectations(t)
+
+	// disabling again has nothing left to revoke
+	_, err = users.UpdateUser(ctx, *disabled)
+
+	assert.NoError(t, err)
+	mockTokenRepo.AssertNumberOfCalls(t, "RevokeRefreshTokensByUserID", 1)
+
+	_, err = users.UpdateUser(ctx, entity.User{ID: "user-id", Email: "test@example.com", Status: "banned"})
+	assert.ErrorIs(t, err, ErrInvalidUserStatus)
+}
+
+func TestUsers_ListUsers(t *testing.T) {
+	ctx := context.Background()
+	mockUserRepo := new(mockUserRepo)
+	users := newTestUsers(mockUserRepo, new(mockTokenRepo))
+
+	mockUserRepo.On("ListUsers", ctx, entity.UserFilter{EmailPrefix: "a", Limit: 3}).Return([]entity.User{
+		{ID: "user-1", Email: "a1@example.com"},
+		{ID: "user-2", Email: "a2@example.com"},
+		{ID: "user-3", Email: "a3@example.com"},
+	}, nil)
+	mockUserRepo.On("ListUsers", ctx, entity.UserFilter{EmailPrefix: "a", AfterEmail: "a2@example.com", Limit: 3}).Return([]entity.User{
+		{ID: "user-3", Email: "a3@example.com"},
+	}, nil)
+
+	page, cursor, err := users.ListUsers(ctx, entity.UserFilter{EmailPrefix: "a", Limit: 2}, "")
+	if assert.NoError(t, err) && assert.Len(t, page, 2) {
+		assert.Equal(t, "user-2", page[1].ID)
+	}
+	assert.NotEmpty(t, cursor)
+
+	page, cursor, err = users.ListUsers(ctx, entity.UserFilter{EmailPrefix: "a", Limit: 2}, cursor)
+	if assert.NoError(t, err) && assert.Len(t, page, 1) {
+		assert.Equal(t, "user-3", page[0].ID)
+	}
+	assert.Empty(t, cursor)
+
+	_, _, err = users.ListUsers(ctx, entity.UserFilter{}, "not a cursor!")
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestUsers_CreateUser_ChecksPasswordPolicy(t *testing.T) {
+	ctx := context.Background()
+	mockUserRepo := new(mockUserRepo)
+	users := newTestUsers(mockUserRepo, new(mockTokenRepo))
+
+	_, err := users.CreateUser(ctx, entity.User{Email: "test@example.com"}, "short")
+	assert.ErrorIs(t, err, ErrPasswordPolicyViolation)
+	mockUserRepo.AssertNotCalled(t, "CreateUser", mock.Anything, mock.Anything)
+
+	mockUserRepo.On("CreateUser", ctx, mock.MatchedBy(func(user entity.User) bool {
+		return user.Status == entity.UserStatusLocked && user.PasswordHash == ""
+	})).Return("user-id", nil)
+	mockUserRepo.On("GetUserByID", ctx, "user-id").Return(&entity.User{ID: "user-id", Email: "test@example.com", Status: entity.UserStatusLocked}, nil)
+
+	user, err := users.CreateUser(ctx, entity.User{Email: "test@example.com", Status: entity.UserStatusLocked}, "")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "user-id", user.ID)
+	}
+}
+
+func TestAuth_CreateTokens_UserStatus(t *testing.T) {
+	ctx := context.Background()
+	mockUserRepo := new(mockUserRepo)
+	mockTokenRepo := new(mockTokenRepo)
+	auth := newTestPolicyAuth(mockUserRepo, mockTokenRepo, newTestStepUp(), nil)
+
+	mockUserRepo.On("GetUserByID", ctx, "disabled-id").Return(&entity.User{ID: "disabled-id", Status: entity.UserStatusDisabled}, nil)
+	mockUserRepo.On("GetUserByID", ctx, "locked-id").Return(&entity.User{ID: "locked-id", Status: entity.UserStatusLocked}, nil)
+
+	_, err := auth.CreateTokens(ctx, "disabled-id", "", "127.0.0.1", DeviceInput{})
+	assert.ErrorIs(t, err, ErrUserDisabled)
+
+	_, err = auth.CreateTokens(ctx, "locked-id", "", "127.0.0.1", DeviceInput{})
+	assert.ErrorIs(t, err, ErrUserLocked)
+
+	mockTokenRepo.AssertNotCalled(t, "CreateRefreshToken", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,401 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"medods-tz/internal/entity"
+	"medods-tz/internal/repository"
+	"medods-tz/internal/repository/repoerrors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	WebhookIDHeader        = "X-Webhook-Id"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+
+	webhookSecretPrefix = "whsec_"
+	// webhookErrorBodyLimit is how much of a failed response is read, the rest is discarded.
+	webhookErrorBodyLimit = 1024
+)
+
+type WebhookConfig struct {
+	// MaxAttempts failed deliveries move a delivery to the dead letters.
+	MaxAttempts int
+	// BaseBackoff is the delay after the first failure, it doubles with every further one up to MaxBackoff.
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	Timeout     time.Duration
+	BatchSize   int
+}
+
+type Webhook struct {
+	webhookRepo repository.WebhookRepository
+	config      WebhookConfig
+	securityLog *logrus.Logger
+	client      *http.Client
+}
+
+func NewWebhook(webhookRepo repository.WebhookRepository, config WebhookConfig, securityLog *logrus.Logger) *Webhook {
+	return &Webhook{
+		webhookRepo: webhookRepo,
+		config:      config,
+		securityLog: securityLog,
+		client: &http.Client{
+			Timeout: config.Timeout,
+			// a redirect is answered like any other non-2xx status, subscribers must register the final URL
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// webhookPayload is the body of every webhook request.
+type webhookPayload struct {
+	ID        string            `json:"id"`
+	Type      string            `json:"type"`
+	CreatedAt time.Time         `json:"created_at"`
+	Data      map[string]string `json:"data"`
+}
+
+// Enqueue writes the event to the outbox of every matching subscription. Callers run it in the
+// transaction of the change it describes, so the event is stored if and only if the change is.
+func (s *Webhook) Enqueue(ctx context.Context, eventType string, data map[string]string) error {
+	subscriptions, err := s.webhookRepo.ListWebhookSubscriptionsForEvent(ctx, eventType)
+	if err != nil {
+		return fmt.Errorf("error while listing webhook subscriptions: %w", err)
+	}
+	if len(subscriptions) == 0 {
+		return nil
+	}
+
+	eventID, err := generateWebhookEventID()
+	if err != nil {
+		return fmt.Errorf("error while generating webhook event id: %w", err)
+	}
+
+	now := time.Now().UTC()
+	payload, err := json.Marshal(webhookPayload{ID: eventID, Type: eventType, CreatedAt: now, Data: data})
+	if err != nil {
+		return fmt.Errorf("error while encoding webhook payload: %w", err)
+	}
+
+	deliveries := make([]entity.WebhookDelivery, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		deliveries = append(deliveries, entity.WebhookDelivery{
+			SubscriptionID: subscription.ID,
+			EventID:        eventID,
+			EventType:      eventType,
+			Payload:        payload,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+		})
+	}
+
+	err = s.webhookRepo.CreateWebhookDeliveries(ctx, deliveries)
+	if err != nil {
+		return fmt.Errorf("error while writing webhook deliveries: %w", err)
+	}
+
+	return nil
+}
+
+// DeliverPending sends due deliveries until none are left. Each batch is leased for twice the request
+// timeout, so several replicas can run the worker without sending the same delivery twice.
+func (s *Webhook) DeliverPending(ctx context.Context) error {
+	for ctx.Err() == nil {
+		now := time.Now()
+		deliveries, err := s.webhookRepo.ClaimWebhookDeliveries(ctx, now, now.Add(2*s.config.Timeout), s.config.BatchSize)
+		if err != nil {
+			return fmt.Errorf("error while claiming webhook deliveries: %w", err)
+		}
+
+		var wg sync.WaitGroup
+		for _, delivery := range deliveries {
+			wg.Add(1)
+			go func(delivery entity.WebhookDelivery) {
+				defer wg.Done()
+				s.deliver(ctx, delivery)
+			}(delivery)
+		}
+		wg.Wait()
+
+		if len(deliveries) < s.config.BatchSize {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (s *Webhook) deliver(ctx context.Context, delivery entity.WebhookDelivery) {
+	statusCode, err := s.send(ctx, delivery)
+
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastStatusCode = statusCode
+	switch {
+	case err == nil:
+		delivery.Status = entity.WebhookDeliveryDelivered
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+	case delivery.Attempts >= s.config.MaxAttempts:
+		delivery.Status = entity.WebhookDeliveryDead
+		delivery.LastError = err.Error()
+		s.securityLog.Warnf("webhook delivery id=%d of event_id=%s to subscription_id=%s failed %d times, giving up: %v",
+			delivery.ID, delivery.EventID, delivery.SubscriptionID, delivery.Attempts, err)
+	default:
+		delivery.Status = entity.WebhookDeliveryPending
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = now.Add(s.backoff(delivery.Attempts))
+	}
+
+	// the outcome is stored even when the worker is stopping, otherwise the delivery would be sent again
+	err = s.webhookRepo.UpdateWebhookDelivery(context.WithoutCancel(ctx), delivery)
+	if err != nil {
+		s.securityLog.Errorf("error while updating webhook delivery id=%d: %v", delivery.ID, err)
+	}
+}
+
+// send posts the payload and returns the response status, 0 when no response was received.
+func (s *Webhook) send(ctx context.Context, delivery entity.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "medods-auth-webhooks/1.0")
+	req.Header.Set(WebhookIDHeader, delivery.EventID)
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp.Unix(), 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, webhookErrorBodyLimit))
+		return resp.StatusCode, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, body)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	return resp.StatusCode, nil
+}
+
+func (s *Webhook) backoff(attempts int) time.Duration {
+	return retryBackoff(s.config.BaseBackoff, s.config.MaxBackoff, attempts)
+}
+
+// SignWebhook returns the signature header value "t=<unix timestamp>,v1=<hex HMAC-SHA256>".
+// The MAC covers the timestamp and the body joined by a dot, so a captured request cannot be
+// replayed later with a fresh timestamp.
+func SignWebhook(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// CreateSubscription stores a subscription and returns it with its secret, which is only shown here.
+// A secret is generated when none is given.
+func (s *Webhook) CreateSubscription(ctx context.Context, subscription entity.WebhookSubscription) (*entity.WebhookSubscription, error) {
+	err := validateWebhookSubscription(subscription)
+	if err != nil {
+		return nil, err
+	}
+
+	if subscription.Secret == "" {
+		subscription.Secret, err = generateWebhookSecret()
+		if err != nil {
+			return nil, fmt.Errorf("error while generating webhook secret: %w", err)
+		}
+	}
+
+	id, err := s.webhookRepo.CreateWebhookSubscription(ctx, subscription)
+	if err != nil {
+		return nil, fmt.Errorf("error while creating webhook subscription: %w", err)
+	}
+
+	created, err := s.getSubscription(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	created.Secret = subscription.Secret
+
+	return created, nil
+}
+
+func (s *Webhook) GetSubscription(ctx context.Context, id string) (*entity.WebhookSubscription, error) {
+	subscription, err := s.getSubscription(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	subscription.Secret = ""
+
+	return subscription, nil
+}
+
+func (s *Webhook) ListSubscriptions(ctx context.Context) ([]entity.WebhookSubscription, error) {
+	subscriptions, err := s.webhookRepo.ListWebhookSubscriptions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error while listing webhook subscriptions: %w", err)
+	}
+
+	for i := range subscriptions {
+		subscriptions[i].Secret = ""
+	}
+
+	return subscriptions, nil
+}
+
+// UpdateSubscription replaces the URL, event types and enabled flag. The secret is rotated
+// only when a new one is given.
+func (s *Webhook) UpdateSubscription(ctx context.Context, subscription entity.WebhookSubscription) (*entity.WebhookSubscription, error) {
+	err := validateWebhookSubscription(subscription)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := s.getSubscription(ctx, subscription.ID)
+	if err != nil {
+		return nil, err
+	}
+	if subscription.Secret == "" {
+		subscription.Secret = current.Secret
+	}
+
+	err = s.webhookRepo.UpdateWebhookSubscription(ctx, subscription)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return nil, ErrWebhookNotFound
+		}
+
+		return nil, fmt.Errorf("error while updating webhook subscription: %w", err)
+	}
+
+	return s.GetSubscription(ctx, subscription.ID)
+}
+
+// DeleteSubscription removes the subscription together with its pending and dead deliveries.
+func (s *Webhook) DeleteSubscription(ctx context.Context, id string) error {
+	err := s.webhookRepo.DeleteWebhookSubscription(ctx, id)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrWebhookNotFound
+		}
+
+		return fmt.Errorf("error while deleting webhook subscription: %w", err)
+	}
+
+	return nil
+}
+
+// ListDeliveries returns deliveries newest first and the cursor of the next page, empty on the last page.
+func (s *Webhook) ListDeliveries(ctx context.Context, filter entity.WebhookDeliveryFilter, cursor string) ([]entity.WebhookDelivery, string, error) {
+	if cursor != "" {
+		beforeID, err := decodeIDCursor(cursor)
+		if err != nil {
+			return nil, "", ErrInvalidCursor
+		}
+		filter.BeforeID = beforeID
+	}
+
+	deliveries, nextCursor, err := listPage(filter.Limit, func(limit int) ([]entity.WebhookDelivery, error) {
+		filter.Limit = limit
+		return s.webhookRepo.ListWebhookDeliveries(ctx, filter)
+	}, func(delivery entity.WebhookDelivery) string {
+		return encodeIDCursor(delivery.ID)
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("error while listing webhook deliveries: %w", err)
+	}
+
+	return deliveries, nextCursor, nil
+}
+
+// Redeliver queues a pending or dead delivery for an immediate attempt with a fresh set of retries.
+func (s *Webhook) Redeliver(ctx context.Context, id int64) error {
+	err := s.webhookRepo.RequeueWebhookDelivery(ctx, id, time.Now())
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return ErrWebhookDeliveryNotFound
+		}
+
+		return fmt.Errorf("error while requeueing webhook delivery: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Webhook) getSubscription(ctx context.Context, id string) (*entity.WebhookSubscription, error) {
+	subscription, err := s.webhookRepo.GetWebhookSubscription(ctx, id)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrNotFound) {
+			return nil, ErrWebhookNotFound
+		}
+
+		return nil, fmt.Errorf("error while getting webhook subscription: %w", err)
+	}
+
+	return subscription, nil
+}
+
+func validateWebhookSubscription(subscription entity.WebhookSubscription) error {
+	parsed, err := url.Parse(subscription.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return ErrInvalidWebhookURL
+	}
+
+	if len(subscription.EventTypes) == 0 {
+		return ErrUnknownWebhookEventType
+	}
+	for _, eventType := range subscription.EventTypes {
+		if eventType != entity.WebhookAllEvents && !slices.Contains(entity.WebhookEventTypes, eventType) {
+			return fmt.Errorf("%w: %s", ErrUnknownWebhookEventType, eventType)
+		}
+	}
+
+	return nil
+}
+
+func generateWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	return webhookSecretPrefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+func generateWebhookEventID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	return "evt_" + hex.EncodeToString(id), nil
+}
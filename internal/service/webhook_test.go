@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"io"
+	"medods-tz/internal/entity"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var testWebhookConfig = WebhookConfig{
+	MaxAttempts: 3,
+	BaseBackoff: time.Minute,
+	MaxBackoff:  time.Hour,
+	Timeout:     time.Second,
+	BatchSize:   10,
+}
+
+func newTestWebhooks() *Webhook {
+	mockWebhookRepo := new(mockWebhookRepo)
+	mockWebhookRepo.On("ListWebhookSubscriptionsForEvent", mock.Anything, mock.Anything).Return([]entity.WebhookSubscription{}, nil).Maybe()
+
+	return NewWebhook(mockWebhookRepo, testWebhookConfig, logrus.New())
+}
+
+func TestWebhook_Enqueue(t *testing.T) {
+	ctx := context.Background()
+	mockWebhookRepo := new(mockWebhookRepo)
+	webhooks := NewWebhook(mockWebhookRepo, testWebhookConfig, logrus.New())
+
+	mockWebhookRepo.On("ListWebhookSubscriptionsForEvent", ctx, entity.WebhookSessionRevoked).Return([]entity.WebhookSubscription{
+		{ID: "sub-1"}, {ID: "sub-2"},
+	}, nil)
+	var deliveries []entity.WebhookDelivery
+	mockWebhookRepo.On("CreateWebhookDeliveries", ctx, mock.Anything).
+		Run(func(args mock.Arguments) { deliveries = args.Get(1).([]entity.WebhookDelivery) }).
+		Return(nil)
+
+	err := webhooks.Enqueue(ctx, entity.WebhookSessionRevoked, map[string]string{"user_id": "user-id"})
+
+	assert.NoError(t, err)
+	assert.Len(t, deliveries, 2)
+	assert.Equal(t, "sub-1", deliveries[0].SubscriptionID)
+	assert.Equal(t, "sub-2", deliveries[1].SubscriptionID)
+	assert.Equal(t, deliveries[0].EventID, deliveries[1].EventID)
+
+	var payload webhookPayload
+	assert.NoError(t, json.Unmarshal(deliveries[0].Payload, &payload))
+	assert.Equal(t, deliveries[0].EventID, payload.ID)
+	assert.Equal(t, entity.WebhookSessionRevoked, payload.Type)
+	assert.Equal(t, "user-id", payload.Data["user_id"])
+}
+
+func TestWebhook_DeliverPending_SignsPayload(t *testing.T) {
+	ctx := context.Background()
+	mockWebhookRepo := new(mockWebhookRepo)
+	webhooks := NewWebhook(mockWebhookRepo, testWebhookConfig, logrus.New())
+
+	payload := []byte(`{"id":"evt_1","type":"session.revoked","data":{}}`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, err := strconv.ParseInt(r.Header.Get(WebhookTimestampHeader), 10, 64)
+		assert.NoError(t, err)
+		assert.Equal(t, payload, body)
+		assert.Equal(t, "evt_1", r.Header.Get(WebhookIDHeader))
+		assert.Equal(t, SignWebhook("whsec_test", time.Unix(timestamp, 0), body), r.Header.Get(WebhookSignatureHeader))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	mockWebhookRepo.On("ClaimWebhookDeliveries", ctx, mock.Anything, mock.Anything, testWebhookConfig.BatchSize).Return([]entity.WebhookDelivery{
+		{ID: 1, EventID: "evt_1", Payload: payload, URL: server.URL, Secret: "whsec_test"},
+	}, nil)
+	mockWebhookRepo.On("UpdateWebhookDelivery", mock.Anything, mock.MatchedBy(func(delivery entity.WebhookDelivery) bool {
+		return delivery.Status == entity.WebhookDeliveryDelivered && delivery.Attempts == 1 &&
+			delivery.LastStatusCode == http.StatusNoContent && delivery.DeliveredAt != nil
+	})).Return(nil)
+
+	err := webhooks.DeliverPending(ctx)
+
+	assert.NoError(t, err)
+	mockWebhookRepo.AssertNumberOfCalls(t, "UpdateWebhookDelivery", 1)
+}
+
+func TestWebhook_DeliverPending_RetriesThenDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	mockWebhookRepo := new(mockWebhookRepo)
+	webhooks := NewWebhook(mockWebhookRepo, testWebhookConfig, logrus.New())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	mockWebhookRepo.On("ClaimWebhookDeliveries", ctx, mock.Anything, mock.Anything, testWebhookConfig.BatchSize).Return([]entity.WebhookDelivery{
+		{ID: 1, URL: server.URL, Secret: "whsec_test", Attempts: 0},
+		{ID: 2, URL: server.URL, Secret: "whsec_test", Attempts: testWebhookConfig.MaxAttempts - 1},
+	}, nil)
+	updated := make(chan entity.WebhookDelivery, 2)
+	mockWebhookRepo.On("UpdateWebhookDelivery", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { updated <- args.Get(1).(entity.WebhookDelivery) }).
+		Return(nil)
+
+	start := time.Now()
+	err := webhooks.DeliverPending(ctx)
+	close(updated)
+
+	assert.NoError(t, err)
+	for delivery := range updated {
+		assert.Equal(t, http.StatusServiceUnavailable, delivery.LastStatusCode)
+		assert.Contains(t, delivery.LastError, "unavailable")
+
+		switch delivery.ID {
+		case 1:
+			assert.Equal(t, entity.WebhookDeliveryPending, delivery.Status)
+			assert.Equal(t, 1, delivery.Attempts)
+			assert.WithinRange(t, delivery.NextAttemptAt, start.Add(testWebhookConfig.BaseBackoff/2), time.Now().Add(testWebhookConfig.BaseBackoff))
+		case 2:
+			assert.Equal(t, entity.WebhookDeliveryDead, delivery.Status)
+			assert.Equal(t, testWebhookConfig.MaxAttempts, delivery.Attempts)
+		}
+	}
+}
+
+func TestWebhook_Backoff(t *testing.T) {
+	webhooks := NewWebhook(new(mockWebhookRepo), testWebhookConfig, logrus.New())
+
+	for attempts, want := range map[int]time.Duration{1: time.Minute, 3: 4 * time.Minute, 20: time.Hour} {
+		delay := webhooks.backoff(attempts)
+		assert.GreaterOrEqual(t, delay, want/2)
+		assert.LessOrEqual(t, delay, want)
+	}
+}
+
+func TestWebhook_CreateSubscription_Validates(t *testing.T) {
+	ctx := context.Background()
+	webhooks := NewWebhook(new(mockWebhookRepo), testWebhookConfig, logrus.New())
+
+	_, err := webhooks.CreateSubscription(ctx, entity.WebhookSubscription{URL: "ftp://example.com", EventTypes: []string{entity.WebhookAllEvents}})
+	assert.ErrorIs(t, err, ErrInvalidWebhookURL)
+
+	_, err = webhooks.CreateSubscription(ctx, entity.WebhookSubscription{URL: "https://example.com/hook", EventTypes: []string{"session.unknown"}})
+	assert.ErrorIs(t, err, ErrUnknownWebhookEventType)
+}
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS password_reset_tokens;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS revoked_at;
+ALTER TABLE users DROP COLUMN IF EXISTS password_hash;
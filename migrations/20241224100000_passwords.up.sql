@@ -0,0 +1,12 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_hash VARCHAR(255) NULL;
+
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS revoked_at TIMESTAMP NULL;
+
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+                                id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                                user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                                token_hash VARCHAR(255) NOT NULL UNIQUE,
+                                created_at TIMESTAMP DEFAULT NOW(),
+                                expires_at TIMESTAMP NOT NULL,
+                                used_at TIMESTAMP NULL
+);
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+                                key VARCHAR(512) PRIMARY KEY,
+                                tokens DOUBLE PRECISION NOT NULL,
+                                allowed BOOLEAN NOT NULL,
+                                updated_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS audit_events (
+                                id BIGSERIAL PRIMARY KEY,
+                                type VARCHAR(64) NOT NULL,
+                                actor_id VARCHAR(255) NOT NULL DEFAULT '',
+                                subject_id VARCHAR(255) NOT NULL DEFAULT '',
+                                ip VARCHAR(255) NOT NULL DEFAULT '',
+                                user_agent TEXT NOT NULL DEFAULT '',
+                                metadata JSONB NOT NULL DEFAULT '{}',
+                                created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS audit_events_subject_id_idx ON audit_events (subject_id, id);
+CREATE INDEX IF NOT EXISTS audit_events_actor_id_idx ON audit_events (actor_id, id);
+CREATE INDEX IF NOT EXISTS audit_events_type_idx ON audit_events (type, id);
+CREATE INDEX IF NOT EXISTS audit_events_created_at_idx ON audit_events (created_at);
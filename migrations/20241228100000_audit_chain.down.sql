@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS audit_checkpoints;
+
+DROP INDEX IF EXISTS audit_events_chain_day_idx;
+ALTER TABLE audit_events DROP COLUMN IF EXISTS hash;
+ALTER TABLE audit_events DROP COLUMN IF EXISTS prev_hash;
+ALTER TABLE audit_events DROP COLUMN IF EXISTS chain_day;
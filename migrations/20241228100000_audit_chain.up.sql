@@ -0,0 +1,19 @@
+ALTER TABLE audit_events ADD COLUMN IF NOT EXISTS chain_day DATE;
+ALTER TABLE audit_events ADD COLUMN IF NOT EXISTS prev_hash VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE audit_events ADD COLUMN IF NOT EXISTS hash VARCHAR(64) NOT NULL DEFAULT '';
+
+UPDATE audit_events SET chain_day = created_at::date WHERE chain_day IS NULL;
+ALTER TABLE audit_events ALTER COLUMN chain_day SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS audit_events_chain_day_idx ON audit_events (chain_day, id);
+
+CREATE TABLE IF NOT EXISTS audit_checkpoints (
+                                day DATE PRIMARY KEY,
+                                first_event_id BIGINT NOT NULL,
+                                last_event_id BIGINT NOT NULL,
+                                event_count INTEGER NOT NULL,
+                                last_hash VARCHAR(64) NOT NULL,
+                                prev_signature VARCHAR(128) NOT NULL DEFAULT '',
+                                signature VARCHAR(128) NOT NULL,
+                                created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+                                id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                                url TEXT NOT NULL,
+                                event_types TEXT[] NOT NULL,
+                                secret VARCHAR(255) NOT NULL,
+                                enabled BOOLEAN NOT NULL DEFAULT TRUE,
+                                created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                                updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS webhook_outbox (
+                                id BIGSERIAL PRIMARY KEY,
+                                subscription_id UUID NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+                                event_id VARCHAR(64) NOT NULL,
+                                event_type VARCHAR(64) NOT NULL,
+                                payload JSONB NOT NULL,
+                                status VARCHAR(16) NOT NULL DEFAULT 'pending',
+                                attempts INTEGER NOT NULL DEFAULT 0,
+                                next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                                locked_until TIMESTAMP,
+                                last_error TEXT NOT NULL DEFAULT '',
+                                last_status_code INTEGER NOT NULL DEFAULT 0,
+                                created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                                delivered_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS webhook_outbox_pending_idx ON webhook_outbox (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_outbox_subscription_id_idx ON webhook_outbox (subscription_id, id);
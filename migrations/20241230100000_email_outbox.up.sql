@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS email_outbox (
+                                id BIGSERIAL PRIMARY KEY,
+                                kind VARCHAR(32) NOT NULL,
+                                recipient VARCHAR(255) NOT NULL,
+                                subject TEXT NOT NULL,
+                                html_body TEXT NOT NULL,
+                                status VARCHAR(16) NOT NULL DEFAULT 'pending',
+                                attempts INTEGER NOT NULL DEFAULT 0,
+                                next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                                locked_until TIMESTAMP,
+                                last_error TEXT NOT NULL DEFAULT '',
+                                created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                                sent_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS email_outbox_pending_idx ON email_outbox (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS email_outbox_status_idx ON email_outbox (status, id);
@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locale VARCHAR(16) NOT NULL DEFAULT '';
+ALTER TABLE email_outbox ADD COLUMN IF NOT EXISTS text_body TEXT NOT NULL DEFAULT '';
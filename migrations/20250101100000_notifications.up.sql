@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS notification_preferences (
+                                user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                                channel VARCHAR(16) NOT NULL,
+                                address VARCHAR(255) NOT NULL DEFAULT '',
+                                min_severity VARCHAR(16) NOT NULL,
+                                updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                                PRIMARY KEY (user_id, channel)
+);
+
+-- the outbox carries notifications of every channel, recipient is the address in the channel
+ALTER TABLE email_outbox ADD COLUMN IF NOT EXISTS channel VARCHAR(16) NOT NULL DEFAULT 'email';
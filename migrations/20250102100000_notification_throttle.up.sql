@@ -0,0 +1,22 @@
+-- the last notification per user, template and key, e.g. the IP of a suspicious login
+CREATE TABLE IF NOT EXISTS notification_throttle (
+                                user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                                template VARCHAR(32) NOT NULL,
+                                dedup_key TEXT NOT NULL,
+                                sent_at TIMESTAMP NOT NULL,
+                                suppressed INTEGER NOT NULL DEFAULT 0,
+                                PRIMARY KEY (user_id, template, dedup_key)
+);
+
+CREATE INDEX IF NOT EXISTS notification_throttle_sent_idx ON notification_throttle (user_id, template, sent_at);
+
+CREATE TABLE IF NOT EXISTS notification_digest_items (
+                                id BIGSERIAL PRIMARY KEY,
+                                user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                                template VARCHAR(32) NOT NULL,
+                                severity VARCHAR(16) NOT NULL,
+                                subject TEXT NOT NULL,
+                                created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS notification_digest_items_user_idx ON notification_digest_items (user_id, created_at);
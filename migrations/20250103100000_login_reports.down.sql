@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS login_reports;
+ALTER TABLE users DROP COLUMN IF EXISTS password_reset_required;
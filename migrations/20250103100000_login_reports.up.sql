@@ -0,0 +1,10 @@
+-- set when the user reported a sign-in as not theirs, login is refused until the password is reset
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_reset_required BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- a row per used "this wasn't me" link, token_id is the jti of the link, which makes it single-use
+CREATE TABLE IF NOT EXISTS login_reports (
+                                token_id VARCHAR(64) PRIMARY KEY,
+                                user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                                ip VARCHAR(255) NOT NULL,
+                                reported_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
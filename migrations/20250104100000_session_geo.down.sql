@@ -0,0 +1,6 @@
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS accuracy_radius;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS longitude;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS latitude;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS asn;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS city;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS country;
@@ -0,0 +1,7 @@
+-- where the session was created from, resolved from client_ip with the GeoIP databases
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS country VARCHAR(2) NOT NULL DEFAULT '';
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS city VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS asn BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION;
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION;
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS accuracy_radius INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,13 @@
+-- a row per risky sign-in held back until the user enters the code emailed to them
+CREATE TABLE IF NOT EXISTS step_up_challenges (
+                                id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                                user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                                code_hash VARCHAR(255) NOT NULL,
+                                client_ip VARCHAR(255) NOT NULL,
+                                operation VARCHAR(32) NOT NULL,
+                                refresh_token_id UUID NULL REFERENCES refresh_tokens(id) ON DELETE CASCADE,
+                                attempts INTEGER NOT NULL DEFAULT 0,
+                                created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                                expires_at TIMESTAMP NOT NULL,
+                                used_at TIMESTAMP NULL
+);
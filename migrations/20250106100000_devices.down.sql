@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS refresh_tokens_device_id_idx;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS device_id;
+DROP TABLE IF EXISTS devices;
@@ -0,0 +1,17 @@
+-- a row per browser or app a user signed in with, recognized by the hash of its device key
+CREATE TABLE IF NOT EXISTS devices (
+                                id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                                user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                                key_hash VARCHAR(64) NOT NULL,
+                                name VARCHAR(255) NOT NULL DEFAULT '',
+                                browser VARCHAR(255) NOT NULL DEFAULT '',
+                                os VARCHAR(255) NOT NULL DEFAULT '',
+                                user_agent TEXT NOT NULL DEFAULT '',
+                                last_ip VARCHAR(255) NOT NULL DEFAULT '',
+                                created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                                last_seen_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                                UNIQUE(user_id, key_hash)
+);
+
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS device_id UUID NULL REFERENCES devices(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS refresh_tokens_device_id_idx ON refresh_tokens (device_id);
@@ -0,0 +1,10 @@
+-- CIDR allow and deny lists of the addresses a user may sign in from
+CREATE TABLE IF NOT EXISTS ip_rules (
+                                id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                                user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                                action VARCHAR(8) NOT NULL,
+                                cidr VARCHAR(64) NOT NULL,
+                                description VARCHAR(255) NOT NULL DEFAULT '',
+                                created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                                UNIQUE(user_id, action, cidr)
+);
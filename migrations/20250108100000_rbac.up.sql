@@ -0,0 +1,28 @@
+-- roles and the permissions they grant, carried in access tokens
+CREATE TABLE IF NOT EXISTS permissions (
+                                name VARCHAR(128) PRIMARY KEY,
+                                description VARCHAR(255) NOT NULL DEFAULT '',
+                                created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS roles (
+                                id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                                name VARCHAR(64) NOT NULL UNIQUE,
+                                description VARCHAR(255) NOT NULL DEFAULT '',
+                                created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+                                role_id UUID NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+                                permission VARCHAR(128) NOT NULL REFERENCES permissions(name) ON DELETE CASCADE,
+                                PRIMARY KEY (role_id, permission)
+);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+                                user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                                role_id UUID NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+                                created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                                PRIMARY KEY (user_id, role_id)
+);
+
+CREATE INDEX IF NOT EXISTS user_roles_role_id_idx ON user_roles(role_id);
@@ -0,0 +1,24 @@
+-- every write of relation tuples gets a revision, reads evaluate the tuples of one revision
+CREATE TABLE IF NOT EXISTS relation_revisions (
+                                revision BIGSERIAL PRIMARY KEY,
+                                created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- deleted tuples stay until no retained revision sees them
+CREATE TABLE IF NOT EXISTS relation_tuples (
+                                id BIGSERIAL PRIMARY KEY,
+                                namespace VARCHAR(64) NOT NULL,
+                                object_id VARCHAR(255) NOT NULL,
+                                relation VARCHAR(64) NOT NULL,
+                                subject_namespace VARCHAR(64) NOT NULL,
+                                subject_id VARCHAR(255) NOT NULL,
+                                subject_relation VARCHAR(64) NOT NULL DEFAULT '',
+                                created_revision BIGINT NOT NULL,
+                                deleted_revision BIGINT
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS relation_tuples_live_idx
+    ON relation_tuples(namespace, object_id, relation, subject_namespace, subject_id, subject_relation)
+    WHERE deleted_revision IS NULL;
+CREATE INDEX IF NOT EXISTS relation_tuples_object_idx ON relation_tuples(namespace, object_id, relation);
+CREATE INDEX IF NOT EXISTS relation_tuples_subject_idx ON relation_tuples(subject_namespace, subject_id, subject_relation);
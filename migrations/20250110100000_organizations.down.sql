@@ -0,0 +1,21 @@
+DROP POLICY IF EXISTS tenant_isolation ON ip_rules;
+ALTER TABLE ip_rules NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE ip_rules DISABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON refresh_tokens;
+ALTER TABLE refresh_tokens NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE refresh_tokens DISABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON memberships;
+DROP POLICY IF EXISTS tenant_isolation ON organizations;
+DROP FUNCTION IF EXISTS current_org_id();
+
+DELETE FROM ip_rules WHERE org_id IS NOT NULL;
+DROP INDEX IF EXISTS ip_rules_org_id_action_cidr_key;
+ALTER TABLE ip_rules DROP CONSTRAINT IF EXISTS ip_rules_owner_check;
+ALTER TABLE ip_rules DROP COLUMN IF EXISTS org_id;
+ALTER TABLE ip_rules ALTER COLUMN user_id SET NOT NULL;
+
+ALTER TABLE step_up_challenges DROP COLUMN IF EXISTS org_id;
+DROP INDEX IF EXISTS refresh_tokens_org_id_idx;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS org_id;
+DROP TABLE IF EXISTS memberships;
+DROP TABLE IF EXISTS organizations;
@@ -0,0 +1,4 @@
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS session_started_at;
+
+DROP POLICY IF EXISTS tenant_isolation ON token_policies;
+DROP TABLE IF EXISTS token_policies;
@@ -0,0 +1,23 @@
+-- how the sessions of an organization are issued, durations in seconds and 0 for the global setting
+CREATE TABLE IF NOT EXISTS token_policies (
+                                org_id UUID PRIMARY KEY REFERENCES organizations(id) ON DELETE CASCADE,
+                                access_token_ttl_seconds BIGINT NOT NULL DEFAULT 0,
+                                refresh_token_ttl_seconds BIGINT NOT NULL DEFAULT 0,
+                                max_session_lifetime_seconds BIGINT NOT NULL DEFAULT 0,
+                                allowed_grants TEXT[] NOT NULL DEFAULT '{}',
+                                require_mfa BOOLEAN NOT NULL DEFAULT false,
+                                signing_key VARCHAR(255) NOT NULL DEFAULT '',
+                                previous_signing_key VARCHAR(255) NOT NULL DEFAULT '',
+                                previous_key_expires_at TIMESTAMP NULL,
+                                updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE token_policies ENABLE ROW LEVEL SECURITY;
+ALTER TABLE token_policies FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON token_policies USING (current_org_id() IS NULL OR org_id = current_org_id());
+
+-- when the session signed in, kept by refreshes so its lifetime can be limited
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS session_started_at TIMESTAMP NULL;
+UPDATE refresh_tokens SET session_started_at = issued_at WHERE session_started_at IS NULL;
+ALTER TABLE refresh_tokens ALTER COLUMN session_started_at SET DEFAULT NOW();
+ALTER TABLE refresh_tokens ALTER COLUMN session_started_at SET NOT NULL;
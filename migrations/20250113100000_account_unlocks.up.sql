@@ -0,0 +1,6 @@
+-- a row per used unlock link, token_id is the jti of the link, which makes it single-use
+CREATE TABLE IF NOT EXISTS account_unlocks (
+                                token_id VARCHAR(64) PRIMARY KEY,
+                                user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                                unlocked_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,16 @@
+DROP POLICY IF EXISTS tenant_isolation ON token_policies;
+CREATE POLICY tenant_isolation ON token_policies USING (current_org_id() IS NULL OR org_id = current_org_id());
+
+DROP POLICY IF EXISTS tenant_isolation ON ip_rules;
+CREATE POLICY tenant_isolation ON ip_rules USING (current_org_id() IS NULL OR org_id = current_org_id());
+
+DROP POLICY IF EXISTS tenant_isolation ON refresh_tokens;
+CREATE POLICY tenant_isolation ON refresh_tokens USING (current_org_id() IS NULL OR org_id = current_org_id());
+
+DROP POLICY IF EXISTS tenant_isolation ON memberships;
+CREATE POLICY tenant_isolation ON memberships USING (current_org_id() IS NULL OR org_id = current_org_id());
+
+DROP POLICY IF EXISTS tenant_isolation ON organizations;
+CREATE POLICY tenant_isolation ON organizations USING (current_org_id() IS NULL OR id = current_org_id());
+
+DROP FUNCTION IF EXISTS rls_bypassed();
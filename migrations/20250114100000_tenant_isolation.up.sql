@@ -0,0 +1,20 @@
+-- row-level security denies by default: without app.org_id queries only see the rows that belong to no
+-- organization, admin and system paths that work across organizations set app.bypass_rls
+CREATE OR REPLACE FUNCTION rls_bypassed() RETURNS BOOLEAN AS $$
+    SELECT COALESCE(current_setting('app.bypass_rls', true), '') = 'on'
+$$ LANGUAGE SQL STABLE;
+
+DROP POLICY IF EXISTS tenant_isolation ON organizations;
+CREATE POLICY tenant_isolation ON organizations USING (rls_bypassed() OR id = current_org_id());
+
+DROP POLICY IF EXISTS tenant_isolation ON memberships;
+CREATE POLICY tenant_isolation ON memberships USING (rls_bypassed() OR org_id = current_org_id());
+
+DROP POLICY IF EXISTS tenant_isolation ON refresh_tokens;
+CREATE POLICY tenant_isolation ON refresh_tokens USING (rls_bypassed() OR org_id IS NOT DISTINCT FROM current_org_id());
+
+DROP POLICY IF EXISTS tenant_isolation ON ip_rules;
+CREATE POLICY tenant_isolation ON ip_rules USING (rls_bypassed() OR org_id IS NOT DISTINCT FROM current_org_id());
+
+DROP POLICY IF EXISTS tenant_isolation ON token_policies;
+CREATE POLICY tenant_isolation ON token_policies USING (rls_bypassed() OR org_id = current_org_id());
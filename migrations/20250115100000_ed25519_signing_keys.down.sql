@@ -0,0 +1,8 @@
+-- Ed25519 keys do not work as HMAC keys, organizations go back to the global key
+SELECT set_config('app.bypass_rls', 'on', false);
+
+UPDATE token_policies
+SET signing_key = '', previous_signing_key = '', previous_key_expires_at = NULL, updated_at = NOW()
+WHERE signing_key <> '' OR previous_signing_key <> '';
+
+SELECT set_config('app.bypass_rls', '', false);
@@ -0,0 +1,10 @@
+-- signing keys of organizations are Ed25519 private keys from now on. The HMAC keys stored so far were
+-- handed out and can mint tokens, so they are dropped instead of kept for verification: organizations
+-- that had one are back on the global key and their members sign in again.
+SELECT set_config('app.bypass_rls', 'on', false);
+
+UPDATE token_policies
+SET signing_key = '', previous_signing_key = '', previous_key_expires_at = NULL, updated_at = NOW()
+WHERE signing_key <> '' OR previous_signing_key <> '';
+
+SELECT set_config('app.bypass_rls', '', false);
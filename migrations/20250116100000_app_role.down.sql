@@ -0,0 +1,11 @@
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'medods_app') THEN
+        ALTER DEFAULT PRIVILEGES IN SCHEMA public REVOKE SELECT, INSERT, UPDATE, DELETE ON TABLES FROM medods_app;
+        ALTER DEFAULT PRIVILEGES IN SCHEMA public REVOKE USAGE, SELECT ON SEQUENCES FROM medods_app;
+        REVOKE ALL ON ALL SEQUENCES IN SCHEMA public FROM medods_app;
+        REVOKE ALL ON ALL TABLES IN SCHEMA public FROM medods_app;
+        REVOKE USAGE ON SCHEMA public FROM medods_app;
+    END IF;
+END
+$$;
@@ -0,0 +1,22 @@
+-- the service connects as a member of medods_app, which is neither a superuser nor exempt from row-level
+-- security, so the tenant isolation policies apply to it; migrations run as the owner of the tables
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'medods_app') THEN
+        IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = current_user AND (rolsuper OR rolcreaterole)) THEN
+            RAISE NOTICE 'role medods_app does not exist and % cannot create it', current_user;
+            RETURN;
+        END IF;
+        CREATE ROLE medods_app NOLOGIN NOSUPERUSER NOBYPASSRLS;
+    END IF;
+
+    GRANT USAGE ON SCHEMA public TO medods_app;
+    GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public TO medods_app;
+    GRANT USAGE, SELECT ON ALL SEQUENCES IN SCHEMA public TO medods_app;
+    REVOKE ALL ON schema_migrations FROM medods_app;
+
+    -- tables of later migrations
+    ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT SELECT, INSERT, UPDATE, DELETE ON TABLES TO medods_app;
+    ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT USAGE, SELECT ON SEQUENCES TO medods_app;
+END
+$$;
@@ -0,0 +1,7 @@
+-- forgotten password requests are queued and answered by a worker, so the endpoint does the same work
+-- whether the email is registered or not
+CREATE TABLE IF NOT EXISTS password_reset_requests (
+    id BIGSERIAL PRIMARY KEY,
+    email VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
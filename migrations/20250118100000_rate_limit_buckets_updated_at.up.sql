@@ -0,0 +1,2 @@
+-- idle buckets are pruned by the time they were last taken from
+CREATE INDEX IF NOT EXISTS rate_limit_buckets_updated_at_idx ON rate_limit_buckets (updated_at);
@@ -0,0 +1,225 @@
+// Package dkim signs outgoing mail as described in RFC 6376, with relaxed/relaxed canonicalization
+// and rsa-sha256 or ed25519-sha256 (RFC 8463) signatures.
+package dkim
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultHeaders are signed unless others are configured. Headers missing from a message are skipped.
+var DefaultHeaders = []string{"From", "To", "Subject", "Date", "Message-ID", "MIME-Version", "Content-Type", "Reply-To", "Cc"}
+
+type Signer struct {
+	domain    string
+	selector  string
+	headers   []string
+	key       crypto.Signer
+	algorithm string
+	now       func() time.Time
+}
+
+// NewSigner creates a signer for the domain and selector, whose public key is published at
+// <selector>._domainkey.<domain>. keyPEM is an RSA (PKCS #1 or #8) or Ed25519 (PKCS #8) private key.
+func NewSigner(domain, selector string, keyPEM []byte, headers []string) (*Signer, error) {
+	if domain == "" || selector == "" {
+		return nil, errors.New("dkim domain and selector are required")
+	}
+
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, errors.New("dkim private key is not PEM encoded")
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported dkim private key type %q", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error while parsing dkim private key: %w", err)
+	}
+
+	signer := &Signer{domain: domain, selector: selector, headers: headers, now: time.Now}
+	if len(signer.headers) == 0 {
+		signer.headers = DefaultHeaders
+	}
+
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		if key.N.BitLen() < 1024 {
+			return nil, errors.New("dkim rsa key must have at least 1024 bits")
+		}
+		signer.key, signer.algorithm = key, "rsa-sha256"
+	case ed25519.PrivateKey:
+		signer.key, signer.algorithm = key, "ed25519-sha256"
+	default:
+		return nil, fmt.Errorf("unsupported dkim private key %T", key)
+	}
+
+	return signer, nil
+}
+
+// LoadSigner reads the private key from a file, see NewSigner.
+func LoadSigner(domain, selector, keyPath string, headers []string) (*Signer, error) {
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSigner(domain, selector, keyPEM, headers)
+}
+
+// Sign returns the message with a DKIM-Signature header prepended. Lines must end with CRLF.
+func (s *Signer) Sign(message []byte) ([]byte, error) {
+	header, body, ok := bytes.Cut(message, []byte("\r\n\r\n"))
+	if !ok {
+		return nil, errors.New("message has no header and body separator")
+	}
+	fields := splitHeader(string(header) + "\r\n")
+
+	bodyHash := sha256.Sum256(canonicalizeBody(body))
+
+	// h= lists the signed fields bottom-up, as verifiers pick repeated fields from the bottom
+	var signedNames []string
+	var signedFields []string
+	used := map[int]bool{}
+	for _, name := range s.headers {
+		for i := len(fields) - 1; i >= 0; i-- {
+			if !used[i] && strings.EqualFold(fieldName(fields[i]), name) {
+				used[i] = true
+				signedNames = append(signedNames, name)
+				signedFields = append(signedFields, fields[i])
+				break
+			}
+		}
+	}
+	if len(signedNames) == 0 {
+		return nil, errors.New("message has none of the headers to sign")
+	}
+
+	value := fmt.Sprintf("v=1; a=%s; c=relaxed/relaxed; d=%s; s=%s; t=%s; h=%s; bh=%s; b=",
+		s.algorithm,
+		s.domain,
+		s.selector,
+		strconv.FormatInt(s.now().Unix(), 10),
+		strings.Join(signedNames, ":"),
+		base64.StdEncoding.EncodeToString(bodyHash[:]))
+
+	hash := sha256.New()
+	for _, field := range signedFields {
+		hash.Write([]byte(canonicalizeHeader(field)))
+	}
+	// the signature field itself is signed with an empty b= and without its trailing CRLF
+	hash.Write([]byte(strings.TrimSuffix(canonicalizeHeader("DKIM-Signature: "+value+"\r\n"), "\r\n")))
+	digest := hash.Sum(nil)
+
+	var signature []byte
+	var err error
+	if s.algorithm == "ed25519-sha256" {
+		signature, err = s.key.Sign(rand.Reader, digest, crypto.Hash(0))
+	} else {
+		signature, err = s.key.Sign(rand.Reader, digest, crypto.SHA256)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error while signing message: %w", err)
+	}
+
+	signed := make([]byte, 0, len(message)+len(value)+512)
+	signed = append(signed, "DKIM-Signature: "+value+foldBase64(base64.StdEncoding.EncodeToString(signature))+"\r\n"...)
+	signed = append(signed, message...)
+
+	return signed, nil
+}
+
+// splitHeader splits a header block into fields, each with its continuation lines and final CRLF.
+func splitHeader(header string) []string {
+	var fields []string
+	for _, line := range strings.SplitAfter(header, "\r\n") {
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(fields) > 0 {
+			fields[len(fields)-1] += line
+			continue
+		}
+		fields = append(fields, line)
+	}
+
+	return fields
+}
+
+func fieldName(field string) string {
+	name, _, _ := strings.Cut(field, ":")
+	return strings.TrimSpace(name)
+}
+
+// canonicalizeHeader is the relaxed header canonicalization of RFC 6376 section 3.4.2.
+func canonicalizeHeader(field string) string {
+	name, value, _ := strings.Cut(field, ":")
+	value = strings.ReplaceAll(value, "\r\n", "")
+	value = strings.Join(strings.FieldsFunc(value, isWSP), " ")
+
+	return strings.ToLower(strings.TrimSpace(name)) + ":" + value + "\r\n"
+}
+
+// canonicalizeBody is the relaxed body canonicalization of RFC 6376 section 3.4.4.
+func canonicalizeBody(body []byte) []byte {
+	lines := strings.Split(string(body), "\r\n")
+	for i, line := range lines {
+		fields := strings.FieldsFunc(line, isWSP)
+		canonical := strings.Join(fields, " ")
+		// whitespace at the start of a line is reduced, not removed
+		if len(line) > 0 && isWSP(rune(line[0])) {
+			canonical = " " + canonical
+			if len(fields) == 0 {
+				canonical = ""
+			}
+		}
+		lines[i] = canonical
+	}
+
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+func isWSP(r rune) bool {
+	return r == ' ' || r == '\t'
+}
+
+// foldBase64 keeps the header lines short, verifiers ignore whitespace in b=.
+func foldBase64(signature string) string {
+	var folded strings.Builder
+	for len(signature) > 72 {
+		folded.WriteString(signature[:72])
+		folded.WriteString("\r\n ")
+		signature = signature[72:]
+	}
+	folded.WriteString(signature)
+
+	return folded.String()
+}
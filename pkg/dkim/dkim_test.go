@@ -0,0 +1,107 @@
+package dkim
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const testMessage = "From: Auth <auth@example.com>\r\n" +
+	"To: user@example.com\r\n" +
+	"Subject: New  sign-in\r\n" +
+	"\tfrom 203.0.113.7\r\n" +
+	"Content-Type: text/plain; charset=UTF-8\r\n" +
+	"\r\n" +
+	"Hello,  \r\n" +
+	"\r\n" +
+	"a new sign-in.\r\n" +
+	"\r\n\r\n"
+
+// verify checks the signature the way a receiver does, with the public key of the DNS record.
+func verify(t *testing.T, signed string, publicKey crypto.PublicKey) {
+	signatureField, message, _ := strings.Cut(signed, "\r\nFrom:")
+	message = "From:" + message
+	header, body, _ := strings.Cut(message, "\r\n\r\n")
+
+	tags := map[string]string{}
+	unfolded := strings.ReplaceAll(strings.TrimPrefix(signatureField, "DKIM-Signature: "), "\r\n ", "")
+	for _, tag := range strings.Split(unfolded, ";") {
+		name, value, _ := strings.Cut(strings.TrimSpace(tag), "=")
+		tags[name] = value
+	}
+
+	bodyHash := sha256.Sum256(canonicalizeBody([]byte(body)))
+	assert.Equal(t, base64.StdEncoding.EncodeToString(bodyHash[:]), tags["bh"])
+
+	fields := splitHeader(header + "\r\n")
+	hash := sha256.New()
+	for _, name := range strings.Split(tags["h"], ":") {
+		for _, field := range fields {
+			if strings.EqualFold(fieldName(field), name) {
+				hash.Write([]byte(canonicalizeHeader(field)))
+			}
+		}
+	}
+	withoutB := regexp.MustCompile(`b=[^;]*$`).ReplaceAllString(signatureField, "b=")
+	hash.Write([]byte(strings.TrimSuffix(canonicalizeHeader(withoutB+"\r\n"), "\r\n")))
+
+	signature, err := base64.StdEncoding.DecodeString(tags["b"])
+	assert.NoError(t, err)
+
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		assert.NoError(t, rsa.VerifyPKCS1v15(key, crypto.SHA256, hash.Sum(nil), signature))
+	case ed25519.PublicKey:
+		assert.True(t, ed25519.Verify(key, hash.Sum(nil), signature))
+	}
+}
+
+func TestSigner_RSA(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	signer, err := NewSigner("example.com", "mail", keyPEM, nil)
+	assert.NoError(t, err)
+
+	signed, err := signer.Sign([]byte(testMessage))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(signed), "DKIM-Signature: v=1; a=rsa-sha256; c=relaxed/relaxed; d=example.com; s=mail;"))
+	assert.Contains(t, string(signed), "h=From:To:Subject:Content-Type;")
+	verify(t, string(signed), &key.PublicKey)
+}
+
+func TestSigner_Ed25519(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	assert.NoError(t, err)
+
+	signer, err := NewSigner("example.com", "mail", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), []string{"From", "Subject"})
+	assert.NoError(t, err)
+
+	signed, err := signer.Sign([]byte(testMessage))
+	assert.NoError(t, err)
+	assert.Contains(t, string(signed), "a=ed25519-sha256;")
+	verify(t, string(signed), publicKey)
+}
+
+func TestCanonicalization(t *testing.T) {
+	// RFC 6376 section 3.4.5
+	assert.Equal(t, "a:X\r\n", canonicalizeHeader("A: X\r\n"))
+	assert.Equal(t, "b:Y Z\r\n", canonicalizeHeader("B : Y\t\r\n\tZ  \r\n"))
+	assert.Equal(t, " C\r\nD E\r\n", string(canonicalizeBody([]byte(" C \r\nD \t E\r\n\r\n\r\n"))))
+
+	// the empty body hashes to the well-known value of RFC 6376 section 3.4.4
+	emptyHash := sha256.Sum256(canonicalizeBody(nil))
+	assert.Equal(t, "47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=", base64.StdEncoding.EncodeToString(emptyHash[:]))
+}
@@ -0,0 +1,116 @@
+// Package geoip looks up the location and network of IP addresses in local MaxMind-format .mmdb
+// files, such as GeoLite2-City and GeoLite2-ASN, without calling any service.
+package geoip
+
+import (
+	"errors"
+	"fmt"
+	"github.com/oschwald/maxminddb-golang"
+	"net"
+)
+
+type Location struct {
+	// Country is the ISO 3166-1 alpha-2 code.
+	Country string
+	// City is the English name.
+	City string
+	// Latitude and Longitude are only meaningful when HasCoordinates is set.
+	Latitude       float64
+	Longitude      float64
+	HasCoordinates bool
+	// AccuracyRadius is in kilometers, mobile and VPN addresses are often hundreds of kilometers off.
+	AccuracyRadius int
+	ASN            uint
+	ASOrganization string
+}
+
+// record holds the fields of the City, Country and ASN databases, each database fills its part.
+type record struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Location struct {
+		Latitude       *float64 `maxminddb:"latitude"`
+		Longitude      *float64 `maxminddb:"longitude"`
+		AccuracyRadius uint16   `maxminddb:"accuracy_radius"`
+	} `maxminddb:"location"`
+	ASN            uint   `maxminddb:"autonomous_system_number"`
+	ASOrganization string `maxminddb:"autonomous_system_organization"`
+}
+
+// Reader merges the records of several databases, the first one that knows a field wins.
+type Reader struct {
+	databases []*maxminddb.Reader
+}
+
+// Open opens the databases at paths, empty paths are skipped.
+func Open(paths ...string) (*Reader, error) {
+	r := &Reader{}
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+
+		database, err := maxminddb.Open(path)
+		if err != nil {
+			_ = r.Close()
+			return nil, fmt.Errorf("error while opening %s: %w", path, err)
+		}
+		r.databases = append(r.databases, database)
+	}
+
+	if len(r.databases) == 0 {
+		return nil, errors.New("no database given")
+	}
+
+	return r, nil
+}
+
+// Lookup returns the location of ip, false when no database has a record for it.
+func (r *Reader) Lookup(ip net.IP) (Location, bool, error) {
+	var location Location
+	found := false
+
+	for _, database := range r.databases {
+		var rec record
+		_, ok, err := database.LookupNetwork(ip, &rec)
+		if err != nil {
+			return Location{}, false, err
+		}
+		if !ok {
+			continue
+		}
+		found = true
+
+		if location.Country == "" {
+			location.Country = rec.Country.ISOCode
+		}
+		if location.City == "" {
+			location.City = rec.City.Names["en"]
+		}
+		if !location.HasCoordinates && rec.Location.Latitude != nil && rec.Location.Longitude != nil {
+			location.Latitude = *rec.Location.Latitude
+			location.Longitude = *rec.Location.Longitude
+			location.AccuracyRadius = int(rec.Location.AccuracyRadius)
+			location.HasCoordinates = true
+		}
+		if location.ASN == 0 {
+			location.ASN = rec.ASN
+			location.ASOrganization = rec.ASOrganization
+		}
+	}
+
+	return location, found, nil
+}
+
+func (r *Reader) Close() error {
+	var errs []error
+	for _, database := range r.databases {
+		errs = append(errs, database.Close())
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,156 @@
+package geoip
+
+import (
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// encode writes value in the MaxMind DB data section format, only the types the tests need.
+func encode(value any) []byte {
+	switch v := value.(type) {
+	case string:
+		if len(v) >= 29 {
+			return append([]byte{2<<5 | 29, byte(len(v) - 29)}, v...)
+		}
+		return append([]byte{2<<5 | byte(len(v))}, v...)
+	case float64:
+		return binary.BigEndian.AppendUint64([]byte{3<<5 | 8}, math.Float64bits(v))
+	case uint32:
+		return binary.BigEndian.AppendUint32([]byte{6<<5 | 4}, v)
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		out := []byte{7<<5 | byte(len(v))}
+		for _, key := range keys {
+			out = append(out, encode(key)...)
+			out = append(out, encode(v[key])...)
+		}
+		return out
+	}
+	panic("unsupported type")
+}
+
+// writeDatabase builds an IPv4 database with 24-bit records that maps each prefix to its record.
+func writeDatabase(t *testing.T, records map[string]map[string]any) string {
+	const empty = -1
+	// a child is a node index, empty, or -2-i for the record of the i-th prefix
+	nodes := [][2]int{{empty, empty}}
+	var data [][]byte
+
+	for prefix, record := range records {
+		network := netip.MustParsePrefix(prefix)
+		address := network.Addr().As4()
+		node := 0
+		for bit := 0; bit < network.Bits(); bit++ {
+			side := int(address[bit/8]>>(7-bit%8)) & 1
+			if bit == network.Bits()-1 {
+				nodes[node][side] = -2 - len(data)
+				break
+			}
+			if nodes[node][side] == empty {
+				nodes = append(nodes, [2]int{empty, empty})
+				nodes[node][side] = len(nodes) - 1
+			}
+			node = nodes[node][side]
+		}
+		data = append(data, encode(record))
+	}
+
+	offsets := make([]int, len(data))
+	var section []byte
+	for i, record := range data {
+		offsets[i] = len(section)
+		section = append(section, record...)
+	}
+
+	var database []byte
+	for _, node := range nodes {
+		for _, child := range node {
+			value := child
+			switch {
+			case child == empty:
+				value = len(nodes)
+			case child < empty:
+				value = len(nodes) + 16 + offsets[-2-child]
+			}
+			database = append(database, byte(value>>16), byte(value>>8), byte(value))
+		}
+	}
+	database = append(database, make([]byte, 16)...)
+	database = append(database, section...)
+	database = append(database, "\xAB\xCD\xEFMaxMind.com"...)
+	database = append(database, encode(map[string]any{
+		"binary_format_major_version": uint32(2),
+		"binary_format_minor_version": uint32(0),
+		"database_type":               "Test",
+		"ip_version":                  uint32(4),
+		"node_count":                  uint32(len(nodes)),
+		"record_size":                 uint32(24),
+	})...)
+
+	path := filepath.Join(t.TempDir(), "test.mmdb")
+	assert.NoError(t, os.WriteFile(path, database, 0o600))
+	return path
+}
+
+func TestReader_Lookup(t *testing.T) {
+	cityDB := writeDatabase(t, map[string]map[string]any{
+		"203.0.113.0/24": {
+			"country":  map[string]any{"iso_code": "DE"},
+			"city":     map[string]any{"names": map[string]any{"en": "Berlin"}},
+			"location": map[string]any{"latitude": 52.52, "longitude": 13.4, "accuracy_radius": uint32(20)},
+		},
+		"198.51.100.0/24": {
+			"country": map[string]any{"iso_code": "US"},
+		},
+	})
+	asnDB := writeDatabase(t, map[string]map[string]any{
+		"203.0.112.0/23": {"autonomous_system_number": uint32(64500), "autonomous_system_organization": "Example"},
+	})
+
+	reader, err := Open(cityDB, "", asnDB)
+	assert.NoError(t, err)
+	defer reader.Close()
+
+	location, ok, err := reader.Lookup(net.ParseIP("203.0.113.7"))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, Location{
+		Country:        "DE",
+		City:           "Berlin",
+		Latitude:       52.52,
+		Longitude:      13.4,
+		HasCoordinates: true,
+		AccuracyRadius: 20,
+		ASN:            64500,
+		ASOrganization: "Example",
+	}, location)
+
+	location, ok, err = reader.Lookup(net.ParseIP("198.51.100.1"))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, Location{Country: "US"}, location)
+
+	_, ok, err = reader.Lookup(net.ParseIP("192.0.2.1"))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestOpen_NoDatabase(t *testing.T) {
+	_, err := Open("", "")
+	assert.Error(t, err)
+
+	_, err = Open(filepath.Join(t.TempDir(), "missing.mmdb"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,96 @@
+package hasher
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"golang.org/x/crypto/argon2"
+	"strings"
+)
+
+const argon2idID = "argon2id"
+
+type Argon2idParams struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+type Argon2id struct {
+	params Argon2idParams
+}
+
+func NewArgon2id(params Argon2idParams) *Argon2id {
+	return &Argon2id{params: params}
+}
+
+func (a *Argon2id) Algorithm() string {
+	return argon2idID
+}
+
+func (a *Argon2id) Hash(password string) (string, error) {
+	salt := make([]byte, a.params.SaltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+
+	hash := argon2.IDKey([]byte(password), salt, a.params.Iterations, a.params.Memory, a.params.Parallelism, a.params.KeyLength)
+
+	return phcHash{
+		id:      argon2idID,
+		version: argon2.Version,
+		params: map[string]int{
+			"m": int(a.params.Memory),
+			"t": int(a.params.Iterations),
+			"p": int(a.params.Parallelism),
+		},
+		salt: salt,
+		hash: hash,
+	}.String(), nil
+}
+
+func (a *Argon2id) Verify(password, encodedHash string) (bool, error) {
+	p, err := a.parse(encodedHash)
+	if err != nil {
+		return false, err
+	}
+
+	hash := argon2.IDKey([]byte(password), p.salt, uint32(p.params["t"]), uint32(p.params["m"]), uint8(p.params["p"]), uint32(len(p.hash)))
+
+	return subtle.ConstantTimeCompare(hash, p.hash) == 1, nil
+}
+
+func (a *Argon2id) Identifies(encodedHash string) bool {
+	return strings.HasPrefix(encodedHash, "$"+argon2idID+"$")
+}
+
+func (a *Argon2id) Weaker(encodedHash string) (bool, error) {
+	p, err := a.parse(encodedHash)
+	if err != nil {
+		return false, err
+	}
+
+	return p.params["m"] < int(a.params.Memory) ||
+		p.params["t"] < int(a.params.Iterations) ||
+		p.params["p"] < int(a.params.Parallelism) ||
+		len(p.salt) < int(a.params.SaltLength) ||
+		len(p.hash) < int(a.params.KeyLength), nil
+}
+
+func (a *Argon2id) parse(encodedHash string) (*phcHash, error) {
+	p, err := parsePHC(encodedHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.id != argon2idID || p.version != argon2.Version {
+		return nil, ErrMalformedHash
+	}
+	if p.params["m"] <= 0 || p.params["t"] <= 0 || p.params["p"] <= 0 || p.params["p"] > 255 || len(p.hash) == 0 {
+		return nil, ErrMalformedHash
+	}
+
+	return p, nil
+}
@@ -0,0 +1,76 @@
+package hasher
+
+import (
+	"errors"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+const bcryptID = "bcrypt"
+
+// bcryptMaxPasswordBytes is the longest password bcrypt hashes, it counts bytes rather than characters.
+const bcryptMaxPasswordBytes = 72
+
+// Bcrypt keeps the modular crypt format ($2a$, $2b$, $2y$) that bcrypt hashes are already stored and imported in.
+type Bcrypt struct {
+	cost int
+}
+
+func NewBcrypt(cost int) *Bcrypt {
+	return &Bcrypt{cost: cost}
+}
+
+func (b *Bcrypt) Algorithm() string {
+	return bcryptID
+}
+
+// MaxPasswordBytes is the longest password Hash accepts, longer ones fail with ErrPasswordTooLong.
+func (b *Bcrypt) MaxPasswordBytes() int {
+	return bcryptMaxPasswordBytes
+}
+
+func (b *Bcrypt) Hash(password string) (string, error) {
+	if len(password) > bcryptMaxPasswordBytes {
+		return "", ErrPasswordTooLong
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.cost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+func (b *Bcrypt) Verify(password, encodedHash string) (bool, error) {
+	// such a password cannot have been hashed
+	if len(password) > bcryptMaxPasswordBytes {
+		return false, nil
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(encodedHash), []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (b *Bcrypt) Identifies(encodedHash string) bool {
+	return strings.HasPrefix(encodedHash, "$2a$") ||
+		strings.HasPrefix(encodedHash, "$2b$") ||
+		strings.HasPrefix(encodedHash, "$2y$")
+}
+
+func (b *Bcrypt) Weaker(encodedHash string) (bool, error) {
+	cost, err := bcrypt.Cost([]byte(encodedHash))
+	if err != nil {
+		return false, err
+	}
+
+	return cost < b.cost, nil
+}
@@ -0,0 +1,97 @@
+package hasher
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrUnknownAlgorithm = errors.New("unknown password hash algorithm")
+	ErrMalformedHash    = errors.New("malformed password hash")
+	ErrPasswordTooLong  = errors.New("password is too long for the hash algorithm")
+)
+
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(password, encodedHash string) (bool, error)
+	NeedsRehash(encodedHash string) bool
+}
+
+// Scheme is a single hashing algorithm with its currently configured parameters.
+type Scheme interface {
+	Algorithm() string
+	Hash(password string) (string, error)
+	Verify(password, encodedHash string) (bool, error)
+	// Identifies reports whether encodedHash was produced by this algorithm.
+	Identifies(encodedHash string) bool
+	// Weaker reports whether encodedHash was produced with weaker parameters than the configured ones.
+	Weaker(encodedHash string) (bool, error)
+}
+
+// Hasher hashes new passwords with the default scheme and verifies hashes of any registered scheme,
+// so users imported with other algorithms can still log in and be migrated on the way.
+type Hasher struct {
+	current Scheme
+	schemes []Scheme
+}
+
+func New(defaultAlgorithm string, schemes ...Scheme) (*Hasher, error) {
+	h := &Hasher{schemes: schemes}
+	for _, scheme := range schemes {
+		if scheme.Algorithm() == defaultAlgorithm {
+			h.current = scheme
+		}
+	}
+
+	if h.current == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAlgorithm, defaultAlgorithm)
+	}
+
+	return h, nil
+}
+
+func (h *Hasher) Hash(password string) (string, error) {
+	return h.current.Hash(password)
+}
+
+func (h *Hasher) Verify(password, encodedHash string) (bool, error) {
+	scheme, err := h.schemeFor(encodedHash)
+	if err != nil {
+		return false, err
+	}
+
+	return scheme.Verify(password, encodedHash)
+}
+
+// MaxPasswordBytes returns the longest password the default scheme hashes, 0 when it has no limit.
+func (h *Hasher) MaxPasswordBytes() int {
+	if limited, ok := h.current.(interface{ MaxPasswordBytes() int }); ok {
+		return limited.MaxPasswordBytes()
+	}
+
+	return 0
+}
+
+func (h *Hasher) NeedsRehash(encodedHash string) bool {
+	scheme, err := h.schemeFor(encodedHash)
+	if err != nil || scheme != h.current {
+		return true
+	}
+
+	weaker, err := scheme.Weaker(encodedHash)
+	if err != nil {
+		return true
+	}
+
+	return weaker
+}
+
+func (h *Hasher) schemeFor(encodedHash string) (Scheme, error) {
+	for _, scheme := range h.schemes {
+		if scheme.Identifies(encodedHash) {
+			return scheme, nil
+		}
+	}
+
+	return nil, ErrUnknownAlgorithm
+}
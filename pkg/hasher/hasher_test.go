@@ -0,0 +1,94 @@
+package hasher
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func testArgon2id() *Argon2id {
+	return NewArgon2id(Argon2idParams{Memory: 1024, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+}
+
+func testScrypt() *Scrypt {
+	return NewScrypt(ScryptParams{N: 1024, R: 8, P: 1, SaltLength: 16, KeyLength: 32})
+}
+
+func TestHasher_HashAndVerify(t *testing.T) {
+	for _, scheme := range []Scheme{testArgon2id(), testScrypt(), NewBcrypt(4)} {
+		t.Run(scheme.Algorithm(), func(t *testing.T) {
+			h, err := New(scheme.Algorithm(), testArgon2id(), testScrypt(), NewBcrypt(4))
+			assert.NoError(t, err)
+
+			encodedHash, err := h.Hash("correct horse")
+			assert.NoError(t, err)
+
+			ok, err := h.Verify("correct horse", encodedHash)
+			assert.NoError(t, err)
+			assert.True(t, ok)
+
+			ok, err = h.Verify("wrong horse", encodedHash)
+			assert.NoError(t, err)
+			assert.False(t, ok)
+
+			assert.False(t, h.NeedsRehash(encodedHash))
+		})
+	}
+}
+
+func TestHasher_PHCFormat(t *testing.T) {
+	encodedHash, err := testArgon2id().Hash("password")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encodedHash, "$argon2id$v=19$m=1024,t=2,p=1$"))
+
+	encodedHash, err = testScrypt().Hash("password")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encodedHash, "$scrypt$ln=10,r=8,p=1$"))
+}
+
+func TestHasher_NeedsRehash(t *testing.T) {
+	h, err := New(argon2idID, testArgon2id(), NewBcrypt(4))
+	assert.NoError(t, err)
+
+	weakArgon2id, err := NewArgon2id(Argon2idParams{Memory: 512, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}).Hash("password")
+	assert.NoError(t, err)
+	assert.True(t, h.NeedsRehash(weakArgon2id))
+
+	ok, err := h.Verify("password", weakArgon2id)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// imported users are moved to the default algorithm
+	importedBcrypt, err := NewBcrypt(4).Hash("password")
+	assert.NoError(t, err)
+	assert.True(t, h.NeedsRehash(importedBcrypt))
+}
+
+func TestHasher_UnknownAlgorithm(t *testing.T) {
+	_, err := New("md5", testArgon2id())
+	assert.ErrorIs(t, err, ErrUnknownAlgorithm)
+
+	h, err := New(argon2idID, testArgon2id())
+	assert.NoError(t, err)
+
+	_, err = h.Verify("password", "$md5$abc")
+	assert.ErrorIs(t, err, ErrUnknownAlgorithm)
+}
+
+func TestBcrypt_PasswordTooLong(t *testing.T) {
+	h, err := New(bcryptID, NewBcrypt(4), testArgon2id())
+	assert.NoError(t, err)
+	assert.Equal(t, 72, h.MaxPasswordBytes())
+
+	// 37 characters, 73 bytes
+	_, err = h.Hash(strings.Repeat("ж", 36) + "!")
+	assert.ErrorIs(t, err, ErrPasswordTooLong)
+
+	ok, err := h.Verify(strings.Repeat("ж", 37), "$2a$04$abcdefghijklmnopqrstuuJ8gE4Do8QaO7mnqTt7sJhSyCVEqQUrG")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	h, err = New(argon2idID, testArgon2id(), NewBcrypt(4))
+	assert.NoError(t, err)
+	assert.Zero(t, h.MaxPasswordBytes())
+}
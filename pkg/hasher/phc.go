@@ -0,0 +1,91 @@
+package hasher
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// phcHash is a hash in the PHC string format: $<id>[$v=<version>][$<param>=<value>(,<param>=<value>)*][$<salt>[$<hash>]]
+type phcHash struct {
+	id      string
+	version int
+	params  map[string]int
+	salt    []byte
+	hash    []byte
+}
+
+var phcEncoding = base64.RawStdEncoding
+
+func (p phcHash) String() string {
+	var b strings.Builder
+	b.WriteString("$" + p.id)
+	if p.version != 0 {
+		b.WriteString(fmt.Sprintf("$v=%d", p.version))
+	}
+
+	keys := phcParamOrder[p.id]
+	params := make([]string, 0, len(keys))
+	for _, key := range keys {
+		params = append(params, fmt.Sprintf("%s=%d", key, p.params[key]))
+	}
+	b.WriteString("$" + strings.Join(params, ","))
+	b.WriteString("$" + phcEncoding.EncodeToString(p.salt))
+	b.WriteString("$" + phcEncoding.EncodeToString(p.hash))
+
+	return b.String()
+}
+
+// phcParamOrder keeps the parameter order stable, as other implementations of the same ids expect it.
+var phcParamOrder = map[string][]string{
+	argon2idID: {"m", "t", "p"},
+	scryptID:   {"ln", "r", "p"},
+}
+
+func parsePHC(encodedHash string) (*phcHash, error) {
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) < 5 || parts[0] != "" {
+		return nil, ErrMalformedHash
+	}
+
+	p := &phcHash{id: parts[1], params: map[string]int{}}
+	parts = parts[2:]
+
+	if strings.HasPrefix(parts[0], "v=") {
+		version, err := strconv.Atoi(strings.TrimPrefix(parts[0], "v="))
+		if err != nil {
+			return nil, ErrMalformedHash
+		}
+		p.version = version
+		parts = parts[1:]
+	}
+
+	if len(parts) != 3 {
+		return nil, ErrMalformedHash
+	}
+
+	for _, param := range strings.Split(parts[0], ",") {
+		key, value, ok := strings.Cut(param, "=")
+		if !ok {
+			return nil, ErrMalformedHash
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, ErrMalformedHash
+		}
+		p.params[key] = n
+	}
+
+	var err error
+	p.salt, err = phcEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrMalformedHash
+	}
+	p.hash, err = phcEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformedHash
+	}
+
+	return p, nil
+}
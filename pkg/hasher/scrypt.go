@@ -0,0 +1,99 @@
+package hasher
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"golang.org/x/crypto/scrypt"
+	"math/bits"
+	"strings"
+)
+
+const scryptID = "scrypt"
+
+type ScryptParams struct {
+	N          int // CPU/memory cost, must be a power of two
+	R          int
+	P          int
+	SaltLength int
+	KeyLength  int
+}
+
+type Scrypt struct {
+	params ScryptParams
+}
+
+func NewScrypt(params ScryptParams) *Scrypt {
+	return &Scrypt{params: params}
+}
+
+func (s *Scrypt) Algorithm() string {
+	return scryptID
+}
+
+func (s *Scrypt) Hash(password string) (string, error) {
+	salt := make([]byte, s.params.SaltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := scrypt.Key([]byte(password), salt, s.params.N, s.params.R, s.params.P, s.params.KeyLength)
+	if err != nil {
+		return "", err
+	}
+
+	return phcHash{
+		id: scryptID,
+		params: map[string]int{
+			"ln": bits.TrailingZeros(uint(s.params.N)),
+			"r":  s.params.R,
+			"p":  s.params.P,
+		},
+		salt: salt,
+		hash: hash,
+	}.String(), nil
+}
+
+func (s *Scrypt) Verify(password, encodedHash string) (bool, error) {
+	p, err := s.parse(encodedHash)
+	if err != nil {
+		return false, err
+	}
+
+	hash, err := scrypt.Key([]byte(password), p.salt, 1<<p.params["ln"], p.params["r"], p.params["p"], len(p.hash))
+	if err != nil {
+		return false, err
+	}
+
+	return subtle.ConstantTimeCompare(hash, p.hash) == 1, nil
+}
+
+func (s *Scrypt) Identifies(encodedHash string) bool {
+	return strings.HasPrefix(encodedHash, "$"+scryptID+"$")
+}
+
+func (s *Scrypt) Weaker(encodedHash string) (bool, error) {
+	p, err := s.parse(encodedHash)
+	if err != nil {
+		return false, err
+	}
+
+	return 1<<p.params["ln"] < s.params.N ||
+		p.params["r"] < s.params.R ||
+		p.params["p"] < s.params.P ||
+		len(p.salt) < s.params.SaltLength ||
+		len(p.hash) < s.params.KeyLength, nil
+}
+
+func (s *Scrypt) parse(encodedHash string) (*phcHash, error) {
+	p, err := parsePHC(encodedHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.id != scryptID || p.params["ln"] <= 0 || p.params["ln"] > 30 || p.params["r"] <= 0 || p.params["p"] <= 0 || len(p.hash) == 0 {
+		return nil, ErrMalformedHash
+	}
+
+	return p, nil
+}
@@ -0,0 +1,83 @@
+// Package iplist matches IP addresses against a list of addresses and CIDR ranges, such as Tor
+// exit nodes or known-bad hosts, loaded from a local file.
+package iplist
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+type List struct {
+	addresses map[netip.Addr]struct{}
+	prefixes  []netip.Prefix
+}
+
+// Load reads a list with an address or a CIDR range per line. Empty lines and everything after
+// a "#" are ignored.
+func Load(path string) (*List, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return Parse(file)
+}
+
+func Parse(r io.Reader) (*List, error) {
+	list := &List{addresses: map[netip.Addr]struct{}{}}
+
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if strings.Contains(line, "/") {
+			prefix, err := netip.ParsePrefix(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+			list.prefixes = append(list.prefixes, prefix.Masked())
+			continue
+		}
+
+		address, err := netip.ParseAddr(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		list.addresses[address.Unmap()] = struct{}{}
+	}
+
+	return list, scanner.Err()
+}
+
+// Contains reports whether ip is listed. Invalid addresses are never listed.
+func (l *List) Contains(ip string) bool {
+	address, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	address = address.Unmap()
+
+	if _, ok := l.addresses[address]; ok {
+		return true
+	}
+	for _, prefix := range l.prefixes {
+		if prefix.Contains(address) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (l *List) Len() int {
+	return len(l.addresses) + len(l.prefixes)
+}
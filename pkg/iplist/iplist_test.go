@@ -0,0 +1,32 @@
+package iplist
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestList_Contains(t *testing.T) {
+	list, err := Parse(strings.NewReader(`
+# tor exit nodes
+203.0.113.7
+198.51.100.0/24 # hosting provider
+2001:db8::/32
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, list.Len())
+
+	assert.True(t, list.Contains("203.0.113.7"))
+	assert.True(t, list.Contains("::ffff:203.0.113.7"))
+	assert.True(t, list.Contains("198.51.100.200"))
+	assert.True(t, list.Contains("2001:db8:1::1"))
+
+	assert.False(t, list.Contains("203.0.113.8"))
+	assert.False(t, list.Contains("2001:db9::1"))
+	assert.False(t, list.Contains("not-an-ip"))
+}
+
+func TestParse_InvalidLine(t *testing.T) {
+	_, err := Parse(strings.NewReader("203.0.113.7\n203.0.113.300\n"))
+	assert.ErrorContains(t, err, "line 2")
+}
@@ -0,0 +1,130 @@
+package passwordpolicy
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+var bloomMagic = [4]byte{'B', 'L', 'M', '1'}
+
+var ErrInvalidBloomFilter = errors.New("invalid bloom filter file")
+
+// BloomFilter is a breached password list keyed by the SHA-1 of the password,
+// the same digest the public breach corpora are distributed with.
+type BloomFilter struct {
+	bits   []uint64
+	m      uint64
+	hashes uint32
+}
+
+// NewBloomFilter sizes a filter for n entries with the given false positive rate.
+func NewBloomFilter(n uint64, falsePositiveRate float64) *BloomFilter {
+	if n == 0 {
+		n = 1
+	}
+
+	m := uint64(math.Ceil(-float64(n) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	hashes := uint32(math.Max(1, math.Round(float64(m)/float64(n)*math.Ln2)))
+
+	return &BloomFilter{
+		bits:   make([]uint64, (m+63)/64),
+		m:      m,
+		hashes: hashes,
+	}
+}
+
+func LoadBloomFilter(path string) (*BloomFilter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadBloomFilter(bufio.NewReader(file))
+}
+
+func ReadBloomFilter(r io.Reader) (*BloomFilter, error) {
+	var header struct {
+		Magic  [4]byte
+		M      uint64
+		Hashes uint32
+	}
+	err := binary.Read(r, binary.BigEndian, &header)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidBloomFilter, err)
+	}
+
+	if header.Magic != bloomMagic || header.M == 0 || header.Hashes == 0 {
+		return nil, ErrInvalidBloomFilter
+	}
+
+	f := &BloomFilter{
+		bits:   make([]uint64, (header.M+63)/64),
+		m:      header.M,
+		hashes: header.Hashes,
+	}
+	err = binary.Read(r, binary.BigEndian, f.bits)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidBloomFilter, err)
+	}
+
+	return f, nil
+}
+
+func (f *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	header := struct {
+		Magic  [4]byte
+		M      uint64
+		Hashes uint32
+	}{bloomMagic, f.m, f.hashes}
+
+	err := binary.Write(w, binary.BigEndian, header)
+	if err != nil {
+		return 0, err
+	}
+	err = binary.Write(w, binary.BigEndian, f.bits)
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(binary.Size(header) + binary.Size(f.bits)), nil
+}
+
+func (f *BloomFilter) AddSHA1(digest [sha1.Size]byte) {
+	for _, position := range f.positions(digest) {
+		f.bits[position/64] |= 1 << (position % 64)
+	}
+}
+
+func (f *BloomFilter) Add(password string) {
+	f.AddSHA1(sha1.Sum([]byte(password)))
+}
+
+func (f *BloomFilter) IsBreached(password string) bool {
+	for _, position := range f.positions(sha1.Sum([]byte(password))) {
+		if f.bits[position/64]&(1<<(position%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// positions derives the bit positions with double hashing from the already uniform SHA-1 digest.
+func (f *BloomFilter) positions(digest [sha1.Size]byte) []uint64 {
+	h1 := binary.BigEndian.Uint64(digest[0:8])
+	h2 := binary.BigEndian.Uint64(digest[8:16])
+
+	positions := make([]uint64, f.hashes)
+	for i := range positions {
+		positions[i] = (h1 + uint64(i)*h2) % f.m
+	}
+
+	return positions
+}
@@ -0,0 +1,172 @@
+package passwordpolicy
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	CodeTooShort          = "too_short"
+	CodeTooLong           = "too_long"
+	CodeCharacterClasses  = "character_classes"
+	CodeRepeatedCharacter = "repeated_characters"
+	CodeBannedWord        = "banned_word"
+	CodeBreached          = "breached"
+)
+
+// words shorter than this are too common inside unrelated passwords to be banned
+const minBannedWordLength = 3
+
+type Violation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type ViolationError struct {
+	Violations []Violation
+}
+
+func (e *ViolationError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, violation := range e.Violations {
+		messages = append(messages, violation.Message)
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// BreachedList answers whether a password is known from public breaches.
+type BreachedList interface {
+	IsBreached(password string) bool
+}
+
+type Config struct {
+	MinLength int
+	MaxLength int
+	// MaxBytes limits the encoded length for hash algorithms that count bytes, such as bcrypt.
+	MaxBytes            int
+	MinCharacterClasses int
+	MaxRepeatedChars    int
+	BannedWords         []string
+}
+
+type Policy struct {
+	config   Config
+	breached BreachedList
+}
+
+// New creates a policy. breached may be nil when no breached password list is configured.
+func New(config Config, breached BreachedList) *Policy {
+	return &Policy{
+		config:   config,
+		breached: breached,
+	}
+}
+
+// Validate checks password against the policy. userWords are banned in addition to the configured
+// words, e.g. the local part of the user's email. A *ViolationError lists every failed rule.
+func (p *Policy) Validate(password string, userWords ...string) error {
+	var violations []Violation
+
+	length := utf8.RuneCountInString(password)
+	if p.config.MinLength > 0 && length < p.config.MinLength {
+		violations = append(violations, Violation{
+			Code:    CodeTooShort,
+			Message: fmt.Sprintf("password must be at least %d characters long", p.config.MinLength),
+		})
+	}
+	if p.config.MaxLength > 0 && length > p.config.MaxLength {
+		violations = append(violations, Violation{
+			Code:    CodeTooLong,
+			Message: fmt.Sprintf("password must be at most %d characters long", p.config.MaxLength),
+		})
+	} else if p.config.MaxBytes > 0 && len(password) > p.config.MaxBytes {
+		violations = append(violations, Violation{
+			Code:    CodeTooLong,
+			Message: fmt.Sprintf("password must be at most %d bytes long, characters outside ASCII take several", p.config.MaxBytes),
+		})
+	}
+
+	if p.config.MinCharacterClasses > 0 && characterClasses(password) < p.config.MinCharacterClasses {
+		violations = append(violations, Violation{
+			Code: CodeCharacterClasses,
+			Message: fmt.Sprintf("password must contain at least %d of: lowercase letters, uppercase letters, digits, symbols",
+				p.config.MinCharacterClasses),
+		})
+	}
+
+	if p.config.MaxRepeatedChars > 0 && longestRun(password) > p.config.MaxRepeatedChars {
+		violations = append(violations, Violation{
+			Code:    CodeRepeatedCharacter,
+			Message: fmt.Sprintf("password must not repeat the same character more than %d times in a row", p.config.MaxRepeatedChars),
+		})
+	}
+
+	lowered := strings.ToLower(password)
+	for _, word := range append(append([]string{}, p.config.BannedWords...), userWords...) {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if utf8.RuneCountInString(word) < minBannedWordLength {
+			continue
+		}
+
+		if strings.Contains(lowered, word) {
+			violations = append(violations, Violation{
+				Code:    CodeBannedWord,
+				Message: "password must not contain your email, the service name or other easily guessed words",
+			})
+			break
+		}
+	}
+
+	if p.breached != nil && p.breached.IsBreached(password) {
+		violations = append(violations, Violation{
+			Code:    CodeBreached,
+			Message: "password has appeared in a data breach, please choose another one",
+		})
+	}
+
+	if len(violations) > 0 {
+		return &ViolationError{Violations: violations}
+	}
+
+	return nil
+}
+
+func characterClasses(password string) int {
+	var lower, upper, digit, symbol int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+
+	return lower + upper + digit + symbol
+}
+
+func longestRun(password string) int {
+	var longest, current int
+	var previous rune
+	for i, r := range []rune(password) {
+		if i > 0 && r == previous {
+			current++
+		} else {
+			current = 1
+		}
+		previous = r
+
+		if current > longest {
+			longest = current
+		}
+	}
+
+	return longest
+}
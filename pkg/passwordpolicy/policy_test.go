@@ -0,0 +1,77 @@
+package passwordpolicy
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func violationCodes(err error) []string {
+	var codes []string
+	if violationErr, ok := err.(*ViolationError); ok {
+		for _, violation := range violationErr.Violations {
+			codes = append(codes, violation.Code)
+		}
+	}
+	return codes
+}
+
+func TestPolicy_Validate(t *testing.T) {
+	breached := NewBloomFilter(10, 0.001)
+	breached.Add("Password123!")
+
+	policy := New(Config{
+		MinLength:           10,
+		MaxLength:           72,
+		MaxBytes:            72,
+		MinCharacterClasses: 3,
+		MaxRepeatedChars:    3,
+		BannedWords:         []string{"medods"},
+	}, breached)
+
+	tests := []struct {
+		name     string
+		password string
+		words    []string
+		codes    []string
+	}{
+		{"valid", "correct-Horse-7", nil, nil},
+		{"too short and simple", "abc", nil, []string{CodeTooShort, CodeCharacterClasses}},
+		{"repeated characters", "Baaaad-password-1", nil, []string{CodeRepeatedCharacter}},
+		{"service name", "MyMedods-2024", nil, []string{CodeBannedWord}},
+		{"email local part", "JohnSmith-2024", []string{"johnsmith"}, []string{CodeBannedWord}},
+		{"short user words are ignored", "correct-Horse-7", []string{"co"}, nil},
+		{"breached", "Password123!", nil, []string{CodeBreached}},
+		{"too many bytes", "Пароль-надёжный-" + strings.Repeat("жук-", 8), nil, []string{CodeTooLong}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.password, tt.words...)
+			if tt.codes == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.Equal(t, tt.codes, violationCodes(err))
+		})
+	}
+}
+
+func TestBloomFilter_RoundTrip(t *testing.T) {
+	filter := NewBloomFilter(100, 0.001)
+	filter.Add("hunter2")
+
+	var buf bytes.Buffer
+	_, err := filter.WriteTo(&buf)
+	assert.NoError(t, err)
+
+	loaded, err := ReadBloomFilter(&buf)
+	assert.NoError(t, err)
+	assert.True(t, loaded.IsBreached("hunter2"))
+	assert.False(t, loaded.IsBreached("correct-Horse-7"))
+
+	_, err = ReadBloomFilter(bytes.NewReader([]byte("not a filter")))
+	assert.ErrorIs(t, err, ErrInvalidBloomFilter)
+}
@@ -0,0 +1,246 @@
+// Package relation parses the namespace configuration of relation tuples: which relations objects
+// of each namespace have and how relations are computed from each other.
+//
+// A configuration declares namespaces with their relations:
+//
+//	namespace user {}
+//
+//	namespace folder {
+//	    relation owner
+//	    relation viewer = owner
+//	}
+//
+//	namespace document {
+//	    relation parent
+//	    relation owner
+//	    relation editor = owner
+//	    relation viewer = editor | parent->viewer
+//	}
+//
+// Every relation holds the subjects of its own tuples. The usersets after "=" add to them: "editor"
+// adds the editors of the same object, "parent->viewer" adds the viewers of every object the parent
+// tuples of the object point to. Everything after a "#" is a comment.
+package relation
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var namePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+type Schema struct {
+	namespaces map[string]*Namespace
+}
+
+type Namespace struct {
+	Name      string
+	Relations map[string]*Relation
+}
+
+type Relation struct {
+	Name string
+	// Rewrite lists the usersets the relation includes besides its own tuples.
+	Rewrite []Userset
+}
+
+// Userset is a computed userset, the Relation of the same object, when Tupleset is empty. Otherwise it
+// is the Relation of each object the Tupleset tuples of the object point to.
+type Userset struct {
+	Tupleset string
+	Relation string
+}
+
+func (u Userset) String() string {
+	if u.Tupleset == "" {
+		return u.Relation
+	}
+
+	return u.Tupleset + "->" + u.Relation
+}
+
+// Load reads the namespace configuration at path.
+func Load(path string) (*Schema, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return Parse(file)
+}
+
+func Parse(r io.Reader) (*Schema, error) {
+	schema := &Schema{namespaces: map[string]*Namespace{}}
+
+	var current *Namespace
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		var err error
+		switch {
+		case fields[0] == "namespace":
+			if current != nil {
+				return nil, fmt.Errorf("line %d: namespace %q is not closed", lineNumber, current.Name)
+			}
+			current, err = schema.parseNamespace(fields)
+		case fields[0] == "relation":
+			if current == nil {
+				return nil, fmt.Errorf("line %d: relation outside of a namespace", lineNumber)
+			}
+			err = current.parseRelation(strings.Join(fields[1:], " "))
+		case len(fields) == 1 && fields[0] == "}":
+			if current == nil {
+				return nil, fmt.Errorf("line %d: unexpected }", lineNumber)
+			}
+			current = nil
+		default:
+			err = fmt.Errorf("unexpected %q", fields[0])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if current != nil {
+		return nil, fmt.Errorf("namespace %q is not closed", current.Name)
+	}
+
+	return schema, schema.validate()
+}
+
+// parseNamespace opens a namespace, it is closed on the same line by "namespace user {}".
+func (s *Schema) parseNamespace(fields []string) (*Namespace, error) {
+	if len(fields) < 3 || len(fields) > 4 || fields[2] != "{" && fields[2] != "{}" {
+		return nil, fmt.Errorf("expected namespace <name> {")
+	}
+
+	name := fields[1]
+	if !namePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid namespace name %q", name)
+	}
+	if _, ok := s.namespaces[name]; ok {
+		return nil, fmt.Errorf("namespace %q is declared twice", name)
+	}
+
+	namespace := &Namespace{Name: name, Relations: map[string]*Relation{}}
+	s.namespaces[name] = namespace
+
+	closed := fields[2] == "{}" || len(fields) == 4 && fields[3] == "}"
+	if len(fields) == 4 && !closed {
+		return nil, fmt.Errorf("unexpected %q", fields[3])
+	}
+	if closed {
+		return nil, nil
+	}
+
+	return namespace, nil
+}
+
+func (n *Namespace) parseRelation(definition string) error {
+	name, rewrite, hasRewrite := strings.Cut(definition, "=")
+	name = strings.TrimSpace(name)
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid relation name %q", name)
+	}
+	if _, ok := n.Relations[name]; ok {
+		return fmt.Errorf("relation %q is declared twice in namespace %q", name, n.Name)
+	}
+
+	relation := &Relation{Name: name}
+	if hasRewrite {
+		for _, item := range strings.Split(rewrite, "|") {
+			item = strings.TrimSpace(item)
+			tupleset, computed, isTupleToUserset := strings.Cut(item, "->")
+
+			userset := Userset{Relation: item}
+			if isTupleToUserset {
+				userset = Userset{Tupleset: strings.TrimSpace(tupleset), Relation: strings.TrimSpace(computed)}
+				if !namePattern.MatchString(userset.Tupleset) {
+					return fmt.Errorf("invalid userset %q", item)
+				}
+			}
+			if !namePattern.MatchString(userset.Relation) {
+				return fmt.Errorf("invalid userset %q", item)
+			}
+
+			relation.Rewrite = append(relation.Rewrite, userset)
+		}
+	}
+
+	n.Relations[name] = relation
+	return nil
+}
+
+// validate checks that usersets refer to relations that exist. The relations reached through a
+// tupleset must exist in at least one namespace, tuples pointing elsewhere simply add nobody.
+func (s *Schema) validate() error {
+	for _, namespace := range s.namespaces {
+		for _, relation := range namespace.Relations {
+			for _, userset := range relation.Rewrite {
+				local := userset.Relation
+				if userset.Tupleset != "" {
+					local = userset.Tupleset
+					if !s.hasRelation(userset.Relation) {
+						return fmt.Errorf("%s#%s: no namespace has relation %q", namespace.Name, relation.Name, userset.Relation)
+					}
+				}
+
+				if _, ok := namespace.Relations[local]; !ok {
+					return fmt.Errorf("%s#%s: unknown relation %q", namespace.Name, relation.Name, local)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *Schema) hasRelation(name string) bool {
+	for _, namespace := range s.namespaces {
+		if _, ok := namespace.Relations[name]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Schema) Namespace(name string) (*Namespace, bool) {
+	namespace, ok := s.namespaces[name]
+	return namespace, ok
+}
+
+// Relation returns the relation of a namespace, ok is false when either is not declared.
+func (s *Schema) Relation(namespace, relation string) (*Relation, bool) {
+	ns, ok := s.namespaces[namespace]
+	if !ok {
+		return nil, false
+	}
+
+	rel, ok := ns.Relations[relation]
+	return rel, ok
+}
+
+// Namespaces returns the declared namespace names, sorted.
+func (s *Schema) Namespaces() []string {
+	names := make([]string, 0, len(s.namespaces))
+	for name := range s.namespaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,82 @@
+package relation
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+const testSchema = `
+# documents in folders
+namespace user {}
+
+namespace group {
+    relation member
+}
+
+namespace folder {
+    relation owner
+    relation viewer = owner
+}
+
+namespace document {
+    relation parent
+    relation owner
+    relation editor = owner # owners edit
+    relation viewer = editor | parent->viewer
+}
+`
+
+func TestParse(t *testing.T) {
+	schema, err := Parse(strings.NewReader(testSchema))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"document", "folder", "group", "user"}, schema.Namespaces())
+
+	user, ok := schema.Namespace("user")
+	assert.True(t, ok)
+	assert.Empty(t, user.Relations)
+
+	viewer, ok := schema.Relation("document", "viewer")
+	require.True(t, ok)
+	assert.Equal(t, []Userset{{Relation: "editor"}, {Tupleset: "parent", Relation: "viewer"}}, viewer.Rewrite)
+	assert.Equal(t, "parent->viewer", viewer.Rewrite[1].String())
+
+	owner, ok := schema.Relation("document", "owner")
+	require.True(t, ok)
+	assert.Empty(t, owner.Rewrite)
+
+	_, ok = schema.Relation("document", "commenter")
+	assert.False(t, ok)
+	_, ok = schema.Relation("spreadsheet", "viewer")
+	assert.False(t, ok)
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		schema string
+		err    string
+	}{
+		{"unclosed namespace", "namespace document {\n relation owner\n", `namespace "document" is not closed`},
+		{"nested namespace", "namespace a {\nnamespace b {}\n}", `line 2: namespace "a" is not closed`},
+		{"relation outside namespace", "relation owner", "line 1: relation outside of a namespace"},
+		{"unknown computed relation", "namespace document {\n relation viewer = editor\n}", `document#viewer: unknown relation "editor"`},
+		{"unknown tupleset", "namespace document {\n relation viewer = parent->viewer\n}", `document#viewer: unknown relation "parent"`},
+		{"relation nowhere", "namespace document {\n relation parent\n relation viewer = parent->reader\n}", `no namespace has relation "reader"`},
+		{"duplicate relation", "namespace document {\n relation owner\n relation owner\n}", `line 3: relation "owner" is declared twice`},
+		{"duplicate namespace", "namespace user {}\nnamespace user {}", `line 2: namespace "user" is declared twice`},
+		{"invalid name", "namespace Document {}", `line 1: invalid namespace name "Document"`},
+		{"invalid userset", "namespace document {\n relation owner\n relation viewer = owner |\n}", `line 3: invalid userset ""`},
+		{"stray brace", "}", "line 1: unexpected }"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tc.schema))
+
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.err)
+			}
+		})
+	}
+}
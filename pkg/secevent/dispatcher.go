@@ -0,0 +1,123 @@
+package secevent
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Dispatcher fans events out to sinks. Every sink has its own bounded queue and goroutine,
+// so one slow sink neither blocks Publish nor delays the others; when its queue is full
+// the event is dropped for that sink and counted.
+type Dispatcher struct {
+	queueSize int
+	sinks     []*queuedSink
+	onError   func(sink string, err error)
+	wg        sync.WaitGroup
+
+	// mu guards closed, so Publish never sends to a closed queue
+	mu     sync.RWMutex
+	closed bool
+}
+
+type queuedSink struct {
+	name    string
+	sink    Sink
+	queue   chan Event
+	dropped atomic.Int64
+}
+
+// NewDispatcher creates a dispatcher whose sinks buffer up to queueSize events each.
+// onError is called from the sink goroutines for failed writes and dropped events, it may be nil.
+func NewDispatcher(queueSize int, onError func(sink string, err error)) *Dispatcher {
+	if onError == nil {
+		onError = func(string, error) {}
+	}
+
+	return &Dispatcher{queueSize: queueSize, onError: onError}
+}
+
+// AddSink registers a sink under a name used in error reports and starts its goroutine.
+// It must be called before Publish.
+func (d *Dispatcher) AddSink(name string, sink Sink) {
+	s := &queuedSink{
+		name:  name,
+		sink:  sink,
+		queue: make(chan Event, d.queueSize),
+	}
+	d.sinks = append(d.sinks, s)
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		for event := range s.queue {
+			if err := s.sink.Write(event); err != nil {
+				d.onError(s.name, err)
+			}
+		}
+	}()
+}
+
+// Publish never blocks. Events published after Close are discarded.
+func (d *Dispatcher) Publish(event Event) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return
+	}
+
+	for _, s := range d.sinks {
+		select {
+		case s.queue <- event:
+		default:
+			if dropped := s.dropped.Add(1); dropped == 1 || dropped%1000 == 0 {
+				d.onError(s.name, fmt.Errorf("queue is full, %d events dropped so far", dropped))
+			}
+		}
+	}
+}
+
+// Dropped returns how many events each sink has lost to a full queue.
+func (d *Dispatcher) Dropped() map[string]int64 {
+	dropped := make(map[string]int64, len(d.sinks))
+	for _, s := range d.sinks {
+		dropped[s.name] = s.dropped.Load()
+	}
+
+	return dropped
+}
+
+// Close stops accepting events and waits until the queues are drained or ctx is done.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return nil
+	}
+	d.closed = true
+	for _, s := range d.sinks {
+		close(s.queue)
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	for _, s := range d.sinks {
+		if err := s.sink.Close(); err != nil {
+			d.onError(s.name, err)
+		}
+	}
+
+	return nil
+}
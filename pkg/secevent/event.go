@@ -0,0 +1,42 @@
+// Package secevent ships security events to SIEM systems: RFC 5424 syslog over UDP, TCP or TLS,
+// ArcSight CEF and rotated JSON Lines files. Sinks are fed through bounded queues,
+// so a slow or unreachable sink drops events instead of blocking the caller.
+package secevent
+
+import "time"
+
+// Event is a security event in a sink-neutral shape.
+type Event struct {
+	Time time.Time
+	// Type is a stable machine-readable identifier, e.g. "login_failed".
+	Type string
+	// Name is a short human-readable description.
+	Name string
+	// Severity follows CEF: 0 is the lowest, 10 the highest.
+	Severity  int
+	Actor     string
+	Subject   string
+	IP        string
+	UserAgent string
+	Fields    map[string]string
+}
+
+// Sink delivers events to one destination. Write is only called from the sink's own queue goroutine.
+type Sink interface {
+	Write(event Event) error
+	Close() error
+}
+
+// syslogSeverity maps the CEF severity scale to RFC 5424 severities.
+func syslogSeverity(severity int) int {
+	switch {
+	case severity >= 8:
+		return 2 // critical
+	case severity >= 6:
+		return 4 // warning
+	case severity >= 4:
+		return 5 // notice
+	default:
+		return 6 // informational
+	}
+}
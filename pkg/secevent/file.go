@@ -0,0 +1,109 @@
+package secevent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+type FileConfig struct {
+	Path string
+	// MaxSize in bytes starts a new file once exceeded, 0 disables rotation.
+	MaxSize int64
+	// MaxBackups is how many rotated files (path.1 is the newest) are kept.
+	MaxBackups int
+	// Format renders each line, JSON Lines by default.
+	Format Formatter
+}
+
+// FileSink appends one event per line and rotates the file by size.
+type FileSink struct {
+	config FileConfig
+	file   *os.File
+	size   int64
+}
+
+func NewFileSink(config FileConfig) (*FileSink, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("file path is required")
+	}
+	if config.Format == nil {
+		config.Format = JSONFormatter{}
+	}
+
+	s := &FileSink{config: config}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *FileSink) Write(event Event) error {
+	line, err := s.config.Format.Format(event)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if s.config.MaxSize > 0 && s.size > 0 && s.size+int64(len(line)) > s.config.MaxSize {
+		if err := s.rotate(); err != nil {
+			return fmt.Errorf("error while rotating %s: %w", s.config.Path, err)
+		}
+	}
+
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+
+	return err
+}
+
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+func (s *FileSink) open() error {
+	if err := os.MkdirAll(filepath.Dir(s.config.Path), os.ModePerm); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(s.config.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	s.file = file
+	s.size = info.Size()
+
+	return nil
+}
+
+// rotate shifts path.N-1 to path.N down to path to path.1 and starts an empty file.
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+
+	if s.config.MaxBackups > 0 {
+		_ = os.Remove(s.backupPath(s.config.MaxBackups))
+		for i := s.config.MaxBackups - 1; i >= 1; i-- {
+			_ = os.Rename(s.backupPath(i), s.backupPath(i+1))
+		}
+		if err := os.Rename(s.config.Path, s.backupPath(1)); err != nil {
+			return err
+		}
+	} else if err := os.Truncate(s.config.Path, 0); err != nil {
+		return err
+	}
+
+	return s.open()
+}
+
+func (s *FileSink) backupPath(n int) string {
+	return fmt.Sprintf("%s.%d", s.config.Path, n)
+}
@@ -0,0 +1,120 @@
+package secevent
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formatter renders an event as a single line without the trailing newline.
+type Formatter interface {
+	Format(event Event) ([]byte, error)
+}
+
+type JSONFormatter struct{}
+
+type jsonEvent struct {
+	Time      string            `json:"time"`
+	Type      string            `json:"type"`
+	Name      string            `json:"name,omitempty"`
+	Severity  int               `json:"severity"`
+	Actor     string            `json:"actor,omitempty"`
+	Subject   string            `json:"subject,omitempty"`
+	IP        string            `json:"ip,omitempty"`
+	UserAgent string            `json:"user_agent,omitempty"`
+	Fields    map[string]string `json:"fields,omitempty"`
+}
+
+func (JSONFormatter) Format(event Event) ([]byte, error) {
+	return json.Marshal(jsonEvent{
+		Time:      event.Time.UTC().Format(time.RFC3339Nano),
+		Type:      event.Type,
+		Name:      event.Name,
+		Severity:  event.Severity,
+		Actor:     event.Actor,
+		Subject:   event.Subject,
+		IP:        event.IP,
+		UserAgent: event.UserAgent,
+		Fields:    event.Fields,
+	})
+}
+
+// cefCustomStrings is the number of cs1..csN extension slots CEF defines.
+const cefCustomStrings = 6
+
+// CEFFormatter renders ArcSight Common Event Format version 0.
+type CEFFormatter struct {
+	Vendor  string
+	Product string
+	Version string
+}
+
+func (f CEFFormatter) Format(event Event) ([]byte, error) {
+	var b strings.Builder
+	b.WriteString("CEF:0|")
+	for _, header := range []string{f.Vendor, f.Product, f.Version, event.Type, eventName(event)} {
+		b.WriteString(escapeCEFHeader(header))
+		b.WriteByte('|')
+	}
+	b.WriteString(strconv.Itoa(min(max(event.Severity, 0), 10)))
+	b.WriteByte('|')
+
+	extension := []string{"rt=" + strconv.FormatInt(event.Time.UnixMilli(), 10)}
+	addExtension := func(key, value string) {
+		if value != "" {
+			extension = append(extension, key+"="+escapeCEFExtension(value))
+		}
+	}
+	addExtension("src", event.IP)
+	addExtension("suser", event.Actor)
+	addExtension("duser", event.Subject)
+	addExtension("requestClientApplication", event.UserAgent)
+
+	// fields go into the custom string slots, the ones that do not fit are appended to msg
+	var overflow []string
+	for i, key := range sortedKeys(event.Fields) {
+		if i < cefCustomStrings {
+			addExtension(fmt.Sprintf("cs%dLabel", i+1), key)
+			addExtension(fmt.Sprintf("cs%d", i+1), event.Fields[key])
+			continue
+		}
+		overflow = append(overflow, key+"="+event.Fields[key])
+	}
+	if len(overflow) > 0 {
+		addExtension("msg", strings.Join(overflow, " "))
+	}
+
+	b.WriteString(strings.Join(extension, " "))
+
+	return []byte(b.String()), nil
+}
+
+func escapeCEFHeader(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, "|", `\|`)
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+}
+
+func escapeCEFExtension(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "=", `\=`, "\r", `\r`, "\n", `\n`).Replace(value)
+}
+
+func eventName(event Event) string {
+	if event.Name != "" {
+		return event.Name
+	}
+	return event.Type
+}
+
+func sortedKeys(fields map[string]string) []string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
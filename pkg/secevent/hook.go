@@ -0,0 +1,60 @@
+package secevent
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+)
+
+// LogEventType is the type of events forwarded from a logrus logger.
+const LogEventType = "security_log"
+
+// Hook forwards entries of a logrus logger, so messages that are not audit events reach the sinks as well.
+type Hook struct {
+	dispatcher *Dispatcher
+	levels     []logrus.Level
+}
+
+// NewHook forwards entries of minLevel and more severe levels.
+func NewHook(dispatcher *Dispatcher, minLevel logrus.Level) *Hook {
+	var levels []logrus.Level
+	for _, level := range logrus.AllLevels {
+		if level <= minLevel {
+			levels = append(levels, level)
+		}
+	}
+
+	return &Hook{dispatcher: dispatcher, levels: levels}
+}
+
+func (h *Hook) Levels() []logrus.Level {
+	return h.levels
+}
+
+func (h *Hook) Fire(entry *logrus.Entry) error {
+	fields := make(map[string]string, len(entry.Data)+1)
+	for key, value := range entry.Data {
+		fields[key] = fmt.Sprint(value)
+	}
+	fields["level"] = entry.Level.String()
+
+	h.dispatcher.Publish(Event{
+		Time:     entry.Time,
+		Type:     LogEventType,
+		Name:     entry.Message,
+		Severity: logSeverity(entry.Level),
+		Fields:   fields,
+	})
+
+	return nil
+}
+
+func logSeverity(level logrus.Level) int {
+	switch {
+	case level <= logrus.ErrorLevel:
+		return 7
+	case level == logrus.WarnLevel:
+		return 5
+	default:
+		return 3
+	}
+}
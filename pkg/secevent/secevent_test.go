@@ -0,0 +1,129 @@
+package secevent
+
+import (
+	"bufio"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testEvent = Event{
+	Time:      time.Date(2024, 12, 28, 10, 0, 0, 123456000, time.UTC),
+	Type:      "login_failed",
+	Name:      "Login failed",
+	Severity:  5,
+	Actor:     "user-id",
+	Subject:   "user-id",
+	IP:        "203.0.113.7",
+	UserAgent: "curl/8.0",
+	Fields:    map[string]string{"email": "a=b|c@example.com"},
+}
+
+func TestCEFFormatter(t *testing.T) {
+	formatter := CEFFormatter{Vendor: "Medods", Product: "auth|service", Version: "1.0"}
+
+	line, err := formatter.Format(testEvent)
+
+	assert.NoError(t, err)
+	assert.Equal(t, `CEF:0|Medods|auth\|service|1.0|login_failed|Login failed|5|rt=1735380000123 src=203.0.113.7 suser=user-id duser=user-id `+
+		`requestClientApplication=curl/8.0 cs1Label=email cs1=a\=b|c@example.com`, string(line))
+}
+
+func TestSyslogSink_RFC5424OverTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString(']')
+		received <- line
+	}()
+
+	sink, err := NewSyslogSink(SyslogConfig{Network: "tcp", Address: listener.Addr().String(), Facility: FacilityAuthPriv, AppName: "auth"})
+	assert.NoError(t, err)
+	defer sink.Close()
+
+	assert.NoError(t, sink.Write(testEvent))
+
+	message := <-received
+	length, frame, _ := strings.Cut(message, " ")
+	assert.NotEmpty(t, length)
+	// authpriv (10) * 8 + notice (5)
+	assert.True(t, strings.HasPrefix(frame, "<85>1 2024-12-28T10:00:00.123456Z "), frame)
+	assert.Contains(t, frame, ` auth `)
+	assert.Contains(t, frame, ` login_failed [event@32473 severity="5" actor="user-id"`)
+	assert.Contains(t, frame, `email="a=b|c@example.com"`)
+}
+
+func TestFileSink_Rotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	sink, err := NewFileSink(FileConfig{Path: path, MaxSize: 300, MaxBackups: 2})
+	assert.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, sink.Write(testEvent))
+	}
+	assert.NoError(t, sink.Close())
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		content, err := os.ReadFile(name)
+		assert.NoError(t, err)
+		assert.Contains(t, string(content), `"type":"login_failed"`)
+	}
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err))
+}
+
+type blockingSink struct {
+	release chan struct{}
+	mu      sync.Mutex
+	written int
+}
+
+func (s *blockingSink) Write(Event) error {
+	<-s.release
+	s.mu.Lock()
+	s.written++
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *blockingSink) Close() error { return nil }
+
+func TestDispatcher_SlowSinkDoesNotBlock(t *testing.T) {
+	dispatcher := NewDispatcher(2, nil)
+	sink := &blockingSink{release: make(chan struct{})}
+	dispatcher.AddSink("slow", sink)
+
+	published := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			dispatcher.Publish(testEvent)
+		}
+		close(published)
+	}()
+
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("Publish blocked on a slow sink")
+	}
+
+	close(sink.release)
+	assert.NoError(t, dispatcher.Close(context.Background()))
+	// one event was being written and two were queued, the rest was dropped
+	assert.LessOrEqual(t, sink.written, 3)
+	assert.Equal(t, int64(10-sink.written), dispatcher.Dropped()["slow"])
+}
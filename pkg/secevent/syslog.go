@@ -0,0 +1,195 @@
+package secevent
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// FacilityAuthPriv is the syslog facility for security and authorization messages.
+	FacilityAuthPriv = 10
+
+	// structuredDataID uses the enterprise number reserved for documentation (RFC 5612).
+	structuredDataID   = "event@32473"
+	syslogDialTimeout  = 5 * time.Second
+	syslogWriteTimeout = 5 * time.Second
+)
+
+type SyslogConfig struct {
+	// Network is udp, tcp or tls.
+	Network  string
+	Address  string
+	Facility int
+	AppName  string
+	// TLS is used when Network is tls.
+	TLS *tls.Config
+	// Body renders the MSG part, for example as CEF. Without it the message is the event name
+	// and all event data goes into RFC 5424 structured data.
+	Body Formatter
+}
+
+// SyslogSink sends RFC 5424 messages, one datagram per message over UDP and
+// with octet-counting framing (RFC 6587, RFC 5425) over TCP and TLS.
+type SyslogSink struct {
+	config   SyslogConfig
+	hostname string
+	procID   string
+	conn     net.Conn
+}
+
+func NewSyslogSink(config SyslogConfig) (*SyslogSink, error) {
+	switch config.Network {
+	case "udp", "tcp", "tls":
+	default:
+		return nil, fmt.Errorf("unknown syslog network %q", config.Network)
+	}
+	if config.Address == "" {
+		return nil, fmt.Errorf("syslog address is required")
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	return &SyslogSink{
+		config:   config,
+		hostname: hostname,
+		procID:   strconv.Itoa(os.Getpid()),
+	}, nil
+}
+
+func (s *SyslogSink) Write(event Event) error {
+	message, err := s.format(event)
+	if err != nil {
+		return err
+	}
+	if s.config.Network != "udp" {
+		message = append([]byte(strconv.Itoa(len(message))+" "), message...)
+	}
+
+	// a broken stream connection is only noticed on write, so reconnect once
+	for attempt := 0; ; attempt++ {
+		err = s.send(message)
+		if err == nil || attempt > 0 {
+			return err
+		}
+		s.closeConn()
+	}
+}
+
+func (s *SyslogSink) Close() error {
+	return s.closeConn()
+}
+
+func (s *SyslogSink) send(message []byte) error {
+	if s.conn == nil {
+		conn, err := s.dial()
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+
+	_ = s.conn.SetWriteDeadline(time.Now().Add(syslogWriteTimeout))
+	_, err := s.conn.Write(message)
+
+	return err
+}
+
+func (s *SyslogSink) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: syslogDialTimeout}
+	if s.config.Network == "tls" {
+		return tls.DialWithDialer(dialer, "tcp", s.config.Address, s.config.TLS)
+	}
+
+	return dialer.Dial(s.config.Network, s.config.Address)
+}
+
+func (s *SyslogSink) closeConn() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+
+	return err
+}
+
+// format renders "<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG".
+func (s *SyslogSink) format(event Event) ([]byte, error) {
+	var b strings.Builder
+	priority := s.config.Facility*8 + syslogSeverity(event.Severity)
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %s %s ",
+		priority,
+		event.Time.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogHeaderField(s.hostname, 255),
+		syslogHeaderField(s.config.AppName, 48),
+		syslogHeaderField(s.procID, 128),
+		syslogHeaderField(event.Type, 32))
+
+	if s.config.Body != nil {
+		body, err := s.config.Body.Format(event)
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString("- ")
+		b.Write(body)
+
+		return []byte(b.String()), nil
+	}
+
+	b.WriteString("[" + structuredDataID)
+	writeParam := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, ` %s="%s"`, syslogParamName(name), escapeSyslogParam(value))
+		}
+	}
+	writeParam("severity", strconv.Itoa(event.Severity))
+	writeParam("actor", event.Actor)
+	writeParam("subject", event.Subject)
+	writeParam("ip", event.IP)
+	writeParam("user_agent", event.UserAgent)
+	for _, key := range sortedKeys(event.Fields) {
+		writeParam(key, event.Fields[key])
+	}
+	b.WriteString("] ")
+	b.WriteString(eventName(event))
+
+	return []byte(b.String()), nil
+}
+
+// syslogHeaderField keeps header fields within the printable US-ASCII range and length RFC 5424 allows.
+func syslogHeaderField(value string, maxLength int) string {
+	value = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, value)
+	if value == "" {
+		return "-"
+	}
+
+	return value[:min(len(value), maxLength)]
+}
+
+func syslogParamName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 || r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, name)
+
+	return name[:min(len(name), 32)]
+}
+
+func escapeSyslogParam(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "]", `\]`).Replace(value)
+}
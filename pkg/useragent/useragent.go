@@ -0,0 +1,108 @@
+// Package useragent recognizes the browser and operating system of a User-Agent header, well enough
+// to show users which device a session belongs to. Unknown agents keep empty fields.
+package useragent
+
+import (
+	"regexp"
+	"strings"
+)
+
+type Agent struct {
+	// Browser is the name and major version, such as "Firefox 121".
+	Browser string
+	// OS is the name and, where the header tells it, the version, such as "macOS 14.2".
+	OS string
+	// Mobile is set for phones and tablets.
+	Mobile bool
+}
+
+// String describes the agent as "Firefox 121 on macOS 14.2", it is empty when nothing was recognized.
+func (a Agent) String() string {
+	switch {
+	case a.Browser != "" && a.OS != "":
+		return a.Browser + " on " + a.OS
+	case a.Browser != "":
+		return a.Browser
+	}
+
+	return a.OS
+}
+
+type browserRule struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+// browserRules are tried in order: most browsers also claim to be Chrome and Safari, so the
+// specific ones come first.
+var browserRules = []browserRule{
+	{"Edge", regexp.MustCompile(`\bEdg(?:e|A|iOS)?/(\d+)`)},
+	{"Opera", regexp.MustCompile(`\b(?:OPR|Opera)/(\d+)`)},
+	{"Yandex Browser", regexp.MustCompile(`\bYaBrowser/(\d+)`)},
+	{"Samsung Internet", regexp.MustCompile(`\bSamsungBrowser/(\d+)`)},
+	{"Firefox", regexp.MustCompile(`\b(?:Firefox|FxiOS)/(\d+)`)},
+	{"Chrome", regexp.MustCompile(`\b(?:Chrome|CriOS)/(\d+)`)},
+	{"Safari", regexp.MustCompile(`\bVersion/(\d+)(?:\.\d+)*.*\bSafari/`)},
+	{"curl", regexp.MustCompile(`^curl/(\d+)`)},
+	{"Postman", regexp.MustCompile(`^PostmanRuntime/(\d+)`)},
+	{"Go HTTP client", regexp.MustCompile(`^Go-http-client/(\d+)`)},
+}
+
+var (
+	windowsPattern = regexp.MustCompile(`Windows NT (\d+\.\d+)`)
+	iosPattern     = regexp.MustCompile(`(?:iPhone|CPU) OS (\d+(?:_\d+)*)`)
+	macPattern     = regexp.MustCompile(`Mac OS X (\d+(?:[_.]\d+)*)`)
+	androidPattern = regexp.MustCompile(`Android (\d+(?:\.\d+)*)`)
+)
+
+// windowsVersions maps NT versions to product names, Windows 11 still reports NT 10.0.
+var windowsVersions = map[string]string{
+	"10.0": "10",
+	"6.3":  "8.1",
+	"6.2":  "8",
+	"6.1":  "7",
+}
+
+func Parse(header string) Agent {
+	var agent Agent
+
+	for _, rule := range browserRules {
+		if match := rule.pattern.FindStringSubmatch(header); match != nil {
+			agent.Browser = rule.name + " " + match[1]
+			break
+		}
+	}
+
+	switch {
+	case strings.Contains(header, "iPhone") || strings.Contains(header, "iPad"):
+		agent.OS = "iOS"
+		if match := iosPattern.FindStringSubmatch(header); match != nil {
+			agent.OS += " " + strings.ReplaceAll(match[1], "_", ".")
+		}
+		agent.Mobile = true
+	case strings.Contains(header, "Android"):
+		agent.OS = "Android"
+		if match := androidPattern.FindStringSubmatch(header); match != nil {
+			agent.OS += " " + match[1]
+		}
+		agent.Mobile = true
+	case strings.Contains(header, "Windows"):
+		agent.OS = "Windows"
+		if match := windowsPattern.FindStringSubmatch(header); match != nil {
+			if version, ok := windowsVersions[match[1]]; ok {
+				agent.OS += " " + version
+			}
+		}
+	case strings.Contains(header, "Mac OS X"):
+		agent.OS = "macOS"
+		if match := macPattern.FindStringSubmatch(header); match != nil {
+			agent.OS += " " + strings.ReplaceAll(match[1], "_", ".")
+		}
+	case strings.Contains(header, "CrOS"):
+		agent.OS = "ChromeOS"
+	case strings.Contains(header, "Linux"):
+		agent.OS = "Linux"
+	}
+
+	return agent
+}
@@ -0,0 +1,50 @@
+package useragent
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		header   string
+		expected Agent
+	}{
+		{
+			"Mozilla/5.0 (Macintosh; Intel Mac OS X 14_2) Gecko/20100101 Firefox/121.0",
+			Agent{Browser: "Firefox 121", OS: "macOS 14.2"},
+		},
+		{
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			Agent{Browser: "Chrome 120", OS: "Windows 10"},
+		},
+		{
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.2210.91",
+			Agent{Browser: "Edge 120", OS: "Windows 10"},
+		},
+		{
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_1_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1.2 Mobile/15E148 Safari/604.1",
+			Agent{Browser: "Safari 17", OS: "iOS 17.1.2", Mobile: true},
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.144 Mobile Safari/537.36",
+			Agent{Browser: "Chrome 120", OS: "Android 14", Mobile: true},
+		},
+		{
+			"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 YaBrowser/23.11.0.0 Safari/537.36",
+			Agent{Browser: "Yandex Browser 23", OS: "Linux"},
+		},
+		{"curl/8.4.0", Agent{Browser: "curl 8"}},
+		{"", Agent{}},
+	} {
+		t.Run(tc.expected.String(), func(t *testing.T) {
+			assert.Equal(t, tc.expected, Parse(tc.header))
+		})
+	}
+}
+
+func TestAgent_String(t *testing.T) {
+	assert.Equal(t, "Firefox 121 on macOS 14.2", Agent{Browser: "Firefox 121", OS: "macOS 14.2"}.String())
+	assert.Equal(t, "curl 8", Agent{Browser: "curl 8"}.String())
+	assert.Equal(t, "Linux", Agent{OS: "Linux"}.String())
+}